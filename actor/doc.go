@@ -0,0 +1,36 @@
+// Package actor runs trees of machines that exchange events.
+//
+// A Runtime hosts machines of the statecharts in a registry. A state may
+// invoke child machines: when the state is entered, the runtime starts a
+// machine of the invoked statechart, and when the state is exited, it stops
+// the child and its own children. When a child stops in a final
+// configuration, its parent receives the event "done.invoke.ID", where ID is
+// the ID of the invocation; when a step of a child fails, the child is
+// stopped and its parent receives "error.invoke.ID".
+//
+// Every machine has a mailbox and a goroutine of its own that takes the
+// events from the mailbox one at a time, so machines run concurrently while
+// each processes its events in order. Send and TrySend put an event in a
+// mailbox from any goroutine; Call also waits for the step processing it:
+//
+//	r := actor.NewRuntime(registry)
+//	if _, err := r.Spawn("order-1", "order", nil); err != nil {
+//		return err
+//	}
+//	step, err := r.Call(ctx, "order-1", "PAY")
+//
+// Mailboxes have a bounded capacity. When the mailbox of a machine is full,
+// Send waits for room until its context is done and TrySend fails with
+// ErrMailboxFull. Shutdown waits for the machines to process the events in
+// their mailboxes, until its context is done, and then stops them.
+//
+// Machines exchange events with actions of the form "send EVENT to TARGET".
+// TARGET is "parent" for the invoking machine, the ID of an invocation of the
+// sender for its child, or the ID of any machine of the runtime. Sent events
+// are put in the mailboxes of their targets after the step that sent them, in
+// the order they were sent. A machine never waits for room in the mailbox of
+// another: when the mailbox of the target is full, the event is dropped and
+// the sender receives "error.communication". Events sent to machines that
+// have stopped or no longer exist are dropped. Idle waits until all events,
+// including those the machines send each other, have been processed.
+package actor
@@ -0,0 +1,599 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ParentTarget is the target of events sent to the invoking machine.
+	ParentTarget = "parent"
+	// CommunicationErrorEvent is the event a machine receives when an event it
+	// sent is not delivered because the mailbox of the target is full.
+	CommunicationErrorEvent = "error.communication"
+	// DefaultMailboxSize is the default capacity of the mailbox of a machine.
+	DefaultMailboxSize = 64
+)
+
+var (
+	// ErrMachineNotFound is returned when addressing a machine the runtime does not host.
+	ErrMachineNotFound = errors.New("actor: machine not found")
+	// ErrMailboxFull is returned by TrySend when the mailbox of a machine is full.
+	ErrMailboxFull = errors.New("actor: mailbox full")
+	// ErrClosed is returned when using a runtime that is shutting down.
+	ErrClosed = errors.New("actor: runtime closed")
+)
+
+// DoneEvent returns the event a parent receives when the child of the given
+// invocation stops in a final configuration.
+func DoneEvent(invokeID string) string { return "done.invoke." + invokeID }
+
+// ErrorEvent returns the event a parent receives when the child of the given
+// invocation fails.
+func ErrorEvent(invokeID string) string { return "error.invoke." + invokeID }
+
+// Runtime hosts machines and the children they invoke. Every machine has a
+// mailbox and a goroutine that takes the events from its mailbox one at a
+// time. A Runtime is safe for concurrent use.
+type Runtime struct {
+	// Registry holds the statecharts machines are started from. Submachine
+	// states of the statecharts are inlined when a machine is started.
+	Registry *sc.StatechartRegistry
+	// Engine executes the machines. If nil, semantics.NewEngine is used. Its
+	// hooks are called from the goroutines of the machines.
+	Engine *semantics.Engine
+	// MailboxSize is the capacity of the mailbox of each machine. If not positive,
+	// DefaultMailboxSize is used.
+	MailboxSize int
+	// HandleError, if not nil, is called from the goroutine of a top-level
+	// machine when it fails to process an event that was not sent by Call.
+	HandleError func(machineID, event string, err error)
+
+	mu       sync.Mutex
+	machines map[string]*actor
+	closed   bool
+	inflight int           // Events enqueued and not yet processed or dropped.
+	idle     chan struct{} // Closed when inflight drops to zero.
+	wg       sync.WaitGroup
+}
+
+// actor is a machine hosted by the runtime. Its place in the machine tree is
+// guarded by the mutex of the runtime.
+type actor struct {
+	id       string
+	parent   string            // The ID of the invoking machine, if any.
+	invokeID string            // The ID of the invocation that started the machine.
+	children map[string]string // Machine IDs by invocation ID.
+
+	mu      sync.Mutex // Guards machine.
+	machine *sc.Machine
+
+	mailbox chan envelope
+	pending []string      // Events raised for the machine by the runtime; only its goroutine uses them.
+	done    chan struct{} // Closed when the machine is stopped.
+	stop    sync.Once
+	sendMu  sync.RWMutex // Held for reading to enqueue and for writing to close the mailbox.
+	closed  bool
+}
+
+// envelope is an event in a mailbox.
+type envelope struct {
+	event string
+	start bool        // Set for the first envelope of a machine, which settles its initial configuration.
+	reply chan result // Receives the outcome of the step, if not nil.
+}
+
+type result struct {
+	step *sc.Step
+	err  error
+}
+
+// message is an event sent from one machine to another.
+type message struct {
+	to, event string
+}
+
+// NewRuntime creates a runtime for the statecharts of the registry.
+func NewRuntime(registry *sc.StatechartRegistry) *Runtime {
+	return &Runtime{Registry: registry}
+}
+
+// Spawn starts a top-level machine of a registered statechart and returns its
+// initial state. The context is copied. The goroutine of the machine starts
+// the children its initial configuration invokes.
+func (r *Runtime) Spawn(id, statechartID string, context *structpb.Struct) (*sc.Machine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrClosed
+	}
+	if _, ok := r.machines[id]; ok {
+		return nil, fmt.Errorf("actor: machine %s already exists", id)
+	}
+	a, err := r.start(id, "", "", statechartID, context)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return proto.Clone(a.machine).(*sc.Machine), nil
+}
+
+// Send puts an event in the mailbox of a machine. While the mailbox is full,
+// it blocks until there is room or ctx is done.
+func (r *Runtime) Send(ctx context.Context, id, event string) error {
+	return r.enqueue(ctx, id, envelope{event: event}, true)
+}
+
+// TrySend puts an event in the mailbox of a machine, failing with
+// ErrMailboxFull instead of blocking if the mailbox is full.
+func (r *Runtime) TrySend(id, event string) error {
+	return r.enqueue(context.Background(), id, envelope{event: event}, false)
+}
+
+// Call sends an event to a machine like Send and waits for the step that
+// processes it. The events the step sends to other machines are delivered
+// asynchronously. A child whose step fails is stopped and its parent
+// notified.
+func (r *Runtime) Call(ctx context.Context, id, event string) (*sc.Step, error) {
+	reply := make(chan result, 1)
+	if err := r.enqueue(ctx, id, envelope{event: event, reply: reply}, true); err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-reply:
+		return res.step, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Idle waits until every event put in a mailbox has been processed or
+// dropped, including the events the machines send each other in turn, or
+// until ctx is done.
+func (r *Runtime) Idle(ctx context.Context) error {
+	r.mu.Lock()
+	if r.inflight == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	idle := r.idle
+	r.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting machines and events from outside the runtime and
+// waits for the machines to become idle, or for ctx to be done. It then stops
+// all machines, dropping the events left in their mailboxes, and waits for
+// their goroutines to exit. It returns the error of ctx if the machines did
+// not become idle in time.
+func (r *Runtime) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	err := r.Idle(ctx)
+	r.mu.Lock()
+	for id := range r.machines {
+		r.remove(id)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	return err
+}
+
+// Machine returns a copy of a machine.
+func (r *Runtime) Machine(id string) (*sc.Machine, bool) {
+	r.mu.Lock()
+	a, ok := r.machines[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return proto.Clone(a.machine).(*sc.Machine), true
+}
+
+// Machines returns the IDs of the hosted machines, in increasing order.
+func (r *Runtime) Machines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.machines))
+	for id := range r.machines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Parent returns the ID of the machine that invoked a machine. It reports
+// false for top-level and unknown machines.
+func (r *Runtime) Parent(id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.machines[id]
+	if !ok || a.parent == "" {
+		return "", false
+	}
+	return a.parent, true
+}
+
+// Children returns the IDs of the machines a machine invoked, in increasing order.
+func (r *Runtime) Children(id string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.machines[id]
+	if !ok {
+		return nil
+	}
+	var ids []string
+	for _, child := range a.children {
+		ids = append(ids, child)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Stop stops a machine and its descendants and removes them from the
+// runtime. The events left in their mailboxes are dropped.
+func (r *Runtime) Stop(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.machines[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMachineNotFound, id)
+	}
+	if parent, ok := r.machines[a.parent]; ok {
+		delete(parent.children, a.invokeID)
+	}
+	r.remove(id)
+	return nil
+}
+
+func (r *Runtime) mailboxSize() int {
+	if r.MailboxSize <= 0 {
+		return DefaultMailboxSize
+	}
+	return r.MailboxSize
+}
+
+// enqueue puts an envelope from outside the runtime in the mailbox of a machine.
+func (r *Runtime) enqueue(ctx context.Context, id string, env envelope, wait bool) error {
+	r.mu.Lock()
+	a, ok := r.machines[id]
+	closed := r.closed
+	r.mu.Unlock()
+	switch {
+	case closed:
+		return ErrClosed
+	case !ok:
+		return fmt.Errorf("%w: %s", ErrMachineNotFound, id)
+	}
+	return r.post(ctx, a, env, wait)
+}
+
+// post puts an envelope in the mailbox of an actor and counts it as in
+// flight. If the mailbox is full and wait is set, post waits for room until
+// ctx is done or the actor is stopped.
+func (r *Runtime) post(ctx context.Context, a *actor, env envelope, wait bool) error {
+	a.sendMu.RLock()
+	defer a.sendMu.RUnlock()
+	if a.closed {
+		return fmt.Errorf("%w: %s", ErrMachineNotFound, a.id)
+	}
+	r.begin()
+	select {
+	case a.mailbox <- env:
+		return nil
+	default:
+	}
+	err := fmt.Errorf("%w: %s", ErrMailboxFull, a.id)
+	if wait {
+		select {
+		case a.mailbox <- env:
+			return nil
+		case <-a.done:
+			err = fmt.Errorf("%w: %s", ErrMachineNotFound, a.id)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	r.end()
+	return err
+}
+
+// begin counts an event as in flight.
+func (r *Runtime) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.track()
+}
+
+// track counts an event as in flight. The caller holds the mutex of the runtime.
+func (r *Runtime) track() {
+	if r.inflight == 0 {
+		r.idle = make(chan struct{})
+	}
+	r.inflight++
+}
+
+// end counts an event as processed or dropped.
+func (r *Runtime) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inflight--
+	if r.inflight == 0 {
+		close(r.idle)
+	}
+}
+
+// engine returns an engine that collects the events sent by the actions of a
+// machine in out, instead of executing those actions.
+func (r *Runtime) engine(out *[]message) *semantics.Engine {
+	e := semantics.Engine{}
+	if r.Engine != nil {
+		e = *r.Engine
+	}
+	execute := e.ExecuteAction
+	if execute == nil {
+		execute = semantics.ExecuteAction
+	}
+	e.ExecuteAction = func(action *sc.Action, context *structpb.Struct) error {
+		if event, target, ok := semantics.SentEvent(action); ok {
+			*out = append(*out, message{to: target, event: event})
+			return nil
+		}
+		return execute(action, context)
+	}
+	return &e
+}
+
+// start creates a machine in its initial configuration and starts its
+// goroutine. The first envelope in the mailbox of the machine has the
+// goroutine start the invoked children and deliver the events sent on entry.
+// The caller holds the mutex of the runtime.
+func (r *Runtime) start(id, parent, invokeID, statechartID string, context *structpb.Struct) (*actor, error) {
+	chart, ok := r.Registry.GetStatecharts()[statechartID]
+	if !ok {
+		return nil, fmt.Errorf("actor: statechart %q is not registered", statechartID)
+	}
+	chart, err := semantics.Inline(chart, r.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("actor: statechart %q: %w", statechartID, err)
+	}
+	var out []message
+	machine, err := r.engine(&out).NewMachine(id, semantics.NewStatechart(chart), context)
+	if err != nil {
+		return nil, err
+	}
+	if r.machines == nil {
+		r.machines = make(map[string]*actor)
+	}
+	a := &actor{
+		id:       id,
+		parent:   parent,
+		invokeID: invokeID,
+		children: make(map[string]string),
+		machine:  machine,
+		mailbox:  make(chan envelope, r.mailboxSize()),
+		done:     make(chan struct{}),
+	}
+	r.machines[id] = a
+	r.track()
+	a.mailbox <- envelope{start: true}
+	r.wg.Add(1)
+	go r.run(a, out)
+	return a, nil
+}
+
+// run takes the envelopes from the mailbox of an actor until it is stopped.
+// The events sent on entry to the initial configuration are in initial.
+func (r *Runtime) run(a *actor, initial []message) {
+	defer r.wg.Done()
+	defer r.close(a)
+	for {
+		select {
+		case <-a.done:
+			return
+		case env := <-a.mailbox:
+			if env.start {
+				r.settle(a, nil, initial)
+			} else {
+				step, err := r.step(a, env.event)
+				if env.reply != nil {
+					env.reply <- result{step, err}
+				} else if err != nil {
+					r.handleError(a, env.event, err)
+				}
+			}
+			for len(a.pending) > 0 {
+				event := a.pending[0]
+				a.pending = a.pending[1:]
+				if _, err := r.step(a, event); err != nil {
+					r.handleError(a, event, err)
+				}
+			}
+			r.end()
+		}
+	}
+}
+
+// close closes the mailbox of a stopped actor and drops the envelopes left in it.
+func (r *Runtime) close(a *actor) {
+	a.sendMu.Lock()
+	a.closed = true
+	a.sendMu.Unlock()
+	for {
+		select {
+		case env := <-a.mailbox:
+			if env.reply != nil {
+				env.reply <- result{err: fmt.Errorf("%w: %s", ErrMachineNotFound, a.id)}
+			}
+			r.end()
+		default:
+			return
+		}
+	}
+}
+
+// handleError reports the failure of a top-level machine to HandleError. The
+// failures of children are reported to their parents.
+func (r *Runtime) handleError(a *actor, event string, err error) {
+	if a.parent == "" && r.HandleError != nil {
+		r.HandleError(a.id, event, err)
+	}
+}
+
+// step sends an event to a machine in its goroutine. A child whose step
+// fails is stopped and its parent notified.
+func (r *Runtime) step(a *actor, event string) (*sc.Step, error) {
+	var out []message
+	a.mu.Lock()
+	before := a.machine.GetConfiguration()
+	step, err := r.engine(&out).Step(a.machine, event)
+	a.mu.Unlock()
+	if err != nil {
+		r.mu.Lock()
+		parent, ok := r.machines[a.parent]
+		ok = ok && r.machines[a.id] == a
+		if ok {
+			delete(parent.children, a.invokeID)
+			r.remove(a.id)
+		}
+		r.mu.Unlock()
+		if ok {
+			r.post(context.Background(), parent, envelope{event: ErrorEvent(a.invokeID)}, true)
+		}
+		return nil, err
+	}
+	r.settle(a, before, out)
+	return step, nil
+}
+
+// settle starts the children invoked by the states a machine entered since
+// the configuration before and stops those of the states it exited, delivers
+// the events the machine sent, and notifies its parent when it stops in a
+// final configuration. A machine that stopped stops all of its children.
+func (r *Runtime) settle(a *actor, before *sc.Configuration, out []message) {
+	a.mu.Lock()
+	root := a.machine.Statechart.RootState
+	is := make(map[string]bool)
+	for _, ref := range a.machine.GetConfiguration().GetStates() {
+		is[ref.Label] = true
+	}
+	stopped := a.machine.State == sc.MachineStateStopped
+	a.mu.Unlock()
+	label := func(s *sc.State) string {
+		if s == root {
+			return semantics.RootState.String()
+		}
+		return s.Label
+	}
+	was := make(map[string]bool)
+	for _, ref := range before.GetStates() {
+		was[ref.Label] = true
+	}
+
+	r.mu.Lock()
+	if r.machines[a.id] != a {
+		// The machine was stopped meanwhile.
+		r.mu.Unlock()
+		return
+	}
+	states := invokingStates(root)
+	for i := len(states) - 1; i >= 0; i-- {
+		if s := states[i]; was[label(s)] && (!is[label(s)] || stopped) {
+			for j := len(s.Invokes) - 1; j >= 0; j-- {
+				if child, ok := a.children[s.Invokes[j].Id]; ok {
+					delete(a.children, s.Invokes[j].Id)
+					r.remove(child)
+				}
+			}
+		}
+	}
+	for _, s := range states {
+		if stopped || !is[label(s)] || was[label(s)] {
+			continue
+		}
+		for _, invoke := range s.Invokes {
+			child := a.id + "/" + invoke.Id
+			if _, err := r.start(child, a.id, invoke.Id, invoke.StatechartId, invoke.Context); err != nil {
+				a.pending = append(a.pending, ErrorEvent(invoke.Id))
+				continue
+			}
+			a.children[invoke.Id] = child
+		}
+	}
+	targets := make([]*actor, len(out))
+	for i, m := range out {
+		switch child, ok := a.children[m.to]; {
+		case m.to == ParentTarget:
+			targets[i] = r.machines[a.parent]
+		case ok:
+			targets[i] = r.machines[child]
+		default:
+			targets[i] = r.machines[m.to]
+		}
+	}
+	parent := r.machines[a.parent]
+	r.mu.Unlock()
+
+	// Machines do not wait for room in each other's mailboxes, so that
+	// machines sending each other events cannot deadlock. Children do wait
+	// for room in the mailbox of their parent to report that they are done,
+	// as parents never wait for their children.
+	for i, m := range out {
+		if targets[i] == nil {
+			continue
+		}
+		if err := r.post(context.Background(), targets[i], envelope{event: m.event}, false); errors.Is(err, ErrMailboxFull) {
+			a.pending = append(a.pending, CommunicationErrorEvent)
+		}
+	}
+	if stopped && parent != nil {
+		r.post(context.Background(), parent, envelope{event: DoneEvent(a.invokeID)}, true)
+	}
+}
+
+// remove stops a machine and its descendants and removes them from the
+// runtime. The caller holds the mutex of the runtime.
+func (r *Runtime) remove(id string) {
+	a, ok := r.machines[id]
+	if !ok {
+		return
+	}
+	for _, child := range a.children {
+		r.remove(child)
+	}
+	delete(r.machines, id)
+	a.stop.Do(func() { close(a.done) })
+}
+
+// invokingStates returns the states that invoke children, in document order.
+func invokingStates(root *sc.State) []*sc.State {
+	var states []*sc.State
+	var visit func(s *sc.State)
+	visit = func(s *sc.State) {
+		if len(s.Invokes) > 0 {
+			states = append(states, s)
+		}
+		for _, child := range s.Children {
+			visit(child)
+		}
+	}
+	visit(root)
+	return states
+}
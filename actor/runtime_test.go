@@ -0,0 +1,338 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// registry holds an order whose Paying state invokes a payment, which reports
+// to the order and may fail on an undefined variable.
+func registry() *sc.StatechartRegistry {
+	return &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"payment": {
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "Authorizing", IsInitial: true},
+				{Label: "Authorized", IsFinal: true},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "approve", From: []string{"Authorizing"}, To: []string{"Authorized"}, Event: "APPROVE", Actions: []*sc.Action{{Label: "send APPROVED to parent"}}},
+				{Label: "fail", From: []string{"Authorizing"}, Event: "FAIL", Actions: []*sc.Action{{Label: "x = missing + 1"}}},
+			},
+		},
+		"order": {
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "Cart", IsInitial: true},
+				{Label: "Paying", Invokes: []*sc.Invoke{{Id: "pay", StatechartId: "payment"}}},
+				{Label: "Paid", IsFinal: true},
+				{Label: "Failed"},
+				{Label: "Cancelled"},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "checkout", From: []string{"Cart"}, To: []string{"Paying"}, Event: "CHECKOUT"},
+				{Label: "approve", From: []string{"Paying"}, Event: "APPROVE", Kind: sc.TransitionKindInternal, Actions: []*sc.Action{{Label: "send APPROVE to pay"}}},
+				{Label: "approved", From: []string{"Paying"}, Event: "APPROVED", Kind: sc.TransitionKindInternal, Actions: []*sc.Action{{Label: "approved = true"}}},
+				{Label: "paid", From: []string{"Paying"}, To: []string{"Paid"}, Event: DoneEvent("pay")},
+				{Label: "failed", From: []string{"Paying"}, To: []string{"Failed"}, Event: ErrorEvent("pay")},
+				{Label: "cancel", From: []string{"Paying"}, To: []string{"Cancelled"}, Event: "CANCEL"},
+			},
+		},
+	}}
+}
+
+func configuration(t *testing.T, r *Runtime, id string) []string {
+	t.Helper()
+	m, ok := r.Machine(id)
+	if !ok {
+		t.Fatalf("Machine(%s) not found", id)
+	}
+	var labels []string
+	for _, ref := range m.Configuration.States {
+		labels = append(labels, ref.Label)
+	}
+	return labels
+}
+
+// call sends an event to a machine and waits until the runtime is idle.
+func call(t *testing.T, r *Runtime, id, event string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.Call(ctx, id, event); err != nil {
+		t.Fatalf("Call(%s, %s) error = %v", id, event, err)
+	}
+	idle(t, r)
+}
+
+func idle(t *testing.T, r *Runtime) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Idle(ctx); err != nil {
+		t.Fatalf("Idle() error = %v", err)
+	}
+}
+
+func shutdown(t *testing.T, r *Runtime) {
+	t.Cleanup(func() {
+		if err := r.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	})
+}
+
+func TestRuntimeInvoke(t *testing.T) {
+	r := NewRuntime(registry())
+	shutdown(t, r)
+	if _, err := r.Spawn("order", "order", nil); err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+	call(t, r, "order", "CHECKOUT")
+	if diff := cmp.Diff([]string{"order/pay"}, r.Children("order")); diff != "" {
+		t.Errorf("Children() mismatch (-want +got):\n%s", diff)
+	}
+	if parent, ok := r.Parent("order/pay"); !ok || parent != "order" {
+		t.Errorf("Parent(order/pay) = %q, %v, want order", parent, ok)
+	}
+
+	// The order forwards APPROVE to the payment, which replies and stops.
+	call(t, r, "order", "APPROVE")
+	if diff := cmp.Diff([]string{"__root__", "Paid"}, configuration(t, r, "order")); diff != "" {
+		t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+	}
+	m, _ := r.Machine("order")
+	if !m.Context.Fields["approved"].GetBoolValue() {
+		t.Error("the order did not receive APPROVED before the payment was done")
+	}
+	if diff := cmp.Diff([]string{"order"}, r.Machines()); diff != "" {
+		t.Errorf("Machines() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRuntimeStopsChildrenOnExit(t *testing.T) {
+	r := NewRuntime(registry())
+	shutdown(t, r)
+	if _, err := r.Spawn("order", "order", nil); err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+	call(t, r, "order", "CHECKOUT")
+	call(t, r, "order", "CANCEL")
+	if diff := cmp.Diff([]string{"order"}, r.Machines()); diff != "" {
+		t.Errorf("Machines() mismatch (-want +got):\n%s", diff)
+	}
+	if err := r.Send(context.Background(), "order/pay", "APPROVE"); !errors.Is(err, ErrMachineNotFound) {
+		t.Errorf("Send() error = %v, want %v", err, ErrMachineNotFound)
+	}
+}
+
+func TestRuntimeChildErrors(t *testing.T) {
+	t.Run("step", func(t *testing.T) {
+		r := NewRuntime(registry())
+		shutdown(t, r)
+		if _, err := r.Spawn("order", "order", nil); err != nil {
+			t.Fatalf("Spawn() error = %v", err)
+		}
+		call(t, r, "order", "CHECKOUT")
+		if _, err := r.Call(context.Background(), "order/pay", "FAIL"); err == nil || !strings.Contains(err.Error(), "undefined variable missing") {
+			t.Errorf("Call() error = %v, want the action error", err)
+		}
+		idle(t, r)
+		if diff := cmp.Diff([]string{"__root__", "Failed"}, configuration(t, r, "order")); diff != "" {
+			t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]string{"order"}, r.Machines()); diff != "" {
+			t.Errorf("Machines() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("start", func(t *testing.T) {
+		reg := registry()
+		delete(reg.Statecharts, "payment")
+		r := NewRuntime(reg)
+		shutdown(t, r)
+		if _, err := r.Spawn("order", "order", nil); err != nil {
+			t.Fatalf("Spawn() error = %v", err)
+		}
+		call(t, r, "order", "CHECKOUT")
+		if diff := cmp.Diff([]string{"__root__", "Failed"}, configuration(t, r, "order")); diff != "" {
+			t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+// counter holds a machine counting INC events.
+func counter() *sc.StatechartRegistry {
+	return &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"counter": {
+			RootState:   &sc.State{Children: []*sc.State{{Label: "Counting", IsInitial: true}}},
+			Transitions: []*sc.Transition{{Label: "inc", From: []string{"Counting"}, Event: "INC", Kind: sc.TransitionKindInternal, Actions: []*sc.Action{{Label: "count = count + 1"}}}},
+		},
+	}}
+}
+
+func TestRuntimeConcurrentSends(t *testing.T) {
+	const machines, senders, events = 200, 8, 10
+	r := NewRuntime(counter())
+	context0, err := structpb.NewStruct(map[string]interface{}{"count": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < machines; i++ {
+		if _, err := r.Spawn(fmt.Sprint("counter-", i), "counter", context0); err != nil {
+			t.Fatalf("Spawn() error = %v", err)
+		}
+	}
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := 0; e < events; e++ {
+				for i := 0; i < machines; i++ {
+					if err := r.Send(context.Background(), fmt.Sprint("counter-", i), "INC"); err != nil {
+						t.Errorf("Send() error = %v", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	idle(t, r)
+	for i := 0; i < machines; i++ {
+		m, _ := r.Machine(fmt.Sprint("counter-", i))
+		if got := m.Context.Fields["count"].GetNumberValue(); got != senders*events {
+			t.Fatalf("counter-%d count = %v, want %d", i, got, senders*events)
+		}
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if got := r.Machines(); len(got) != 0 {
+		t.Errorf("Machines() after Shutdown = %v, want none", got)
+	}
+}
+
+func TestRuntimeBackPressure(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r := NewRuntime(counter())
+	r.MailboxSize = 1
+	r.Engine = &semantics.Engine{ExecuteAction: func(action *sc.Action, context *structpb.Struct) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}}
+	shutdown(t, r)
+	if _, err := r.Spawn("counter", "counter", nil); err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+	idle(t, r)
+	// The machine blocks in the first INC, and the second fills its mailbox.
+	for i := 0; i < 2; i++ {
+		if err := r.TrySend("counter", "INC"); err != nil {
+			t.Fatalf("TrySend() error = %v", err)
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+	if err := r.TrySend("counter", "INC"); !errors.Is(err, ErrMailboxFull) {
+		t.Errorf("TrySend() error = %v, want %v", err, ErrMailboxFull)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Send(ctx, "counter", "INC"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Send waits for room in the mailbox.
+	sent := make(chan error)
+	go func() { sent <- r.Send(context.Background(), "counter", "INC") }()
+	close(release)
+	// The second and third INC follow the first.
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+	if err := <-sent; err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+	idle(t, r)
+}
+
+func TestRuntimeShutdown(t *testing.T) {
+	r := NewRuntime(&sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"echo": {
+			RootState:   &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+			Transitions: []*sc.Transition{{Label: "echo", From: []string{"A"}, Event: "PING", Kind: sc.TransitionKindInternal, Actions: []*sc.Action{{Label: "send PING to other"}}}},
+		},
+	}})
+	for _, id := range []string{"one", "other"} {
+		if _, err := r.Spawn(id, "echo", nil); err != nil {
+			t.Fatalf("Spawn(%s) error = %v", id, err)
+		}
+	}
+	// The machines keep sending each other events, so they never become idle.
+	if err := r.Send(context.Background(), "one", "PING"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := r.Machines(); len(got) != 0 {
+		t.Errorf("Machines() after Shutdown = %v, want none", got)
+	}
+	if err := r.Send(context.Background(), "one", "PING"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := r.Spawn("one", "echo", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Spawn() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	r := NewRuntime(registry())
+	shutdown(t, r)
+	if _, err := r.Spawn("order", "missing", nil); err == nil || !strings.Contains(err.Error(), `statechart "missing" is not registered`) {
+		t.Errorf("Spawn() error = %v, want an error about the statechart", err)
+	}
+	if _, err := r.Spawn("order", "order", nil); err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+	if _, err := r.Spawn("order", "order", nil); err == nil {
+		t.Error("Spawn() of an existing machine succeeded")
+	}
+	if err := r.Stop("order"); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := r.Stop("order"); !errors.Is(err, ErrMachineNotFound) {
+		t.Errorf("Stop() error = %v, want %v", err, ErrMachineNotFound)
+	}
+}
+
+func TestRuntimeHandleError(t *testing.T) {
+	errs := make(chan string, 1)
+	r := NewRuntime(registry())
+	r.HandleError = func(machineID, event string, err error) {
+		errs <- fmt.Sprintf("%s %s: %v", machineID, event, err)
+	}
+	shutdown(t, r)
+	if _, err := r.Spawn("payment", "payment", nil); err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+	if err := r.Send(context.Background(), "payment", "FAIL"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := <-errs; !strings.HasPrefix(got, "payment FAIL: ") || !strings.Contains(got, "undefined variable missing") {
+		t.Errorf("HandleError got %q, want the action error of payment", got)
+	}
+}
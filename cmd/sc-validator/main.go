@@ -0,0 +1,91 @@
+// Command sc-validator serves the SemanticValidator over gRPC.
+//
+// Usage:
+//
+//	sc-validator [-addr host:port]
+//
+// Besides the SemanticValidator, the server serves the gRPC health checking
+// protocol and server reflection, so that it can be probed and explored with
+// tools such as grpc-health-probe and grpcurl. It stops gracefully on SIGINT
+// or SIGTERM, reporting itself as not serving while in-flight calls finish.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+const usage = `usage:
+	sc-validator [-addr host:port]
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "sc-validator: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned for invalid command lines.
+var errUsage = errors.New(usage)
+
+// run serves the SemanticValidator until ctx is done.
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sc-validator", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addr := fs.String("addr", "localhost:50051", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv, hs := newServer()
+	// Stop the server when ctx is done, or free the goroutine when Serve
+	// fails first.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		hs.Shutdown()
+		srv.GracefulStop()
+	}()
+	fmt.Fprintf(stderr, "sc-validator: serving on %s\n", lis.Addr())
+	if err := srv.Serve(lis); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// newServer returns a gRPC server for the SemanticValidator, with health
+// checking and reflection, and its health server.
+func newServer() (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer()
+	validationv1.RegisterSemanticValidatorServer(srv, validation.NewSemanticValidator())
+	hs := health.NewServer()
+	hs.SetServingStatus(validationv1.SemanticValidator_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+	return srv, hs
+}
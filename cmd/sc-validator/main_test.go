@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+// dialer serves newServer over an in-memory connection and returns the
+// dial option connecting to it.
+func dialer(t *testing.T) grpc.DialOption {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv, _ := newServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) })
+}
+
+func dial(t *testing.T, opt grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient("passthrough:///bufnet", opt, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestValidateWithService validates statecharts with the client of the
+// semantics package against the server.
+func TestValidateWithService(t *testing.T) {
+	client, err := semantics.NewValidatorClient("passthrough:///bufnet", dialer(t))
+	if err != nil {
+		t.Fatalf("NewValidatorClient() error = %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	valid := semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "Off", IsInitial: true},
+			{Label: "On"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "on", From: []string{"Off"}, To: []string{"On"}, Event: "TOGGLE"},
+			{Label: "off", From: []string{"On"}, To: []string{"Off"}, Event: "TOGGLE"},
+		},
+	})
+	if err := valid.ValidateWithService(ctx, client); err != nil {
+		t.Errorf("ValidateWithService() of a valid statechart error = %v", err)
+	}
+
+	// Only warnings: the state B cannot be reached.
+	unreachable := semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "A", IsInitial: true},
+			{Label: "B"},
+		}},
+	})
+	if err := unreachable.ValidateWithService(ctx, client); err != nil {
+		t.Errorf("ValidateWithService() of a statechart with warnings error = %v", err)
+	}
+
+	invalid := semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "A", IsInitial: true},
+			{Label: "A"},
+		}},
+	})
+	err = invalid.ValidateWithService(ctx, client)
+	if err == nil || !strings.Contains(err.Error(), "UNIQUE_STATE_LABELS") {
+		t.Errorf("ValidateWithService() of duplicate labels error = %v, want a UNIQUE_STATE_LABELS violation", err)
+	}
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := dial(t, dialer(t))
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(conn)
+	for _, service := range []string{"", "statecharts.validation.v1.SemanticValidator"} {
+		resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", service, err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, want SERVING", service, resp.Status)
+		}
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo() error = %v", err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	sort.Strings(services)
+	want := []string{"grpc.health.v1.Health", "grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection", "statecharts.validation.v1.SemanticValidator"}
+	if diff := cmp.Diff(want, services); diff != "" {
+		t.Errorf("services mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if err := run(context.Background(), []string{"extra"}, io.Discard); !errors.Is(err, errUsage) {
+		t.Errorf("run() with an argument error = %v, want usage", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stderr, w := io.Pipe()
+	done := make(chan error)
+	go func() { done <- run(ctx, []string{"-addr", "localhost:0"}, w) }()
+	line, err := bufio.NewReader(stderr).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, ok := strings.CutPrefix(strings.TrimSpace(line), "sc-validator: serving on ")
+	if !ok {
+		t.Fatalf("run() printed %q, want the address it serves on", line)
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run() did not stop when its context was done")
+	}
+}
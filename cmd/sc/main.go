@@ -0,0 +1,101 @@
+// Command sc works with statecharts.
+//
+// Usage:
+//
+//	sc generate go [-package name] [-o file] chart
+//
+// Charts are read as protobuf JSON (.json), text (.textproto, .txtpb) or
+// binary (any other extension) encodings of statecharts.v1.Statechart.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/codegen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+const usage = `usage:
+	sc generate go [-package name] [-o file] chart
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "sc: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned for invalid command lines.
+var errUsage = errors.New(usage)
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) < 2 || args[0] != "generate" {
+		return errUsage
+	}
+	switch args[1] {
+	case "go":
+		return generateGo(args[2:], stdout)
+	}
+	return errUsage
+}
+
+func generateGo(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sc generate go", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	pkg := fs.String("package", "", "name of the generated package (default: the chart file name)")
+	out := fs.String("o", "", "output file (default: standard output)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	path := fs.Arg(0)
+	chart, err := readChart(path)
+	if err != nil {
+		return err
+	}
+	if *pkg == "" {
+		*pkg = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	src, err := codegen.GenerateGo(chart, codegen.GoOptions{Package: *pkg, Source: filepath.Base(path)})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if *out == "" {
+		_, err := stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// readChart reads a statechart, choosing the encoding by the file extension.
+func readChart(path string) (*sc.Statechart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	chart := &sc.Statechart{}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = protojson.Unmarshal(data, chart)
+	case ".textproto", ".txtpb":
+		err = prototext.Unmarshal(data, chart)
+	default:
+		err = proto.Unmarshal(data, chart)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return chart, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+func TestGenerateGo(t *testing.T) {
+	dir := filepath.Join("..", "..", "codegen", "internal", "counter")
+	want, err := os.ReadFile(filepath.Join(dir, "counter.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := run([]string{"generate", "go", filepath.Join(dir, "counter.json")}, &stdout); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if diff := cmp.Diff(string(want), stdout.String()); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+
+	// The chart is read in the encoding of its extension and written to -o.
+	chart, err := readChart(filepath.Join(dir, "counter.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := prototext.Marshal(chart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "counter.textproto")
+	if err := os.WriteFile(in, text, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(tmp, "counter.go")
+	if err := run([]string{"generate", "go", "-package", "counter", "-o", out, in}, &stdout); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = bytes.Replace(want, []byte("from counter.json"), []byte("from counter.textproto"), 1)
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"generate"},
+		{"generate", "rust", "chart.json"},
+		{"generate", "go"},
+		{"validate", "chart.json"},
+	} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q) error = %v, want usage", args, err)
+		}
+	}
+	if err := run([]string{"generate", "go", "missing.json"}, &bytes.Buffer{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("run() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,22 @@
+// Package codegen generates code from statecharts.
+//
+// GenerateGo emits a Go package that drives the semantics engine with a
+// statechart embedded in it. The package declares typed constants for the
+// states and events of the chart, a Context struct with the variables its
+// guards and actions refer to, Guards and Actions interfaces the application
+// implements, and a Machine type with a method per event:
+//
+//	m, err := turnstile.New(ctx, &turnstile.Context{}, guards, actions)
+//	if err != nil {
+//		return err
+//	}
+//	if err := m.TurnOn(ctx); err != nil {
+//		return err
+//	}
+//	if m.In(turnstile.StateOn) {
+//		...
+//	}
+//
+// References to states and events that do not exist in the chart then fail to
+// compile instead of failing at runtime. The "sc generate go" command wraps GenerateGo.
+package codegen
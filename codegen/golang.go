@@ -0,0 +1,687 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GoOptions configures GenerateGo.
+type GoOptions struct {
+	// Package is the name of the generated package.
+	Package string
+	// Source names the file the chart was read from in the header of the
+	// generated code. It may be empty.
+	Source string
+}
+
+// machineMethods are the methods of the generated Machine type; event methods
+// that would collide with them get an "Event" suffix.
+var machineMethods = map[string]bool{
+	"Send": true, "Configuration": true, "In": true, "Done": true, "Context": true, "Snapshot": true,
+}
+
+// GenerateGo generates a Go package for the statechart. The result is gofmt-ed.
+//
+// Guards are identified by their expressions and actions by their labels; the
+// names of the methods implementing them are derived from those, with operators
+// spelled out: the guard "count < limit" is implemented by CountLessThanLimit.
+// The fields of Context are the variables the guards and actions refer to when
+// read as expressions of the semantics package. Their types are inferred from
+// their use and are interface{} when the use is ambiguous.
+func GenerateGo(chart *sc.Statechart, opts GoOptions) ([]byte, error) {
+	if !token.IsIdentifier(opts.Package) {
+		return nil, fmt.Errorf("invalid package name %q", opts.Package)
+	}
+	chart = proto.Clone(chart).(*sc.Statechart)
+	if err := semantics.NewStatechart(chart).Validate(); err != nil {
+		return nil, err
+	}
+	g := &goGenerator{chart: chart, opts: opts}
+	if err := g.collect(); err != nil {
+		return nil, err
+	}
+	src, err := g.generate()
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return out, nil
+}
+
+// goName is a chart element together with the Go identifier generated for it.
+type goName struct {
+	label string
+	name  string
+}
+
+// goField is a field of the generated Context struct.
+type goField struct {
+	goName
+	typ string
+}
+
+type goGenerator struct {
+	chart *sc.Statechart
+	opts  GoOptions
+
+	states  []goName
+	events  []goName
+	methods []string // Names of the Machine methods sending the events.
+	guards  []goName
+	actions []goName
+	fields  []goField
+}
+
+// collect names the states, events, guards, actions and context variables of the chart.
+func (g *goGenerator) collect() error {
+	var visit func(s *sc.State)
+	visit = func(s *sc.State) {
+		if s != g.chart.RootState {
+			g.states = append(g.states, goName{label: s.Label, name: "State" + identifier(s.Label)})
+		}
+		for _, c := range s.Children {
+			visit(c)
+		}
+	}
+	visit(g.chart.RootState)
+	if err := unique("states", g.states); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	addEvent := func(event string) {
+		if event == "" || seen[event] {
+			return
+		}
+		seen[event] = true
+		g.events = append(g.events, goName{label: event, name: "Event" + identifier(event)})
+		method := exported(identifier(event), "Event")
+		if machineMethods[method] {
+			method += "Event"
+		}
+		g.methods = append(g.methods, method)
+	}
+	for _, e := range g.chart.Events {
+		addEvent(e.GetLabel())
+	}
+	for _, t := range g.chart.Transitions {
+		addEvent(t.GetEvent())
+	}
+	if err := unique("events", g.events); err != nil {
+		return err
+	}
+
+	types := &contextTypes{types: make(map[string]string), conflicts: make(map[string]bool)}
+	var guards, actions []string
+	for _, t := range g.chart.Transitions {
+		// The engine takes else branches without evaluating their guards.
+		if expr := strings.TrimSpace(t.GetGuard().GetExpression()); expr != "" && !semantics.IsElse(t.GetGuard()) {
+			if e, err := semantics.ParseExpression(expr); err == nil && callsIn(e) {
+				return fmt.Errorf("transition %s: guard %q tests the configuration with in(), which generated guards cannot", t.GetLabel(), expr)
+			}
+			guards = appendString(guards, t.GetGuard().GetExpression())
+		}
+		for _, a := range t.GetActions() {
+			if _, ok := semantics.RaisedEvent(a); ok {
+				// The engine raises the event; there is nothing to implement.
+				continue
+			}
+			actions = appendString(actions, a.GetLabel())
+		}
+	}
+	// Types propagate through assignments, so infer them until they are stable.
+	for i := 0; i < 3; i++ {
+		for _, guard := range guards {
+			if e, err := semantics.ParseExpression(guard); err == nil {
+				types.mark(e, boolType)
+			}
+		}
+		for _, action := range actions {
+			if assignments, err := semantics.ParseAssignments(action); err == nil {
+				for _, a := range assignments {
+					types.set(a.Target.Path, types.infer(a.Value))
+				}
+			}
+		}
+	}
+	g.guards = methodNames(guards, "Guard")
+	g.actions = methodNames(actions, "Action")
+	for _, name := range types.order {
+		g.fields = append(g.fields, goField{goName: goName{label: name, name: exported(identifier(name), "Field")}, typ: types.typeOf(name)})
+	}
+	fields := make([]goName, len(g.fields))
+	for i, f := range g.fields {
+		fields[i] = f.goName
+	}
+	return unique("context variables", fields)
+}
+
+func (g *goGenerator) generate() ([]byte, error) {
+	embedded, err := protojson.Marshal(g.chart)
+	if err != nil {
+		return nil, err
+	}
+	var compact, indented bytes.Buffer
+	if err := json.Compact(&compact, embedded); err != nil {
+		return nil, err
+	}
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format+"\n", args...) }
+
+	if g.opts.Source != "" {
+		p("// Code generated by sc generate go from %s. DO NOT EDIT.", g.opts.Source)
+	} else {
+		p("// Code generated by sc generate go. DO NOT EDIT.")
+	}
+	p("")
+	p("package %s", g.opts.Package)
+	p("")
+	p("import (")
+	p("%q", "context")
+	p("%q", "fmt")
+	p("%q", "sync")
+	p("")
+	p("%q", "github.com/tmc/sc")
+	p("%q", "github.com/tmc/sc/semantics/v1")
+	p("%q", "google.golang.org/protobuf/encoding/protojson")
+	p("%q", "google.golang.org/protobuf/proto")
+	p("%q", "google.golang.org/protobuf/types/known/structpb")
+	p(")")
+	p("")
+
+	p("// State is a state of the statechart.")
+	p("type State string")
+	p("")
+	p("// States of the statechart.")
+	p("const (")
+	for _, s := range g.states {
+		p("%s State = %q", s.name, s.label)
+	}
+	p(")")
+	p("")
+	p("// Event is an event of the statechart.")
+	p("type Event string")
+	p("")
+	if len(g.events) > 0 {
+		p("// Events of the statechart.")
+		p("const (")
+		for _, e := range g.events {
+			p("%s Event = %q", e.name, e.label)
+		}
+		p(")")
+		p("")
+	}
+
+	p("// Context is the context of a machine, shared by its guards and actions.")
+	p("type Context struct {")
+	for _, f := range g.fields {
+		p("%s %s `json:%q`", f.name, f.typ, f.label)
+	}
+	p("}")
+	p("")
+	p("// Guards evaluates the guards of the statechart.")
+	p("type Guards interface {")
+	for _, guard := range g.guards {
+		p("// %s evaluates the guard %q.", guard.name, guard.label)
+		p("%s(ctx context.Context, c *Context) (bool, error)", guard.name)
+	}
+	p("}")
+	p("")
+	p("// Actions executes the actions of the statechart.")
+	p("type Actions interface {")
+	for _, action := range g.actions {
+		p("// %s executes the action %q.", action.name, action.label)
+		p("%s(ctx context.Context, c *Context) error", action.name)
+	}
+	p("}")
+	p("")
+
+	p("// Machine is a machine of the statechart. It is safe for concurrent use.")
+	p("type Machine struct {")
+	p("guards  Guards")
+	p("actions Actions")
+	p("")
+	p("mu      sync.Mutex")
+	p("context *Context")
+	p("machine *sc.Machine")
+	p("}")
+	p("")
+	p("// New creates a machine in the initial configuration of the statechart and")
+	p("// takes the eventless transitions enabled in it. A nil context starts the")
+	p("// machine with a zero Context.")
+	p("func New(ctx context.Context, c *Context, guards Guards, actions Actions) (*Machine, error) {")
+	p("if c == nil {")
+	p("c = &Context{}")
+	p("}")
+	p("m := &Machine{guards: guards, actions: actions, context: c}")
+	p("machine, err := m.engine(ctx).NewMachine(%q, chart, nil)", g.opts.Package)
+	p("if err != nil {")
+	p("return nil, err")
+	p("}")
+	p("m.machine = machine")
+	p("return m, nil")
+	p("}")
+	p("")
+	p("// Send sends an event to the machine. If a guard or action fails, the")
+	p("// configuration is left unchanged; changes that actions made to the")
+	p("// context are not undone.")
+	p("func (m *Machine) Send(ctx context.Context, event Event) error {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("_, err := m.engine(ctx).Step(m.machine, string(event))")
+	p("return err")
+	p("}")
+	p("")
+	for i, e := range g.events {
+		p("// %s sends the %s event.", g.methods[i], e.label)
+		p("func (m *Machine) %s(ctx context.Context) error {", g.methods[i])
+		p("return m.Send(ctx, %s)", e.name)
+		p("}")
+		p("")
+	}
+	p("// Configuration returns the active states in document order.")
+	p("func (m *Machine) Configuration() []State {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("var states []State")
+	p("for _, ref := range m.machine.GetConfiguration().GetStates() {")
+	p("if ref.GetLabel() != semantics.RootState.String() {")
+	p("states = append(states, State(ref.GetLabel()))")
+	p("}")
+	p("}")
+	p("return states")
+	p("}")
+	p("")
+	p("// In reports whether a state is active.")
+	p("func (m *Machine) In(state State) bool {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("for _, ref := range m.machine.GetConfiguration().GetStates() {")
+	p("if ref.GetLabel() == string(state) {")
+	p("return true")
+	p("}")
+	p("}")
+	p("return false")
+	p("}")
+	p("")
+	p("// Done reports whether the machine has reached a final configuration.")
+	p("func (m *Machine) Done() bool {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("return m.machine.GetState() == sc.MachineStateStopped")
+	p("}")
+	p("")
+	p("// Context returns a copy of the context of the machine.")
+	p("func (m *Machine) Context() *Context {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("c := *m.context")
+	deep := false
+	for _, f := range g.fields {
+		switch f.typ {
+		case structType:
+			p("c.%s, _ = cloneValue(c.%s).(%s)", f.name, f.name, f.typ)
+			deep = true
+		case anyType:
+			p("c.%s = cloneValue(c.%s)", f.name, f.name)
+			deep = true
+		}
+	}
+	p("return &c")
+	p("}")
+	p("")
+	p("// Snapshot returns a copy of the underlying machine, including its step history.")
+	p("func (m *Machine) Snapshot() *sc.Machine {")
+	p("m.mu.Lock()")
+	p("defer m.mu.Unlock()")
+	p("return proto.Clone(m.machine).(*sc.Machine)")
+	p("}")
+	p("")
+	p("// engine returns an engine that evaluates guards and executes actions with")
+	p("// the implementations of the machine.")
+	p("func (m *Machine) engine(ctx context.Context) *semantics.Engine {")
+	p("return &semantics.Engine{")
+	p("EvaluateGuard: func(guard *sc.Guard, _ *structpb.Struct) (bool, error) {")
+	p("switch guard.GetExpression() {")
+	p("case \"\":")
+	p("return true, nil")
+	for _, guard := range g.guards {
+		p("case %q:", guard.label)
+		p("return m.guards.%s(ctx, m.context)", guard.name)
+	}
+	p("}")
+	p("return false, fmt.Errorf(\"unknown guard %%q\", guard.GetExpression())")
+	p("},")
+	p("ExecuteAction: func(action *sc.Action, _ *structpb.Struct) error {")
+	p("switch action.GetLabel() {")
+	for _, action := range g.actions {
+		p("case %q:", action.label)
+		p("return m.actions.%s(ctx, m.context)", action.name)
+	}
+	p("}")
+	p("return fmt.Errorf(\"unknown action %%q\", action.GetLabel())")
+	p("},")
+	p("}")
+	p("}")
+	p("")
+	if deep {
+		p("// cloneValue returns a deep copy of a value of a context field.")
+		p("func cloneValue(v interface{}) interface{} {")
+		p("switch v := v.(type) {")
+		p("case map[string]interface{}:")
+		p("if v == nil {")
+		p("return v")
+		p("}")
+		p("c := make(map[string]interface{}, len(v))")
+		p("for k, e := range v {")
+		p("c[k] = cloneValue(e)")
+		p("}")
+		p("return c")
+		p("case []interface{}:")
+		p("if v == nil {")
+		p("return v")
+		p("}")
+		p("c := make([]interface{}, len(v))")
+		p("for i, e := range v {")
+		p("c[i] = cloneValue(e)")
+		p("}")
+		p("return c")
+		p("}")
+		p("return v")
+		p("}")
+		p("")
+	}
+	p("// Statechart returns a copy of the statechart.")
+	p("func Statechart() *sc.Statechart {")
+	p("return proto.Clone(chart.Statechart).(*sc.Statechart)")
+	p("}")
+	p("")
+	p("// chart is the statechart the machines execute.")
+	p("var chart = func() *semantics.Statechart {")
+	p("c := &sc.Statechart{}")
+	p("if err := protojson.Unmarshal([]byte(chartJSON), c); err != nil {")
+	p("panic(err)")
+	p("}")
+	p("return semantics.NewStatechart(c)")
+	p("}()")
+	p("")
+	if bytes.ContainsRune(indented.Bytes(), '`') {
+		p("const chartJSON = %s", strconv.Quote(indented.String()))
+	} else {
+		p("const chartJSON = `%s`", indented.String())
+	}
+	return b.Bytes(), nil
+}
+
+// unique reports an error if two elements have the same Go name.
+func unique(kind string, names []goName) error {
+	seen := make(map[string]string)
+	for _, n := range names {
+		if n.name == "" || n.name == "State" || n.name == "Event" {
+			return fmt.Errorf("%s: no Go name for %q", kind, n.label)
+		}
+		if other, ok := seen[n.name]; ok {
+			return fmt.Errorf("%s %q and %q have the same Go name %s", kind, other, n.label, n.name)
+		}
+		seen[n.name] = n.label
+	}
+	return nil
+}
+
+// methodNames names the methods implementing guards or actions, numbering
+// methods whose names would collide.
+func methodNames(labels []string, prefix string) []goName {
+	var names []goName
+	used := make(map[string]bool)
+	for _, label := range labels {
+		name := exported(identifier(label), prefix)
+		if used[name] {
+			for i := 2; ; i++ {
+				if n := name + strconv.Itoa(i); !used[n] {
+					name = n
+					break
+				}
+			}
+		}
+		used[name] = true
+		names = append(names, goName{label: label, name: name})
+	}
+	return names
+}
+
+// operatorWords spells out the operators of the expression language in Go names.
+var operatorWords = map[string]string{
+	"<": "LessThan", "<=": "AtMost", ">": "GreaterThan", ">=": "AtLeast",
+	"==": "Is", "!=": "IsNot", "!": "Not", "&&": "And", "||": "Or",
+	"+": "Plus", "-": "Minus", "*": "Times", "/": "Over", "%": "Mod", "=": "Gets",
+}
+
+// identifier converts a label to a Go identifier in mixed caps. Runs of
+// letters and digits are words, operators are spelled out and other characters
+// separate words. Words in all caps, such as the parts of "TURN_ON", are
+// capitalized: the result is "TurnOn".
+func identifier(label string) string {
+	var (
+		sb   strings.Builder
+		word []rune
+		op   []rune
+	)
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		if len(word) > 1 && strings.ToUpper(w) == w {
+			w = strings.ToLower(w)
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+		word = word[:0]
+	}
+	flushOp := func() {
+		if len(op) == 0 {
+			return
+		}
+		if w, ok := operatorWords[string(op)]; ok {
+			sb.WriteString(w)
+		} else {
+			for _, r := range op {
+				sb.WriteString(operatorWords[string(r)])
+			}
+		}
+		op = op[:0]
+	}
+	for _, r := range label {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushOp()
+			word = append(word, r)
+		case strings.ContainsRune("<>=!&|+-*/%", r):
+			flushWord()
+			op = append(op, r)
+		default:
+			flushWord()
+			flushOp()
+		}
+	}
+	flushWord()
+	flushOp()
+	return sb.String()
+}
+
+// exported returns name, prefixed if it does not start with a letter.
+func exported(name, prefix string) string {
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return prefix + name
+	}
+	return name
+}
+
+func appendString(s []string, v string) []string {
+	for _, x := range s {
+		if x == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// Go types of context variables.
+const (
+	numberType = "float64"
+	stringType = "string"
+	boolType   = "bool"
+	anyType    = "interface{}"
+	structType = "map[string]interface{}"
+)
+
+// contextTypes infers the types of context variables from the expressions using them.
+type contextTypes struct {
+	order     []string
+	types     map[string]string // Empty while unknown.
+	conflicts map[string]bool
+}
+
+// set records that the variable at path holds values of type typ, which is
+// empty if unknown. Variables used with conflicting types hold any value;
+// variables with nested fields are maps.
+func (c *contextTypes) set(path []string, typ string) {
+	path = variablePath(path)
+	name := path[0]
+	current, ok := c.types[name]
+	if !ok {
+		c.order = append(c.order, name)
+		c.types[name] = ""
+	}
+	switch {
+	case len(path) > 1 || current == structType:
+		c.types[name] = structType
+	case c.conflicts[name], typ == "", typ == current:
+	case current == "":
+		c.types[name] = typ
+	default:
+		c.types[name] = anyType
+		c.conflicts[name] = true
+	}
+}
+
+// typeOf returns the Go type of a variable.
+func (c *contextTypes) typeOf(name string) string {
+	if typ := c.types[name]; typ != "" {
+		return typ
+	}
+	return anyType
+}
+
+// mark infers the type of e and records that it is used as a value of type typ.
+func (c *contextTypes) mark(e semantics.Expr, typ string) {
+	c.infer(e)
+	if id, ok := e.(*semantics.Ident); ok {
+		c.set(id.Path, typ)
+	}
+}
+
+// infer returns the type of e, recording the variables it uses. It returns the
+// empty string if the type is unknown.
+func (c *contextTypes) infer(e semantics.Expr) string {
+	switch e := e.(type) {
+	case *semantics.Literal:
+		switch e.Value.GetKind().(type) {
+		case *structpb.Value_NumberValue:
+			return numberType
+		case *structpb.Value_StringValue:
+			return stringType
+		case *structpb.Value_BoolValue:
+			return boolType
+		}
+	case *semantics.Ident:
+		c.set(e.Path, "")
+		path := variablePath(e.Path)
+		if typ := c.types[path[0]]; len(path) == 1 && typ != anyType {
+			return typ
+		}
+	case *semantics.Unary:
+		if e.Op == "!" {
+			c.mark(e.X, boolType)
+			return boolType
+		}
+		c.mark(e.X, numberType)
+		return numberType
+	case *semantics.Binary:
+		x, y := c.infer(e.X), c.infer(e.Y)
+		switch e.Op {
+		case "&&", "||":
+			c.mark(e.X, boolType)
+			c.mark(e.Y, boolType)
+			return boolType
+		case "==", "!=":
+			if x != "" {
+				c.mark(e.Y, x)
+			}
+			if y != "" {
+				c.mark(e.X, y)
+			}
+			return boolType
+		case "<", "<=", ">", ">=", "+":
+			typ := numberType
+			if x == stringType || y == stringType {
+				typ = stringType
+			}
+			c.mark(e.X, typ)
+			c.mark(e.Y, typ)
+			if e.Op == "+" {
+				return typ
+			}
+			return boolType
+		}
+		c.mark(e.X, numberType)
+		c.mark(e.Y, numberType)
+		return numberType
+	case *semantics.Call:
+		for _, arg := range e.Args {
+			c.infer(arg)
+		}
+	}
+	return ""
+}
+
+// callsIn reports whether e calls in().
+func callsIn(e semantics.Expr) bool {
+	switch e := e.(type) {
+	case *semantics.Unary:
+		return callsIn(e.X)
+	case *semantics.Binary:
+		return callsIn(e.X) || callsIn(e.Y)
+	case *semantics.Call:
+		return e.Func == "in"
+	}
+	return false
+}
+
+// variablePath strips the optional "context." prefix from a variable path.
+func variablePath(path []string) []string {
+	if len(path) > 1 && path[0] == "context" {
+		return path[1:]
+	}
+	return path
+}
@@ -0,0 +1,207 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TestGenerateGoCounter checks that the generated package in internal/counter,
+// whose behavior is tested there, is up to date.
+func TestGenerateGoCounter(t *testing.T) {
+	dir := filepath.Join("internal", "counter")
+	data, err := os.ReadFile(filepath.Join(dir, "counter.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart := &sc.Statechart{}
+	if err := protojson.Unmarshal(data, chart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GenerateGo(chart, GoOptions{Package: "counter", Source: "counter.json"})
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "counter.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("generated code is out of date; run go generate ./codegen/... (-want +got):\n%s", diff)
+	}
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	valid := &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+	}
+	tests := []struct {
+		name    string
+		chart   *sc.Statechart
+		pkg     string
+		wantErr string
+	}{
+		{"invalid package", valid, "my-package", "invalid package name"},
+		{"invalid chart", &sc.Statechart{RootState: &sc.State{Children: []*sc.State{{Label: "A"}, {Label: "A"}}}}, "p", "duplicate"},
+		{"state names collide", &sc.Statechart{
+			RootState: &sc.State{Children: []*sc.State{{Label: "card entered", IsInitial: true}, {Label: "Card Entered"}}},
+		}, "p", `states "card entered" and "Card Entered" have the same Go name StateCardEntered`},
+		{"event names collide", &sc.Statechart{
+			RootState: valid.RootState,
+			Events:    []*sc.Event{{Label: "TURN_ON"}, {Label: "TurnOn"}},
+		}, "p", "same Go name EventTurnOn"},
+		{"in guard", &sc.Statechart{
+			RootState:   valid.RootState,
+			Transitions: []*sc.Transition{{Label: "t", From: []string{"A"}, Event: "E", Guard: &sc.Guard{Expression: "ready && in(A)"}}},
+		}, "p", "tests the configuration with in()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateGo(tt.chart, GoOptions{Package: tt.pkg})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GenerateGo() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateGoEventMethods(t *testing.T) {
+	chart := &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+		Events:    []*sc.Event{{Label: "send"}, {Label: "turn on"}, {Label: "42"}},
+	}
+	src, err := GenerateGo(chart, GoOptions{Package: "p"})
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"func (m *Machine) SendEvent(ctx context.Context) error",
+		"func (m *Machine) TurnOn(ctx context.Context) error",
+		"func (m *Machine) Event42(ctx context.Context) error",
+		"EventSend   Event = \"send\"",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateGoElseGuard(t *testing.T) {
+	chart := &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "A", IsInitial: true},
+			{Label: "C", Type: sc.StateTypeChoice},
+			{Label: "B"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "go", From: []string{"A"}, To: []string{"C"}, Event: "GO"},
+			{Label: "ready", From: []string{"C"}, To: []string{"B"}, Guard: &sc.Guard{Expression: "ready"}},
+			{Label: "otherwise", From: []string{"C"}, To: []string{"A"}, Guard: &sc.Guard{Expression: "else"}},
+		},
+	}
+	src, err := GenerateGo(chart, GoOptions{Package: "p"})
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	if !strings.Contains(string(src), "Ready(ctx context.Context, c *Context) (bool, error)") {
+		t.Errorf("generated code does not implement the guard ready:\n%s", src)
+	}
+	if strings.Contains(string(src), `case "else":`) {
+		t.Errorf("generated code implements the else guard:\n%s", src)
+	}
+}
+
+func TestGenerateGoContextCopy(t *testing.T) {
+	chart := &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+		Transitions: []*sc.Transition{
+			{From: []string{"A"}, To: []string{"A"}, Event: "E", Guard: &sc.Guard{Expression: "context.order.total > 10 && x"}, Actions: []*sc.Action{{Label: "x = 1"}, {Label: "n = 2"}}},
+		},
+	}
+	src, err := GenerateGo(chart, GoOptions{Package: "p"})
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"c := *m.context",
+		"c.Order, _ = cloneValue(c.Order).(map[string]interface{})",
+		"c.X = cloneValue(c.X)",
+		"func cloneValue(v interface{}) interface{}",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(string(src), "cloneValue(c.N)") {
+		t.Errorf("generated code deep copies a number field:\n%s", src)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		label, want string
+	}{
+		{"TURN_ON", "TurnOn"},
+		{"turnOn", "TurnOn"},
+		{"Card Entered", "CardEntered"},
+		{"count < limit", "CountLessThanLimit"},
+		{"count<=limit", "CountAtMostLimit"},
+		{"!(a && b)", "NotAAndB"},
+		{"count = count + 1", "CountGetsCountPlus1"},
+		{"context.order.total >= 10", "ContextOrderTotalAtLeast10"},
+		{"status == 'open'", "StatusIsOpen"},
+		{"ID", "Id"},
+	}
+	for _, tt := range tests {
+		if got := identifier(tt.label); got != tt.want {
+			t.Errorf("identifier(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestContextTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		guards  []string
+		actions []string
+		want    map[string]string
+	}{
+		{"numbers", []string{"count < limit"}, []string{"count = count + 1"}, map[string]string{"count": "float64", "limit": "float64"}},
+		{"strings", []string{"status == 'open'"}, []string{"name = first + ' ' + last"}, map[string]string{"status": "string", "name": "string", "first": "string", "last": "string"}},
+		{"booleans", []string{"!locked && ready"}, []string{"locked = true"}, map[string]string{"locked": "bool", "ready": "bool"}},
+		{"propagated", nil, []string{"copy = original", "original = 1"}, map[string]string{"copy": "float64", "original": "float64"}},
+		{"conflicting", []string{"x"}, []string{"x = 1"}, map[string]string{"x": "interface{}"}},
+		{"unknown", []string{"a == b"}, nil, map[string]string{"a": "interface{}", "b": "interface{}"}},
+		{"nested", []string{"context.order.total > 10"}, nil, map[string]string{"order": "map[string]interface{}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := &sc.Statechart{
+				RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+			}
+			for _, guard := range tt.guards {
+				chart.Transitions = append(chart.Transitions, &sc.Transition{From: []string{"A"}, To: []string{"A"}, Event: "E", Guard: &sc.Guard{Expression: guard}})
+			}
+			for _, action := range tt.actions {
+				chart.Transitions = append(chart.Transitions, &sc.Transition{From: []string{"A"}, To: []string{"A"}, Event: "E", Actions: []*sc.Action{{Label: action}}})
+			}
+			g := &goGenerator{chart: semantics.NewStatechart(chart).Statechart}
+			if err := g.collect(); err != nil {
+				t.Fatalf("collect() error = %v", err)
+			}
+			got := make(map[string]string)
+			for _, f := range g.fields {
+				got[f.label] = f.typ
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("context types mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
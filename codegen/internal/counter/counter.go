@@ -0,0 +1,289 @@
+// Code generated by sc generate go from counter.json. DO NOT EDIT.
+
+package counter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// State is a state of the statechart.
+type State string
+
+// States of the statechart.
+const (
+	StateCounting State = "Counting"
+	StateFull     State = "Full"
+	StateDone     State = "Done"
+)
+
+// Event is an event of the statechart.
+type Event string
+
+// Events of the statechart.
+const (
+	EventInc    Event = "INC"
+	EventReset  Event = "RESET"
+	EventFinish Event = "FINISH"
+)
+
+// Context is the context of a machine, shared by its guards and actions.
+type Context struct {
+	Count float64 `json:"count"`
+	Limit float64 `json:"limit"`
+	Keep  bool    `json:"keep"`
+}
+
+// Guards evaluates the guards of the statechart.
+type Guards interface {
+	// CountLessThanLimit evaluates the guard "count < limit".
+	CountLessThanLimit(ctx context.Context, c *Context) (bool, error)
+	// NotKeep evaluates the guard "!keep".
+	NotKeep(ctx context.Context, c *Context) (bool, error)
+}
+
+// Actions executes the actions of the statechart.
+type Actions interface {
+	// CountGetsCountPlus1 executes the action "count = count + 1".
+	CountGetsCountPlus1(ctx context.Context, c *Context) error
+	// CountGets0 executes the action "count = 0".
+	CountGets0(ctx context.Context, c *Context) error
+}
+
+// Machine is a machine of the statechart. It is safe for concurrent use.
+type Machine struct {
+	guards  Guards
+	actions Actions
+
+	mu      sync.Mutex
+	context *Context
+	machine *sc.Machine
+}
+
+// New creates a machine in the initial configuration of the statechart and
+// takes the eventless transitions enabled in it. A nil context starts the
+// machine with a zero Context.
+func New(ctx context.Context, c *Context, guards Guards, actions Actions) (*Machine, error) {
+	if c == nil {
+		c = &Context{}
+	}
+	m := &Machine{guards: guards, actions: actions, context: c}
+	machine, err := m.engine(ctx).NewMachine("counter", chart, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.machine = machine
+	return m, nil
+}
+
+// Send sends an event to the machine. If a guard or action fails, the
+// configuration is left unchanged; changes that actions made to the
+// context are not undone.
+func (m *Machine) Send(ctx context.Context, event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.engine(ctx).Step(m.machine, string(event))
+	return err
+}
+
+// Inc sends the INC event.
+func (m *Machine) Inc(ctx context.Context) error {
+	return m.Send(ctx, EventInc)
+}
+
+// Reset sends the RESET event.
+func (m *Machine) Reset(ctx context.Context) error {
+	return m.Send(ctx, EventReset)
+}
+
+// Finish sends the FINISH event.
+func (m *Machine) Finish(ctx context.Context) error {
+	return m.Send(ctx, EventFinish)
+}
+
+// Configuration returns the active states in document order.
+func (m *Machine) Configuration() []State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var states []State
+	for _, ref := range m.machine.GetConfiguration().GetStates() {
+		if ref.GetLabel() != semantics.RootState.String() {
+			states = append(states, State(ref.GetLabel()))
+		}
+	}
+	return states
+}
+
+// In reports whether a state is active.
+func (m *Machine) In(state State) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ref := range m.machine.GetConfiguration().GetStates() {
+		if ref.GetLabel() == string(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// Done reports whether the machine has reached a final configuration.
+func (m *Machine) Done() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.machine.GetState() == sc.MachineStateStopped
+}
+
+// Context returns a copy of the context of the machine.
+func (m *Machine) Context() *Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *m.context
+	return &c
+}
+
+// Snapshot returns a copy of the underlying machine, including its step history.
+func (m *Machine) Snapshot() *sc.Machine {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return proto.Clone(m.machine).(*sc.Machine)
+}
+
+// engine returns an engine that evaluates guards and executes actions with
+// the implementations of the machine.
+func (m *Machine) engine(ctx context.Context) *semantics.Engine {
+	return &semantics.Engine{
+		EvaluateGuard: func(guard *sc.Guard, _ *structpb.Struct) (bool, error) {
+			switch guard.GetExpression() {
+			case "":
+				return true, nil
+			case "count < limit":
+				return m.guards.CountLessThanLimit(ctx, m.context)
+			case "!keep":
+				return m.guards.NotKeep(ctx, m.context)
+			}
+			return false, fmt.Errorf("unknown guard %q", guard.GetExpression())
+		},
+		ExecuteAction: func(action *sc.Action, _ *structpb.Struct) error {
+			switch action.GetLabel() {
+			case "count = count + 1":
+				return m.actions.CountGetsCountPlus1(ctx, m.context)
+			case "count = 0":
+				return m.actions.CountGets0(ctx, m.context)
+			}
+			return fmt.Errorf("unknown action %q", action.GetLabel())
+		},
+	}
+}
+
+// Statechart returns a copy of the statechart.
+func Statechart() *sc.Statechart {
+	return proto.Clone(chart.Statechart).(*sc.Statechart)
+}
+
+// chart is the statechart the machines execute.
+var chart = func() *semantics.Statechart {
+	c := &sc.Statechart{}
+	if err := protojson.Unmarshal([]byte(chartJSON), c); err != nil {
+		panic(err)
+	}
+	return semantics.NewStatechart(c)
+}()
+
+const chartJSON = `{
+  "rootState": {
+    "label": "__root__",
+    "children": [
+      {
+        "label": "Counting",
+        "isInitial": true
+      },
+      {
+        "label": "Full"
+      },
+      {
+        "label": "Done",
+        "isFinal": true
+      }
+    ]
+  },
+  "transitions": [
+    {
+      "label": "inc",
+      "from": [
+        "Counting"
+      ],
+      "to": [
+        "Counting"
+      ],
+      "event": "INC",
+      "guard": {
+        "expression": "count < limit"
+      },
+      "actions": [
+        {
+          "label": "count = count + 1"
+        }
+      ]
+    },
+    {
+      "label": "full",
+      "from": [
+        "Counting"
+      ],
+      "to": [
+        "Full"
+      ],
+      "event": "INC"
+    },
+    {
+      "label": "reset",
+      "from": [
+        "Counting"
+      ],
+      "to": [
+        "Counting"
+      ],
+      "event": "RESET",
+      "actions": [
+        {
+          "label": "count = 0"
+        }
+      ]
+    },
+    {
+      "label": "reset_full",
+      "from": [
+        "Full"
+      ],
+      "to": [
+        "Counting"
+      ],
+      "event": "RESET",
+      "actions": [
+        {
+          "label": "count = 0"
+        }
+      ]
+    },
+    {
+      "label": "finish",
+      "from": [
+        "Full"
+      ],
+      "to": [
+        "Done"
+      ],
+      "event": "FINISH",
+      "guard": {
+        "expression": "!keep"
+      }
+    }
+  ]
+}`
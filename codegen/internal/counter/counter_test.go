@@ -0,0 +1,104 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type guards struct{}
+
+func (guards) CountLessThanLimit(ctx context.Context, c *Context) (bool, error) {
+	return c.Count < c.Limit, nil
+}
+
+func (guards) NotKeep(ctx context.Context, c *Context) (bool, error) { return !c.Keep, nil }
+
+type actions struct{ fail bool }
+
+func (a actions) CountGetsCountPlus1(ctx context.Context, c *Context) error {
+	if a.fail {
+		return errors.New("failed")
+	}
+	c.Count++
+	return nil
+}
+
+func (actions) CountGets0(ctx context.Context, c *Context) error {
+	c.Count = 0
+	return nil
+}
+
+func TestMachine(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(ctx, &Context{Limit: 2}, guards{}, actions{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := m.Inc(ctx); err != nil {
+			t.Fatalf("Inc() error = %v", err)
+		}
+	}
+	if got := m.Context().Count; got != 2 {
+		t.Errorf("Count = %v, want 2", got)
+	}
+	if diff := cmp.Diff([]State{StateFull}, m.Configuration()); diff != "" {
+		t.Errorf("Configuration() mismatch (-want +got):\n%s", diff)
+	}
+	if err := m.Reset(ctx); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if !m.In(StateCounting) || m.Context().Count != 0 {
+		t.Errorf("after Reset: configuration %v, count %v", m.Configuration(), m.Context().Count)
+	}
+	for _, event := range []Event{EventInc, EventInc, EventInc, EventFinish} {
+		if err := m.Send(ctx, event); err != nil {
+			t.Fatalf("Send(%s) error = %v", event, err)
+		}
+	}
+	if !m.Done() {
+		t.Errorf("Done() = false, configuration %v", m.Configuration())
+	}
+	if got := len(m.Snapshot().GetStepHistory()); got != 8 {
+		t.Errorf("step history has %d steps, want 8", got)
+	}
+}
+
+func TestMachineConcurrentContext(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(ctx, &Context{Limit: 100}, guards{}, actions{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Inc(ctx)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		m.Context().Count++
+	}
+	<-done
+	if got := m.Context().Count; got != 100 {
+		t.Errorf("Count = %v, want 100", got)
+	}
+}
+
+func TestMachineActionError(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(ctx, &Context{Limit: 2}, guards{}, actions{fail: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := m.Inc(ctx); err == nil {
+		t.Error("Inc() error = nil, want the action error")
+	}
+	if !m.In(StateCounting) {
+		t.Errorf("configuration after a failed step = %v", m.Configuration())
+	}
+}
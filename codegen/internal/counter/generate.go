@@ -0,0 +1,4 @@
+// Package counter is a machine generated from counter.json by "sc generate go".
+package counter
+
+//go:generate go run github.com/tmc/sc/cmd/sc generate go -o counter.go counter.json
@@ -0,0 +1,365 @@
+// Package gateway serves the StatechartService and the SemanticValidator as an
+// HTTP/JSON API.
+//
+// Requests and responses are the messages of the services encoded with
+// protojson. Resource IDs and the verbs of custom methods are taken from the
+// path, the remaining fields of GET and DELETE requests from the query, and
+// those of POST requests from the body:
+//
+//	POST   /v1/statecharts                      RegisterStatechart
+//	GET    /v1/statecharts                      ListStatecharts
+//	GET    /v1/statecharts/{statechart}         GetStatechart
+//	POST   /v1/statecharts:validate             ValidateChart
+//	POST   /v1/traces:validate                  ValidateTrace
+//	POST   /v1/machines                         CreateMachine
+//	GET    /v1/machines                         ListMachines
+//	GET    /v1/machines/{machine}               GetMachine
+//	DELETE /v1/machines/{machine}               DeleteMachine
+//	POST   /v1/machines/{machine}:step          Step
+//	POST   /v1/machines/{machine}:batchStep     BatchStep
+//	GET    /v1/openapi.json                     the OpenAPI document of the API
+//
+// Errors are reported with the HTTP status corresponding to their gRPC code
+// and a google.rpc.Status body. The Watch stream is only served over gRPC.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// MaxBodySize is the largest request body the gateway reads.
+const MaxBodySize = 4 << 20
+
+// Validator is the part of a SemanticValidator server the gateway exposes.
+type Validator interface {
+	ValidateChart(context.Context, *validationv1.ValidateChartRequest) (*validationv1.ValidateChartResponse, error)
+	ValidateTrace(context.Context, *validationv1.ValidateTraceRequest) (*validationv1.ValidateTraceResponse, error)
+}
+
+// Gateway is an http.Handler calling a StatechartService and a
+// SemanticValidator for HTTP/JSON requests.
+type Gateway struct {
+	routes  []route
+	openAPI []byte
+}
+
+// route maps requests with an HTTP method and a path to a method of a service.
+type route struct {
+	method string
+	path   string // Path template; segments of the form {param} or {param}:verb match an ID.
+	rpc    protoreflect.MethodDescriptor
+	body   bool                                    // Whether the request is read from the body rather than the query.
+	params map[string]protoreflect.FieldDescriptor // The request fields the path parameters set.
+	new    func() protoreflect.Message             // Returns a new request.
+	call   func(context.Context, proto.Message) (proto.Message, error)
+}
+
+// New creates a gateway for a StatechartService and a SemanticValidator.
+func New(service pb.StatechartServiceServer, validator Validator) *Gateway {
+	g := &Gateway{routes: []route{
+		newRoute("POST", "/v1/statecharts", true, service.RegisterStatechart),
+		newRoute("GET", "/v1/statecharts", false, service.ListStatecharts),
+		newRoute("GET", "/v1/statecharts/{statechart}", false, service.GetStatechart),
+		newRoute("POST", "/v1/statecharts:validate", true, validator.ValidateChart),
+		newRoute("POST", "/v1/traces:validate", true, validator.ValidateTrace),
+		newRoute("POST", "/v1/machines", true, service.CreateMachine),
+		newRoute("GET", "/v1/machines", false, service.ListMachines),
+		newRoute("GET", "/v1/machines/{machine}", false, service.GetMachine),
+		newRoute("DELETE", "/v1/machines/{machine}", false, service.DeleteMachine),
+		newRoute("POST", "/v1/machines/{machine}:step", true, service.Step),
+		newRoute("POST", "/v1/machines/{machine}:batchStep", true, service.BatchStep),
+	}}
+	g.openAPI = g.document()
+	return g
+}
+
+// newRoute returns a route to the method of a service taking Req. A path
+// parameter {p} sets the field p_id of the request if it has one, and
+// otherwise its name field to the resource name ps/{p}.
+func newRoute[Req, Resp proto.Message](method, path string, body bool, call func(context.Context, Req) (Resp, error)) route {
+	var zero Req
+	input := zero.ProtoReflect().Descriptor()
+	rt := route{
+		method: method,
+		path:   path,
+		rpc:    methodOf(input),
+		body:   body,
+		params: make(map[string]protoreflect.FieldDescriptor),
+		new:    zero.ProtoReflect().New,
+		call: func(ctx context.Context, req proto.Message) (proto.Message, error) {
+			return call(ctx, req.(Req))
+		},
+	}
+	for _, param := range pathParams(path) {
+		fd := input.Fields().ByName(protoreflect.Name(param + "_id"))
+		if fd == nil {
+			fd = input.Fields().ByName("name")
+		}
+		if fd == nil || fd.Kind() != protoreflect.StringKind {
+			panic(fmt.Sprintf("gateway: %s has no field for path parameter %q", input.FullName(), param))
+		}
+		rt.params[param] = fd
+	}
+	return rt
+}
+
+// methodOf returns the method of the services of the file of a request
+// message that takes it.
+func methodOf(input protoreflect.MessageDescriptor) protoreflect.MethodDescriptor {
+	services := input.ParentFile().Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			if methods.Get(j).Input() == input {
+				return methods.Get(j)
+			}
+		}
+	}
+	panic(fmt.Sprintf("gateway: no method takes %s", input.FullName()))
+}
+
+// pathParams returns the names of the parameters of a path template.
+func pathParams(template string) []string {
+	var params []string
+	for _, seg := range strings.Split(template, "/") {
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			name, _, _ = strings.Cut(name, "}")
+			params = append(params, name)
+		}
+	}
+	return params
+}
+
+// serve calls the method of a route for a request with the given path parameters.
+func (rt *route) serve(r *http.Request, params map[string]string) (proto.Message, error) {
+	req := rt.new()
+	if rt.body {
+		if err := readBody(r, req.Interface()); err != nil {
+			return nil, err
+		}
+	} else if err := setQuery(req, r.URL.Query()); err != nil {
+		return nil, err
+	}
+	for param, id := range params {
+		fd := rt.params[param]
+		if fd.Name() == "name" {
+			id = param + "s/" + id
+		}
+		req.Set(fd, protoreflect.ValueOfString(id))
+	}
+	return rt.call(r.Context(), req.Interface())
+}
+
+// ServeHTTP serves a request of the HTTP/JSON API.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/openapi.json" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, status.Newf(codes.Unimplemented, "method %s not allowed", r.Method))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(g.openAPI)
+		return
+	}
+	var allowed []string
+	for _, rt := range g.routes {
+		params, ok := match(rt.path, r.URL.Path)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = append(allowed, rt.method)
+			continue
+		}
+		resp, err := rt.serve(r, params)
+		if err != nil {
+			st := status.Convert(err)
+			writeError(w, HTTPStatus(st.Code()), st)
+			return
+		}
+		b, err := protojson.Marshal(resp)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, status.Newf(codes.Internal, "encoding response: %v", err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, status.Newf(codes.Unimplemented, "method %s not allowed", r.Method))
+		return
+	}
+	writeError(w, http.StatusNotFound, status.Newf(codes.NotFound, "no route for %s", r.URL.Path))
+}
+
+// match matches a path against a path template and returns its parameters.
+func match(template, path string) (map[string]string, bool) {
+	want, got := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range want {
+		name, ok := strings.CutPrefix(seg, "{")
+		if !ok {
+			if seg != got[i] {
+				return nil, false
+			}
+			continue
+		}
+		name, verb, _ := strings.Cut(name, "}")
+		id, ok := strings.CutSuffix(got[i], verb)
+		if !ok || id == "" || verb == "" && strings.Contains(id, ":") {
+			return nil, false
+		}
+		params[name] = id
+	}
+	return params, true
+}
+
+// readBody decodes the body of a request into a message. An empty body
+// leaves the message unchanged.
+func readBody(r *http.Request, m proto.Message) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	switch {
+	case err != nil:
+		return status.Errorf(codes.InvalidArgument, "reading body: %v", err)
+	case len(b) > MaxBodySize:
+		return status.Errorf(codes.InvalidArgument, "body exceeds %d bytes", MaxBodySize)
+	case len(b) == 0:
+		return nil
+	}
+	if err := protojson.Unmarshal(b, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "decoding body: %v", err)
+	}
+	return nil
+}
+
+// setQuery sets the fields of a message named by the parameters of a query.
+// Only fields of scalar and enum types, and repeated fields of those, can be
+// set.
+func setQuery(m protoreflect.Message, query map[string][]string) error {
+	fields := m.Descriptor().Fields()
+	for name, values := range query {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			return status.Errorf(codes.InvalidArgument, "unknown query parameter %q", name)
+		}
+		if !fd.IsList() && len(values) > 1 {
+			return status.Errorf(codes.InvalidArgument, "query parameter %q given %d times", name, len(values))
+		}
+		for _, s := range values {
+			v, err := parseValue(fd, s)
+			if err != nil {
+				return status.Errorf(codes.InvalidArgument, "query parameter %q: %v", name, err)
+			}
+			if fd.IsList() {
+				m.Mutable(fd).List().Append(v)
+			} else {
+				m.Set(fd, v)
+			}
+		}
+	}
+	return nil
+}
+
+// parseValue parses the value of a scalar or enum field.
+func parseValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.EnumKind:
+		if v := fd.Enum().Values().ByName(protoreflect.Name(s)); v != nil {
+			return protoreflect.ValueOfEnum(v.Number()), nil
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a value of %s", s, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %v", fd.Kind())
+}
+
+// writeError writes an error response with a google.rpc.Status body. Details
+// of types the gateway cannot encode are dropped.
+func writeError(w http.ResponseWriter, code int, st *status.Status) {
+	b, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		b, _ = protojson.Marshal(status.New(st.Code(), st.Message()).Proto())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// HTTPStatus returns the HTTP status corresponding to a gRPC code.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
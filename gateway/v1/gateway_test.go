@@ -0,0 +1,219 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/service/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+// light is a statechart of a light that counts how often it is switched on.
+const light = `{
+	"rootState": {"children": [
+		{"label": "Off", "isInitial": true},
+		{"label": "On"}
+	]},
+	"transitions": [
+		{"label": "on", "from": ["Off"], "to": ["On"], "event": "TOGGLE", "actions": [{"label": "count = count + 1"}]},
+		{"label": "off", "from": ["On"], "to": ["Off"], "event": "TOGGLE"}
+	]
+}`
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(New(service.NewStatechartService(nil), validation.NewSemanticValidator()))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends a request and decodes the response into resp, or into a
+// google.rpc.Status if the request fails. It returns the HTTP status.
+func do(t *testing.T, srv *httptest.Server, method, path, body string, resp proto.Message) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, path, err)
+	}
+	defer r.Body.Close()
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("%s %s Content-Type = %q, want application/json", method, path, got)
+	}
+	if _, ok := resp.(*spb.Status); !ok && r.StatusCode != http.StatusOK {
+		resp = &spb.Status{}
+	}
+	if err := protojson.Unmarshal(b, resp); err != nil {
+		t.Fatalf("%s %s returned %d with body %s: %v", method, path, r.StatusCode, b, err)
+	}
+	return r.StatusCode
+}
+
+// must is do for requests that must succeed.
+func must(t *testing.T, srv *httptest.Server, method, path, body string, resp proto.Message) {
+	t.Helper()
+	if code := do(t, srv, method, path, body, resp); code != http.StatusOK {
+		t.Fatalf("%s %s status = %d, want %d", method, path, code, http.StatusOK)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	srv := newServer(t)
+
+	registered := &pb.RegisteredStatechart{}
+	must(t, srv, "POST", "/v1/statecharts", `{"statechartId": "light", "statechart": `+light+`}`, registered)
+	if registered.Name != "statecharts/light" {
+		t.Errorf("registered name = %q, want statecharts/light", registered.Name)
+	}
+	must(t, srv, "GET", "/v1/statecharts/light", "", registered)
+	if got := len(registered.GetStatechart().GetTransitions()); got != 2 {
+		t.Errorf("got statechart with %d transitions, want 2", got)
+	}
+	statecharts := &pb.ListStatechartsResponse{}
+	must(t, srv, "GET", "/v1/statecharts?page_size=10", "", statecharts)
+	if len(statecharts.Statecharts) != 1 {
+		t.Errorf("listed %d statecharts, want 1", len(statecharts.Statecharts))
+	}
+
+	created := &pb.CreateMachineResponse{}
+	must(t, srv, "POST", "/v1/machines", `{"statechartId": "light", "machineId": "kitchen", "context": {"count": 0}}`, created)
+	stepped := &pb.StepResponse{}
+	must(t, srv, "POST", "/v1/machines/kitchen:step", `{"event": "TOGGLE", "expectedStepIndex": "0"}`, stepped)
+	if got := stepped.Machine.Context.AsMap()["count"]; got != 1.0 {
+		t.Errorf("count after step = %v, want 1", got)
+	}
+	batch := &pb.BatchStepResponse{}
+	must(t, srv, "POST", "/v1/machines/kitchen:batchStep", `{"events": ["TOGGLE", "TOGGLE"]}`, batch)
+	if len(batch.Steps) != 2 || codes.Code(batch.Result.Code) != codes.OK {
+		t.Errorf("BatchStep returned %d steps with result %v, want 2 and OK", len(batch.Steps), batch.Result)
+	}
+
+	machine := &sc.Machine{}
+	must(t, srv, "GET", "/v1/machines/kitchen", "", machine)
+	if machine.Id != "kitchen" || len(machine.StepHistory) != 3 {
+		t.Errorf("got machine %q with %d steps, want kitchen with 3", machine.Id, len(machine.StepHistory))
+	}
+	machines := &pb.ListMachinesResponse{}
+	must(t, srv, "GET", "/v1/machines?filter=configuration:On", "", machines)
+	if len(machines.Machines) != 1 {
+		t.Errorf("listed %d machines in On, want 1", len(machines.Machines))
+	}
+	must(t, srv, "GET", "/v1/machines?filter=configuration:Off", "", machines)
+	if len(machines.Machines) != 0 {
+		t.Errorf("listed %d machines in Off, want 0", len(machines.Machines))
+	}
+	must(t, srv, "DELETE", "/v1/machines/kitchen", "", &emptypb.Empty{})
+	if code := do(t, srv, "GET", "/v1/machines/kitchen", "", machine); code != http.StatusNotFound {
+		t.Errorf("GET of a deleted machine status = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestGatewayValidate(t *testing.T) {
+	srv := newServer(t)
+	resp := &validationv1.ValidateChartResponse{}
+	must(t, srv, "POST", "/v1/statecharts:validate", `{"chart": {"rootState": {"children": [{"label": "A"}, {"label": "A"}]}}}`, resp)
+	var rules []string
+	for _, v := range resp.Violations {
+		rules = append(rules, v.Rule.String())
+	}
+	if codes.Code(resp.Status.Code) != codes.FailedPrecondition || !strings.Contains(strings.Join(rules, " "), "UNIQUE_STATE_LABELS") {
+		t.Errorf("ValidateChart returned %v with violations of %v, want FailedPrecondition and UNIQUE_STATE_LABELS", resp.Status, rules)
+	}
+
+	// Registration rejects the chart with the violations in the details of the error.
+	st := &spb.Status{}
+	if code := do(t, srv, "POST", "/v1/statecharts", `{"statechartId": "twins", "statechart": {"rootState": {"children": [{"label": "A"}, {"label": "A"}]}}}`, st); code != http.StatusBadRequest {
+		t.Errorf("POST of an invalid statechart status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if len(st.Details) != 1 || !strings.HasSuffix(st.Details[0].TypeUrl, "google.rpc.BadRequest") {
+		t.Errorf("error details = %v, want a google.rpc.BadRequest", st.Details)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	srv := newServer(t)
+	tests := []struct {
+		method, path, body string
+		want               int
+		code               codes.Code
+	}{
+		{"GET", "/v1/nothing", "", http.StatusNotFound, codes.NotFound},
+		{"PUT", "/v1/machines", "", http.StatusMethodNotAllowed, codes.Unimplemented},
+		{"POST", "/v1/machines", "{", http.StatusBadRequest, codes.InvalidArgument},
+		{"POST", "/v1/machines", `{"unknown": 1}`, http.StatusBadRequest, codes.InvalidArgument},
+		{"POST", "/v1/machines", `{"statechartId": "missing"}`, http.StatusNotFound, codes.NotFound},
+		{"GET", "/v1/machines?page_size=many", "", http.StatusBadRequest, codes.InvalidArgument},
+		{"GET", "/v1/machines?unknown=1", "", http.StatusBadRequest, codes.InvalidArgument},
+		{"GET", "/v1/machines?filter=color=red", "", http.StatusBadRequest, codes.InvalidArgument},
+		{"POST", "/v1/machines/missing:step", `{"event": "GO"}`, http.StatusNotFound, codes.NotFound},
+		{"POST", "/v1/machines/missing:jump", "", http.StatusNotFound, codes.NotFound},
+		{"POST", "/v1/statecharts", `{"statechartId": "empty"}`, http.StatusBadRequest, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		st := &spb.Status{}
+		if got := do(t, srv, tt.method, tt.path, tt.body, st); got != tt.want || codes.Code(st.Code) != tt.code {
+			t.Errorf("%s %s = %d %v, want %d %v", tt.method, tt.path, got, codes.Code(st.Code), tt.want, tt.code)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.Aborted:            http.StatusConflict,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Code(99):           http.StatusInternalServerError,
+		codes.FailedPrecondition: http.StatusBadRequest,
+	}
+	for code, want := range tests {
+		if got := HTTPStatus(code); got != want {
+			t.Errorf("HTTPStatus(%v) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		template, path string
+		want           map[string]string
+	}{
+		{"/v1/machines", "/v1/machines", map[string]string{}},
+		{"/v1/machines/{machine}", "/v1/machines/kitchen", map[string]string{"machine": "kitchen"}},
+		{"/v1/machines/{machine}", "/v1/machines/kitchen:step", nil},
+		{"/v1/machines/{machine}", "/v1/machines/", nil},
+		{"/v1/machines/{machine}:step", "/v1/machines/kitchen:step", map[string]string{"machine": "kitchen"}},
+		{"/v1/machines/{machine}:step", "/v1/machines/:step", nil},
+		{"/v1/machines/{machine}:step", "/v1/machines/kitchen:batchStep", nil},
+		{"/v1/statecharts:validate", "/v1/statecharts", nil},
+	}
+	for _, tt := range tests {
+		got, ok := match(tt.template, tt.path)
+		if ok != (tt.want != nil) || ok && len(got) != len(tt.want) || got["machine"] != tt.want["machine"] {
+			t.Errorf("match(%q, %q) = %v, %v, want %v", tt.template, tt.path, got, ok, tt.want)
+		}
+	}
+}
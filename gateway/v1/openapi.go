@@ -0,0 +1,219 @@
+package gateway
+
+import (
+	"encoding/json"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// document is an OpenAPI 3 document.
+type document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       info                             `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type components struct {
+	Schemas map[string]*schema `json:"schemas"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Tags        []string             `json:"tags"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is an OpenAPI schema object. The empty schema allows any value.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Deprecated           bool               `json:"deprecated,omitempty"`
+}
+
+// OpenAPI returns the OpenAPI 3 document of the HTTP/JSON API. Its schemas
+// are derived from the descriptors of the messages of the services, with the
+// property names and value encodings of protojson.
+func (g *Gateway) OpenAPI() []byte {
+	return append([]byte(nil), g.openAPI...)
+}
+
+// document returns the OpenAPI document of the routes.
+func (g *Gateway) document() []byte {
+	doc := &document{
+		OpenAPI:    "3.0.3",
+		Info:       info{Title: "Statecharts API", Version: "v1"},
+		Paths:      make(map[string]map[string]*operation),
+		Components: components{Schemas: make(map[string]*schema)},
+	}
+	errorStatus := (*spb.Status)(nil).ProtoReflect().Descriptor()
+	for _, rt := range g.routes {
+		op := &operation{
+			OperationID: string(rt.rpc.Name()),
+			Tags:        []string{string(rt.rpc.Parent().Name())},
+			Responses: map[string]*response{
+				"200":     {Description: "The response of the method.", Content: doc.content(rt.rpc.Output())},
+				"default": {Description: "An error, with the HTTP status corresponding to its code.", Content: doc.content(errorStatus)},
+			},
+		}
+		for _, param := range pathParams(rt.path) {
+			op.Parameters = append(op.Parameters, &parameter{Name: param, In: "path", Required: true, Schema: &schema{Type: "string"}})
+		}
+		if rt.body {
+			op.RequestBody = &requestBody{Required: true, Content: doc.content(rt.rpc.Input())}
+		} else {
+			fields := rt.rpc.Input().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				if fd := fields.Get(i); !rt.binds(fd) && !fd.IsMap() && fd.Message() == nil {
+					op.Parameters = append(op.Parameters, &parameter{Name: string(fd.Name()), In: "query", Schema: doc.field(fd)})
+				}
+			}
+		}
+		if doc.Paths[rt.path] == nil {
+			doc.Paths[rt.path] = make(map[string]*operation)
+		}
+		doc.Paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append(b, '\n')
+}
+
+// binds reports whether a path parameter of the route sets a field.
+func (rt *route) binds(fd protoreflect.FieldDescriptor) bool {
+	for _, bound := range rt.params {
+		if bound == fd {
+			return true
+		}
+	}
+	return false
+}
+
+// content returns the JSON content of a message.
+func (doc *document) content(md protoreflect.MessageDescriptor) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: doc.message(md)}}
+}
+
+// message returns the schema of a message: a reference to its component,
+// which is added to the document if needed, or the schema of the JSON
+// encoding of a well-known type.
+func (doc *document) message(md protoreflect.MessageDescriptor) *schema {
+	switch md.FullName() {
+	case "google.protobuf.Struct", "google.protobuf.Empty":
+		return &schema{Type: "object"}
+	case "google.protobuf.Value":
+		return &schema{}
+	case "google.protobuf.ListValue":
+		return &schema{Type: "array", Items: &schema{}}
+	case "google.protobuf.Any":
+		return &schema{Type: "object", Properties: map[string]*schema{"@type": {Type: "string"}}, AdditionalProperties: &schema{}}
+	case "google.protobuf.Timestamp":
+		return &schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration":
+		return &schema{Type: "string"}
+	}
+	name := string(md.FullName())
+	ref := &schema{Ref: "#/components/schemas/" + name}
+	if _, ok := doc.Components.Schemas[name]; ok {
+		return ref
+	}
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	doc.Components.Schemas[name] = s // Added before the fields, for recursive messages.
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		s.Properties[fd.JSONName()] = doc.field(fd)
+	}
+	return ref
+}
+
+// field returns the schema of the protojson encoding of a field.
+func (doc *document) field(fd protoreflect.FieldDescriptor) *schema {
+	var s *schema
+	switch {
+	case fd.IsMap():
+		s = &schema{Type: "object", AdditionalProperties: doc.value(fd.MapValue())}
+	case fd.IsList():
+		s = &schema{Type: "array", Items: doc.value(fd)}
+	default:
+		s = doc.value(fd)
+	}
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDeprecated() {
+		if s.Ref != "" {
+			s = &schema{AllOf: []*schema{s}}
+		}
+		s.Deprecated = true
+	}
+	return s
+}
+
+// value returns the schema of a single value of a field.
+func (doc *document) value(fd protoreflect.FieldDescriptor) *schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &schema{Type: "boolean"}
+	case protoreflect.StringKind:
+		return &schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &schema{Type: "string", Format: "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &schema{Type: "integer", Format: "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &schema{Type: "string", Format: "int64"} // protojson encodes 64-bit integers as strings.
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &schema{Type: "number", Format: "double"}
+	case protoreflect.EnumKind:
+		s := &schema{Type: "string"}
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			s.Enum = append(s.Enum, string(values.Get(i).Name()))
+		}
+		return s
+	}
+	return doc.message(fd.Message())
+}
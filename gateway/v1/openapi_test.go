@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/tmc/sc/service/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+var update = flag.Bool("update", false, "update the OpenAPI document in docs")
+
+// TestOpenAPI checks the published OpenAPI document against the one the
+// gateway serves.
+func TestOpenAPI(t *testing.T) {
+	g := New(service.NewStatechartService(nil), validation.NewSemanticValidator())
+	path := filepath.Join("..", "..", "docs", "gateway", "v1", "openapi.json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, g.OpenAPI(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if diff := cmp.Diff(string(want), string(g.OpenAPI())); diff != "" {
+		t.Errorf("%s is out of date; run go test -update (-want +got):\n%s", path, diff)
+	}
+
+	srv := newServer(t)
+	r, err := srv.Client().Get(srv.URL + "/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	served, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served, want) {
+		t.Error("served OpenAPI document differs from the published one")
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	g := New(service.NewStatechartService(nil), validation.NewSemanticValidator())
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name, In string
+			}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.Unmarshal(g.OpenAPI(), &doc); err != nil {
+		t.Fatalf("OpenAPI() is not JSON: %v", err)
+	}
+	for _, rt := range g.routes {
+		op, ok := doc.Paths[rt.path][map[string]string{http.MethodGet: "get", http.MethodPost: "post", http.MethodDelete: "delete"}[rt.method]]
+		if !ok || op.OperationID != string(rt.rpc.Name()) {
+			t.Errorf("%s %s: operation %q, want %s", rt.method, rt.path, op.OperationID, rt.rpc.Name())
+		}
+	}
+	var params []string
+	for _, p := range doc.Paths["/v1/machines"]["get"].Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if diff := cmp.Diff([]string{"query:page_size", "query:page_token", "query:filter"}, params); diff != "" {
+		t.Errorf("ListMachines parameters mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := doc.Components.Schemas["statecharts.v1.StepRequest"].Properties["expectedStepIndex"]; !ok {
+		t.Error("StepRequest schema has no expectedStepIndex property")
+	}
+}
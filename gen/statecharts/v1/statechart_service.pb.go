@@ -10,6 +10,7 @@ import (
 	status "google.golang.org/genproto/googleapis/rpc/status"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	structpb "google.golang.org/protobuf/types/known/structpb"
 	reflect "reflect"
 	sync "sync"
@@ -68,31 +69,521 @@ func (x *StatechartRegistry) GetStatecharts() map[string]*Statechart {
 	return nil
 }
 
+// * RegisteredStatechart is a statechart registered with the service.
+type RegisteredStatechart struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`             // The resource name of the statechart, statecharts/{statechart}.
+	Statechart    *Statechart            `protobuf:"bytes,2,opt,name=statechart,proto3" json:"statechart,omitempty"` // The statechart.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisteredStatechart) Reset() {
+	*x = RegisteredStatechart{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisteredStatechart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisteredStatechart) ProtoMessage() {}
+
+func (x *RegisteredStatechart) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisteredStatechart.ProtoReflect.Descriptor instead.
+func (*RegisteredStatechart) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegisteredStatechart) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RegisteredStatechart) GetStatechart() *Statechart {
+	if x != nil {
+		return x.Statechart
+	}
+	return nil
+}
+
+// * RegisterStatechartRequest is the request message for registering a statechart.
+// A statechart that violates a SemanticValidator rule with error severity is
+// rejected with INVALID_ARGUMENT and a BadRequest detail listing the violations.
+type RegisterStatechartRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StatechartId  string                 `protobuf:"bytes,1,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"`  // The ID of the statechart, which becomes the final segment of its name.
+	Statechart    *Statechart            `protobuf:"bytes,2,opt,name=statechart,proto3" json:"statechart,omitempty"`                          // The statechart to register.
+	ValidateOnly  bool                   `protobuf:"varint,3,opt,name=validate_only,json=validateOnly,proto3" json:"validate_only,omitempty"` // If set, the statechart is validated but not registered, and the response has no name.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterStatechartRequest) Reset() {
+	*x = RegisterStatechartRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterStatechartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterStatechartRequest) ProtoMessage() {}
+
+func (x *RegisterStatechartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterStatechartRequest.ProtoReflect.Descriptor instead.
+func (*RegisterStatechartRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RegisterStatechartRequest) GetStatechartId() string {
+	if x != nil {
+		return x.StatechartId
+	}
+	return ""
+}
+
+func (x *RegisterStatechartRequest) GetStatechart() *Statechart {
+	if x != nil {
+		return x.Statechart
+	}
+	return nil
+}
+
+func (x *RegisterStatechartRequest) GetValidateOnly() bool {
+	if x != nil {
+		return x.ValidateOnly
+	}
+	return false
+}
+
+// * GetStatechartRequest is the request message for getting a registered statechart.
+type GetStatechartRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // The resource name of the statechart.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatechartRequest) Reset() {
+	*x = GetStatechartRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatechartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatechartRequest) ProtoMessage() {}
+
+func (x *GetStatechartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatechartRequest.ProtoReflect.Descriptor instead.
+func (*GetStatechartRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetStatechartRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// * ListStatechartsRequest is the request message for listing registered statecharts.
+// Statecharts are listed in increasing order of ID.
+type ListStatechartsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PageSize      int32                  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`   // The maximum number of statecharts to return; 50 if zero, at most 1000.
+	PageToken     string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"` // The next_page_token of a previous call, to get the next page.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStatechartsRequest) Reset() {
+	*x = ListStatechartsRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStatechartsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStatechartsRequest) ProtoMessage() {}
+
+func (x *ListStatechartsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStatechartsRequest.ProtoReflect.Descriptor instead.
+func (*ListStatechartsRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListStatechartsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListStatechartsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+// * ListStatechartsResponse is the response message for listing registered statecharts.
+type ListStatechartsResponse struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Statecharts   []*RegisteredStatechart `protobuf:"bytes,1,rep,name=statecharts,proto3" json:"statecharts,omitempty"`                            // The statecharts.
+	NextPageToken string                  `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // The token of the next page; empty on the last page.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStatechartsResponse) Reset() {
+	*x = ListStatechartsResponse{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStatechartsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStatechartsResponse) ProtoMessage() {}
+
+func (x *ListStatechartsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStatechartsResponse.ProtoReflect.Descriptor instead.
+func (*ListStatechartsResponse) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListStatechartsResponse) GetStatecharts() []*RegisteredStatechart {
+	if x != nil {
+		return x.Statecharts
+	}
+	return nil
+}
+
+func (x *ListStatechartsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 // * CreateMachineRequest is the request message for creating a new machine.
 // It requires a statechart ID.
 type CreateMachineRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	StatechartId  string                 `protobuf:"bytes,1,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"` // The ID of the statechart to create an instance from.
-	Context       *structpb.Struct       `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`                               // The initial context of the machine.
+	StatechartId  string                 `protobuf:"bytes,1,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"` // The ID of the statechart to create an instance from.
+	Context       *structpb.Struct       `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`                               // The initial context of the machine.
+	MachineId     string                 `protobuf:"bytes,3,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`          // The ID of the machine; generated if empty.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateMachineRequest) Reset() {
+	*x = CreateMachineRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateMachineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateMachineRequest) ProtoMessage() {}
+
+func (x *CreateMachineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateMachineRequest.ProtoReflect.Descriptor instead.
+func (*CreateMachineRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateMachineRequest) GetStatechartId() string {
+	if x != nil {
+		return x.StatechartId
+	}
+	return ""
+}
+
+func (x *CreateMachineRequest) GetContext() *structpb.Struct {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *CreateMachineRequest) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+// * CreateMachineResponse is the response message for creating a new machine.
+// It returns the created machine.
+type CreateMachineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Machine       *Machine               `protobuf:"bytes,1,opt,name=machine,proto3" json:"machine,omitempty"` // The created machine.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateMachineResponse) Reset() {
+	*x = CreateMachineResponse{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateMachineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateMachineResponse) ProtoMessage() {}
+
+func (x *CreateMachineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateMachineResponse.ProtoReflect.Descriptor instead.
+func (*CreateMachineResponse) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateMachineResponse) GetMachine() *Machine {
+	if x != nil {
+		return x.Machine
+	}
+	return nil
+}
+
+// * GetMachineRequest is the request message for getting a machine.
+type GetMachineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // The resource name of the machine.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMachineRequest) Reset() {
+	*x = GetMachineRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMachineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMachineRequest) ProtoMessage() {}
+
+func (x *GetMachineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMachineRequest.ProtoReflect.Descriptor instead.
+func (*GetMachineRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetMachineRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// * ListMachinesRequest is the request message for listing machines.
+// Machines are listed in increasing order of ID.
+//
+// The filter is a conjunction of terms joined by AND, each of one of the forms
+//
+//	statechart = "ID"          the machine was created from the statechart ID;
+//	state = RUNNING            the machine is running, or STOPPED for stopped;
+//	configuration:"LABEL"      the state LABEL is in the configuration of the machine.
+//
+// Quotes around values are optional.
+type ListMachinesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PageSize      int32                  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`   // The maximum number of machines to return; 50 if zero, at most 1000.
+	PageToken     string                 `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"` // The next_page_token of a previous call with the same filter, to get the next page.
+	Filter        string                 `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`                        // The filter machines must match.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMachinesRequest) Reset() {
+	*x = ListMachinesRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMachinesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMachinesRequest) ProtoMessage() {}
+
+func (x *ListMachinesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMachinesRequest.ProtoReflect.Descriptor instead.
+func (*ListMachinesRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListMachinesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListMachinesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListMachinesRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+// * ListMachinesResponse is the response message for listing machines.
+type ListMachinesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Machines      []*Machine             `protobuf:"bytes,1,rep,name=machines,proto3" json:"machines,omitempty"`                                  // The machines.
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"` // The token of the next page; empty on the last page.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateMachineRequest) Reset() {
-	*x = CreateMachineRequest{}
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[1]
+func (x *ListMachinesResponse) Reset() {
+	*x = ListMachinesResponse{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateMachineRequest) String() string {
+func (x *ListMachinesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateMachineRequest) ProtoMessage() {}
+func (*ListMachinesResponse) ProtoMessage() {}
 
-func (x *CreateMachineRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[1]
+func (x *ListMachinesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -103,49 +594,48 @@ func (x *CreateMachineRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateMachineRequest.ProtoReflect.Descriptor instead.
-func (*CreateMachineRequest) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ListMachinesResponse.ProtoReflect.Descriptor instead.
+func (*ListMachinesResponse) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *CreateMachineRequest) GetStatechartId() string {
+func (x *ListMachinesResponse) GetMachines() []*Machine {
 	if x != nil {
-		return x.StatechartId
+		return x.Machines
 	}
-	return ""
+	return nil
 }
 
-func (x *CreateMachineRequest) GetContext() *structpb.Struct {
+func (x *ListMachinesResponse) GetNextPageToken() string {
 	if x != nil {
-		return x.Context
+		return x.NextPageToken
 	}
-	return nil
+	return ""
 }
 
-// * CreateMachineResponse is the response message for creating a new machine.
-// It returns the created machine.
-type CreateMachineResponse struct {
+// * DeleteMachineRequest is the request message for deleting a machine.
+type DeleteMachineRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Machine       *Machine               `protobuf:"bytes,1,opt,name=machine,proto3" json:"machine,omitempty"` // The created machine.
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // The resource name of the machine.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreateMachineResponse) Reset() {
-	*x = CreateMachineResponse{}
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[2]
+func (x *DeleteMachineRequest) Reset() {
+	*x = DeleteMachineRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreateMachineResponse) String() string {
+func (x *DeleteMachineRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateMachineResponse) ProtoMessage() {}
+func (*DeleteMachineRequest) ProtoMessage() {}
 
-func (x *CreateMachineResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[2]
+func (x *DeleteMachineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -156,32 +646,38 @@ func (x *CreateMachineResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateMachineResponse.ProtoReflect.Descriptor instead.
-func (*CreateMachineResponse) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use DeleteMachineRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMachineRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *CreateMachineResponse) GetMachine() *Machine {
+func (x *DeleteMachineRequest) GetName() string {
 	if x != nil {
-		return x.Machine
+		return x.Name
 	}
-	return nil
+	return ""
 }
 
 // * StepRequest is the request message for the Step method.
-// It is defined a statechart ID, an event, and an optional context.
+// It is defined by a machine ID, an event, and an optional context.
+// Requests that set expected_step_index fail with ABORTED unless the machine has
+// taken exactly that many steps, so that concurrent clients do not step a machine
+// from a configuration they have not seen.
 type StepRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	StatechartId  string                 `protobuf:"bytes,1,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"` // The id of the statechart to step.
-	Event         string                 `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`                                   // The event to step the statechart with.
-	Context       *structpb.Struct       `protobuf:"bytes,3,opt,name=context,proto3" json:"context,omitempty"`                               // The context attached to the Event.
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Deprecated: Marked as deprecated in statecharts/v1/statechart_service.proto.
+	StatechartId      string           `protobuf:"bytes,1,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"`                         // The id of the statechart whose only machine to step; use machine_id.
+	Event             string           `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`                                                           // The event to step the machine with.
+	Context           *structpb.Struct `protobuf:"bytes,3,opt,name=context,proto3" json:"context,omitempty"`                                                       // The context attached to the Event.
+	MachineId         string           `protobuf:"bytes,4,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`                                  // The ID of the machine to step.
+	ExpectedStepIndex *int64           `protobuf:"varint,5,opt,name=expected_step_index,json=expectedStepIndex,proto3,oneof" json:"expected_step_index,omitempty"` // The index in the step history the step must get.
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *StepRequest) Reset() {
 	*x = StepRequest{}
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[3]
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -193,7 +689,7 @@ func (x *StepRequest) String() string {
 func (*StepRequest) ProtoMessage() {}
 
 func (x *StepRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[3]
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -206,9 +702,10 @@ func (x *StepRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StepRequest.ProtoReflect.Descriptor instead.
 func (*StepRequest) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{3}
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{12}
 }
 
+// Deprecated: Marked as deprecated in statecharts/v1/statechart_service.proto.
 func (x *StepRequest) GetStatechartId() string {
 	if x != nil {
 		return x.StatechartId
@@ -230,6 +727,20 @@ func (x *StepRequest) GetContext() *structpb.Struct {
 	return nil
 }
 
+func (x *StepRequest) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+func (x *StepRequest) GetExpectedStepIndex() int64 {
+	if x != nil && x.ExpectedStepIndex != nil {
+		return *x.ExpectedStepIndex
+	}
+	return 0
+}
+
 // * StepResponse is the response message for the Step method.
 // It returns the current state of the statechart and the result of the step operation.
 type StepResponse struct {
@@ -242,7 +753,7 @@ type StepResponse struct {
 
 func (x *StepResponse) Reset() {
 	*x = StepResponse{}
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[4]
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -254,7 +765,7 @@ func (x *StepResponse) String() string {
 func (*StepResponse) ProtoMessage() {}
 
 func (x *StepResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[4]
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -267,7 +778,7 @@ func (x *StepResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StepResponse.ProtoReflect.Descriptor instead.
 func (*StepResponse) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{4}
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *StepResponse) GetMachine() *Machine {
@@ -284,31 +795,390 @@ func (x *StepResponse) GetResult() *status.Status {
 	return nil
 }
 
+// * BatchStepRequest is the request message for the BatchStep method.
+// The events are processed in order, each in a step of its own. If a step fails,
+// the machine is left as it was before the first step.
+type BatchStepRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	MachineId         string                 `protobuf:"bytes,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`                                  // The ID of the machine to step.
+	Events            []string               `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`                                                         // The events to step the machine with, in order.
+	Context           *structpb.Struct       `protobuf:"bytes,3,opt,name=context,proto3" json:"context,omitempty"`                                                       // The context attached to the first event.
+	ExpectedStepIndex *int64                 `protobuf:"varint,4,opt,name=expected_step_index,json=expectedStepIndex,proto3,oneof" json:"expected_step_index,omitempty"` // The index in the step history the first step must get.
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *BatchStepRequest) Reset() {
+	*x = BatchStepRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchStepRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchStepRequest) ProtoMessage() {}
+
+func (x *BatchStepRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchStepRequest.ProtoReflect.Descriptor instead.
+func (*BatchStepRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BatchStepRequest) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+func (x *BatchStepRequest) GetEvents() []string {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *BatchStepRequest) GetContext() *structpb.Struct {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *BatchStepRequest) GetExpectedStepIndex() int64 {
+	if x != nil && x.ExpectedStepIndex != nil {
+		return *x.ExpectedStepIndex
+	}
+	return 0
+}
+
+// * BatchStepResponse is the response message for the BatchStep method.
+// It returns the machine after the steps, the steps, and the result of the batch.
+type BatchStepResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Machine       *Machine               `protobuf:"bytes,1,opt,name=machine,proto3" json:"machine,omitempty"` // The machine after the steps, or as it was if a step failed.
+	Steps         []*Step                `protobuf:"bytes,2,rep,name=steps,proto3" json:"steps,omitempty"`     // The steps, one for each event; empty if a step failed.
+	Result        *status.Status         `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`   // The result of the batch; a failure names the event whose step failed.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchStepResponse) Reset() {
+	*x = BatchStepResponse{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchStepResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchStepResponse) ProtoMessage() {}
+
+func (x *BatchStepResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchStepResponse.ProtoReflect.Descriptor instead.
+func (*BatchStepResponse) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BatchStepResponse) GetMachine() *Machine {
+	if x != nil {
+		return x.Machine
+	}
+	return nil
+}
+
+func (x *BatchStepResponse) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *BatchStepResponse) GetResult() *status.Status {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+// * WatchRequest is the request message for the Watch method.
+// It selects the machines to watch and the step of their history to start from.
+// A client that was disconnected resumes by passing the index following the
+// last step it received for each machine.
+type WatchRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	MachineIds       []string               `protobuf:"bytes,1,rep,name=machine_ids,json=machineIds,proto3" json:"machine_ids,omitempty"`                                                                                                // The IDs of the machines to watch; all machines, including those created later, if empty.
+	StatechartId     string                 `protobuf:"bytes,2,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"`                                                                                          // If set, only machines of this statechart are watched.
+	StartStepIndex   int64                  `protobuf:"varint,3,opt,name=start_step_index,json=startStepIndex,proto3" json:"start_step_index,omitempty"`                                                                                 // The index in the step history of the first step sent for each machine; negative to send only new steps.
+	StartStepIndexes map[string]int64       `protobuf:"bytes,4,rep,name=start_step_indexes,json=startStepIndexes,proto3" json:"start_step_indexes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Start step indexes overriding start_step_index for individual machines.
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *WatchRequest) GetMachineIds() []string {
+	if x != nil {
+		return x.MachineIds
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetStatechartId() string {
+	if x != nil {
+		return x.StatechartId
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetStartStepIndex() int64 {
+	if x != nil {
+		return x.StartStepIndex
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetStartStepIndexes() map[string]int64 {
+	if x != nil {
+		return x.StartStepIndexes
+	}
+	return nil
+}
+
+// * WatchResponse is a message of the Watch stream.
+// It holds a step of a watched machine and the configuration the step resulted in.
+// A response without a step reports a machine created while watching, in its initial configuration.
+type WatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MachineId     string                 `protobuf:"bytes,1,opt,name=machine_id,json=machineId,proto3" json:"machine_id,omitempty"`          // The ID of the machine.
+	StepIndex     int64                  `protobuf:"varint,2,opt,name=step_index,json=stepIndex,proto3" json:"step_index,omitempty"`         // The index of the step in the step history of the machine; -1 without a step.
+	Step          *Step                  `protobuf:"bytes,3,opt,name=step,proto3" json:"step,omitempty"`                                     // The step.
+	Configuration *Configuration         `protobuf:"bytes,4,opt,name=configuration,proto3" json:"configuration,omitempty"`                   // The configuration of the machine after the step.
+	State         MachineState           `protobuf:"varint,5,opt,name=state,proto3,enum=statecharts.v1.MachineState" json:"state,omitempty"` // The state of the machine after the step.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statechart_service_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statechart_service_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *WatchResponse) GetMachineId() string {
+	if x != nil {
+		return x.MachineId
+	}
+	return ""
+}
+
+func (x *WatchResponse) GetStepIndex() int64 {
+	if x != nil {
+		return x.StepIndex
+	}
+	return 0
+}
+
+func (x *WatchResponse) GetStep() *Step {
+	if x != nil {
+		return x.Step
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetConfiguration() *Configuration {
+	if x != nil {
+		return x.Configuration
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetState() MachineState {
+	if x != nil {
+		return x.State
+	}
+	return MachineState_MACHINE_STATE_UNSPECIFIED
+}
+
 var File_statecharts_v1_statechart_service_proto protoreflect.FileDescriptor
 
 const file_statecharts_v1_statechart_service_proto_rawDesc = "" +
 	"\n" +
-	"'statecharts/v1/statechart_service.proto\x12\x0estatecharts.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17google/rpc/status.proto\x1a statecharts/v1/statecharts.proto\"\xc7\x01\n" +
+	"'statecharts/v1/statechart_service.proto\x12\x0estatecharts.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17google/rpc/status.proto\x1a statecharts/v1/statecharts.proto\"\xc7\x01\n" +
 	"\x12StatechartRegistry\x12U\n" +
 	"\vstatecharts\x18\x01 \x03(\v23.statecharts.v1.StatechartRegistry.StatechartsEntryR\vstatecharts\x1aZ\n" +
 	"\x10StatechartsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
-	"\x05value\x18\x02 \x01(\v2\x1a.statecharts.v1.StatechartR\x05value:\x028\x01\"n\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.statecharts.v1.StatechartR\x05value:\x028\x01\"f\n" +
+	"\x14RegisteredStatechart\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12:\n" +
+	"\n" +
+	"statechart\x18\x02 \x01(\v2\x1a.statecharts.v1.StatechartR\n" +
+	"statechart\"\xa1\x01\n" +
+	"\x19RegisterStatechartRequest\x12#\n" +
+	"\rstatechart_id\x18\x01 \x01(\tR\fstatechartId\x12:\n" +
+	"\n" +
+	"statechart\x18\x02 \x01(\v2\x1a.statecharts.v1.StatechartR\n" +
+	"statechart\x12#\n" +
+	"\rvalidate_only\x18\x03 \x01(\bR\fvalidateOnly\"*\n" +
+	"\x14GetStatechartRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"T\n" +
+	"\x16ListStatechartsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\"\x89\x01\n" +
+	"\x17ListStatechartsResponse\x12F\n" +
+	"\vstatecharts\x18\x01 \x03(\v2$.statecharts.v1.RegisteredStatechartR\vstatecharts\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\x8d\x01\n" +
 	"\x14CreateMachineRequest\x12#\n" +
 	"\rstatechart_id\x18\x01 \x01(\tR\fstatechartId\x121\n" +
-	"\acontext\x18\x02 \x01(\v2\x17.google.protobuf.StructR\acontext\"J\n" +
+	"\acontext\x18\x02 \x01(\v2\x17.google.protobuf.StructR\acontext\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x03 \x01(\tR\tmachineId\"J\n" +
 	"\x15CreateMachineResponse\x121\n" +
-	"\amachine\x18\x01 \x01(\v2\x17.statecharts.v1.MachineR\amachine\"{\n" +
-	"\vStepRequest\x12#\n" +
-	"\rstatechart_id\x18\x01 \x01(\tR\fstatechartId\x12\x14\n" +
+	"\amachine\x18\x01 \x01(\v2\x17.statecharts.v1.MachineR\amachine\"'\n" +
+	"\x11GetMachineRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"i\n" +
+	"\x13ListMachinesRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x16\n" +
+	"\x06filter\x18\x03 \x01(\tR\x06filter\"s\n" +
+	"\x14ListMachinesResponse\x123\n" +
+	"\bmachines\x18\x01 \x03(\v2\x17.statecharts.v1.MachineR\bmachines\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"*\n" +
+	"\x14DeleteMachineRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\xeb\x01\n" +
+	"\vStepRequest\x12'\n" +
+	"\rstatechart_id\x18\x01 \x01(\tB\x02\x18\x01R\fstatechartId\x12\x14\n" +
 	"\x05event\x18\x02 \x01(\tR\x05event\x121\n" +
-	"\acontext\x18\x03 \x01(\v2\x17.google.protobuf.StructR\acontext\"m\n" +
+	"\acontext\x18\x03 \x01(\v2\x17.google.protobuf.StructR\acontext\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x04 \x01(\tR\tmachineId\x123\n" +
+	"\x13expected_step_index\x18\x05 \x01(\x03H\x00R\x11expectedStepIndex\x88\x01\x01B\x16\n" +
+	"\x14_expected_step_index\"m\n" +
 	"\fStepResponse\x121\n" +
 	"\amachine\x18\x01 \x01(\v2\x17.statecharts.v1.MachineR\amachine\x12*\n" +
-	"\x06result\x18\x02 \x01(\v2\x12.google.rpc.StatusR\x06result2\xb4\x01\n" +
-	"\x11StatechartService\x12\\\n" +
-	"\rCreateMachine\x12$.statecharts.v1.CreateMachineRequest\x1a%.statecharts.v1.CreateMachineResponse\x12A\n" +
-	"\x04Step\x12\x1b.statecharts.v1.StepRequest\x1a\x1c.statecharts.v1.StepResponseB\xb5\x01\n" +
+	"\x06result\x18\x02 \x01(\v2\x12.google.rpc.StatusR\x06result\"\xc9\x01\n" +
+	"\x10BatchStepRequest\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x01 \x01(\tR\tmachineId\x12\x16\n" +
+	"\x06events\x18\x02 \x03(\tR\x06events\x121\n" +
+	"\acontext\x18\x03 \x01(\v2\x17.google.protobuf.StructR\acontext\x123\n" +
+	"\x13expected_step_index\x18\x04 \x01(\x03H\x00R\x11expectedStepIndex\x88\x01\x01B\x16\n" +
+	"\x14_expected_step_index\"\x9e\x01\n" +
+	"\x11BatchStepResponse\x121\n" +
+	"\amachine\x18\x01 \x01(\v2\x17.statecharts.v1.MachineR\amachine\x12*\n" +
+	"\x05steps\x18\x02 \x03(\v2\x14.statecharts.v1.StepR\x05steps\x12*\n" +
+	"\x06result\x18\x03 \x01(\v2\x12.google.rpc.StatusR\x06result\"\xa5\x02\n" +
+	"\fWatchRequest\x12\x1f\n" +
+	"\vmachine_ids\x18\x01 \x03(\tR\n" +
+	"machineIds\x12#\n" +
+	"\rstatechart_id\x18\x02 \x01(\tR\fstatechartId\x12(\n" +
+	"\x10start_step_index\x18\x03 \x01(\x03R\x0estartStepIndex\x12`\n" +
+	"\x12start_step_indexes\x18\x04 \x03(\v22.statecharts.v1.WatchRequest.StartStepIndexesEntryR\x10startStepIndexes\x1aC\n" +
+	"\x15StartStepIndexesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xf0\x01\n" +
+	"\rWatchResponse\x12\x1d\n" +
+	"\n" +
+	"machine_id\x18\x01 \x01(\tR\tmachineId\x12\x1d\n" +
+	"\n" +
+	"step_index\x18\x02 \x01(\x03R\tstepIndex\x12(\n" +
+	"\x04step\x18\x03 \x01(\v2\x14.statecharts.v1.StepR\x04step\x12C\n" +
+	"\rconfiguration\x18\x04 \x01(\v2\x1d.statecharts.v1.ConfigurationR\rconfiguration\x122\n" +
+	"\x05state\x18\x05 \x01(\x0e2\x1c.statecharts.v1.MachineStateR\x05state2\xea\x06\n" +
+	"\x11StatechartService\x12e\n" +
+	"\x12RegisterStatechart\x12).statecharts.v1.RegisterStatechartRequest\x1a$.statecharts.v1.RegisteredStatechart\x12[\n" +
+	"\rGetStatechart\x12$.statecharts.v1.GetStatechartRequest\x1a$.statecharts.v1.RegisteredStatechart\x12b\n" +
+	"\x0fListStatecharts\x12&.statecharts.v1.ListStatechartsRequest\x1a'.statecharts.v1.ListStatechartsResponse\x12\\\n" +
+	"\rCreateMachine\x12$.statecharts.v1.CreateMachineRequest\x1a%.statecharts.v1.CreateMachineResponse\x12H\n" +
+	"\n" +
+	"GetMachine\x12!.statecharts.v1.GetMachineRequest\x1a\x17.statecharts.v1.Machine\x12Y\n" +
+	"\fListMachines\x12#.statecharts.v1.ListMachinesRequest\x1a$.statecharts.v1.ListMachinesResponse\x12M\n" +
+	"\rDeleteMachine\x12$.statecharts.v1.DeleteMachineRequest\x1a\x16.google.protobuf.Empty\x12A\n" +
+	"\x04Step\x12\x1b.statecharts.v1.StepRequest\x1a\x1c.statecharts.v1.StepResponse\x12P\n" +
+	"\tBatchStep\x12 .statecharts.v1.BatchStepRequest\x1a!.statecharts.v1.BatchStepResponse\x12F\n" +
+	"\x05Watch\x12\x1c.statecharts.v1.WatchRequest\x1a\x1d.statecharts.v1.WatchResponse0\x01B\xb5\x01\n" +
 	"\x12com.statecharts.v1B\x16StatechartServiceProtoP\x01Z.github.com/tmc/sc/statecharts/v1;statechartsv1\xa2\x02\x03SXX\xaa\x02\x0eStatecharts.V1\xca\x02\x0eStatecharts\\V1\xe2\x02\x1aStatecharts\\V1\\GPBMetadata\xea\x02\x0fStatecharts::V1b\x06proto3"
 
 var (
@@ -323,36 +1193,82 @@ func file_statecharts_v1_statechart_service_proto_rawDescGZIP() []byte {
 	return file_statecharts_v1_statechart_service_proto_rawDescData
 }
 
-var file_statecharts_v1_statechart_service_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_statecharts_v1_statechart_service_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_statecharts_v1_statechart_service_proto_goTypes = []any{
-	(*StatechartRegistry)(nil),    // 0: statecharts.v1.StatechartRegistry
-	(*CreateMachineRequest)(nil),  // 1: statecharts.v1.CreateMachineRequest
-	(*CreateMachineResponse)(nil), // 2: statecharts.v1.CreateMachineResponse
-	(*StepRequest)(nil),           // 3: statecharts.v1.StepRequest
-	(*StepResponse)(nil),          // 4: statecharts.v1.StepResponse
-	nil,                           // 5: statecharts.v1.StatechartRegistry.StatechartsEntry
-	(*structpb.Struct)(nil),       // 6: google.protobuf.Struct
-	(*Machine)(nil),               // 7: statecharts.v1.Machine
-	(*status.Status)(nil),         // 8: google.rpc.Status
-	(*Statechart)(nil),            // 9: statecharts.v1.Statechart
+	(*StatechartRegistry)(nil),        // 0: statecharts.v1.StatechartRegistry
+	(*RegisteredStatechart)(nil),      // 1: statecharts.v1.RegisteredStatechart
+	(*RegisterStatechartRequest)(nil), // 2: statecharts.v1.RegisterStatechartRequest
+	(*GetStatechartRequest)(nil),      // 3: statecharts.v1.GetStatechartRequest
+	(*ListStatechartsRequest)(nil),    // 4: statecharts.v1.ListStatechartsRequest
+	(*ListStatechartsResponse)(nil),   // 5: statecharts.v1.ListStatechartsResponse
+	(*CreateMachineRequest)(nil),      // 6: statecharts.v1.CreateMachineRequest
+	(*CreateMachineResponse)(nil),     // 7: statecharts.v1.CreateMachineResponse
+	(*GetMachineRequest)(nil),         // 8: statecharts.v1.GetMachineRequest
+	(*ListMachinesRequest)(nil),       // 9: statecharts.v1.ListMachinesRequest
+	(*ListMachinesResponse)(nil),      // 10: statecharts.v1.ListMachinesResponse
+	(*DeleteMachineRequest)(nil),      // 11: statecharts.v1.DeleteMachineRequest
+	(*StepRequest)(nil),               // 12: statecharts.v1.StepRequest
+	(*StepResponse)(nil),              // 13: statecharts.v1.StepResponse
+	(*BatchStepRequest)(nil),          // 14: statecharts.v1.BatchStepRequest
+	(*BatchStepResponse)(nil),         // 15: statecharts.v1.BatchStepResponse
+	(*WatchRequest)(nil),              // 16: statecharts.v1.WatchRequest
+	(*WatchResponse)(nil),             // 17: statecharts.v1.WatchResponse
+	nil,                               // 18: statecharts.v1.StatechartRegistry.StatechartsEntry
+	nil,                               // 19: statecharts.v1.WatchRequest.StartStepIndexesEntry
+	(*Statechart)(nil),                // 20: statecharts.v1.Statechart
+	(*structpb.Struct)(nil),           // 21: google.protobuf.Struct
+	(*Machine)(nil),                   // 22: statecharts.v1.Machine
+	(*status.Status)(nil),             // 23: google.rpc.Status
+	(*Step)(nil),                      // 24: statecharts.v1.Step
+	(*Configuration)(nil),             // 25: statecharts.v1.Configuration
+	(MachineState)(0),                 // 26: statecharts.v1.MachineState
+	(*emptypb.Empty)(nil),             // 27: google.protobuf.Empty
 }
 var file_statecharts_v1_statechart_service_proto_depIdxs = []int32{
-	5, // 0: statecharts.v1.StatechartRegistry.statecharts:type_name -> statecharts.v1.StatechartRegistry.StatechartsEntry
-	6, // 1: statecharts.v1.CreateMachineRequest.context:type_name -> google.protobuf.Struct
-	7, // 2: statecharts.v1.CreateMachineResponse.machine:type_name -> statecharts.v1.Machine
-	6, // 3: statecharts.v1.StepRequest.context:type_name -> google.protobuf.Struct
-	7, // 4: statecharts.v1.StepResponse.machine:type_name -> statecharts.v1.Machine
-	8, // 5: statecharts.v1.StepResponse.result:type_name -> google.rpc.Status
-	9, // 6: statecharts.v1.StatechartRegistry.StatechartsEntry.value:type_name -> statecharts.v1.Statechart
-	1, // 7: statecharts.v1.StatechartService.CreateMachine:input_type -> statecharts.v1.CreateMachineRequest
-	3, // 8: statecharts.v1.StatechartService.Step:input_type -> statecharts.v1.StepRequest
-	2, // 9: statecharts.v1.StatechartService.CreateMachine:output_type -> statecharts.v1.CreateMachineResponse
-	4, // 10: statecharts.v1.StatechartService.Step:output_type -> statecharts.v1.StepResponse
-	9, // [9:11] is the sub-list for method output_type
-	7, // [7:9] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	18, // 0: statecharts.v1.StatechartRegistry.statecharts:type_name -> statecharts.v1.StatechartRegistry.StatechartsEntry
+	20, // 1: statecharts.v1.RegisteredStatechart.statechart:type_name -> statecharts.v1.Statechart
+	20, // 2: statecharts.v1.RegisterStatechartRequest.statechart:type_name -> statecharts.v1.Statechart
+	1,  // 3: statecharts.v1.ListStatechartsResponse.statecharts:type_name -> statecharts.v1.RegisteredStatechart
+	21, // 4: statecharts.v1.CreateMachineRequest.context:type_name -> google.protobuf.Struct
+	22, // 5: statecharts.v1.CreateMachineResponse.machine:type_name -> statecharts.v1.Machine
+	22, // 6: statecharts.v1.ListMachinesResponse.machines:type_name -> statecharts.v1.Machine
+	21, // 7: statecharts.v1.StepRequest.context:type_name -> google.protobuf.Struct
+	22, // 8: statecharts.v1.StepResponse.machine:type_name -> statecharts.v1.Machine
+	23, // 9: statecharts.v1.StepResponse.result:type_name -> google.rpc.Status
+	21, // 10: statecharts.v1.BatchStepRequest.context:type_name -> google.protobuf.Struct
+	22, // 11: statecharts.v1.BatchStepResponse.machine:type_name -> statecharts.v1.Machine
+	24, // 12: statecharts.v1.BatchStepResponse.steps:type_name -> statecharts.v1.Step
+	23, // 13: statecharts.v1.BatchStepResponse.result:type_name -> google.rpc.Status
+	19, // 14: statecharts.v1.WatchRequest.start_step_indexes:type_name -> statecharts.v1.WatchRequest.StartStepIndexesEntry
+	24, // 15: statecharts.v1.WatchResponse.step:type_name -> statecharts.v1.Step
+	25, // 16: statecharts.v1.WatchResponse.configuration:type_name -> statecharts.v1.Configuration
+	26, // 17: statecharts.v1.WatchResponse.state:type_name -> statecharts.v1.MachineState
+	20, // 18: statecharts.v1.StatechartRegistry.StatechartsEntry.value:type_name -> statecharts.v1.Statechart
+	2,  // 19: statecharts.v1.StatechartService.RegisterStatechart:input_type -> statecharts.v1.RegisterStatechartRequest
+	3,  // 20: statecharts.v1.StatechartService.GetStatechart:input_type -> statecharts.v1.GetStatechartRequest
+	4,  // 21: statecharts.v1.StatechartService.ListStatecharts:input_type -> statecharts.v1.ListStatechartsRequest
+	6,  // 22: statecharts.v1.StatechartService.CreateMachine:input_type -> statecharts.v1.CreateMachineRequest
+	8,  // 23: statecharts.v1.StatechartService.GetMachine:input_type -> statecharts.v1.GetMachineRequest
+	9,  // 24: statecharts.v1.StatechartService.ListMachines:input_type -> statecharts.v1.ListMachinesRequest
+	11, // 25: statecharts.v1.StatechartService.DeleteMachine:input_type -> statecharts.v1.DeleteMachineRequest
+	12, // 26: statecharts.v1.StatechartService.Step:input_type -> statecharts.v1.StepRequest
+	14, // 27: statecharts.v1.StatechartService.BatchStep:input_type -> statecharts.v1.BatchStepRequest
+	16, // 28: statecharts.v1.StatechartService.Watch:input_type -> statecharts.v1.WatchRequest
+	1,  // 29: statecharts.v1.StatechartService.RegisterStatechart:output_type -> statecharts.v1.RegisteredStatechart
+	1,  // 30: statecharts.v1.StatechartService.GetStatechart:output_type -> statecharts.v1.RegisteredStatechart
+	5,  // 31: statecharts.v1.StatechartService.ListStatecharts:output_type -> statecharts.v1.ListStatechartsResponse
+	7,  // 32: statecharts.v1.StatechartService.CreateMachine:output_type -> statecharts.v1.CreateMachineResponse
+	22, // 33: statecharts.v1.StatechartService.GetMachine:output_type -> statecharts.v1.Machine
+	10, // 34: statecharts.v1.StatechartService.ListMachines:output_type -> statecharts.v1.ListMachinesResponse
+	27, // 35: statecharts.v1.StatechartService.DeleteMachine:output_type -> google.protobuf.Empty
+	13, // 36: statecharts.v1.StatechartService.Step:output_type -> statecharts.v1.StepResponse
+	15, // 37: statecharts.v1.StatechartService.BatchStep:output_type -> statecharts.v1.BatchStepResponse
+	17, // 38: statecharts.v1.StatechartService.Watch:output_type -> statecharts.v1.WatchResponse
+	29, // [29:39] is the sub-list for method output_type
+	19, // [19:29] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_statecharts_v1_statechart_service_proto_init() }
@@ -361,13 +1277,15 @@ func file_statecharts_v1_statechart_service_proto_init() {
 		return
 	}
 	file_statecharts_v1_statecharts_proto_init()
+	file_statecharts_v1_statechart_service_proto_msgTypes[12].OneofWrappers = []any{}
+	file_statecharts_v1_statechart_service_proto_msgTypes[14].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_statecharts_v1_statechart_service_proto_rawDesc), len(file_statecharts_v1_statechart_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -11,6 +11,7 @@ import (
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -19,8 +20,16 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StatechartService_CreateMachine_FullMethodName = "/statecharts.v1.StatechartService/CreateMachine"
-	StatechartService_Step_FullMethodName          = "/statecharts.v1.StatechartService/Step"
+	StatechartService_RegisterStatechart_FullMethodName = "/statecharts.v1.StatechartService/RegisterStatechart"
+	StatechartService_GetStatechart_FullMethodName      = "/statecharts.v1.StatechartService/GetStatechart"
+	StatechartService_ListStatecharts_FullMethodName    = "/statecharts.v1.StatechartService/ListStatecharts"
+	StatechartService_CreateMachine_FullMethodName      = "/statecharts.v1.StatechartService/CreateMachine"
+	StatechartService_GetMachine_FullMethodName         = "/statecharts.v1.StatechartService/GetMachine"
+	StatechartService_ListMachines_FullMethodName       = "/statecharts.v1.StatechartService/ListMachines"
+	StatechartService_DeleteMachine_FullMethodName      = "/statecharts.v1.StatechartService/DeleteMachine"
+	StatechartService_Step_FullMethodName               = "/statecharts.v1.StatechartService/Step"
+	StatechartService_BatchStep_FullMethodName          = "/statecharts.v1.StatechartService/BatchStep"
+	StatechartService_Watch_FullMethodName              = "/statecharts.v1.StatechartService/Watch"
 )
 
 // StatechartServiceClient is the client API for StatechartService service.
@@ -29,12 +38,33 @@ const (
 //
 // *
 // StatechartService defines the main service for interacting with statecharts.
-// It allows creating a new machine and stepping a statechart through a single iteration.
+// It allows registering statecharts, creating, inspecting and deleting machines,
+// stepping machines and watching machines as they step.
+//
+// Registered statecharts have resource names of the form statecharts/{statechart}
+// and machines of the form machines/{machine}, where {statechart} is the ID of the
+// statechart and {machine} the ID of the machine.
 type StatechartServiceClient interface {
+	// Register a statechart, after validating it with the SemanticValidator rules and inlining its submachines.
+	RegisterStatechart(ctx context.Context, in *RegisterStatechartRequest, opts ...grpc.CallOption) (*RegisteredStatechart, error)
+	// Get a registered statechart.
+	GetStatechart(ctx context.Context, in *GetStatechartRequest, opts ...grpc.CallOption) (*RegisteredStatechart, error)
+	// List the registered statecharts.
+	ListStatecharts(ctx context.Context, in *ListStatechartsRequest, opts ...grpc.CallOption) (*ListStatechartsResponse, error)
 	// Create a new machine.
 	CreateMachine(ctx context.Context, in *CreateMachineRequest, opts ...grpc.CallOption) (*CreateMachineResponse, error)
-	// Step a statechart through a single iteration.
+	// Get a machine.
+	GetMachine(ctx context.Context, in *GetMachineRequest, opts ...grpc.CallOption) (*Machine, error)
+	// List machines.
+	ListMachines(ctx context.Context, in *ListMachinesRequest, opts ...grpc.CallOption) (*ListMachinesResponse, error)
+	// Delete a machine, ending the Watch streams that name it.
+	DeleteMachine(ctx context.Context, in *DeleteMachineRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Step a machine through a single iteration.
 	Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*StepResponse, error)
+	// Step a machine through a sequence of iterations, all of which take effect or none.
+	BatchStep(ctx context.Context, in *BatchStepRequest, opts ...grpc.CallOption) (*BatchStepResponse, error)
+	// Watch the steps of machines, starting from a given step of their history.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
 }
 
 type statechartServiceClient struct {
@@ -45,6 +75,36 @@ func NewStatechartServiceClient(cc grpc.ClientConnInterface) StatechartServiceCl
 	return &statechartServiceClient{cc}
 }
 
+func (c *statechartServiceClient) RegisterStatechart(ctx context.Context, in *RegisterStatechartRequest, opts ...grpc.CallOption) (*RegisteredStatechart, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisteredStatechart)
+	err := c.cc.Invoke(ctx, StatechartService_RegisterStatechart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statechartServiceClient) GetStatechart(ctx context.Context, in *GetStatechartRequest, opts ...grpc.CallOption) (*RegisteredStatechart, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisteredStatechart)
+	err := c.cc.Invoke(ctx, StatechartService_GetStatechart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statechartServiceClient) ListStatecharts(ctx context.Context, in *ListStatechartsRequest, opts ...grpc.CallOption) (*ListStatechartsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStatechartsResponse)
+	err := c.cc.Invoke(ctx, StatechartService_ListStatecharts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *statechartServiceClient) CreateMachine(ctx context.Context, in *CreateMachineRequest, opts ...grpc.CallOption) (*CreateMachineResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(CreateMachineResponse)
@@ -55,6 +115,36 @@ func (c *statechartServiceClient) CreateMachine(ctx context.Context, in *CreateM
 	return out, nil
 }
 
+func (c *statechartServiceClient) GetMachine(ctx context.Context, in *GetMachineRequest, opts ...grpc.CallOption) (*Machine, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Machine)
+	err := c.cc.Invoke(ctx, StatechartService_GetMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statechartServiceClient) ListMachines(ctx context.Context, in *ListMachinesRequest, opts ...grpc.CallOption) (*ListMachinesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMachinesResponse)
+	err := c.cc.Invoke(ctx, StatechartService_ListMachines_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statechartServiceClient) DeleteMachine(ctx context.Context, in *DeleteMachineRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, StatechartService_DeleteMachine_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *statechartServiceClient) Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*StepResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(StepResponse)
@@ -65,18 +155,68 @@ func (c *statechartServiceClient) Step(ctx context.Context, in *StepRequest, opt
 	return out, nil
 }
 
+func (c *statechartServiceClient) BatchStep(ctx context.Context, in *BatchStepRequest, opts ...grpc.CallOption) (*BatchStepResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchStepResponse)
+	err := c.cc.Invoke(ctx, StatechartService_BatchStep_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statechartServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StatechartService_ServiceDesc.Streams[0], StatechartService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StatechartService_WatchClient = grpc.ServerStreamingClient[WatchResponse]
+
 // StatechartServiceServer is the server API for StatechartService service.
 // All implementations must embed UnimplementedStatechartServiceServer
 // for forward compatibility.
 //
 // *
 // StatechartService defines the main service for interacting with statecharts.
-// It allows creating a new machine and stepping a statechart through a single iteration.
+// It allows registering statecharts, creating, inspecting and deleting machines,
+// stepping machines and watching machines as they step.
+//
+// Registered statecharts have resource names of the form statecharts/{statechart}
+// and machines of the form machines/{machine}, where {statechart} is the ID of the
+// statechart and {machine} the ID of the machine.
 type StatechartServiceServer interface {
+	// Register a statechart, after validating it with the SemanticValidator rules and inlining its submachines.
+	RegisterStatechart(context.Context, *RegisterStatechartRequest) (*RegisteredStatechart, error)
+	// Get a registered statechart.
+	GetStatechart(context.Context, *GetStatechartRequest) (*RegisteredStatechart, error)
+	// List the registered statecharts.
+	ListStatecharts(context.Context, *ListStatechartsRequest) (*ListStatechartsResponse, error)
 	// Create a new machine.
 	CreateMachine(context.Context, *CreateMachineRequest) (*CreateMachineResponse, error)
-	// Step a statechart through a single iteration.
+	// Get a machine.
+	GetMachine(context.Context, *GetMachineRequest) (*Machine, error)
+	// List machines.
+	ListMachines(context.Context, *ListMachinesRequest) (*ListMachinesResponse, error)
+	// Delete a machine, ending the Watch streams that name it.
+	DeleteMachine(context.Context, *DeleteMachineRequest) (*emptypb.Empty, error)
+	// Step a machine through a single iteration.
 	Step(context.Context, *StepRequest) (*StepResponse, error)
+	// Step a machine through a sequence of iterations, all of which take effect or none.
+	BatchStep(context.Context, *BatchStepRequest) (*BatchStepResponse, error)
+	// Watch the steps of machines, starting from a given step of their history.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
 	mustEmbedUnimplementedStatechartServiceServer()
 }
 
@@ -87,12 +227,36 @@ type StatechartServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedStatechartServiceServer struct{}
 
+func (UnimplementedStatechartServiceServer) RegisterStatechart(context.Context, *RegisterStatechartRequest) (*RegisteredStatechart, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterStatechart not implemented")
+}
+func (UnimplementedStatechartServiceServer) GetStatechart(context.Context, *GetStatechartRequest) (*RegisteredStatechart, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatechart not implemented")
+}
+func (UnimplementedStatechartServiceServer) ListStatecharts(context.Context, *ListStatechartsRequest) (*ListStatechartsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStatecharts not implemented")
+}
 func (UnimplementedStatechartServiceServer) CreateMachine(context.Context, *CreateMachineRequest) (*CreateMachineResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateMachine not implemented")
 }
+func (UnimplementedStatechartServiceServer) GetMachine(context.Context, *GetMachineRequest) (*Machine, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMachine not implemented")
+}
+func (UnimplementedStatechartServiceServer) ListMachines(context.Context, *ListMachinesRequest) (*ListMachinesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMachines not implemented")
+}
+func (UnimplementedStatechartServiceServer) DeleteMachine(context.Context, *DeleteMachineRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMachine not implemented")
+}
 func (UnimplementedStatechartServiceServer) Step(context.Context, *StepRequest) (*StepResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Step not implemented")
 }
+func (UnimplementedStatechartServiceServer) BatchStep(context.Context, *BatchStepRequest) (*BatchStepResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchStep not implemented")
+}
+func (UnimplementedStatechartServiceServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedStatechartServiceServer) mustEmbedUnimplementedStatechartServiceServer() {}
 func (UnimplementedStatechartServiceServer) testEmbeddedByValue()                           {}
 
@@ -114,6 +278,60 @@ func RegisterStatechartServiceServer(s grpc.ServiceRegistrar, srv StatechartServ
 	s.RegisterService(&StatechartService_ServiceDesc, srv)
 }
 
+func _StatechartService_RegisterStatechart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterStatechartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).RegisterStatechart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_RegisterStatechart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).RegisterStatechart(ctx, req.(*RegisterStatechartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatechartService_GetStatechart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatechartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).GetStatechart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_GetStatechart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).GetStatechart(ctx, req.(*GetStatechartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatechartService_ListStatecharts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStatechartsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).ListStatecharts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_ListStatecharts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).ListStatecharts(ctx, req.(*ListStatechartsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StatechartService_CreateMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateMachineRequest)
 	if err := dec(in); err != nil {
@@ -132,6 +350,60 @@ func _StatechartService_CreateMachine_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StatechartService_GetMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMachineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).GetMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_GetMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).GetMachine(ctx, req.(*GetMachineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatechartService_ListMachines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMachinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).ListMachines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_ListMachines_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).ListMachines(ctx, req.(*ListMachinesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatechartService_DeleteMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMachineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).DeleteMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_DeleteMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).DeleteMachine(ctx, req.(*DeleteMachineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _StatechartService_Step_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StepRequest)
 	if err := dec(in); err != nil {
@@ -150,6 +422,35 @@ func _StatechartService_Step_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StatechartService_BatchStep_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchStepRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatechartServiceServer).BatchStep(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StatechartService_BatchStep_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatechartServiceServer).BatchStep(ctx, req.(*BatchStepRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatechartService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StatechartServiceServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StatechartService_WatchServer = grpc.ServerStreamingServer[WatchResponse]
+
 // StatechartService_ServiceDesc is the grpc.ServiceDesc for StatechartService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -157,15 +458,49 @@ var StatechartService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "statecharts.v1.StatechartService",
 	HandlerType: (*StatechartServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterStatechart",
+			Handler:    _StatechartService_RegisterStatechart_Handler,
+		},
+		{
+			MethodName: "GetStatechart",
+			Handler:    _StatechartService_GetStatechart_Handler,
+		},
+		{
+			MethodName: "ListStatecharts",
+			Handler:    _StatechartService_ListStatecharts_Handler,
+		},
 		{
 			MethodName: "CreateMachine",
 			Handler:    _StatechartService_CreateMachine_Handler,
 		},
+		{
+			MethodName: "GetMachine",
+			Handler:    _StatechartService_GetMachine_Handler,
+		},
+		{
+			MethodName: "ListMachines",
+			Handler:    _StatechartService_ListMachines_Handler,
+		},
+		{
+			MethodName: "DeleteMachine",
+			Handler:    _StatechartService_DeleteMachine_Handler,
+		},
 		{
 			MethodName: "Step",
 			Handler:    _StatechartService_Step_Handler,
 		},
+		{
+			MethodName: "BatchStep",
+			Handler:    _StatechartService_BatchStep_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _StatechartService_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "statecharts/v1/statechart_service.proto",
 }
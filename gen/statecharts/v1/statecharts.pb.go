@@ -32,6 +32,10 @@ const (
 	StateType_STATE_TYPE_BASIC       StateType = 1 // A basic state (has no sub-states).
 	StateType_STATE_TYPE_NORMAL      StateType = 2 // A normal state (has sub-states related by XOR semantics).
 	StateType_STATE_TYPE_PARALLEL    StateType = 3 // A parallel state (has sub-states related by AND semantics).
+	StateType_STATE_TYPE_CHOICE      StateType = 4 // A choice pseudostate, whose outgoing branches are selected after the actions of the incoming transition.
+	StateType_STATE_TYPE_JUNCTION    StateType = 5 // A junction pseudostate, whose outgoing branches are selected before the incoming transition fires.
+	StateType_STATE_TYPE_ENTRY_POINT StateType = 6 // An entry point of a chart used as a submachine, entered from the containing chart.
+	StateType_STATE_TYPE_EXIT_POINT  StateType = 7 // An exit point of a chart used as a submachine, left by transitions of the containing chart.
 	// Aliases for clarity with academic/literature terminology
 	StateType_STATE_TYPE_ORTHOGONAL StateType = 3 // An alias for STATE_TYPE_PARALLEL. An orthogonal state is a state with concurrently active sub-states (AND semantics).
 )
@@ -43,6 +47,10 @@ var (
 		1: "STATE_TYPE_BASIC",
 		2: "STATE_TYPE_NORMAL",
 		3: "STATE_TYPE_PARALLEL",
+		4: "STATE_TYPE_CHOICE",
+		5: "STATE_TYPE_JUNCTION",
+		6: "STATE_TYPE_ENTRY_POINT",
+		7: "STATE_TYPE_EXIT_POINT",
 		// Duplicate value: 3: "STATE_TYPE_ORTHOGONAL",
 	}
 	StateType_value = map[string]int32{
@@ -50,6 +58,10 @@ var (
 		"STATE_TYPE_BASIC":       1,
 		"STATE_TYPE_NORMAL":      2,
 		"STATE_TYPE_PARALLEL":    3,
+		"STATE_TYPE_CHOICE":      4,
+		"STATE_TYPE_JUNCTION":    5,
+		"STATE_TYPE_ENTRY_POINT": 6,
+		"STATE_TYPE_EXIT_POINT":  7,
 		"STATE_TYPE_ORTHOGONAL":  3,
 	}
 )
@@ -81,6 +93,61 @@ func (StateType) EnumDescriptor() ([]byte, []int) {
 	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{0}
 }
 
+// *
+// TransitionKind determines which states a transition exits and enters.
+// The kinds follow UML; the local kind is called internal in SCXML.
+type TransitionKind int32
+
+const (
+	TransitionKind_TRANSITION_KIND_UNSPECIFIED TransitionKind = 0 // Unspecified kind, treated as external.
+	TransitionKind_TRANSITION_KIND_EXTERNAL    TransitionKind = 1 // Exits and re-enters its source, also when targeting itself or a descendant.
+	TransitionKind_TRANSITION_KIND_INTERNAL    TransitionKind = 2 // Exits and enters no state; it has no targets other than its source.
+	TransitionKind_TRANSITION_KIND_LOCAL       TransitionKind = 3 // Stays in its compound source when all targets are descendants of it.
+)
+
+// Enum value maps for TransitionKind.
+var (
+	TransitionKind_name = map[int32]string{
+		0: "TRANSITION_KIND_UNSPECIFIED",
+		1: "TRANSITION_KIND_EXTERNAL",
+		2: "TRANSITION_KIND_INTERNAL",
+		3: "TRANSITION_KIND_LOCAL",
+	}
+	TransitionKind_value = map[string]int32{
+		"TRANSITION_KIND_UNSPECIFIED": 0,
+		"TRANSITION_KIND_EXTERNAL":    1,
+		"TRANSITION_KIND_INTERNAL":    2,
+		"TRANSITION_KIND_LOCAL":       3,
+	}
+)
+
+func (x TransitionKind) Enum() *TransitionKind {
+	p := new(TransitionKind)
+	*p = x
+	return p
+}
+
+func (x TransitionKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TransitionKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_statecharts_v1_statecharts_proto_enumTypes[1].Descriptor()
+}
+
+func (TransitionKind) Type() protoreflect.EnumType {
+	return &file_statecharts_v1_statecharts_proto_enumTypes[1]
+}
+
+func (x TransitionKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TransitionKind.Descriptor instead.
+func (TransitionKind) EnumDescriptor() ([]byte, []int) {
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{1}
+}
+
 // *
 // MachineState encodes the high-level state of a statechart.
 type MachineState int32
@@ -116,11 +183,11 @@ func (x MachineState) String() string {
 }
 
 func (MachineState) Descriptor() protoreflect.EnumDescriptor {
-	return file_statecharts_v1_statecharts_proto_enumTypes[1].Descriptor()
+	return file_statecharts_v1_statecharts_proto_enumTypes[2].Descriptor()
 }
 
 func (MachineState) Type() protoreflect.EnumType {
-	return &file_statecharts_v1_statecharts_proto_enumTypes[1]
+	return &file_statecharts_v1_statecharts_proto_enumTypes[2]
 }
 
 func (x MachineState) Number() protoreflect.EnumNumber {
@@ -129,7 +196,7 @@ func (x MachineState) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use MachineState.Descriptor instead.
 func (MachineState) EnumDescriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{1}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{2}
 }
 
 // * Complete, static description of a statechart.
@@ -203,6 +270,8 @@ type State struct {
 	Children      []*State               `protobuf:"bytes,3,rep,name=children,proto3" json:"children,omitempty"`                        // The sub-states. If a state has no sub-states, it is considered a BASIC state.
 	IsInitial     bool                   `protobuf:"varint,4,opt,name=is_initial,json=isInitial,proto3" json:"is_initial,omitempty"`    // Default child of XOR composite.
 	IsFinal       bool                   `protobuf:"varint,5,opt,name=is_final,json=isFinal,proto3" json:"is_final,omitempty"`          // Terminal child.
+	Submachine    string                 `protobuf:"bytes,6,opt,name=submachine,proto3" json:"submachine,omitempty"`                    // The ID of a registered statechart this state stands for, if any.
+	Invokes       []*Invoke              `protobuf:"bytes,7,rep,name=invokes,proto3" json:"invokes,omitempty"`                          // The child machines started when the state is entered and stopped when it is exited.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -272,24 +341,103 @@ func (x *State) GetIsFinal() bool {
 	return false
 }
 
+func (x *State) GetSubmachine() string {
+	if x != nil {
+		return x.Submachine
+	}
+	return ""
+}
+
+func (x *State) GetInvokes() []*Invoke {
+	if x != nil {
+		return x.Invokes
+	}
+	return nil
+}
+
+// *
+// Invoke starts a child machine of a registered statechart while its state is active.
+// The parent receives the event "done.invoke.<id>" when the child stops in a final
+// configuration and "error.invoke.<id>" when the child fails.
+type Invoke struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                         // The ID of the invocation, unique within the statechart.
+	StatechartId  string                 `protobuf:"bytes,2,opt,name=statechart_id,json=statechartId,proto3" json:"statechart_id,omitempty"` // The ID of the registered statechart the child machine runs.
+	Context       *structpb.Struct       `protobuf:"bytes,3,opt,name=context,proto3" json:"context,omitempty"`                               // The initial context of the child machine.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Invoke) Reset() {
+	*x = Invoke{}
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Invoke) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Invoke) ProtoMessage() {}
+
+func (x *Invoke) ProtoReflect() protoreflect.Message {
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Invoke.ProtoReflect.Descriptor instead.
+func (*Invoke) Descriptor() ([]byte, []int) {
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Invoke) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Invoke) GetStatechartId() string {
+	if x != nil {
+		return x.StatechartId
+	}
+	return ""
+}
+
+func (x *Invoke) GetContext() *structpb.Struct {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
 // *
 // Transition represents a transition between states in a statechart.
 // It connects source (from) states to target (to) states and is triggered by an event.
 type Transition struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`     // The label of the transition.
-	From          []string               `protobuf:"bytes,2,rep,name=from,proto3" json:"from,omitempty"`       // The source (from) State reference(s).
-	To            []string               `protobuf:"bytes,3,rep,name=to,proto3" json:"to,omitempty"`           // The target (to) State reference(s).
-	Event         string                 `protobuf:"bytes,4,opt,name=event,proto3" json:"event,omitempty"`     // The label of the event that triggers the transition.
-	Guard         *Guard                 `protobuf:"bytes,5,opt,name=guard,proto3" json:"guard,omitempty"`     // The guard of the transition, a condition for the transition to occur.
-	Actions       []*Action              `protobuf:"bytes,6,rep,name=actions,proto3" json:"actions,omitempty"` // The action(s) associated with the transition.
+	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`                                   // The label of the transition.
+	From          []string               `protobuf:"bytes,2,rep,name=from,proto3" json:"from,omitempty"`                                     // The source (from) State reference(s).
+	To            []string               `protobuf:"bytes,3,rep,name=to,proto3" json:"to,omitempty"`                                         // The target (to) State reference(s).
+	Event         string                 `protobuf:"bytes,4,opt,name=event,proto3" json:"event,omitempty"`                                   // The label of the event that triggers the transition.
+	Guard         *Guard                 `protobuf:"bytes,5,opt,name=guard,proto3" json:"guard,omitempty"`                                   // The guard of the transition, a condition for the transition to occur.
+	Actions       []*Action              `protobuf:"bytes,6,rep,name=actions,proto3" json:"actions,omitempty"`                               // The action(s) associated with the transition.
+	Kind          TransitionKind         `protobuf:"varint,7,opt,name=kind,proto3,enum=statecharts.v1.TransitionKind" json:"kind,omitempty"` // The kind of the transition, external by default.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Transition) Reset() {
 	*x = Transition{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[2]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -301,7 +449,7 @@ func (x *Transition) String() string {
 func (*Transition) ProtoMessage() {}
 
 func (x *Transition) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[2]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -314,7 +462,7 @@ func (x *Transition) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Transition.ProtoReflect.Descriptor instead.
 func (*Transition) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{2}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Transition) GetLabel() string {
@@ -359,6 +507,13 @@ func (x *Transition) GetActions() []*Action {
 	return nil
 }
 
+func (x *Transition) GetKind() TransitionKind {
+	if x != nil {
+		return x.Kind
+	}
+	return TransitionKind_TRANSITION_KIND_UNSPECIFIED
+}
+
 // * Event represents an event in a statechart. Each event has a label that identifies it.
 type Event struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -369,7 +524,7 @@ type Event struct {
 
 func (x *Event) Reset() {
 	*x = Event{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[3]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -381,7 +536,7 @@ func (x *Event) String() string {
 func (*Event) ProtoMessage() {}
 
 func (x *Event) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[3]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -394,7 +549,7 @@ func (x *Event) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Event.ProtoReflect.Descriptor instead.
 func (*Event) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{3}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Event) GetLabel() string {
@@ -404,7 +559,9 @@ func (x *Event) GetLabel() string {
 	return ""
 }
 
-// * Guard is a guard for a transition. It represents a condition that must be satisfied for the transition to occur.
+// *
+// Guard is a guard for a transition. It represents a condition that must be satisfied for the transition to occur.
+// The expression "else" marks the branch of a pseudostate taken when no other branch is enabled.
 type Guard struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Expression    string                 `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"`
@@ -414,7 +571,7 @@ type Guard struct {
 
 func (x *Guard) Reset() {
 	*x = Guard{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[4]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -426,7 +583,7 @@ func (x *Guard) String() string {
 func (*Guard) ProtoMessage() {}
 
 func (x *Guard) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[4]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -439,7 +596,7 @@ func (x *Guard) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Guard.ProtoReflect.Descriptor instead.
 func (*Guard) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{4}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Guard) GetExpression() string {
@@ -449,7 +606,10 @@ func (x *Guard) GetExpression() string {
 	return ""
 }
 
-// * Action is an action associated with a transition. Each action has a label that identifies it.
+// *
+// Action is an action associated with a transition. Each action has a label that identifies it.
+// The label "raise EVENT" raises an internal event; "send EVENT to TARGET" sends an event to
+// another machine: the parent, an invoked child or any machine by ID.
 type Action struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
@@ -459,7 +619,7 @@ type Action struct {
 
 func (x *Action) Reset() {
 	*x = Action{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[5]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -471,7 +631,7 @@ func (x *Action) String() string {
 func (*Action) ProtoMessage() {}
 
 func (x *Action) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[5]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -484,7 +644,7 @@ func (x *Action) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Action.ProtoReflect.Descriptor instead.
 func (*Action) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{5}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Action) GetLabel() string {
@@ -504,7 +664,7 @@ type StateRef struct {
 
 func (x *StateRef) Reset() {
 	*x = StateRef{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[6]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -516,7 +676,7 @@ func (x *StateRef) String() string {
 func (*StateRef) ProtoMessage() {}
 
 func (x *StateRef) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[6]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -529,7 +689,7 @@ func (x *StateRef) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StateRef.ProtoReflect.Descriptor instead.
 func (*StateRef) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{6}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *StateRef) GetLabel() string {
@@ -549,7 +709,7 @@ type Configuration struct {
 
 func (x *Configuration) Reset() {
 	*x = Configuration{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[7]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -561,7 +721,7 @@ func (x *Configuration) String() string {
 func (*Configuration) ProtoMessage() {}
 
 func (x *Configuration) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[7]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -574,7 +734,7 @@ func (x *Configuration) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Configuration.ProtoReflect.Descriptor instead.
 func (*Configuration) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{7}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *Configuration) GetStates() []*StateRef {
@@ -593,13 +753,14 @@ type Machine struct {
 	Statechart    *Statechart            `protobuf:"bytes,4,opt,name=statechart,proto3" json:"statechart,omitempty"`                         // The statechart definition.
 	Configuration *Configuration         `protobuf:"bytes,5,opt,name=configuration,proto3" json:"configuration,omitempty"`                   // The current configuration of the machine.
 	StepHistory   []*Step                `protobuf:"bytes,6,rep,name=step_history,json=stepHistory,proto3" json:"step_history,omitempty"`    // The history of steps that have been carried out by the machine.
+	Name          string                 `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`                                     // The resource name of a machine of the service, machines/{machine}.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Machine) Reset() {
 	*x = Machine{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[8]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -611,7 +772,7 @@ func (x *Machine) String() string {
 func (*Machine) ProtoMessage() {}
 
 func (x *Machine) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[8]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -624,7 +785,7 @@ func (x *Machine) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Machine.ProtoReflect.Descriptor instead.
 func (*Machine) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{8}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Machine) GetId() string {
@@ -669,6 +830,13 @@ func (x *Machine) GetStepHistory() []*Step {
 	return nil
 }
 
+func (x *Machine) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
 // * Step is a step in the execution of a statechart.
 type Step struct {
 	state                  protoimpl.MessageState `protogen:"open.v1"`
@@ -683,7 +851,7 @@ type Step struct {
 
 func (x *Step) Reset() {
 	*x = Step{}
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[9]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -695,7 +863,7 @@ func (x *Step) String() string {
 func (*Step) ProtoMessage() {}
 
 func (x *Step) ProtoReflect() protoreflect.Message {
-	mi := &file_statecharts_v1_statecharts_proto_msgTypes[9]
+	mi := &file_statecharts_v1_statecharts_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -708,7 +876,7 @@ func (x *Step) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Step.ProtoReflect.Descriptor instead.
 func (*Step) Descriptor() ([]byte, []int) {
-	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{9}
+	return file_statecharts_v1_statecharts_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Step) GetEvents() []*Event {
@@ -756,14 +924,22 @@ const file_statecharts_v1_statecharts_proto_rawDesc = "" +
 	"\n" +
 	"root_state\x18\x01 \x01(\v2\x15.statecharts.v1.StateR\trootState\x12<\n" +
 	"\vtransitions\x18\x02 \x03(\v2\x1a.statecharts.v1.TransitionR\vtransitions\x12-\n" +
-	"\x06events\x18\x03 \x03(\v2\x15.statecharts.v1.EventR\x06events\"\xb9\x01\n" +
+	"\x06events\x18\x03 \x03(\v2\x15.statecharts.v1.EventR\x06events\"\x8b\x02\n" +
 	"\x05State\x12\x14\n" +
 	"\x05label\x18\x01 \x01(\tR\x05label\x12-\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x19.statecharts.v1.StateTypeR\x04type\x121\n" +
 	"\bchildren\x18\x03 \x03(\v2\x15.statecharts.v1.StateR\bchildren\x12\x1d\n" +
 	"\n" +
 	"is_initial\x18\x04 \x01(\bR\tisInitial\x12\x19\n" +
-	"\bis_final\x18\x05 \x01(\bR\aisFinal\"\xbb\x01\n" +
+	"\bis_final\x18\x05 \x01(\bR\aisFinal\x12\x1e\n" +
+	"\n" +
+	"submachine\x18\x06 \x01(\tR\n" +
+	"submachine\x120\n" +
+	"\ainvokes\x18\a \x03(\v2\x16.statecharts.v1.InvokeR\ainvokes\"p\n" +
+	"\x06Invoke\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rstatechart_id\x18\x02 \x01(\tR\fstatechartId\x121\n" +
+	"\acontext\x18\x03 \x01(\v2\x17.google.protobuf.StructR\acontext\"\xef\x01\n" +
 	"\n" +
 	"Transition\x12\x14\n" +
 	"\x05label\x18\x01 \x01(\tR\x05label\x12\x12\n" +
@@ -771,7 +947,8 @@ const file_statecharts_v1_statecharts_proto_rawDesc = "" +
 	"\x02to\x18\x03 \x03(\tR\x02to\x12\x14\n" +
 	"\x05event\x18\x04 \x01(\tR\x05event\x12+\n" +
 	"\x05guard\x18\x05 \x01(\v2\x15.statecharts.v1.GuardR\x05guard\x120\n" +
-	"\aactions\x18\x06 \x03(\v2\x16.statecharts.v1.ActionR\aactions\"\x1d\n" +
+	"\aactions\x18\x06 \x03(\v2\x16.statecharts.v1.ActionR\aactions\x122\n" +
+	"\x04kind\x18\a \x01(\x0e2\x1e.statecharts.v1.TransitionKindR\x04kind\"\x1d\n" +
 	"\x05Event\x12\x14\n" +
 	"\x05label\x18\x01 \x01(\tR\x05label\"'\n" +
 	"\x05Guard\x12\x1e\n" +
@@ -783,7 +960,7 @@ const file_statecharts_v1_statecharts_proto_rawDesc = "" +
 	"\bStateRef\x12\x14\n" +
 	"\x05label\x18\x01 \x01(\tR\x05label\"A\n" +
 	"\rConfiguration\x120\n" +
-	"\x06states\x18\x01 \x03(\v2\x18.statecharts.v1.StateRefR\x06states\"\xba\x02\n" +
+	"\x06states\x18\x01 \x03(\v2\x18.statecharts.v1.StateRefR\x06states\"\xce\x02\n" +
 	"\aMachine\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
 	"\x05state\x18\x02 \x01(\x0e2\x1c.statecharts.v1.MachineStateR\x05state\x121\n" +
@@ -792,19 +969,29 @@ const file_statecharts_v1_statecharts_proto_rawDesc = "" +
 	"statechart\x18\x04 \x01(\v2\x1a.statecharts.v1.StatechartR\n" +
 	"statechart\x12C\n" +
 	"\rconfiguration\x18\x05 \x01(\v2\x1d.statecharts.v1.ConfigurationR\rconfiguration\x127\n" +
-	"\fstep_history\x18\x06 \x03(\v2\x14.statecharts.v1.StepR\vstepHistory\"\xd4\x02\n" +
+	"\fstep_history\x18\x06 \x03(\v2\x14.statecharts.v1.StepR\vstepHistory\x12\x12\n" +
+	"\x04name\x18\a \x01(\tR\x04name\"\xd4\x02\n" +
 	"\x04Step\x12-\n" +
 	"\x06events\x18\x01 \x03(\v2\x15.statecharts.v1.EventR\x06events\x12<\n" +
 	"\vtransitions\x18\x02 \x03(\v2\x1a.statecharts.v1.TransitionR\vtransitions\x12T\n" +
 	"\x16starting_configuration\x18\x03 \x01(\v2\x1d.statecharts.v1.ConfigurationR\x15startingConfiguration\x12V\n" +
 	"\x17resulting_configuration\x18\x04 \x01(\v2\x1d.statecharts.v1.ConfigurationR\x16resultingConfiguration\x121\n" +
-	"\acontext\x18\x05 \x01(\v2\x17.google.protobuf.StructR\acontext*\x8c\x01\n" +
+	"\acontext\x18\x05 \x01(\v2\x17.google.protobuf.StructR\acontext*\xf3\x01\n" +
 	"\tStateType\x12\x1a\n" +
 	"\x16STATE_TYPE_UNSPECIFIED\x10\x00\x12\x14\n" +
 	"\x10STATE_TYPE_BASIC\x10\x01\x12\x15\n" +
 	"\x11STATE_TYPE_NORMAL\x10\x02\x12\x17\n" +
-	"\x13STATE_TYPE_PARALLEL\x10\x03\x12\x19\n" +
-	"\x15STATE_TYPE_ORTHOGONAL\x10\x03\x1a\x02\x10\x01*c\n" +
+	"\x13STATE_TYPE_PARALLEL\x10\x03\x12\x15\n" +
+	"\x11STATE_TYPE_CHOICE\x10\x04\x12\x17\n" +
+	"\x13STATE_TYPE_JUNCTION\x10\x05\x12\x1a\n" +
+	"\x16STATE_TYPE_ENTRY_POINT\x10\x06\x12\x19\n" +
+	"\x15STATE_TYPE_EXIT_POINT\x10\a\x12\x19\n" +
+	"\x15STATE_TYPE_ORTHOGONAL\x10\x03\x1a\x02\x10\x01*\x88\x01\n" +
+	"\x0eTransitionKind\x12\x1f\n" +
+	"\x1bTRANSITION_KIND_UNSPECIFIED\x10\x00\x12\x1c\n" +
+	"\x18TRANSITION_KIND_EXTERNAL\x10\x01\x12\x1c\n" +
+	"\x18TRANSITION_KIND_INTERNAL\x10\x02\x12\x19\n" +
+	"\x15TRANSITION_KIND_LOCAL\x10\x03*c\n" +
 	"\fMachineState\x12\x1d\n" +
 	"\x19MACHINE_STATE_UNSPECIFIED\x10\x00\x12\x19\n" +
 	"\x15MACHINE_STATE_RUNNING\x10\x01\x12\x19\n" +
@@ -823,47 +1010,52 @@ func file_statecharts_v1_statecharts_proto_rawDescGZIP() []byte {
 	return file_statecharts_v1_statecharts_proto_rawDescData
 }
 
-var file_statecharts_v1_statecharts_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_statecharts_v1_statecharts_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_statecharts_v1_statecharts_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_statecharts_v1_statecharts_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_statecharts_v1_statecharts_proto_goTypes = []any{
 	(StateType)(0),          // 0: statecharts.v1.StateType
-	(MachineState)(0),       // 1: statecharts.v1.MachineState
-	(*Statechart)(nil),      // 2: statecharts.v1.Statechart
-	(*State)(nil),           // 3: statecharts.v1.State
-	(*Transition)(nil),      // 4: statecharts.v1.Transition
-	(*Event)(nil),           // 5: statecharts.v1.Event
-	(*Guard)(nil),           // 6: statecharts.v1.Guard
-	(*Action)(nil),          // 7: statecharts.v1.Action
-	(*StateRef)(nil),        // 8: statecharts.v1.StateRef
-	(*Configuration)(nil),   // 9: statecharts.v1.Configuration
-	(*Machine)(nil),         // 10: statecharts.v1.Machine
-	(*Step)(nil),            // 11: statecharts.v1.Step
-	(*structpb.Struct)(nil), // 12: google.protobuf.Struct
+	(TransitionKind)(0),     // 1: statecharts.v1.TransitionKind
+	(MachineState)(0),       // 2: statecharts.v1.MachineState
+	(*Statechart)(nil),      // 3: statecharts.v1.Statechart
+	(*State)(nil),           // 4: statecharts.v1.State
+	(*Invoke)(nil),          // 5: statecharts.v1.Invoke
+	(*Transition)(nil),      // 6: statecharts.v1.Transition
+	(*Event)(nil),           // 7: statecharts.v1.Event
+	(*Guard)(nil),           // 8: statecharts.v1.Guard
+	(*Action)(nil),          // 9: statecharts.v1.Action
+	(*StateRef)(nil),        // 10: statecharts.v1.StateRef
+	(*Configuration)(nil),   // 11: statecharts.v1.Configuration
+	(*Machine)(nil),         // 12: statecharts.v1.Machine
+	(*Step)(nil),            // 13: statecharts.v1.Step
+	(*structpb.Struct)(nil), // 14: google.protobuf.Struct
 }
 var file_statecharts_v1_statecharts_proto_depIdxs = []int32{
-	3,  // 0: statecharts.v1.Statechart.root_state:type_name -> statecharts.v1.State
-	4,  // 1: statecharts.v1.Statechart.transitions:type_name -> statecharts.v1.Transition
-	5,  // 2: statecharts.v1.Statechart.events:type_name -> statecharts.v1.Event
+	4,  // 0: statecharts.v1.Statechart.root_state:type_name -> statecharts.v1.State
+	6,  // 1: statecharts.v1.Statechart.transitions:type_name -> statecharts.v1.Transition
+	7,  // 2: statecharts.v1.Statechart.events:type_name -> statecharts.v1.Event
 	0,  // 3: statecharts.v1.State.type:type_name -> statecharts.v1.StateType
-	3,  // 4: statecharts.v1.State.children:type_name -> statecharts.v1.State
-	6,  // 5: statecharts.v1.Transition.guard:type_name -> statecharts.v1.Guard
-	7,  // 6: statecharts.v1.Transition.actions:type_name -> statecharts.v1.Action
-	8,  // 7: statecharts.v1.Configuration.states:type_name -> statecharts.v1.StateRef
-	1,  // 8: statecharts.v1.Machine.state:type_name -> statecharts.v1.MachineState
-	12, // 9: statecharts.v1.Machine.context:type_name -> google.protobuf.Struct
-	2,  // 10: statecharts.v1.Machine.statechart:type_name -> statecharts.v1.Statechart
-	9,  // 11: statecharts.v1.Machine.configuration:type_name -> statecharts.v1.Configuration
-	11, // 12: statecharts.v1.Machine.step_history:type_name -> statecharts.v1.Step
-	5,  // 13: statecharts.v1.Step.events:type_name -> statecharts.v1.Event
-	4,  // 14: statecharts.v1.Step.transitions:type_name -> statecharts.v1.Transition
-	9,  // 15: statecharts.v1.Step.starting_configuration:type_name -> statecharts.v1.Configuration
-	9,  // 16: statecharts.v1.Step.resulting_configuration:type_name -> statecharts.v1.Configuration
-	12, // 17: statecharts.v1.Step.context:type_name -> google.protobuf.Struct
-	18, // [18:18] is the sub-list for method output_type
-	18, // [18:18] is the sub-list for method input_type
-	18, // [18:18] is the sub-list for extension type_name
-	18, // [18:18] is the sub-list for extension extendee
-	0,  // [0:18] is the sub-list for field type_name
+	4,  // 4: statecharts.v1.State.children:type_name -> statecharts.v1.State
+	5,  // 5: statecharts.v1.State.invokes:type_name -> statecharts.v1.Invoke
+	14, // 6: statecharts.v1.Invoke.context:type_name -> google.protobuf.Struct
+	8,  // 7: statecharts.v1.Transition.guard:type_name -> statecharts.v1.Guard
+	9,  // 8: statecharts.v1.Transition.actions:type_name -> statecharts.v1.Action
+	1,  // 9: statecharts.v1.Transition.kind:type_name -> statecharts.v1.TransitionKind
+	10, // 10: statecharts.v1.Configuration.states:type_name -> statecharts.v1.StateRef
+	2,  // 11: statecharts.v1.Machine.state:type_name -> statecharts.v1.MachineState
+	14, // 12: statecharts.v1.Machine.context:type_name -> google.protobuf.Struct
+	3,  // 13: statecharts.v1.Machine.statechart:type_name -> statecharts.v1.Statechart
+	11, // 14: statecharts.v1.Machine.configuration:type_name -> statecharts.v1.Configuration
+	13, // 15: statecharts.v1.Machine.step_history:type_name -> statecharts.v1.Step
+	7,  // 16: statecharts.v1.Step.events:type_name -> statecharts.v1.Event
+	6,  // 17: statecharts.v1.Step.transitions:type_name -> statecharts.v1.Transition
+	11, // 18: statecharts.v1.Step.starting_configuration:type_name -> statecharts.v1.Configuration
+	11, // 19: statecharts.v1.Step.resulting_configuration:type_name -> statecharts.v1.Configuration
+	14, // 20: statecharts.v1.Step.context:type_name -> google.protobuf.Struct
+	21, // [21:21] is the sub-list for method output_type
+	21, // [21:21] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
 }
 
 func init() { file_statecharts_v1_statecharts_proto_init() }
@@ -876,8 +1068,8 @@ func file_statecharts_v1_statecharts_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_statecharts_v1_statecharts_proto_rawDesc), len(file_statecharts_v1_statecharts_proto_rawDesc)),
-			NumEnums:      2,
-			NumMessages:   10,
+			NumEnums:      3,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
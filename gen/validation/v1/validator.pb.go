@@ -24,6 +24,57 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// *
+// SourceFormat defines the text formats of statecharts.
+type SourceFormat int32
+
+const (
+	SourceFormat_SOURCE_FORMAT_UNSPECIFIED SourceFormat = 0 // Unspecified format.
+	SourceFormat_JSON                      SourceFormat = 1 // The protobuf JSON encoding of statecharts.v1.Statechart.
+	SourceFormat_TEXTPROTO                 SourceFormat = 2 // The protobuf text format of statecharts.v1.Statechart.
+)
+
+// Enum value maps for SourceFormat.
+var (
+	SourceFormat_name = map[int32]string{
+		0: "SOURCE_FORMAT_UNSPECIFIED",
+		1: "JSON",
+		2: "TEXTPROTO",
+	}
+	SourceFormat_value = map[string]int32{
+		"SOURCE_FORMAT_UNSPECIFIED": 0,
+		"JSON":                      1,
+		"TEXTPROTO":                 2,
+	}
+)
+
+func (x SourceFormat) Enum() *SourceFormat {
+	p := new(SourceFormat)
+	*p = x
+	return p
+}
+
+func (x SourceFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SourceFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_validation_v1_validator_proto_enumTypes[0].Descriptor()
+}
+
+func (SourceFormat) Type() protoreflect.EnumType {
+	return &file_validation_v1_validator_proto_enumTypes[0]
+}
+
+func (x SourceFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SourceFormat.Descriptor instead.
+func (SourceFormat) EnumDescriptor() ([]byte, []int) {
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{0}
+}
+
 // *
 // Severity defines the severity level of a validation violation.
 type Severity int32
@@ -62,11 +113,11 @@ func (x Severity) String() string {
 }
 
 func (Severity) Descriptor() protoreflect.EnumDescriptor {
-	return file_validation_v1_validator_proto_enumTypes[0].Descriptor()
+	return file_validation_v1_validator_proto_enumTypes[1].Descriptor()
 }
 
 func (Severity) Type() protoreflect.EnumType {
-	return &file_validation_v1_validator_proto_enumTypes[0]
+	return &file_validation_v1_validator_proto_enumTypes[1]
 }
 
 func (x Severity) Number() protoreflect.EnumNumber {
@@ -75,7 +126,7 @@ func (x Severity) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Severity.Descriptor instead.
 func (Severity) EnumDescriptor() ([]byte, []int) {
-	return file_validation_v1_validator_proto_rawDescGZIP(), []int{0}
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{1}
 }
 
 // *
@@ -83,25 +134,51 @@ func (Severity) EnumDescriptor() ([]byte, []int) {
 type RuleId int32
 
 const (
-	RuleId_RULE_UNSPECIFIED                   RuleId = 0 // Unspecified rule.
-	RuleId_UNIQUE_STATE_LABELS                RuleId = 1 // All state labels must be unique.
-	RuleId_SINGLE_DEFAULT_CHILD               RuleId = 2 // XOR composite states must have exactly one default child.
-	RuleId_BASIC_HAS_NO_CHILDREN              RuleId = 3 // Basic states cannot have children.
-	RuleId_COMPOUND_HAS_CHILDREN              RuleId = 4 // Compound states must have children.
-	RuleId_DETERMINISTIC_TRANSITION_SELECTION RuleId = 5 // Transition selection must be deterministic.
-	RuleId_NO_EVENT_BROADCAST_CYCLES          RuleId = 6 // Event broadcast must not create cycles.
+	RuleId_RULE_UNSPECIFIED                   RuleId = 0  // Unspecified rule.
+	RuleId_UNIQUE_STATE_LABELS                RuleId = 1  // All state labels must be unique.
+	RuleId_SINGLE_DEFAULT_CHILD               RuleId = 2  // XOR composite states must have exactly one default child.
+	RuleId_BASIC_HAS_NO_CHILDREN              RuleId = 3  // Basic states cannot have children.
+	RuleId_COMPOUND_HAS_CHILDREN              RuleId = 4  // Compound states must have children.
+	RuleId_DETERMINISTIC_TRANSITION_SELECTION RuleId = 5  // Transition selection must be deterministic.
+	RuleId_NO_EVENT_BROADCAST_CYCLES          RuleId = 6  // Event broadcast must not create cycles.
+	RuleId_REACHABLE_STATES                   RuleId = 7  // Every state must be reachable from the initial configuration.
+	RuleId_LIVE_TRANSITIONS                   RuleId = 8  // Every transition must be able to fire in some reachable configuration.
+	RuleId_NO_DEADLOCKS                       RuleId = 9  // Every reachable configuration without outgoing transitions must be final.
+	RuleId_ROOT_STATE                         RuleId = 10 // The root state must exist and be labeled __root__.
+	RuleId_FORKS_AND_JOINS                    RuleId = 11 // The sources of a transition must be able to be active together and its targets must be orthogonal.
+	RuleId_INTERNAL_TRANSITIONS               RuleId = 12 // Internal transitions must target no state other than their sources.
+	RuleId_PSEUDOSTATES                       RuleId = 13 // Choices and junctions must be leaves left by eventless branches, with at most one else branch.
+	RuleId_INLINED_SUBMACHINES                RuleId = 14 // Submachine states must be inlined before the statechart is executed or analyzed.
+	RuleId_CONNECTION_POINTS                  RuleId = 15 // Entry and exit points must be children of the root state, entered and left only by the containing chart.
+	RuleId_INVOKES                            RuleId = 16 // Invocations must name a statechart and have IDs unique within the statechart.
+	RuleId_CONFIGURATION_GRAPH                RuleId = 17 // The configuration graph must be small enough to be explored by the behavioral rules.
+	RuleId_TRACE_STEPS                        RuleId = 18 // Each machine of a trace must follow from the previous one by a step of the chart.
+	RuleId_TRANSITION_ENDPOINTS               RuleId = 19 // The sources and targets of transitions must be states of the statechart.
 )
 
 // Enum value maps for RuleId.
 var (
 	RuleId_name = map[int32]string{
-		0: "RULE_UNSPECIFIED",
-		1: "UNIQUE_STATE_LABELS",
-		2: "SINGLE_DEFAULT_CHILD",
-		3: "BASIC_HAS_NO_CHILDREN",
-		4: "COMPOUND_HAS_CHILDREN",
-		5: "DETERMINISTIC_TRANSITION_SELECTION",
-		6: "NO_EVENT_BROADCAST_CYCLES",
+		0:  "RULE_UNSPECIFIED",
+		1:  "UNIQUE_STATE_LABELS",
+		2:  "SINGLE_DEFAULT_CHILD",
+		3:  "BASIC_HAS_NO_CHILDREN",
+		4:  "COMPOUND_HAS_CHILDREN",
+		5:  "DETERMINISTIC_TRANSITION_SELECTION",
+		6:  "NO_EVENT_BROADCAST_CYCLES",
+		7:  "REACHABLE_STATES",
+		8:  "LIVE_TRANSITIONS",
+		9:  "NO_DEADLOCKS",
+		10: "ROOT_STATE",
+		11: "FORKS_AND_JOINS",
+		12: "INTERNAL_TRANSITIONS",
+		13: "PSEUDOSTATES",
+		14: "INLINED_SUBMACHINES",
+		15: "CONNECTION_POINTS",
+		16: "INVOKES",
+		17: "CONFIGURATION_GRAPH",
+		18: "TRACE_STEPS",
+		19: "TRANSITION_ENDPOINTS",
 	}
 	RuleId_value = map[string]int32{
 		"RULE_UNSPECIFIED":                   0,
@@ -111,6 +188,19 @@ var (
 		"COMPOUND_HAS_CHILDREN":              4,
 		"DETERMINISTIC_TRANSITION_SELECTION": 5,
 		"NO_EVENT_BROADCAST_CYCLES":          6,
+		"REACHABLE_STATES":                   7,
+		"LIVE_TRANSITIONS":                   8,
+		"NO_DEADLOCKS":                       9,
+		"ROOT_STATE":                         10,
+		"FORKS_AND_JOINS":                    11,
+		"INTERNAL_TRANSITIONS":               12,
+		"PSEUDOSTATES":                       13,
+		"INLINED_SUBMACHINES":                14,
+		"CONNECTION_POINTS":                  15,
+		"INVOKES":                            16,
+		"CONFIGURATION_GRAPH":                17,
+		"TRACE_STEPS":                        18,
+		"TRANSITION_ENDPOINTS":               19,
 	}
 )
 
@@ -125,11 +215,11 @@ func (x RuleId) String() string {
 }
 
 func (RuleId) Descriptor() protoreflect.EnumDescriptor {
-	return file_validation_v1_validator_proto_enumTypes[1].Descriptor()
+	return file_validation_v1_validator_proto_enumTypes[2].Descriptor()
 }
 
 func (RuleId) Type() protoreflect.EnumType {
-	return &file_validation_v1_validator_proto_enumTypes[1]
+	return &file_validation_v1_validator_proto_enumTypes[2]
 }
 
 func (x RuleId) Number() protoreflect.EnumNumber {
@@ -138,18 +228,22 @@ func (x RuleId) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use RuleId.Descriptor instead.
 func (RuleId) EnumDescriptor() ([]byte, []int) {
-	return file_validation_v1_validator_proto_rawDescGZIP(), []int{1}
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{2}
 }
 
 // *
 // ValidateChartRequest is the request message for validating a statechart.
-// It contains the statechart to validate and an optional list of rules to ignore.
+// It contains the statechart to validate, an optional list of rules to ignore,
+// and optional severities overriding the default severities of rules.
+// The statechart is given either as chart or, to locate violations in its text, as source.
 type ValidateChartRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Chart         *v1.Statechart         `protobuf:"bytes,1,opt,name=chart,proto3" json:"chart,omitempty"`                                                                              // The statechart to validate.
-	IgnoreRules   []RuleId               `protobuf:"varint,2,rep,packed,name=ignore_rules,json=ignoreRules,proto3,enum=statecharts.validation.v1.RuleId" json:"ignore_rules,omitempty"` // Optional list of rules to ignore during validation.
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Chart             *v1.Statechart         `protobuf:"bytes,1,opt,name=chart,proto3" json:"chart,omitempty"`                                                                                                                                                                     // The statechart to validate.
+	IgnoreRules       []RuleId               `protobuf:"varint,2,rep,packed,name=ignore_rules,json=ignoreRules,proto3,enum=statecharts.validation.v1.RuleId" json:"ignore_rules,omitempty"`                                                                                        // Optional list of rules to ignore during validation.
+	SeverityOverrides map[string]Severity    `protobuf:"bytes,3,rep,name=severity_overrides,json=severityOverrides,proto3" json:"severity_overrides,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value,enum=statecharts.validation.v1.Severity"` // Severities of violations by rule ID, replacing the defaults of the rules.
+	Source            *ChartSource           `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`                                                                                                                                                                   // The statechart to validate in a text format, instead of chart.
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *ValidateChartRequest) Reset() {
@@ -196,16 +290,34 @@ func (x *ValidateChartRequest) GetIgnoreRules() []RuleId {
 	return nil
 }
 
+func (x *ValidateChartRequest) GetSeverityOverrides() map[string]Severity {
+	if x != nil {
+		return x.SeverityOverrides
+	}
+	return nil
+}
+
+func (x *ValidateChartRequest) GetSource() *ChartSource {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
 // *
 // ValidateTraceRequest is the request message for validating a trace.
-// It contains the statechart and trace to validate, and an optional list of rules to ignore.
+// It contains the statechart and trace to validate, an optional list of rules to ignore,
+// and optional severities overriding the default severities of rules.
+// The statechart is given either as chart or, to locate violations in its text, as source.
 type ValidateTraceRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Chart         *v1.Statechart         `protobuf:"bytes,1,opt,name=chart,proto3" json:"chart,omitempty"`                                                                              // The statechart definition.
-	Trace         []*v1.Machine          `protobuf:"bytes,2,rep,name=trace,proto3" json:"trace,omitempty"`                                                                              // The trace of machine states to validate.
-	IgnoreRules   []RuleId               `protobuf:"varint,3,rep,packed,name=ignore_rules,json=ignoreRules,proto3,enum=statecharts.validation.v1.RuleId" json:"ignore_rules,omitempty"` // Optional list of rules to ignore during validation.
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Chart             *v1.Statechart         `protobuf:"bytes,1,opt,name=chart,proto3" json:"chart,omitempty"`                                                                                                                                                                     // The statechart definition.
+	Trace             []*v1.Machine          `protobuf:"bytes,2,rep,name=trace,proto3" json:"trace,omitempty"`                                                                                                                                                                     // The trace of machine states to validate.
+	IgnoreRules       []RuleId               `protobuf:"varint,3,rep,packed,name=ignore_rules,json=ignoreRules,proto3,enum=statecharts.validation.v1.RuleId" json:"ignore_rules,omitempty"`                                                                                        // Optional list of rules to ignore during validation.
+	SeverityOverrides map[string]Severity    `protobuf:"bytes,4,rep,name=severity_overrides,json=severityOverrides,proto3" json:"severity_overrides,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value,enum=statecharts.validation.v1.Severity"` // Severities of violations by rule ID, replacing the defaults of the rules.
+	Source            *ChartSource           `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`                                                                                                                                                                   // The statechart definition in a text format, instead of chart.
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *ValidateTraceRequest) Reset() {
@@ -259,6 +371,74 @@ func (x *ValidateTraceRequest) GetIgnoreRules() []RuleId {
 	return nil
 }
 
+func (x *ValidateTraceRequest) GetSeverityOverrides() map[string]Severity {
+	if x != nil {
+		return x.SeverityOverrides
+	}
+	return nil
+}
+
+func (x *ValidateTraceRequest) GetSource() *ChartSource {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+// *
+// ChartSource is a statechart in a text format, such as a file open in an editor.
+type ChartSource struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        SourceFormat           `protobuf:"varint,1,opt,name=format,proto3,enum=statecharts.validation.v1.SourceFormat" json:"format,omitempty"` // The format of the text.
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`                                                  // The text of the statechart.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChartSource) Reset() {
+	*x = ChartSource{}
+	mi := &file_validation_v1_validator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChartSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChartSource) ProtoMessage() {}
+
+func (x *ChartSource) ProtoReflect() protoreflect.Message {
+	mi := &file_validation_v1_validator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChartSource.ProtoReflect.Descriptor instead.
+func (*ChartSource) Descriptor() ([]byte, []int) {
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChartSource) GetFormat() SourceFormat {
+	if x != nil {
+		return x.Format
+	}
+	return SourceFormat_SOURCE_FORMAT_UNSPECIFIED
+}
+
+func (x *ChartSource) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
 // *
 // ValidateChartResponse is the response message for chart validation.
 // It contains a status and a list of violations found during validation.
@@ -272,7 +452,7 @@ type ValidateChartResponse struct {
 
 func (x *ValidateChartResponse) Reset() {
 	*x = ValidateChartResponse{}
-	mi := &file_validation_v1_validator_proto_msgTypes[2]
+	mi := &file_validation_v1_validator_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -284,7 +464,7 @@ func (x *ValidateChartResponse) String() string {
 func (*ValidateChartResponse) ProtoMessage() {}
 
 func (x *ValidateChartResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_validation_v1_validator_proto_msgTypes[2]
+	mi := &file_validation_v1_validator_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -297,7 +477,7 @@ func (x *ValidateChartResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ValidateChartResponse.ProtoReflect.Descriptor instead.
 func (*ValidateChartResponse) Descriptor() ([]byte, []int) {
-	return file_validation_v1_validator_proto_rawDescGZIP(), []int{2}
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ValidateChartResponse) GetStatus() *status.Status {
@@ -327,7 +507,7 @@ type ValidateTraceResponse struct {
 
 func (x *ValidateTraceResponse) Reset() {
 	*x = ValidateTraceResponse{}
-	mi := &file_validation_v1_validator_proto_msgTypes[3]
+	mi := &file_validation_v1_validator_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -339,7 +519,7 @@ func (x *ValidateTraceResponse) String() string {
 func (*ValidateTraceResponse) ProtoMessage() {}
 
 func (x *ValidateTraceResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_validation_v1_validator_proto_msgTypes[3]
+	mi := &file_validation_v1_validator_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -352,7 +532,7 @@ func (x *ValidateTraceResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ValidateTraceResponse.ProtoReflect.Descriptor instead.
 func (*ValidateTraceResponse) Descriptor() ([]byte, []int) {
-	return file_validation_v1_validator_proto_rawDescGZIP(), []int{3}
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ValidateTraceResponse) GetStatus() *status.Status {
@@ -372,19 +552,23 @@ func (x *ValidateTraceResponse) GetViolations() []*Violation {
 // *
 // Violation represents a rule violation found during validation.
 // It includes the rule that was violated, the severity, a message, and optional location hints.
+// Rules registered by applications have no RuleId and are identified by rule_id alone.
+// Every violation found is reported, not only the first of each rule.
 type Violation struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Rule          RuleId                 `protobuf:"varint,1,opt,name=rule,proto3,enum=statecharts.validation.v1.RuleId" json:"rule,omitempty"`           // The rule that was violated.
+	Rule          RuleId                 `protobuf:"varint,1,opt,name=rule,proto3,enum=statecharts.validation.v1.RuleId" json:"rule,omitempty"`           // The rule that was violated; RULE_UNSPECIFIED for rules registered by applications.
 	Severity      Severity               `protobuf:"varint,2,opt,name=severity,proto3,enum=statecharts.validation.v1.Severity" json:"severity,omitempty"` // The severity of the violation.
 	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`                                            // A human-readable message describing the violation.
-	Xpath         []string               `protobuf:"bytes,4,rep,name=xpath,proto3" json:"xpath,omitempty"`                                                // Location hints (optional).
+	Xpath         []string               `protobuf:"bytes,4,rep,name=xpath,proto3" json:"xpath,omitempty"`                                                // Paths of the offending elements of the chart, such as /root_state/children[2]/children[0].
+	RuleId        string                 `protobuf:"bytes,5,opt,name=rule_id,json=ruleId,proto3" json:"rule_id,omitempty"`                                // The ID of the rule that was violated: the name of its RuleId for built-in rules.
+	Locations     []*SourceLocation      `protobuf:"bytes,6,rep,name=locations,proto3" json:"locations,omitempty"`                                        // Positions of the elements at xpath in the text of the chart, if it was given as source.
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Violation) Reset() {
 	*x = Violation{}
-	mi := &file_validation_v1_validator_proto_msgTypes[4]
+	mi := &file_validation_v1_validator_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -396,7 +580,7 @@ func (x *Violation) String() string {
 func (*Violation) ProtoMessage() {}
 
 func (x *Violation) ProtoReflect() protoreflect.Message {
-	mi := &file_validation_v1_validator_proto_msgTypes[4]
+	mi := &file_validation_v1_validator_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -409,7 +593,7 @@ func (x *Violation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Violation.ProtoReflect.Descriptor instead.
 func (*Violation) Descriptor() ([]byte, []int) {
-	return file_validation_v1_validator_proto_rawDescGZIP(), []int{4}
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Violation) GetRule() RuleId {
@@ -440,18 +624,108 @@ func (x *Violation) GetXpath() []string {
 	return nil
 }
 
+func (x *Violation) GetRuleId() string {
+	if x != nil {
+		return x.RuleId
+	}
+	return ""
+}
+
+func (x *Violation) GetLocations() []*SourceLocation {
+	if x != nil {
+		return x.Locations
+	}
+	return nil
+}
+
+// *
+// SourceLocation is the position of an element of a statechart in its text:
+// the position of its field name, or of its value in a list.
+type SourceLocation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Xpath         string                 `protobuf:"bytes,1,opt,name=xpath,proto3" json:"xpath,omitempty"`    // The path of the element.
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`     // The line of the element, starting at 1.
+	Column        int32                  `protobuf:"varint,3,opt,name=column,proto3" json:"column,omitempty"` // The column of the element in bytes, starting at 1.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourceLocation) Reset() {
+	*x = SourceLocation{}
+	mi := &file_validation_v1_validator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceLocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceLocation) ProtoMessage() {}
+
+func (x *SourceLocation) ProtoReflect() protoreflect.Message {
+	mi := &file_validation_v1_validator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceLocation.ProtoReflect.Descriptor instead.
+func (*SourceLocation) Descriptor() ([]byte, []int) {
+	return file_validation_v1_validator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SourceLocation) GetXpath() string {
+	if x != nil {
+		return x.Xpath
+	}
+	return ""
+}
+
+func (x *SourceLocation) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *SourceLocation) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
 var File_validation_v1_validator_proto protoreflect.FileDescriptor
 
 const file_validation_v1_validator_proto_rawDesc = "" +
 	"\n" +
-	"\x1dvalidation/v1/validator.proto\x12\x19statecharts.validation.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17google/rpc/status.proto\x1a statecharts/v1/statecharts.proto\"\x8e\x01\n" +
+	"\x1dvalidation/v1/validator.proto\x12\x19statecharts.validation.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17google/rpc/status.proto\x1a statecharts/v1/statecharts.proto\"\xb0\x03\n" +
 	"\x14ValidateChartRequest\x120\n" +
 	"\x05chart\x18\x01 \x01(\v2\x1a.statecharts.v1.StatechartR\x05chart\x12D\n" +
-	"\fignore_rules\x18\x02 \x03(\x0e2!.statecharts.validation.v1.RuleIdR\vignoreRules\"\xbd\x01\n" +
+	"\fignore_rules\x18\x02 \x03(\x0e2!.statecharts.validation.v1.RuleIdR\vignoreRules\x12u\n" +
+	"\x12severity_overrides\x18\x03 \x03(\v2F.statecharts.validation.v1.ValidateChartRequest.SeverityOverridesEntryR\x11severityOverrides\x12>\n" +
+	"\x06source\x18\x04 \x01(\v2&.statecharts.validation.v1.ChartSourceR\x06source\x1ai\n" +
+	"\x16SeverityOverridesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x129\n" +
+	"\x05value\x18\x02 \x01(\x0e2#.statecharts.validation.v1.SeverityR\x05value:\x028\x01\"\xdf\x03\n" +
 	"\x14ValidateTraceRequest\x120\n" +
 	"\x05chart\x18\x01 \x01(\v2\x1a.statecharts.v1.StatechartR\x05chart\x12-\n" +
 	"\x05trace\x18\x02 \x03(\v2\x17.statecharts.v1.MachineR\x05trace\x12D\n" +
-	"\fignore_rules\x18\x03 \x03(\x0e2!.statecharts.validation.v1.RuleIdR\vignoreRules\"\x89\x01\n" +
+	"\fignore_rules\x18\x03 \x03(\x0e2!.statecharts.validation.v1.RuleIdR\vignoreRules\x12u\n" +
+	"\x12severity_overrides\x18\x04 \x03(\v2F.statecharts.validation.v1.ValidateTraceRequest.SeverityOverridesEntryR\x11severityOverrides\x12>\n" +
+	"\x06source\x18\x05 \x01(\v2&.statecharts.validation.v1.ChartSourceR\x06source\x1ai\n" +
+	"\x16SeverityOverridesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x129\n" +
+	"\x05value\x18\x02 \x01(\x0e2#.statecharts.validation.v1.SeverityR\x05value:\x028\x01\"b\n" +
+	"\vChartSource\x12?\n" +
+	"\x06format\x18\x01 \x01(\x0e2'.statecharts.validation.v1.SourceFormatR\x06format\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"\x89\x01\n" +
 	"\x15ValidateChartResponse\x12*\n" +
 	"\x06status\x18\x01 \x01(\v2\x12.google.rpc.StatusR\x06status\x12D\n" +
 	"\n" +
@@ -461,17 +735,27 @@ const file_validation_v1_validator_proto_rawDesc = "" +
 	"\x06status\x18\x01 \x01(\v2\x12.google.rpc.StatusR\x06status\x12D\n" +
 	"\n" +
 	"violations\x18\x02 \x03(\v2$.statecharts.validation.v1.ViolationR\n" +
-	"violations\"\xb3\x01\n" +
+	"violations\"\x95\x02\n" +
 	"\tViolation\x125\n" +
 	"\x04rule\x18\x01 \x01(\x0e2!.statecharts.validation.v1.RuleIdR\x04rule\x12?\n" +
 	"\bseverity\x18\x02 \x01(\x0e2#.statecharts.validation.v1.SeverityR\bseverity\x12\x18\n" +
 	"\amessage\x18\x03 \x01(\tR\amessage\x12\x14\n" +
-	"\x05xpath\x18\x04 \x03(\tR\x05xpath*F\n" +
+	"\x05xpath\x18\x04 \x03(\tR\x05xpath\x12\x17\n" +
+	"\arule_id\x18\x05 \x01(\tR\x06ruleId\x12G\n" +
+	"\tlocations\x18\x06 \x03(\v2).statecharts.validation.v1.SourceLocationR\tlocations\"R\n" +
+	"\x0eSourceLocation\x12\x14\n" +
+	"\x05xpath\x18\x01 \x01(\tR\x05xpath\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x03 \x01(\x05R\x06column*F\n" +
+	"\fSourceFormat\x12\x1d\n" +
+	"\x19SOURCE_FORMAT_UNSPECIFIED\x10\x00\x12\b\n" +
+	"\x04JSON\x10\x01\x12\r\n" +
+	"\tTEXTPROTO\x10\x02*F\n" +
 	"\bSeverity\x12\x18\n" +
 	"\x14SEVERITY_UNSPECIFIED\x10\x00\x12\b\n" +
 	"\x04INFO\x10\x01\x12\v\n" +
 	"\aWARNING\x10\x02\x12\t\n" +
-	"\x05ERROR\x10\x03*\xce\x01\n" +
+	"\x05ERROR\x10\x03*\xde\x03\n" +
 	"\x06RuleId\x12\x14\n" +
 	"\x10RULE_UNSPECIFIED\x10\x00\x12\x17\n" +
 	"\x13UNIQUE_STATE_LABELS\x10\x01\x12\x18\n" +
@@ -479,7 +763,22 @@ const file_validation_v1_validator_proto_rawDesc = "" +
 	"\x15BASIC_HAS_NO_CHILDREN\x10\x03\x12\x19\n" +
 	"\x15COMPOUND_HAS_CHILDREN\x10\x04\x12&\n" +
 	"\"DETERMINISTIC_TRANSITION_SELECTION\x10\x05\x12\x1d\n" +
-	"\x19NO_EVENT_BROADCAST_CYCLES\x10\x062\xfb\x01\n" +
+	"\x19NO_EVENT_BROADCAST_CYCLES\x10\x06\x12\x14\n" +
+	"\x10REACHABLE_STATES\x10\a\x12\x14\n" +
+	"\x10LIVE_TRANSITIONS\x10\b\x12\x10\n" +
+	"\fNO_DEADLOCKS\x10\t\x12\x0e\n" +
+	"\n" +
+	"ROOT_STATE\x10\n" +
+	"\x12\x13\n" +
+	"\x0fFORKS_AND_JOINS\x10\v\x12\x18\n" +
+	"\x14INTERNAL_TRANSITIONS\x10\f\x12\x10\n" +
+	"\fPSEUDOSTATES\x10\r\x12\x17\n" +
+	"\x13INLINED_SUBMACHINES\x10\x0e\x12\x15\n" +
+	"\x11CONNECTION_POINTS\x10\x0f\x12\v\n" +
+	"\aINVOKES\x10\x10\x12\x17\n" +
+	"\x13CONFIGURATION_GRAPH\x10\x11\x12\x0f\n" +
+	"\vTRACE_STEPS\x10\x12\x12\x18\n" +
+	"\x14TRANSITION_ENDPOINTS\x10\x132\xfb\x01\n" +
 	"\x11SemanticValidator\x12r\n" +
 	"\rValidateChart\x12/.statecharts.validation.v1.ValidateChartRequest\x1a0.statecharts.validation.v1.ValidateChartResponse\x12r\n" +
 	"\rValidateTrace\x12/.statecharts.validation.v1.ValidateTraceRequest\x1a0.statecharts.validation.v1.ValidateTraceResponseB\xe3\x01\n" +
@@ -497,41 +796,54 @@ func file_validation_v1_validator_proto_rawDescGZIP() []byte {
 	return file_validation_v1_validator_proto_rawDescData
 }
 
-var file_validation_v1_validator_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_validation_v1_validator_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_validation_v1_validator_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_validation_v1_validator_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_validation_v1_validator_proto_goTypes = []any{
-	(Severity)(0),                 // 0: statecharts.validation.v1.Severity
-	(RuleId)(0),                   // 1: statecharts.validation.v1.RuleId
-	(*ValidateChartRequest)(nil),  // 2: statecharts.validation.v1.ValidateChartRequest
-	(*ValidateTraceRequest)(nil),  // 3: statecharts.validation.v1.ValidateTraceRequest
-	(*ValidateChartResponse)(nil), // 4: statecharts.validation.v1.ValidateChartResponse
-	(*ValidateTraceResponse)(nil), // 5: statecharts.validation.v1.ValidateTraceResponse
-	(*Violation)(nil),             // 6: statecharts.validation.v1.Violation
-	(*v1.Statechart)(nil),         // 7: statecharts.v1.Statechart
-	(*v1.Machine)(nil),            // 8: statecharts.v1.Machine
-	(*status.Status)(nil),         // 9: google.rpc.Status
+	(SourceFormat)(0),             // 0: statecharts.validation.v1.SourceFormat
+	(Severity)(0),                 // 1: statecharts.validation.v1.Severity
+	(RuleId)(0),                   // 2: statecharts.validation.v1.RuleId
+	(*ValidateChartRequest)(nil),  // 3: statecharts.validation.v1.ValidateChartRequest
+	(*ValidateTraceRequest)(nil),  // 4: statecharts.validation.v1.ValidateTraceRequest
+	(*ChartSource)(nil),           // 5: statecharts.validation.v1.ChartSource
+	(*ValidateChartResponse)(nil), // 6: statecharts.validation.v1.ValidateChartResponse
+	(*ValidateTraceResponse)(nil), // 7: statecharts.validation.v1.ValidateTraceResponse
+	(*Violation)(nil),             // 8: statecharts.validation.v1.Violation
+	(*SourceLocation)(nil),        // 9: statecharts.validation.v1.SourceLocation
+	nil,                           // 10: statecharts.validation.v1.ValidateChartRequest.SeverityOverridesEntry
+	nil,                           // 11: statecharts.validation.v1.ValidateTraceRequest.SeverityOverridesEntry
+	(*v1.Statechart)(nil),         // 12: statecharts.v1.Statechart
+	(*v1.Machine)(nil),            // 13: statecharts.v1.Machine
+	(*status.Status)(nil),         // 14: google.rpc.Status
 }
 var file_validation_v1_validator_proto_depIdxs = []int32{
-	7,  // 0: statecharts.validation.v1.ValidateChartRequest.chart:type_name -> statecharts.v1.Statechart
-	1,  // 1: statecharts.validation.v1.ValidateChartRequest.ignore_rules:type_name -> statecharts.validation.v1.RuleId
-	7,  // 2: statecharts.validation.v1.ValidateTraceRequest.chart:type_name -> statecharts.v1.Statechart
-	8,  // 3: statecharts.validation.v1.ValidateTraceRequest.trace:type_name -> statecharts.v1.Machine
-	1,  // 4: statecharts.validation.v1.ValidateTraceRequest.ignore_rules:type_name -> statecharts.validation.v1.RuleId
-	9,  // 5: statecharts.validation.v1.ValidateChartResponse.status:type_name -> google.rpc.Status
-	6,  // 6: statecharts.validation.v1.ValidateChartResponse.violations:type_name -> statecharts.validation.v1.Violation
-	9,  // 7: statecharts.validation.v1.ValidateTraceResponse.status:type_name -> google.rpc.Status
-	6,  // 8: statecharts.validation.v1.ValidateTraceResponse.violations:type_name -> statecharts.validation.v1.Violation
-	1,  // 9: statecharts.validation.v1.Violation.rule:type_name -> statecharts.validation.v1.RuleId
-	0,  // 10: statecharts.validation.v1.Violation.severity:type_name -> statecharts.validation.v1.Severity
-	2,  // 11: statecharts.validation.v1.SemanticValidator.ValidateChart:input_type -> statecharts.validation.v1.ValidateChartRequest
-	3,  // 12: statecharts.validation.v1.SemanticValidator.ValidateTrace:input_type -> statecharts.validation.v1.ValidateTraceRequest
-	4,  // 13: statecharts.validation.v1.SemanticValidator.ValidateChart:output_type -> statecharts.validation.v1.ValidateChartResponse
-	5,  // 14: statecharts.validation.v1.SemanticValidator.ValidateTrace:output_type -> statecharts.validation.v1.ValidateTraceResponse
-	13, // [13:15] is the sub-list for method output_type
-	11, // [11:13] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	12, // 0: statecharts.validation.v1.ValidateChartRequest.chart:type_name -> statecharts.v1.Statechart
+	2,  // 1: statecharts.validation.v1.ValidateChartRequest.ignore_rules:type_name -> statecharts.validation.v1.RuleId
+	10, // 2: statecharts.validation.v1.ValidateChartRequest.severity_overrides:type_name -> statecharts.validation.v1.ValidateChartRequest.SeverityOverridesEntry
+	5,  // 3: statecharts.validation.v1.ValidateChartRequest.source:type_name -> statecharts.validation.v1.ChartSource
+	12, // 4: statecharts.validation.v1.ValidateTraceRequest.chart:type_name -> statecharts.v1.Statechart
+	13, // 5: statecharts.validation.v1.ValidateTraceRequest.trace:type_name -> statecharts.v1.Machine
+	2,  // 6: statecharts.validation.v1.ValidateTraceRequest.ignore_rules:type_name -> statecharts.validation.v1.RuleId
+	11, // 7: statecharts.validation.v1.ValidateTraceRequest.severity_overrides:type_name -> statecharts.validation.v1.ValidateTraceRequest.SeverityOverridesEntry
+	5,  // 8: statecharts.validation.v1.ValidateTraceRequest.source:type_name -> statecharts.validation.v1.ChartSource
+	0,  // 9: statecharts.validation.v1.ChartSource.format:type_name -> statecharts.validation.v1.SourceFormat
+	14, // 10: statecharts.validation.v1.ValidateChartResponse.status:type_name -> google.rpc.Status
+	8,  // 11: statecharts.validation.v1.ValidateChartResponse.violations:type_name -> statecharts.validation.v1.Violation
+	14, // 12: statecharts.validation.v1.ValidateTraceResponse.status:type_name -> google.rpc.Status
+	8,  // 13: statecharts.validation.v1.ValidateTraceResponse.violations:type_name -> statecharts.validation.v1.Violation
+	2,  // 14: statecharts.validation.v1.Violation.rule:type_name -> statecharts.validation.v1.RuleId
+	1,  // 15: statecharts.validation.v1.Violation.severity:type_name -> statecharts.validation.v1.Severity
+	9,  // 16: statecharts.validation.v1.Violation.locations:type_name -> statecharts.validation.v1.SourceLocation
+	1,  // 17: statecharts.validation.v1.ValidateChartRequest.SeverityOverridesEntry.value:type_name -> statecharts.validation.v1.Severity
+	1,  // 18: statecharts.validation.v1.ValidateTraceRequest.SeverityOverridesEntry.value:type_name -> statecharts.validation.v1.Severity
+	3,  // 19: statecharts.validation.v1.SemanticValidator.ValidateChart:input_type -> statecharts.validation.v1.ValidateChartRequest
+	4,  // 20: statecharts.validation.v1.SemanticValidator.ValidateTrace:input_type -> statecharts.validation.v1.ValidateTraceRequest
+	6,  // 21: statecharts.validation.v1.SemanticValidator.ValidateChart:output_type -> statecharts.validation.v1.ValidateChartResponse
+	7,  // 22: statecharts.validation.v1.SemanticValidator.ValidateTrace:output_type -> statecharts.validation.v1.ValidateTraceResponse
+	21, // [21:23] is the sub-list for method output_type
+	19, // [19:21] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_validation_v1_validator_proto_init() }
@@ -544,8 +856,8 @@ func file_validation_v1_validator_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_validation_v1_validator_proto_rawDesc), len(file_validation_v1_validator_proto_rawDesc)),
-			NumEnums:      2,
-			NumMessages:   5,
+			NumEnums:      3,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
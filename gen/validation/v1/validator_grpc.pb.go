@@ -33,7 +33,7 @@ const (
 type SemanticValidatorClient interface {
 	// ValidateChart validates a statechart definition against semantic rules.
 	ValidateChart(ctx context.Context, in *ValidateChartRequest, opts ...grpc.CallOption) (*ValidateChartResponse, error)
-	// ValidateTrace validates a statechart and a trace of machine states.
+	// ValidateTrace validates a statechart and replays a trace of its machines with the step engine.
 	ValidateTrace(ctx context.Context, in *ValidateTraceRequest, opts ...grpc.CallOption) (*ValidateTraceResponse, error)
 }
 
@@ -75,7 +75,7 @@ func (c *semanticValidatorClient) ValidateTrace(ctx context.Context, in *Validat
 type SemanticValidatorServer interface {
 	// ValidateChart validates a statechart definition against semantic rules.
 	ValidateChart(context.Context, *ValidateChartRequest) (*ValidateChartResponse, error)
-	// ValidateTrace validates a statechart and a trace of machine states.
+	// ValidateTrace validates a statechart and replays a trace of its machines with the step engine.
 	ValidateTrace(context.Context, *ValidateTraceRequest) (*ValidateTraceResponse, error)
 	mustEmbedUnimplementedSemanticValidatorServer()
 }
@@ -10,11 +10,13 @@ require (
 	google.golang.org/protobuf v1.36.5
 )
 
-require github.com/google/go-cmp v0.6.0
+require (
+	github.com/google/go-cmp v0.6.0
+	google.golang.org/grpc v1.72.0
+)
 
 require (
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
-	google.golang.org/grpc v1.72.0 // indirect
 )
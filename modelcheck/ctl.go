@@ -0,0 +1,240 @@
+package modelcheck
+
+// CheckCTL checks that a CTL formula holds in every initial state of the model.
+//
+// States without successors repeat forever, so every path is infinite. If the
+// formula does not hold, the counterexample starts in a violating initial
+// state. For AG p it leads to a state violating p, for AF p it is a lasso on
+// which p never holds, and for AX p it ends in a successor violating p.
+func (m *Model) CheckCTL(src string) (*Result, error) {
+	f, err := m.parseFormula(src, ctl)
+	if err != nil {
+		return nil, err
+	}
+	k, err := m.explore()
+	if err != nil {
+		return nil, err
+	}
+	c := &ctlChecker{k: k, labels: newLabeling(k)}
+	sat, err := c.sat(f)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Property: src, Holds: true, LoopStart: -1, States: len(k.states)}
+	for _, i := range k.initial {
+		if sat[i] {
+			continue
+		}
+		result.Holds = false
+		path, loopStart, err := c.counterexample(f, i)
+		if err != nil {
+			return nil, err
+		}
+		result.Counterexample = k.trace(path)
+		result.LoopStart = loopStart
+		break
+	}
+	return result, nil
+}
+
+// ctlChecker labels the states of a structure with the subformulas they satisfy.
+type ctlChecker struct {
+	k      *kripke
+	labels *labeling
+}
+
+// sat returns the set of states satisfying f.
+func (c *ctlChecker) sat(f *formula) ([]bool, error) {
+	n := len(c.k.states)
+	result := make([]bool, n)
+	args := make([][]bool, len(f.args))
+	for i, arg := range f.args {
+		s, err := c.sat(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = s
+	}
+	switch f.op {
+	case "true":
+		for i := range result {
+			result[i] = true
+		}
+	case "false":
+	case "prop":
+		for i := range result {
+			ok, err := c.labels.holds(f.prop, i)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = ok
+		}
+	case "!":
+		for i := range result {
+			result[i] = !args[0][i]
+		}
+	case "&&":
+		for i := range result {
+			result[i] = args[0][i] && args[1][i]
+		}
+	case "||":
+		for i := range result {
+			result[i] = args[0][i] || args[1][i]
+		}
+	case "->":
+		for i := range result {
+			result[i] = !args[0][i] || args[1][i]
+		}
+	case "EX":
+		for i := range result {
+			result[i] = c.some(i, args[0])
+		}
+	case "AX":
+		for i := range result {
+			result[i] = c.all(i, args[0])
+		}
+	case "EF":
+		result = c.leastFixpoint(all(n), args[0], c.some)
+	case "AF":
+		result = c.leastFixpoint(all(n), args[0], c.all)
+	case "EU":
+		result = c.leastFixpoint(args[0], args[1], c.some)
+	case "AU":
+		result = c.leastFixpoint(args[0], args[1], c.all)
+	case "EG":
+		result = c.greatestFixpoint(args[0], c.some)
+	case "AG":
+		result = c.greatestFixpoint(args[0], c.all)
+	}
+	return result, nil
+}
+
+// leastFixpoint computes the least set Z with Z = goal ∪ (hold ∩ pre(Z)).
+func (c *ctlChecker) leastFixpoint(hold, goal []bool, pre func(int, []bool) bool) []bool {
+	z := append([]bool(nil), goal...)
+	for changed := true; changed; {
+		changed = false
+		for i := range z {
+			if !z[i] && hold[i] && pre(i, z) {
+				z[i], changed = true, true
+			}
+		}
+	}
+	return z
+}
+
+// greatestFixpoint computes the greatest set Z with Z = hold ∩ pre(Z).
+func (c *ctlChecker) greatestFixpoint(hold []bool, pre func(int, []bool) bool) []bool {
+	z := append([]bool(nil), hold...)
+	for changed := true; changed; {
+		changed = false
+		for i := range z {
+			if z[i] && !pre(i, z) {
+				z[i], changed = false, true
+			}
+		}
+	}
+	return z
+}
+
+// some reports whether some successor of state i is in the set.
+func (c *ctlChecker) some(i int, set []bool) bool {
+	for _, e := range c.k.states[i].edges {
+		if set[e.to] {
+			return true
+		}
+	}
+	return false
+}
+
+// all reports whether every successor of state i is in the set.
+func (c *ctlChecker) all(i int, set []bool) bool {
+	for _, e := range c.k.states[i].edges {
+		if !set[e.to] {
+			return false
+		}
+	}
+	return true
+}
+
+func all(n int) []bool {
+	set := make([]bool, n)
+	for i := range set {
+		set[i] = true
+	}
+	return set
+}
+
+// counterexample returns a path from the initial state i that shows why f
+// fails there, and the index at which the path loops or -1.
+func (c *ctlChecker) counterexample(f *formula, i int) ([]int, int, error) {
+	switch f.op {
+	case "AG":
+		// Find a shortest path to a state violating the argument.
+		sat, err := c.sat(f.args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		return c.k.shortestPath(i, func(j int) bool { return !sat[j] }), -1, nil
+	case "AX":
+		sat, err := c.sat(f.args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, e := range c.k.states[i].edges {
+			if !sat[e.to] {
+				return []int{i, e.to}, -1, nil
+			}
+		}
+	case "AF":
+		// Follow states satisfying EG !p until one repeats.
+		sat, err := c.sat(f.args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		violating := make([]bool, len(sat))
+		for j := range sat {
+			violating[j] = !sat[j]
+		}
+		eg := c.greatestFixpoint(violating, c.some)
+		path, seen := []int{i}, map[int]int{i: 0}
+		for current := i; ; {
+			for _, e := range c.k.states[current].edges {
+				if eg[e.to] {
+					current = e.to
+					break
+				}
+			}
+			path = append(path, current)
+			if start, ok := seen[current]; ok {
+				return path, start, nil
+			}
+			seen[current] = len(path) - 1
+		}
+	}
+	return []int{i}, -1, nil
+}
+
+// shortestPath returns a shortest path from state i to a state satisfying goal.
+func (k *kripke) shortestPath(i int, goal func(int) bool) []int {
+	parent := map[int]int{i: -1}
+	queue := []int{i}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if goal(current) {
+			var path []int
+			for j := current; j >= 0; j = parent[j] {
+				path = append([]int{j}, path...)
+			}
+			return path
+		}
+		for _, e := range k.states[current].edges {
+			if _, ok := parent[e.to]; !ok {
+				parent[e.to] = current
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	return []int{i}
+}
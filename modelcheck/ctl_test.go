@@ -0,0 +1,80 @@
+package modelcheck
+
+import (
+	"testing"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCheckCTL(t *testing.T) {
+	tests := []struct {
+		formula   string
+		holds     bool
+		wantTrace int
+		wantLoop  bool
+	}{
+		{formula: "AG EF Idle", holds: true},
+		{formula: "EF Serving", holds: true},
+		{formula: "AG(Waiting -> EX Serving)", holds: true},
+		{formula: "E[Idle U Waiting]", holds: true},
+		{formula: "A[!Serving U Waiting]", holds: true},
+		{formula: "EG !Serving", holds: true},
+		{formula: "AG !Serving", holds: false, wantTrace: 3},
+		{formula: "AF Serving", holds: false, wantLoop: true},
+		{formula: "AX Idle", holds: false, wantTrace: 2},
+		{formula: "Waiting", holds: false, wantTrace: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			model := &Model{Chart: serverChart()}
+			result, err := model.CheckCTL(tt.formula)
+			if err != nil {
+				t.Fatalf("CheckCTL(%q) error = %v", tt.formula, err)
+			}
+			if result.Holds != tt.holds {
+				t.Fatalf("CheckCTL(%q) holds = %v, want %v", tt.formula, result.Holds, tt.holds)
+			}
+			if tt.holds {
+				return
+			}
+			if tt.wantTrace != 0 && len(result.Counterexample) != tt.wantTrace {
+				t.Errorf("len(Counterexample) = %d, want %d", len(result.Counterexample), tt.wantTrace)
+			}
+			if got := result.LoopStart >= 0; got != tt.wantLoop {
+				t.Errorf("LoopStart = %d, want loop %v", result.LoopStart, tt.wantLoop)
+			}
+			replay(t, model, result)
+		})
+	}
+}
+
+func TestCheckCTLContext(t *testing.T) {
+	chart := counterChart("count < 2")
+	chart.Transitions = append(chart.Transitions, &sc.Transition{
+		Label: "reset", From: []string{"Counting"}, To: []string{"Counting"}, Event: "RESET",
+		Actions: []*sc.Action{{Label: "count = 0"}},
+	})
+	model := &Model{
+		Chart:     chart,
+		Variables: []Variable{{Name: "count", Domain: []*structpb.Value{structpb.NewNumberValue(0), structpb.NewNumberValue(1), structpb.NewNumberValue(2)}}},
+	}
+	tests := []struct {
+		formula string
+		holds   bool
+	}{
+		{"AG {count <= 2}", true},
+		{"AG EF {count == 0}", true},
+		{"AG({count == 2} -> AX({count == 2} || RESET))", true},
+		{"AG {count < 2}", false},
+	}
+	for _, tt := range tests {
+		result, err := model.CheckCTL(tt.formula)
+		if err != nil {
+			t.Fatalf("CheckCTL(%q) error = %v", tt.formula, err)
+		}
+		if result.Holds != tt.holds {
+			t.Errorf("CheckCTL(%q) holds = %v, want %v", tt.formula, result.Holds, tt.holds)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package modelcheck implements a bounded explicit-state model checker for statecharts.
+//
+// A Model explores every configuration and context reachable from the initial
+// states of a statechart, where the context is restricted to variables with
+// finite domains. Properties are safety invariants, LTL formulas and CTL
+// formulas over the explored state space. Violated properties come with a
+// counterexample trace of machines that can be replayed with a semantics.Engine.
+//
+// Models can also be exported to NuSMV and Promela for verification with
+// established tools. The Mapping returned by an exporter translates the
+// counterexamples those tools report back into sequences of events.
+//
+// GenerateTests derives conformance tests from the explored state space: runs
+// that activate every state, fire every transition or fire every feasible pair
+// of consecutive transitions. The tests can be written as a Go table for use in
+// the tests of another implementation of the chart.
+package modelcheck
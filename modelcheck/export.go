@@ -0,0 +1,611 @@
+package modelcheck
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Exported models
+//
+// The exporters translate a model into the input language of an external model
+// checker. Each microstep of the engine becomes one step of the exported model:
+//
+//   - every OR-state has a variable holding its active child, and a state is
+//     active if its parent is active and, for OR-states, selects it;
+//   - the variable "event" holds the event processed by the next microstep. The
+//     environment chooses it freely in stable configurations, where no eventless
+//     transition is enabled, and it is "none" while eventless transitions settle
+//     and once the machine has stopped;
+//   - a transition fires if it is enabled, its guard holds and no conflicting
+//     transition of higher priority fires, which reproduces the greedy
+//     selection of the engine;
+//   - actions are applied in priority order, as in the engine.
+//
+// Unlike the engine, an event that enables no transition is a step that leaves
+// the state unchanged, and eventless transitions are not bounded.
+
+// encoding is the symbolic structure shared by the exporters.
+type encoding struct {
+	model *Model
+
+	root    *sc.State
+	states  []*sc.State // Document order.
+	parent  map[*sc.State]*sc.State
+	depth   map[*sc.State]int
+	byLabel map[string]*sc.State
+	index   map[*sc.State]int // Position among the children of the parent.
+
+	regions   []*sc.State // OR-states, in document order.
+	events    []string
+	instances []*instance // In priority order.
+	variables []*encodedVariable
+	constants map[string]*structpb.Value // Fixed context fields.
+	strings   []string                   // String values, in order of first use.
+
+	used    map[string]bool
+	symbols map[string]string // Keyed by kind and name.
+	mapping *Mapping
+}
+
+// instance is a transition fired from one of its sources.
+type instance struct {
+	transition *sc.Transition
+	// sources are the states that must all be active for the transition to be
+	// enabled; source is the deepest of them, which determines its priority.
+	sources []*sc.State
+	source  *sc.State
+	// domain is the OR-state whose descendants are exited, or nil for targetless transitions.
+	domain  *sc.State
+	targets []*sc.State
+	actions []*semantics.Assignment
+	name    string
+}
+
+// encodedVariable is a context variable with a finite domain.
+type encodedVariable struct {
+	name   string
+	kind   string // "number", "string" or "bool".
+	domain []*structpb.Value
+	// init lists the initial values of the variable.
+	init []*structpb.Value
+}
+
+func (m *Model) encode(format string) (*encoding, error) {
+	if m.Chart == nil {
+		return nil, errors.New("model has no chart")
+	}
+	if err := m.Chart.Validate(); err != nil {
+		return nil, err
+	}
+	e := &encoding{
+		model:     m,
+		root:      m.Chart.RootState,
+		parent:    make(map[*sc.State]*sc.State),
+		depth:     make(map[*sc.State]int),
+		byLabel:   make(map[string]*sc.State),
+		index:     make(map[*sc.State]int),
+		constants: make(map[string]*structpb.Value),
+		used:      make(map[string]bool),
+		symbols:   make(map[string]string),
+		mapping: &Mapping{
+			Format:        format,
+			EventVariable: "event",
+			NoEvent:       "none",
+			Events:        make(map[string]string),
+			States:        make(map[string]string),
+			Regions:       make(map[string]string),
+			Transitions:   make(map[string]string),
+			Variables:     make(map[string]string),
+			Constants:     make(map[string]string),
+		},
+	}
+	for _, reserved := range []string{"event", "none", "final", "stable", "stopped"} {
+		e.used[reserved] = true
+	}
+
+	var visit func(state, parent *sc.State, depth int)
+	visit = func(state, parent *sc.State, depth int) {
+		e.states = append(e.states, state)
+		e.parent[state] = parent
+		e.depth[state] = depth
+		e.byLabel[state.Label] = state
+		if len(state.Children) > 0 && state.Type != sc.StateTypeParallel {
+			e.regions = append(e.regions, state)
+		}
+		for i, child := range state.Children {
+			e.index[child] = i
+			visit(child, state, depth+1)
+		}
+	}
+	visit(e.root, nil, 0)
+	for _, s := range e.states {
+		if semantics.IsPseudostate(s) {
+			return nil, fmt.Errorf("state %s is a pseudostate, which models do not support", s.Label)
+		}
+		e.mapping.States[e.stateSymbol(s)] = s.Label
+	}
+	for _, r := range e.regions {
+		e.mapping.Regions[e.regionSymbol(r)] = r.Label
+	}
+
+	e.events = m.Events
+	if len(e.events) == 0 {
+		e.events = m.alphabet()
+	}
+	for _, event := range e.events {
+		e.mapping.Events[e.eventSymbol(event)] = event
+	}
+
+	if err := e.encodeVariables(); err != nil {
+		return nil, err
+	}
+	if err := e.encodeTransitions(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *encoding) encodeVariables() error {
+	for name, v := range e.model.Context.GetFields() {
+		e.constants[name] = v
+	}
+	for _, v := range e.model.Variables {
+		if len(v.Domain) == 0 {
+			return fmt.Errorf("variable %s has an empty domain", v.Name)
+		}
+		ev := &encodedVariable{name: v.Name, domain: v.Domain}
+		for _, d := range v.Domain {
+			kind, err := valueKind(d)
+			if err != nil {
+				return fmt.Errorf("variable %s: %w", v.Name, err)
+			}
+			if ev.kind != "" && ev.kind != kind {
+				return fmt.Errorf("variable %s: domain mixes %s and %s values", v.Name, ev.kind, kind)
+			}
+			ev.kind = kind
+			if kind == "string" {
+				e.constantSymbol(d.GetStringValue())
+			}
+		}
+		ev.init = v.Domain
+		if fixed, ok := e.constants[v.Name]; ok {
+			ev.init = []*structpb.Value{fixed}
+			delete(e.constants, v.Name)
+		}
+		e.variables = append(e.variables, ev)
+		e.mapping.Variables[e.variableSymbol(v.Name)] = v.Name
+	}
+	return nil
+}
+
+func valueKind(v *structpb.Value) (string, error) {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		if k.NumberValue != math.Trunc(k.NumberValue) {
+			return "", fmt.Errorf("%v is not an integer", k.NumberValue)
+		}
+		return "number", nil
+	case *structpb.Value_StringValue:
+		return "string", nil
+	case *structpb.Value_BoolValue:
+		return "bool", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v.AsInterface())
+}
+
+func (e *encoding) encodeTransitions() error {
+	for _, t := range e.model.Chart.Transitions {
+		var targets []*sc.State
+		for _, to := range t.To {
+			target, ok := e.byLabel[to]
+			if !ok {
+				return fmt.Errorf("transition %s: state '%s' not found", t.Label, to)
+			}
+			targets = append(targets, target)
+		}
+		var actions []*semantics.Assignment
+		for _, a := range t.Actions {
+			if _, ok := semantics.RaisedEvent(a); ok {
+				return fmt.Errorf("transition %s: action %q raises an event, which models do not support", t.Label, a.Label)
+			}
+			assignments, err := semantics.ParseAssignments(a.Label)
+			if errors.Is(err, semantics.ErrNotAssignment) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, assignment := range assignments {
+				if e.variable(assignment.Target.Path) == nil {
+					return fmt.Errorf("transition %s: action %q assigns %s, which is not a model variable", t.Label, a.Label, assignment.Target)
+				}
+			}
+			actions = append(actions, assignments...)
+		}
+		in := &instance{
+			transition: t,
+			targets:    targets,
+			actions:    actions,
+		}
+		for _, from := range t.From {
+			source, ok := e.byLabel[from]
+			if !ok {
+				return fmt.Errorf("transition %s: state '%s' not found", t.Label, from)
+			}
+			in.sources = append(in.sources, source)
+			if in.source == nil || e.depth[source] > e.depth[in.source] {
+				in.source = source
+			}
+		}
+		if in.source == nil {
+			// A transition without sources is never enabled.
+			continue
+		}
+		switch {
+		case t.Kind == sc.TransitionKindInternal:
+			in.targets = nil
+		case len(targets) > 0:
+			in.domain = e.domain(in, targets)
+		}
+		name := t.Label
+		if name == "" {
+			name = fmt.Sprintf("t%d", len(e.instances))
+		}
+		in.name = e.symbol("fire", name, "f_"+name)
+		e.mapping.Transitions[in.name] = t.Label
+		e.instances = append(e.instances, in)
+	}
+	sort.SliceStable(e.instances, func(i, j int) bool {
+		return e.depth[e.instances[i].source] > e.depth[e.instances[j].source]
+	})
+	return nil
+}
+
+// domain returns the least OR-state, or the root, that is a proper ancestor of
+// every source and every target, or the source of a local transition to its
+// descendants, as in the engine.
+func (e *encoding) domain(in *instance, targets []*sc.State) *sc.State {
+	sources := in.sources
+	source := sources[0]
+	if in.transition.Kind == sc.TransitionKindLocal && len(sources) == 1 && len(source.Children) > 0 && source.Type != sc.StateTypeParallel {
+		local := true
+		for _, t := range targets {
+			if !e.isDescendant(t, source) {
+				local = false
+				break
+			}
+		}
+		if local {
+			return source
+		}
+	}
+	for anc := e.parent[sources[0]]; anc != nil; anc = e.parent[anc] {
+		if anc != e.root && anc.Type == sc.StateTypeParallel {
+			continue
+		}
+		contains := true
+		for _, t := range append(append([]*sc.State(nil), sources...), targets...) {
+			if !e.isDescendant(t, anc) {
+				contains = false
+				break
+			}
+		}
+		if contains {
+			return anc
+		}
+	}
+	return e.root
+}
+
+// isDescendant reports whether state is a proper descendant of ancestor.
+func (e *encoding) isDescendant(state, ancestor *sc.State) bool {
+	for p := e.parent[state]; p != nil; p = e.parent[p] {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// conflicting reports whether two instances exit a common state whenever both are enabled.
+func (e *encoding) conflicting(a, b *instance) bool {
+	if a.transition == b.transition {
+		return true
+	}
+	switch {
+	case a.domain != nil && b.domain != nil:
+		return a.domain == b.domain || e.isDescendant(a.domain, b.domain) || e.isDescendant(b.domain, a.domain)
+	case a.domain != nil:
+		return e.exitsSource(a, b)
+	case b.domain != nil:
+		return e.exitsSource(b, a)
+	}
+	for _, s := range a.sources {
+		for _, t := range b.sources {
+			if s == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exitsSource reports whether a exits one of the sources of b.
+func (e *encoding) exitsSource(a, b *instance) bool {
+	for _, s := range b.sources {
+		if e.isDescendant(s, a.domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionUpdates returns, for an OR-state, the instances that set its child and the child they set.
+//
+// An instance resets every OR-state below and including its domain: OR-states
+// on the way to a target select the child leading to it and all others select
+// their default child.
+func (e *encoding) regionUpdates(region *sc.State) (updates []*instance, children []*sc.State) {
+	for _, in := range e.instances {
+		if in.domain == nil || (region != in.domain && !e.isDescendant(region, in.domain)) {
+			continue
+		}
+		child := defaultChild(region)
+		for _, t := range in.targets {
+			if !e.isDescendant(t, region) {
+				continue
+			}
+			for child = t; e.parent[child] != region; child = e.parent[child] {
+			}
+		}
+		updates = append(updates, in)
+		children = append(children, child)
+	}
+	return updates, children
+}
+
+func defaultChild(state *sc.State) *sc.State {
+	for _, child := range state.Children {
+		if child.IsInitial {
+			return child
+		}
+	}
+	return state.Children[0]
+}
+
+// variable returns the model variable a path refers to, or nil.
+func (e *encoding) variable(path []string) *encodedVariable {
+	if len(path) > 1 && path[0] == "context" {
+		path = path[1:]
+	}
+	if len(path) != 1 {
+		return nil
+	}
+	for _, v := range e.variables {
+		if v.name == path[0] {
+			return v
+		}
+	}
+	return nil
+}
+
+// symbol returns a unique identifier for a name of a kind, preferring the given candidate.
+func (e *encoding) symbol(kind, name, candidate string) string {
+	key := kind + "\x00" + name
+	if s, ok := e.symbols[key]; ok {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range candidate {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	s := sb.String()
+	for i := 2; e.used[s]; i++ {
+		s = fmt.Sprintf("%s_%d", sb.String(), i)
+	}
+	e.used[s] = true
+	e.symbols[key] = s
+	return s
+}
+
+func (e *encoding) stateSymbol(s *sc.State) string { return e.symbol("state", s.Label, "s_"+s.Label) }
+func (e *encoding) activeSymbol(s *sc.State) string {
+	return e.symbol("active", s.Label, "in_"+s.Label)
+}
+func (e *encoding) regionSymbol(s *sc.State) string { return e.symbol("region", s.Label, "r_"+s.Label) }
+func (e *encoding) eventSymbol(event string) string { return e.symbol("event", event, "e_"+event) }
+func (e *encoding) variableSymbol(name string) string {
+	return e.symbol("variable", name, "v_"+name)
+}
+
+func (e *encoding) constantSymbol(value string) string {
+	key := "constant\x00" + value
+	if _, ok := e.symbols[key]; !ok {
+		e.strings = append(e.strings, value)
+	}
+	s := e.symbol("constant", value, "c_"+value)
+	e.mapping.Constants[s] = value
+	return s
+}
+
+// dialect describes the expression syntax of a target language.
+type dialect struct {
+	and, or, not, eq, neq, mod string
+	boolean                    func(bool) string
+}
+
+// source returns the condition under which an instance is enabled and its
+// guard holds, regardless of the event.
+func (e *encoding) source(in *instance, d *dialect) (string, error) {
+	var active []string
+	for _, s := range in.sources {
+		active = append(active, e.activeSymbol(s))
+	}
+	condition := strings.Join(active, " "+d.and+" ")
+	guard, err := e.guard(in, d)
+	if err != nil {
+		return "", err
+	}
+	if guard != "" {
+		condition += " " + d.and + " " + guard
+	}
+	return condition, nil
+}
+
+// enabled returns the condition under which an instance is enabled in the current step.
+func (e *encoding) enabled(in *instance, d *dialect) (string, error) {
+	condition, err := e.source(in, d)
+	if err != nil {
+		return "", err
+	}
+	event := "none"
+	if in.transition.Event != "" {
+		event = e.eventSymbol(in.transition.Event)
+	}
+	return condition + " " + d.and + " event " + d.eq + " " + event, nil
+}
+
+// render renders an expression in a dialect. Variables are replaced by the
+// expressions holding their current values.
+func (e *encoding) render(x semantics.Expr, d *dialect, current map[string]string) (string, error) {
+	switch x := x.(type) {
+	case *semantics.Literal:
+		return e.renderValue(x.Value, d)
+	case *semantics.Ident:
+		if v := e.variable(x.Path); v != nil {
+			return current[v.name], nil
+		}
+		path := x.Path
+		if len(path) > 1 && path[0] == "context" {
+			path = path[1:]
+		}
+		if c, ok := e.constants[strings.Join(path, ".")]; ok && len(path) == 1 {
+			return e.renderValue(c, d)
+		}
+		return "", fmt.Errorf("%s is not a model variable", x)
+	case *semantics.Unary:
+		operand, err := e.render(x.X, d, current)
+		if err != nil {
+			return "", err
+		}
+		if x.Op == "!" {
+			return d.not + operand, nil
+		}
+		return "-" + operand, nil
+	case *semantics.Binary:
+		left, err := e.render(x.X, d, current)
+		if err != nil {
+			return "", err
+		}
+		right, err := e.render(x.Y, d, current)
+		if err != nil {
+			return "", err
+		}
+		op := x.Op
+		switch op {
+		case "&&":
+			op = d.and
+		case "||":
+			op = d.or
+		case "==":
+			op = d.eq
+		case "!=":
+			op = d.neq
+		case "%":
+			op = d.mod
+		}
+		return "(" + left + " " + op + " " + right + ")", nil
+	case *semantics.Call:
+		label, err := semantics.InState(x)
+		if err != nil {
+			return "", err
+		}
+		state, ok := e.byLabel[label.String()]
+		if !ok {
+			return "", fmt.Errorf("in(): state '%s' not found", label)
+		}
+		return e.activeSymbol(state), nil
+	}
+	return "", fmt.Errorf("unsupported expression %s", x)
+}
+
+func (e *encoding) renderValue(v *structpb.Value, d *dialect) (string, error) {
+	kind, err := valueKind(v)
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case "number":
+		return fmt.Sprintf("%d", int64(v.GetNumberValue())), nil
+	case "bool":
+		return d.boolean(v.GetBoolValue()), nil
+	}
+	return e.constantSymbol(v.GetStringValue()), nil
+}
+
+// guard renders the guard of an instance, or "" if it has none.
+func (e *encoding) guard(in *instance, d *dialect) (string, error) {
+	g := in.transition.GetGuard().GetExpression()
+	if strings.TrimSpace(g) == "" {
+		return "", nil
+	}
+	x, err := semantics.ParseExpression(g)
+	if err != nil {
+		return "", err
+	}
+	current := make(map[string]string)
+	for _, v := range e.variables {
+		current[v.name] = e.variableSymbol(v.name)
+	}
+	s, err := e.render(x, d, current)
+	if err != nil {
+		return "", fmt.Errorf("transition %s: guard %q: %w", in.transition.Label, g, err)
+	}
+	return s, nil
+}
+
+// nonFinal returns the basic states that are not final. The configuration is
+// final when none of them is active.
+func (e *encoding) nonFinal() []*sc.State {
+	var result []*sc.State
+	for _, s := range e.states {
+		if len(s.Children) == 0 && !s.IsFinal {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// eventless returns the instances of transitions without an event.
+func (e *encoding) eventless() []*instance {
+	var result []*instance
+	for _, in := range e.instances {
+		if in.transition.Event == "" {
+			result = append(result, in)
+		}
+	}
+	return result
+}
+
+// higherConflicts returns the instances of higher priority that conflict with the i-th instance.
+func (e *encoding) higherConflicts(i int) []*instance {
+	var result []*instance
+	for _, other := range e.instances[:i] {
+		if other.transition.Event == e.instances[i].transition.Event && e.conflicting(other, e.instances[i]) {
+			result = append(result, other)
+		}
+	}
+	return result
+}
@@ -0,0 +1,333 @@
+package modelcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// turnstileChart has orthogonal regions, nested transitions of different
+// priority and a guarded transition with an action.
+func turnstileChart() *semantics.Statechart {
+	return semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Off", IsInitial: true},
+				{
+					Label: "On",
+					Type:  sc.StateTypeParallel,
+					Children: []*sc.State{
+						{
+							Label: "Turnstile Control",
+							Children: []*sc.State{
+								{Label: "Blocked", IsInitial: true},
+								{Label: "Unblocked"},
+							},
+						},
+						{
+							Label: "Card Reader Control",
+							Children: []*sc.State{
+								{Label: "Ready", IsInitial: true},
+								{Label: "Card Entered"},
+							},
+						},
+					},
+				},
+				{Label: "Broken", IsFinal: true},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "turn_on", From: []string{"Off"}, To: []string{"On"}, Event: "TURN_ON"},
+			{Label: "turn_off", From: []string{"On"}, To: []string{"Off"}, Event: "TURN_OFF"},
+			{Label: "card", From: []string{"Ready"}, To: []string{"Card Entered"}, Event: "CARD"},
+			{Label: "pass", From: []string{"Card Entered"}, To: []string{"Ready", "Unblocked"}, Event: "PASS", Guard: &sc.Guard{Expression: "passes < 2"}, Actions: []*sc.Action{{Label: "passes = passes + 1"}, {Label: "beep"}}},
+			{Label: "lock", From: []string{"Unblocked"}, To: []string{"Blocked"}, Event: "TURN_OFF"},
+			{Label: "wear", From: []string{"Off"}, To: []string{"Broken"}, Guard: &sc.Guard{Expression: "passes == 2"}},
+		},
+	})
+}
+
+func turnstileModel() *Model {
+	return &Model{
+		Chart:     turnstileChart(),
+		Variables: []Variable{{Name: "passes", Domain: countDomain(3)}},
+		Context:   &structpb.Struct{Fields: map[string]*structpb.Value{"passes": structpb.NewNumberValue(0)}},
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  *Model
+		export func(*Model, *bytes.Buffer) (*Mapping, error)
+		golden string
+	}{
+		{"nusmv", turnstileModel(), func(m *Model, w *bytes.Buffer) (*Mapping, error) { return m.ExportNuSMV(w) }, "turnstile.smv"},
+		{"promela", turnstileModel(), func(m *Model, w *bytes.Buffer) (*Mapping, error) { return m.ExportPromela(w) }, "turnstile.pml"},
+		{"nusmv strings", colorModel(), func(m *Model, w *bytes.Buffer) (*Mapping, error) { return m.ExportNuSMV(w) }, "light.smv"},
+		{"promela strings", colorModel(), func(m *Model, w *bytes.Buffer) (*Mapping, error) { return m.ExportPromela(w) }, "light.pml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			mapping, err := tt.export(tt.model, &buf)
+			if err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+			mappingJSON, err := json.MarshalIndent(mapping, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+			checkGolden(t, tt.golden+".json", append(mappingJSON, '\n'))
+		})
+	}
+}
+
+func TestExportIn(t *testing.T) {
+	model := &Model{Chart: counterChart("in(Lit)"), Variables: []Variable{{Name: "count", Domain: countDomain(2)}}}
+	for name, want := range map[string]string{"nusmv": "in_Lit & event = e_INC", "promela": "in_Lit && event == e_INC"} {
+		var buf bytes.Buffer
+		var err error
+		if name == "nusmv" {
+			_, err = model.ExportNuSMV(&buf)
+		} else {
+			_, err = model.ExportPromela(&buf)
+		}
+		if err != nil {
+			t.Fatalf("%s: export error = %v", name, err)
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%s: output does not contain %q:\n%s", name, want, buf.String())
+		}
+	}
+}
+
+func TestExportJoin(t *testing.T) {
+	chart := counterChart("true")
+	chart.Transitions = append(chart.Transitions, &sc.Transition{Label: "join", From: []string{"Counting", "Lit"}, To: []string{"Dark"}, Event: "RESET"})
+	model := &Model{Chart: chart, Variables: []Variable{{Name: "count", Domain: countDomain(2)}}}
+	var buf bytes.Buffer
+	if _, err := model.ExportNuSMV(&buf); err != nil {
+		t.Fatalf("ExportNuSMV() error = %v", err)
+	}
+	if want := "in_Counting & in_Lit & event = e_RESET"; !strings.Contains(buf.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, buf.String())
+	}
+}
+
+func TestExportTransitionKinds(t *testing.T) {
+	model := &Model{Chart: semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "S", IsInitial: true, Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "B"}}},
+			{Label: "T"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "ext", From: []string{"S"}, To: []string{"B"}, Event: "EXT"},
+			{Label: "loc", From: []string{"S"}, To: []string{"B"}, Event: "LOC", Kind: sc.TransitionKindLocal},
+			{Label: "int", From: []string{"S"}, To: []string{"S"}, Event: "INT", Kind: sc.TransitionKindInternal},
+		},
+	})}
+	var buf bytes.Buffer
+	if _, err := model.ExportNuSMV(&buf); err != nil {
+		t.Fatalf("ExportNuSMV() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"f_ext : s_S;", "f_ext : s_B;", "f_loc : s_B;"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	// The local transition stays in S and the internal one changes no region.
+	for _, unwanted := range []string{"f_loc : s_S;", "f_int : s_"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+// colorModel uses string and boolean variables and eventless transitions.
+func colorModel() *Model {
+	return &Model{
+		Chart: semantics.NewStatechart(&sc.Statechart{
+			RootState: &sc.State{
+				Children: []*sc.State{
+					{Label: "Light", IsInitial: true},
+				},
+			},
+			Transitions: []*sc.Transition{
+				{Label: "next", From: []string{"Light"}, Event: "TICK", Guard: &sc.Guard{Expression: "enabled"}, Actions: []*sc.Action{{Label: "pending = true"}}},
+				{Label: "to_green", From: []string{"Light"}, Guard: &sc.Guard{Expression: "pending && color == 'red'"}, Actions: []*sc.Action{{Label: "color = 'green'; pending = false"}}},
+				{Label: "to_red", From: []string{"Light"}, Guard: &sc.Guard{Expression: "pending && color != 'red'"}, Actions: []*sc.Action{{Label: "color = 'red'; pending = false"}}},
+			},
+		}),
+		Context: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"enabled": structpb.NewBoolValue(true),
+			"pending": structpb.NewBoolValue(false),
+		}},
+		Variables: []Variable{
+			{Name: "color", Domain: []*structpb.Value{structpb.NewStringValue("red"), structpb.NewStringValue("green")}},
+			{Name: "pending", Domain: []*structpb.Value{structpb.NewBoolValue(false), structpb.NewBoolValue(true)}},
+		},
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		model *Model
+	}{
+		{"undeclared assignment", &Model{Chart: counterChart("true")}},
+		{"unknown guard variable", &Model{Chart: counterChart("limit > 0"), Variables: []Variable{{Name: "count", Domain: countDomain(2)}}}},
+		{"fractional domain", &Model{Chart: counterChart("true"), Variables: []Variable{{Name: "count", Domain: []*structpb.Value{structpb.NewNumberValue(0.5)}}}}},
+		{"mixed domain", &Model{Chart: counterChart("true"), Variables: []Variable{{Name: "count", Domain: []*structpb.Value{structpb.NewNumberValue(0), structpb.NewStringValue("one")}}}}},
+		{"unknown in state", &Model{Chart: counterChart("in(Missing)"), Variables: []Variable{{Name: "count", Domain: countDomain(2)}}}},
+		{"raise", &Model{Chart: semantics.NewStatechart(&sc.Statechart{
+			RootState:   &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+			Transitions: []*sc.Transition{{Label: "ping", From: []string{"A"}, Event: "PING", Actions: []*sc.Action{{Label: "raise PONG"}}}},
+		})}},
+		{"pseudostate", &Model{Chart: semantics.NewStatechart(&sc.Statechart{
+			RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "J", Type: sc.StateTypeJunction}}},
+			Transitions: []*sc.Transition{
+				{Label: "in", From: []string{"A"}, To: []string{"J"}, Event: "GO"},
+				{Label: "out", From: []string{"J"}, To: []string{"A"}},
+			},
+		})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := tt.model.ExportNuSMV(&buf); err == nil {
+				t.Error("ExportNuSMV() expected error")
+			}
+			if _, err := tt.model.ExportPromela(&buf); err == nil {
+				t.Error("ExportPromela() expected error")
+			}
+		})
+	}
+}
+
+func TestParseNuSMVTrace(t *testing.T) {
+	var buf bytes.Buffer
+	mapping, err := turnstileModel().ExportNuSMV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := `-- specification G !in_Unblocked  is false
+-- as demonstrated by the following execution sequence
+Trace Description: LTL Counterexample
+Trace Type: Counterexample
+  -> State: 1.1 <-
+    event = e_TURN_ON
+    r___root__ = s_Off
+    r_Turnstile_Control = s_Blocked
+    r_Card_Reader_Control = s_Ready
+    v_passes = 0
+  -> State: 1.2 <-
+    event = e_CARD
+    r___root__ = s_On
+  -- Loop starts here
+  -> State: 1.3 <-
+    event = e_PASS
+    r_Card_Reader_Control = s_Card_Entered
+  -> State: 1.4 <-
+    event = e_TURN_OFF
+    r_Turnstile_Control = s_Unblocked
+    r_Card_Reader_Control = s_Ready
+    v_passes = 1
+`
+	trace, loopStart, err := ParseNuSMVTrace(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("ParseNuSMVTrace() error = %v", err)
+	}
+	if loopStart != 2 {
+		t.Errorf("loopStart = %d, want 2", loopStart)
+	}
+	if got := trace[3]["r___root__"]; got != "s_On" {
+		t.Errorf("unchanged variable r___root__ = %q, want s_On", got)
+	}
+	events, err := mapping.EventSequence(trace)
+	if err != nil {
+		t.Fatalf("EventSequence() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"TURN_ON", "CARD", "PASS"}, events); diff != "" {
+		t.Errorf("EventSequence() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Replaying the events reproduces the counterexample in the engine.
+	engine := semantics.NewEngine()
+	m, err := engine.NewMachine("replay", turnstileChart(), turnstileModel().Context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if _, err := engine.Step(m, event); err != nil {
+			t.Fatalf("Step(%s) error = %v", event, err)
+		}
+	}
+	want := []string{"__root__", "On", "Turnstile Control", "Unblocked", "Card Reader Control", "Ready"}
+	var got []string
+	for _, s := range m.Configuration.States {
+		got = append(got, s.Label)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("replayed configuration mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseSpinTrace(t *testing.T) {
+	var buf bytes.Buffer
+	mapping, err := turnstileModel().ExportPromela(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := `      sc: event=e_TURN_ON
+      sc: event=e_CARD
+      sc: event=none
+      sc: event=e_PASS
+spin: trail ends after 40 steps
+`
+	trace, err := ParseSpinTrace(strings.NewReader(output), mapping)
+	if err != nil {
+		t.Fatalf("ParseSpinTrace() error = %v", err)
+	}
+	events, err := mapping.EventSequence(trace)
+	if err != nil {
+		t.Fatalf("EventSequence() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"TURN_ON", "CARD", "PASS"}, events); diff != "" {
+		t.Errorf("EventSequence() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run go test -update to create it)", err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("%s mismatch (-want +got):\n%s", name, diff)
+	}
+}
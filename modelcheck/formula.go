@@ -0,0 +1,357 @@
+package modelcheck
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+// Formula syntax
+//
+// Formulas are built from propositions with the boolean connectives
+// ! && || -> and parentheses. A proposition is one of
+//
+//	true, false
+//	Idle, "Card Entered"   a state is active (names with spaces are quoted)
+//	PASS                   the last processed event was PASS
+//	{count < 3}            a guard expression over the context holds
+//
+// LTL formulas add the temporal operators X (next), F (eventually), G (always),
+// U (until) and R (release), as in G(Request -> F Response). CTL formulas add
+// the path-quantified operators AX, EX, AF, EF, AG, EG, A[p U q] and E[p U q].
+// A state or event whose name is an operator must be quoted.
+
+// formula is a node of a parsed formula.
+type formula struct {
+	op   string // true, false, prop, or an operator
+	prop *proposition
+	args []*formula
+}
+
+func (f *formula) String() string {
+	switch f.op {
+	case "true", "false":
+		return f.op
+	case "prop":
+		return f.prop.String()
+	case "!", "X", "F", "G", "AX", "EX", "AF", "EF", "AG", "EG":
+		return f.op + "(" + f.args[0].String() + ")"
+	case "AU", "EU":
+		return f.op[:1] + "[" + f.args[0].String() + " U " + f.args[1].String() + "]"
+	}
+	return "(" + f.args[0].String() + " " + f.op + " " + f.args[1].String() + ")"
+}
+
+// propositionKind is the kind of a proposition.
+type propositionKind int
+
+const (
+	stateProposition propositionKind = iota
+	eventProposition
+	contextProposition
+)
+
+// proposition is an atomic formula.
+type proposition struct {
+	kind propositionKind
+	name string
+	expr semantics.Expr
+}
+
+func (p *proposition) String() string {
+	if p.kind == contextProposition {
+		return "{" + p.name + "}"
+	}
+	for _, r := range p.name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return fmt.Sprintf("%q", p.name)
+		}
+	}
+	return p.name
+}
+
+// holds reports whether the proposition holds in a state.
+func (p *proposition) holds(s *state) (bool, error) {
+	switch p.kind {
+	case stateProposition:
+		return s.active[p.name], nil
+	case eventProposition:
+		return s.event == p.name, nil
+	}
+	v, err := semantics.Eval(p.expr, semantics.Env{Context: s.machine.GetContext()})
+	if err != nil {
+		return false, fmt.Errorf("proposition %s: %w", p, err)
+	}
+	return v.GetBoolValue(), nil
+}
+
+// logic selects the temporal operators accepted by the parser.
+type logic int
+
+const (
+	ltl logic = iota
+	ctl
+)
+
+var (
+	ltlUnary = map[string]bool{"X": true, "F": true, "G": true}
+	ctlUnary = map[string]bool{"AX": true, "EX": true, "AF": true, "EF": true, "AG": true, "EG": true}
+)
+
+// parseFormula parses a formula, resolving propositions against the model.
+func (m *Model) parseFormula(src string, l logic) (*formula, error) {
+	tokens, err := tokenizeFormula(src)
+	if err != nil {
+		return nil, fmt.Errorf("formula %q: %w", src, err)
+	}
+	p := &formulaParser{model: m, logic: l, tokens: tokens}
+	f, err := p.parseImplies()
+	if err == nil && p.peek().text != "" {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("formula %q: %w", src, err)
+	}
+	return f, nil
+}
+
+type formulaToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeFormula(src string) ([]formulaToken, error) {
+	var tokens []formulaToken
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, formulaToken{text: string(rs[i:j])})
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated name")
+			}
+			tokens = append(tokens, formulaToken{text: string(rs[i+1 : j]), quoted: true})
+			i = j + 1
+		case r == '{':
+			j := i + 1
+			for j < len(rs) && rs[j] != '}' {
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated expression")
+			}
+			tokens = append(tokens, formulaToken{text: string(rs[i : j+1])})
+			i = j + 1
+		default:
+			if i+1 < len(rs) {
+				if two := string(rs[i : i+2]); two == "&&" || two == "||" || two == "->" {
+					tokens = append(tokens, formulaToken{text: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("!()[]", r) {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, formulaToken{text: string(r)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+type formulaParser struct {
+	model  *Model
+	logic  logic
+	tokens []formulaToken
+	pos    int
+}
+
+func (p *formulaParser) peek() formulaToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return formulaToken{}
+}
+
+func (p *formulaParser) next() formulaToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *formulaParser) expect(text string) error {
+	if t := p.next(); t.text != text || t.quoted {
+		if t.text == "" {
+			return fmt.Errorf("expected %q at end of formula", text)
+		}
+		return fmt.Errorf("expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+// isOp reports whether the next token is the unquoted operator op.
+func (p *formulaParser) isOp(op string) bool {
+	t := p.peek()
+	return !t.quoted && t.text == op
+}
+
+func (p *formulaParser) parseImplies() (*formula, error) {
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("->") {
+		return x, nil
+	}
+	p.next()
+	y, err := p.parseImplies()
+	if err != nil {
+		return nil, err
+	}
+	return &formula{op: "->", args: []*formula{x, y}}, nil
+}
+
+func (p *formulaParser) parseOr() (*formula, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *formulaParser) parseAnd() (*formula, error) {
+	return p.parseBinary("&&", p.parseUntil)
+}
+
+func (p *formulaParser) parseBinary(op string, operand func() (*formula, error)) (*formula, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(op) {
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &formula{op: op, args: []*formula{x, y}}
+	}
+	return x, nil
+}
+
+func (p *formulaParser) parseUntil() (*formula, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if p.logic != ltl || !(p.isOp("U") || p.isOp("R")) {
+		return x, nil
+	}
+	op := p.next().text
+	y, err := p.parseUntil()
+	if err != nil {
+		return nil, err
+	}
+	return &formula{op: op, args: []*formula{x, y}}, nil
+}
+
+func (p *formulaParser) parseUnary() (*formula, error) {
+	t := p.peek()
+	unary := t.text == "!" ||
+		p.logic == ltl && ltlUnary[t.text] ||
+		p.logic == ctl && ctlUnary[t.text]
+	if t.quoted || !unary {
+		return p.parsePrimary()
+	}
+	p.next()
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &formula{op: t.text, args: []*formula{x}}, nil
+}
+
+func (p *formulaParser) parsePrimary() (*formula, error) {
+	t := p.next()
+	switch {
+	case t.quoted:
+		return p.resolve(t.text)
+	case t.text == "":
+		return nil, fmt.Errorf("unexpected end of formula")
+	case t.text == "(":
+		f, err := p.parseImplies()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	case p.logic == ctl && (t.text == "A" || t.text == "E") && p.isOp("["):
+		p.next()
+		x, err := p.parseImplies()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("U"); err != nil {
+			return nil, err
+		}
+		y, err := p.parseImplies()
+		if err != nil {
+			return nil, err
+		}
+		return &formula{op: t.text + "U", args: []*formula{x, y}}, p.expect("]")
+	case t.text == "true" || t.text == "false":
+		return &formula{op: t.text}, nil
+	case strings.HasPrefix(t.text, "{"):
+		src := t.text[1 : len(t.text)-1]
+		e, err := semantics.ParseExpression(src)
+		if err != nil {
+			return nil, err
+		}
+		return &formula{op: "prop", prop: &proposition{kind: contextProposition, name: src, expr: e}}, nil
+	case strings.ContainsAny(t.text, "!()[]&|-"):
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return p.resolve(t.text)
+}
+
+// resolve resolves a name to a state proposition or, failing that, an event proposition.
+func (p *formulaParser) resolve(name string) (*formula, error) {
+	if hasState(p.model.Chart.RootState, name) {
+		return &formula{op: "prop", prop: &proposition{kind: stateProposition, name: name}}, nil
+	}
+	for _, event := range append(p.model.alphabet(), p.model.Events...) {
+		if event == name {
+			return &formula{op: "prop", prop: &proposition{kind: eventProposition, name: name}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown state or event %q", name)
+}
+
+func hasState(state *sc.State, label string) bool {
+	if state == nil {
+		return false
+	}
+	if state.Label == label {
+		return true
+	}
+	for _, child := range state.Children {
+		if hasState(child, label) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package modelcheck
+
+import (
+	"testing"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+func TestParseFormula(t *testing.T) {
+	model := &Model{Chart: semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Idle", IsInitial: true},
+				{Label: "Card Entered"},
+				{Label: "G"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "card", From: []string{"Idle"}, To: []string{"Card Entered"}, Event: "CARD"},
+		},
+	})}
+	tests := []struct {
+		src     string
+		logic   logic
+		want    string
+		wantErr bool
+	}{
+		{src: "G(Idle -> F \"Card Entered\")", logic: ltl, want: "G((Idle -> F(\"Card Entered\")))"},
+		{src: "Idle && CARD || !Idle", logic: ltl, want: "((Idle && CARD) || !(Idle))"},
+		{src: "Idle U CARD R Idle", logic: ltl, want: "(Idle U (CARD R Idle))"},
+		{src: "Idle -> CARD -> Idle", logic: ltl, want: "(Idle -> (CARD -> Idle))"},
+		{src: "X X {count + 1 > 2}", logic: ltl, want: "X(X({count + 1 > 2}))"},
+		{src: "G \"G\"", logic: ltl, want: "G(G)"},
+		{src: "AG(Idle -> EF \"Card Entered\")", logic: ctl, want: "AG((Idle -> EF(\"Card Entered\")))"},
+		{src: "A[Idle U CARD] && E[true U false]", logic: ctl, want: "(A[Idle U CARD] && E[true U false])"},
+		{src: "G Idle", logic: ctl, wantErr: true},
+		{src: "AG Idle", logic: ltl, wantErr: true},
+		{src: "Idle U CARD", logic: ctl, wantErr: true},
+		{src: "Missing", logic: ltl, wantErr: true},
+		{src: "(Idle", logic: ltl, wantErr: true},
+		{src: "Idle &&", logic: ltl, wantErr: true},
+		{src: "{count >}", logic: ltl, wantErr: true},
+		{src: "\"Idle", logic: ltl, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			f, err := model.parseFormula(tt.src, tt.logic)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFormula(%q) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			}
+			if err == nil && f.String() != tt.want {
+				t.Errorf("parseFormula(%q) = %s, want %s", tt.src, f, tt.want)
+			}
+		})
+	}
+}
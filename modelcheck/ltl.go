@@ -0,0 +1,537 @@
+package modelcheck
+
+import (
+	"sort"
+)
+
+// CheckLTL checks that an LTL formula holds on every run of the model.
+//
+// Runs are infinite: a state without successors repeats forever. The check
+// translates the negated formula into a generalized Büchi automaton and searches
+// the product with the state space for an accepting cycle. If the formula does
+// not hold, the counterexample is a lasso-shaped run.
+func (m *Model) CheckLTL(src string) (*Result, error) {
+	f, err := m.parseFormula(src, ltl)
+	if err != nil {
+		return nil, err
+	}
+	k, err := m.explore()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Property: src, Holds: true, LoopStart: -1, States: len(k.states)}
+
+	a := newBuchi(nnf(f, true))
+	p := &product{k: k, a: a, labels: newLabeling(k)}
+	path, loopStart, err := p.acceptingLasso()
+	if err != nil {
+		return nil, err
+	}
+	if path != nil {
+		result.Holds = false
+		result.Counterexample = k.trace(path)
+		result.LoopStart = loopStart
+	}
+	return result, nil
+}
+
+// nnf returns the negation normal form of f, or of its negation if negate is set.
+// The result only contains the operators && || X U R, with negation applied to
+// propositions only.
+func nnf(f *formula, negate bool) *formula {
+	binary := func(op string, x, y *formula) *formula {
+		return &formula{op: op, args: []*formula{x, y}}
+	}
+	dual := map[string]string{"&&": "||", "||": "&&", "U": "R", "R": "U"}
+	switch f.op {
+	case "true", "false":
+		if negate == (f.op == "true") {
+			return &formula{op: "false"}
+		}
+		return &formula{op: "true"}
+	case "prop":
+		if negate {
+			return &formula{op: "!", args: []*formula{f}}
+		}
+		return f
+	case "!":
+		return nnf(f.args[0], !negate)
+	case "&&", "||", "U", "R":
+		op := f.op
+		if negate {
+			op = dual[op]
+		}
+		return binary(op, nnf(f.args[0], negate), nnf(f.args[1], negate))
+	case "->":
+		if negate {
+			return binary("&&", nnf(f.args[0], false), nnf(f.args[1], true))
+		}
+		return binary("||", nnf(f.args[0], true), nnf(f.args[1], false))
+	case "X":
+		return &formula{op: "X", args: []*formula{nnf(f.args[0], negate)}}
+	case "F":
+		if negate {
+			return binary("R", &formula{op: "false"}, nnf(f.args[0], true))
+		}
+		return binary("U", &formula{op: "true"}, nnf(f.args[0], false))
+	case "G":
+		if negate {
+			return binary("U", &formula{op: "true"}, nnf(f.args[0], true))
+		}
+		return binary("R", &formula{op: "false"}, nnf(f.args[0], false))
+	}
+	panic("modelcheck: unexpected operator " + f.op)
+}
+
+// formulaSet is a set of formulas keyed by their string form.
+type formulaSet map[string]*formula
+
+func (s formulaSet) with(fs ...*formula) formulaSet {
+	c := make(formulaSet, len(s)+len(fs))
+	for k, f := range s {
+		c[k] = f
+	}
+	for _, f := range fs {
+		c[f.String()] = f
+	}
+	return c
+}
+
+func (s formulaSet) key() string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var key string
+	for _, k := range keys {
+		key += k + "\x00"
+	}
+	return key
+}
+
+// buchiNode is a node of the tableau. The literals in old constrain the state
+// of the structure the node is matched with.
+type buchiNode struct {
+	incoming map[int]bool
+	old      formulaSet
+	next     formulaSet
+	literals []*formula
+}
+
+// initialNode marks the nodes that may start a run.
+const initialNode = -1
+
+// buchi is a generalized Büchi automaton.
+type buchi struct {
+	nodes []*buchiNode
+	// accepting lists one set of nodes per until subformula; an accepting run
+	// visits each of them infinitely often.
+	accepting [][]bool
+	// successors lists the successor nodes of each node.
+	successors [][]int
+}
+
+// newBuchi translates a formula in negation normal form into a generalized
+// Büchi automaton, following Gerth, Peled, Vardi and Wolper (1995).
+func newBuchi(f *formula) *buchi {
+	a := &buchi{}
+	index := make(map[string]int)
+	a.expand(map[int]bool{initialNode: true}, formulaSet{}.with(f), formulaSet{}, formulaSet{}, index)
+
+	for _, u := range untils(f, nil) {
+		key, right := u.String(), u.args[1].String()
+		set := make([]bool, len(a.nodes))
+		for i, n := range a.nodes {
+			_, hasUntil := n.old[key]
+			_, hasRight := n.old[right]
+			set[i] = !hasUntil || hasRight
+		}
+		a.accepting = append(a.accepting, set)
+	}
+	a.successors = make([][]int, len(a.nodes))
+	for j, n := range a.nodes {
+		for i := range n.incoming {
+			if i != initialNode {
+				a.successors[i] = append(a.successors[i], j)
+			}
+		}
+	}
+	for _, s := range a.successors {
+		sort.Ints(s)
+	}
+	return a
+}
+
+func (a *buchi) expand(incoming map[int]bool, pending, old, next formulaSet, index map[string]int) {
+	if len(pending) == 0 {
+		key := old.key() + "\x01" + next.key()
+		if i, ok := index[key]; ok {
+			for j := range incoming {
+				a.nodes[i].incoming[j] = true
+			}
+			return
+		}
+		n := &buchiNode{incoming: incoming, old: old, next: next}
+		for _, f := range old {
+			if f.op == "prop" || f.op == "!" {
+				n.literals = append(n.literals, f)
+			}
+		}
+		sort.Slice(n.literals, func(i, j int) bool { return n.literals[i].String() < n.literals[j].String() })
+		id := len(a.nodes)
+		index[key] = id
+		a.nodes = append(a.nodes, n)
+		a.expand(map[int]bool{id: true}, next.with(), formulaSet{}, formulaSet{}, index)
+		return
+	}
+
+	// Expand formulas in a deterministic order.
+	keys := make([]string, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	f := pending[keys[0]]
+	pending = pending.with()
+	delete(pending, keys[0])
+	if _, ok := old[keys[0]]; ok {
+		a.expand(incoming, pending, old, next, index)
+		return
+	}
+	old = old.with(f)
+
+	switch f.op {
+	case "true":
+		a.expand(incoming, pending, old, next, index)
+	case "false":
+		// Contradiction: the node is discarded.
+	case "prop", "!":
+		if _, ok := old[negation(f).String()]; ok {
+			return
+		}
+		a.expand(incoming, pending, old, next, index)
+	case "&&":
+		a.expand(incoming, pending.with(f.args...), old, next, index)
+	case "||":
+		a.expand(copyIncoming(incoming), pending.with(f.args[0]), old, next, index)
+		a.expand(copyIncoming(incoming), pending.with(f.args[1]), old, next, index)
+	case "X":
+		a.expand(incoming, pending, old, next.with(f.args[0]), index)
+	case "U":
+		// x U y = y || (x && X(x U y))
+		a.expand(copyIncoming(incoming), pending.with(f.args[0]), old, next.with(f), index)
+		a.expand(copyIncoming(incoming), pending.with(f.args[1]), old, next, index)
+	case "R":
+		// x R y = y && (x || X(x R y))
+		a.expand(copyIncoming(incoming), pending.with(f.args[1]), old, next.with(f), index)
+		a.expand(copyIncoming(incoming), pending.with(f.args...), old, next, index)
+	}
+}
+
+func copyIncoming(incoming map[int]bool) map[int]bool {
+	c := make(map[int]bool, len(incoming))
+	for i := range incoming {
+		c[i] = true
+	}
+	return c
+}
+
+// negation returns the complementary literal.
+func negation(f *formula) *formula {
+	if f.op == "!" {
+		return f.args[0]
+	}
+	return &formula{op: "!", args: []*formula{f}}
+}
+
+// untils returns the until subformulas of f.
+func untils(f *formula, result []*formula) []*formula {
+	for _, arg := range f.args {
+		result = untils(arg, result)
+	}
+	if f.op == "U" {
+		for _, u := range result {
+			if u.String() == f.String() {
+				return result
+			}
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// labeling evaluates propositions in the states of a structure, caching the results.
+type labeling struct {
+	k     *kripke
+	cache map[string][]int8
+}
+
+func newLabeling(k *kripke) *labeling {
+	return &labeling{k: k, cache: make(map[string][]int8)}
+}
+
+// holds reports whether a proposition holds in state i.
+func (l *labeling) holds(p *proposition, i int) (bool, error) {
+	key := p.String()
+	values, ok := l.cache[key]
+	if !ok {
+		values = make([]int8, len(l.k.states))
+		l.cache[key] = values
+	}
+	if values[i] == 0 {
+		ok, err := p.holds(l.k.states[i])
+		if err != nil {
+			return false, err
+		}
+		values[i] = -1
+		if ok {
+			values[i] = 1
+		}
+	}
+	return values[i] == 1, nil
+}
+
+// product is the synchronous product of a state space and a Büchi automaton.
+type product struct {
+	k      *kripke
+	a      *buchi
+	labels *labeling
+}
+
+// productState pairs a state of the structure with a node of the automaton.
+type productState struct {
+	s, q int
+}
+
+// matches reports whether state s satisfies the literals of node q.
+func (p *product) matches(s, q int) (bool, error) {
+	for _, lit := range p.a.nodes[q].literals {
+		prop, want := lit.prop, true
+		if lit.op == "!" {
+			prop, want = lit.args[0].prop, false
+		}
+		ok, err := p.labels.holds(prop, s)
+		if err != nil {
+			return false, err
+		}
+		if ok != want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *product) initial() ([]productState, error) {
+	var result []productState
+	for _, s := range p.k.initial {
+		for q, n := range p.a.nodes {
+			if !n.incoming[initialNode] {
+				continue
+			}
+			ok, err := p.matches(s, q)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result = append(result, productState{s, q})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (p *product) successors(ps productState) ([]productState, error) {
+	var result []productState
+	for _, e := range p.k.states[ps.s].edges {
+		for _, q := range p.a.successors[ps.q] {
+			ok, err := p.matches(e.to, q)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result = append(result, productState{e.to, q})
+			}
+		}
+	}
+	return result, nil
+}
+
+// acceptingLasso searches for a run of the product that visits every accepting
+// set infinitely often. It returns the states of the structure along a prefix
+// and a cycle that ends where the cycle starts, and the index of the cycle start.
+// It returns a nil path if there is no accepting run.
+func (p *product) acceptingLasso() ([]int, int, error) {
+	// Explore the reachable product breadth first, remembering parents for the prefix.
+	initial, err := p.initial()
+	if err != nil {
+		return nil, 0, err
+	}
+	parent := make(map[productState]productState)
+	succ := make(map[productState][]productState)
+	var order []productState
+	for _, ps := range initial {
+		if _, ok := parent[ps]; !ok {
+			parent[ps] = ps
+			order = append(order, ps)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		next, err := p.successors(order[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		succ[order[i]] = next
+		for _, ps := range next {
+			if _, ok := parent[ps]; !ok {
+				parent[ps] = order[i]
+				order = append(order, ps)
+			}
+		}
+	}
+
+	for _, scc := range stronglyConnected(order, succ) {
+		if !p.accepting(scc, succ) {
+			continue
+		}
+		inSCC := make(map[productState]bool, len(scc))
+		for _, ps := range scc {
+			inSCC[ps] = true
+		}
+		// The prefix reaches the first state of the component discovered by the search.
+		entry := scc[0]
+		for _, ps := range order {
+			if inSCC[ps] {
+				entry = ps
+				break
+			}
+		}
+		var prefix []productState
+		for ps := entry; ; ps = parent[ps] {
+			prefix = append([]productState{ps}, prefix...)
+			if parent[ps] == ps {
+				break
+			}
+		}
+		// The cycle visits each accepting set and returns to the entry.
+		cycle, current := []productState(nil), entry
+		for _, set := range p.a.accepting {
+			if set[current.q] {
+				continue
+			}
+			path := bfs(current, succ, inSCC, func(ps productState) bool { return set[ps.q] })
+			cycle = append(cycle, path...)
+			current = path[len(path)-1]
+		}
+		cycle = append(cycle, bfs(current, succ, inSCC, func(ps productState) bool { return ps == entry })...)
+
+		var path []int
+		for _, ps := range append(prefix, cycle...) {
+			path = append(path, ps.s)
+		}
+		return path, len(prefix) - 1, nil
+	}
+	return nil, 0, nil
+}
+
+// accepting reports whether a strongly connected component contains a cycle
+// and intersects every accepting set.
+func (p *product) accepting(scc []productState, succ map[productState][]productState) bool {
+	if len(scc) == 1 {
+		var loop bool
+		for _, ps := range succ[scc[0]] {
+			loop = loop || ps == scc[0]
+		}
+		if !loop {
+			return false
+		}
+	}
+	for _, set := range p.a.accepting {
+		var found bool
+		for _, ps := range scc {
+			found = found || set[ps.q]
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// bfs returns a shortest non-empty path from start to a state satisfying goal,
+// staying within the given states. The path excludes start.
+func bfs(start productState, succ map[productState][]productState, within map[productState]bool, goal func(productState) bool) []productState {
+	parent := map[productState]productState{}
+	queue := []productState{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range succ[current] {
+			if !within[next] {
+				continue
+			}
+			if _, seen := parent[next]; seen {
+				continue
+			}
+			parent[next] = current
+			if goal(next) {
+				var path []productState
+				for ps := next; ; ps = parent[ps] {
+					path = append([]productState{ps}, path...)
+					if parent[ps] == start {
+						break
+					}
+				}
+				return path
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+// stronglyConnected returns the strongly connected components of a graph using
+// Tarjan's algorithm.
+func stronglyConnected(states []productState, succ map[productState][]productState) [][]productState {
+	var (
+		index   = make(map[productState]int)
+		lowlink = make(map[productState]int)
+		onStack = make(map[productState]bool)
+		stack   []productState
+		result  [][]productState
+		visit   func(v productState)
+	)
+	visit = func(v productState) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range succ[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		if lowlink[v] == index[v] {
+			var scc []productState
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			result = append(result, scc)
+		}
+	}
+	for _, v := range states {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package modelcheck
+
+import (
+	"testing"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+func TestCheckLTL(t *testing.T) {
+	withoutCancel := serverChart()
+	withoutCancel.Transitions = withoutCancel.Transitions[:3]
+
+	tests := []struct {
+		name    string
+		chart   *semantics.Statechart
+		formula string
+		holds   bool
+	}{
+		{"response", withoutCancel, "G(Waiting -> F Serving)", true},
+		{"response with cancel", serverChart(), "G(Waiting -> F Serving)", false},
+		{"next state", serverChart(), "G(Idle -> X(Waiting))", true},
+		{"event proposition", serverChart(), "G(SERVE -> Serving)", true},
+		{"until", serverChart(), "Idle U REQUEST", true},
+		{"release", serverChart(), "Serving R !DONE", true},
+		{"infinitely often idle", serverChart(), "G F Idle", true},
+		{"eventually always idle", serverChart(), "F G Idle", false},
+		{"never serving", serverChart(), "G !Serving", false},
+		{"always idle", serverChart(), "Idle", true},
+		{"false", serverChart(), "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &Model{Chart: tt.chart}
+			result, err := model.CheckLTL(tt.formula)
+			if err != nil {
+				t.Fatalf("CheckLTL(%q) error = %v", tt.formula, err)
+			}
+			if result.Holds != tt.holds {
+				t.Fatalf("CheckLTL(%q) holds = %v, want %v", tt.formula, result.Holds, tt.holds)
+			}
+			if tt.holds {
+				if result.Counterexample != nil {
+					t.Errorf("CheckLTL(%q) has a counterexample for a property that holds", tt.formula)
+				}
+				return
+			}
+			if result.LoopStart < 0 {
+				t.Errorf("LoopStart = %d, want a lasso", result.LoopStart)
+			}
+			replay(t, model, result)
+		})
+	}
+}
+
+func TestCheckLTLCounterexample(t *testing.T) {
+	model := &Model{Chart: serverChart()}
+	result, err := model.CheckLTL("G(Waiting -> F Serving)")
+	if err != nil {
+		t.Fatalf("CheckLTL() error = %v", err)
+	}
+	// The shortest lasso requests and cancels forever.
+	var events []string
+	for _, step := range result.Counterexample[len(result.Counterexample)-1].GetStepHistory() {
+		events = append(events, step.GetEvents()[0].GetLabel())
+	}
+	for _, event := range events {
+		if event != "REQUEST" && event != "CANCEL" {
+			t.Errorf("counterexample events = %v, want only REQUEST and CANCEL", events)
+			break
+		}
+	}
+	for _, m := range result.Counterexample[result.LoopStart:] {
+		for _, s := range m.GetConfiguration().GetStates() {
+			if s.GetLabel() == "Serving" {
+				t.Errorf("counterexample loop reaches Serving")
+			}
+		}
+	}
+}
+
+func TestCheckLTLTerminalStates(t *testing.T) {
+	chart := semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Start", IsInitial: true},
+				{Label: "End", IsFinal: true},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "finish", From: []string{"Start"}, To: []string{"End"}, Event: "FINISH"},
+		},
+	})
+	model := &Model{Chart: chart}
+	// Every run leaves Start and then repeats the final state forever.
+	result, err := model.CheckLTL("F G End")
+	if err != nil {
+		t.Fatalf("CheckLTL() error = %v", err)
+	}
+	if !result.Holds {
+		t.Error("CheckLTL(F G End) violated, want holds")
+	}
+
+	result, err = model.CheckLTL("G !End")
+	if err != nil {
+		t.Fatalf("CheckLTL() error = %v", err)
+	}
+	if result.Holds {
+		t.Fatal("CheckLTL(G !End) holds, want violation")
+	}
+	if result.LoopStart < 1 {
+		t.Errorf("LoopStart = %d, want the loop to start in End", result.LoopStart)
+	}
+	replay(t, model, result)
+}
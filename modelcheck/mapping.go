@@ -0,0 +1,124 @@
+package modelcheck
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Mapping relates the symbols of an exported model to the statechart, so that
+// counterexamples found by external tools can be translated back.
+// It is written alongside the exported model as JSON.
+type Mapping struct {
+	// Format is the format of the exported model: "nusmv" or "promela".
+	Format string `json:"format"`
+	// EventVariable is the variable holding the event processed by the next step.
+	EventVariable string `json:"eventVariable"`
+	// NoEvent is the value of EventVariable for steps that process no event.
+	NoEvent string `json:"noEvent"`
+	// Events maps event symbols to events.
+	Events map[string]string `json:"events"`
+	// States maps state symbols to state labels.
+	States map[string]string `json:"states"`
+	// Regions maps the variables holding the active child of an OR-state to its label.
+	Regions map[string]string `json:"regions"`
+	// Transitions maps the symbols that are true when a transition fires to its label.
+	Transitions map[string]string `json:"transitions"`
+	// Variables maps variable symbols to context variables.
+	Variables map[string]string `json:"variables"`
+	// Constants maps the symbols of string values to the values.
+	Constants map[string]string `json:"constants"`
+}
+
+// EventSequence translates a trace of an exported model into the sequence of
+// events to send to a machine to reproduce it. Each state of the trace maps
+// symbols to their values; the event of the last state is not processed.
+// Steps without an event settle eventless transitions and are skipped.
+func (m *Mapping) EventSequence(trace []map[string]string) ([]string, error) {
+	var events []string
+	for i, state := range trace {
+		if i == len(trace)-1 {
+			break
+		}
+		symbol, ok := state[m.EventVariable]
+		if !ok {
+			return nil, fmt.Errorf("state %d: %s is undefined", i+1, m.EventVariable)
+		}
+		if symbol == m.NoEvent {
+			continue
+		}
+		event, ok := m.Events[symbol]
+		if !ok {
+			return nil, fmt.Errorf("state %d: unknown event %s", i+1, symbol)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ParseNuSMVTrace parses a counterexample printed by NuSMV. It returns the
+// states of the trace with the values of all variables, and the index of the
+// state at which the trace loops, or -1.
+//
+// NuSMV only prints the variables that change from one state to the next; the
+// returned states carry the unchanged values forward.
+func ParseNuSMVTrace(r io.Reader) ([]map[string]string, int, error) {
+	var (
+		trace     []map[string]string
+		loopStart = -1
+		loopNext  bool
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "-- Loop starts here":
+			loopNext = true
+		case strings.HasPrefix(line, "-> State:"):
+			state := make(map[string]string)
+			if len(trace) > 0 {
+				for k, v := range trace[len(trace)-1] {
+					state[k] = v
+				}
+			}
+			if loopNext {
+				loopStart, loopNext = len(trace), false
+			}
+			trace = append(trace, state)
+		case strings.HasPrefix(line, "->"), strings.HasPrefix(line, "--"):
+			// Input sections and comments.
+		case len(trace) > 0 && strings.Contains(line, " = "):
+			name, value, _ := strings.Cut(line, " = ")
+			trace[len(trace)-1][name] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(trace) == 0 {
+		return nil, 0, fmt.Errorf("no states in trace")
+	}
+	return trace, loopStart, nil
+}
+
+// ParseSpinTrace parses the output of replaying a SPIN trail of an exported
+// Promela model with "spin -t". The exported model prints the event of every
+// step; each printed event becomes a state of the returned trace, followed by
+// a final state without an event.
+func ParseSpinTrace(r io.Reader, m *Mapping) ([]map[string]string, error) {
+	var trace []map[string]string
+	scanner := bufio.NewScanner(r)
+	prefix := "sc: " + m.EventVariable + "="
+	for scanner.Scan() {
+		_, event, ok := strings.Cut(scanner.Text(), prefix)
+		if !ok {
+			continue
+		}
+		trace = append(trace, map[string]string{m.EventVariable: strings.TrimSpace(event)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return append(trace, map[string]string{}), nil
+}
@@ -0,0 +1,326 @@
+package modelcheck
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DefaultMaxStates is the default bound on the number of explored states.
+const DefaultMaxStates = 100000
+
+// ErrStateLimit is returned when the state space exceeds the bound of a model.
+var ErrStateLimit = errors.New("state limit exceeded")
+
+// Variable is a context variable with a finite domain.
+type Variable struct {
+	// Name is the name of the context field.
+	Name string
+	// Domain lists the values the variable may take. It is an error for an
+	// action to assign a value outside the domain.
+	Domain []*structpb.Value
+}
+
+// Model is a statechart together with the environment it is checked in.
+type Model struct {
+	// Chart is the statechart to check.
+	Chart *semantics.Statechart
+	// Context holds the fixed initial values of context fields.
+	Context *structpb.Struct
+	// Variables lists the context variables with finite domains. Every
+	// combination of their values is an initial state, unless the variable is
+	// set in Context.
+	Variables []Variable
+	// Events lists the events the environment may send. If empty, the events
+	// of the chart and its transitions are used.
+	Events []string
+	// Engine executes the chart. If nil, semantics.NewEngine is used.
+	Engine *semantics.Engine
+	// MaxStates bounds the number of explored states. If zero, DefaultMaxStates is used.
+	MaxStates int
+}
+
+// Result is the outcome of checking a property.
+type Result struct {
+	// Property is the name or formula of the property.
+	Property string
+	// Holds reports whether the property holds in every initial state.
+	Holds bool
+	// Counterexample is a trace of machines violating the property, starting in
+	// an initial state. Each machine carries the steps leading to it in its history.
+	Counterexample []*sc.Machine
+	// LoopStart is the index in Counterexample at which an infinite
+	// counterexample repeats: the last machine is in the same state as the
+	// machine at LoopStart. It is -1 for finite counterexamples.
+	LoopStart int
+	// States is the number of explored states.
+	States int
+}
+
+// state is a state of the Kripke structure: a machine snapshot together with the
+// event that led to it.
+type state struct {
+	machine *sc.Machine
+	event   string
+	active  map[string]bool
+	edges   []edge
+	parent  int
+}
+
+// edge is a transition of the Kripke structure. Terminal states have a single
+// stuttering edge to themselves with a nil step.
+type edge struct {
+	to   int
+	step *sc.Step
+}
+
+// kripke is the explored state space of a model.
+type kripke struct {
+	states  []*state
+	initial []int
+}
+
+// explore builds the Kripke structure of the model.
+func (m *Model) explore() (*kripke, error) {
+	if m.Chart == nil {
+		return nil, errors.New("model has no chart")
+	}
+	engine := m.Engine
+	if engine == nil {
+		engine = semantics.NewEngine()
+	}
+	maxStates := m.MaxStates
+	if maxStates == 0 {
+		maxStates = DefaultMaxStates
+	}
+	events := m.Events
+	if len(events) == 0 {
+		events = m.alphabet()
+	}
+
+	k := &kripke{}
+	index := make(map[string]int)
+	add := func(machine *sc.Machine, event string, parent int) (int, error) {
+		if err := m.checkDomains(machine.Context); err != nil {
+			return 0, err
+		}
+		key := stateKey(machine, event)
+		if i, ok := index[key]; ok {
+			return i, nil
+		}
+		if len(k.states) >= maxStates {
+			return 0, fmt.Errorf("%w: more than %d states", ErrStateLimit, maxStates)
+		}
+		s := &state{machine: machine, event: event, active: make(map[string]bool), parent: parent}
+		for _, ref := range machine.GetConfiguration().GetStates() {
+			s.active[ref.GetLabel()] = true
+		}
+		index[key] = len(k.states)
+		k.states = append(k.states, s)
+		return index[key], nil
+	}
+
+	contexts, err := m.initialContexts()
+	if err != nil {
+		return nil, err
+	}
+	for _, context := range contexts {
+		machine, err := engine.NewMachine("model", m.Chart, context)
+		if err != nil {
+			return nil, err
+		}
+		i, err := add(machine, "", -1)
+		if err != nil {
+			return nil, err
+		}
+		k.initial = appendUnique(k.initial, i)
+	}
+
+	for i := 0; i < len(k.states); i++ {
+		s := k.states[i]
+		if s.machine.State != sc.MachineStateStopped {
+			for _, event := range events {
+				machine := proto.Clone(s.machine).(*sc.Machine)
+				step, err := engine.Step(machine, event)
+				if err != nil {
+					return nil, fmt.Errorf("event %s in %s: %w", event, s, err)
+				}
+				if len(step.Transitions) == 0 {
+					continue
+				}
+				machine.StepHistory = nil
+				j, err := add(machine, event, i)
+				if err != nil {
+					return nil, err
+				}
+				s.edges = append(s.edges, edge{to: j, step: step})
+			}
+		}
+		if len(s.edges) == 0 {
+			s.edges = append(s.edges, edge{to: i})
+		}
+	}
+	return k, nil
+}
+
+// alphabet returns the events of the chart and its transitions, excluding the empty event.
+func (m *Model) alphabet() []string {
+	var events []string
+	seen := map[string]bool{"": true}
+	add := func(event string) {
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	for _, e := range m.Chart.Events {
+		add(e.GetLabel())
+	}
+	for _, t := range m.Chart.Transitions {
+		add(t.GetEvent())
+	}
+	return events
+}
+
+// initialContexts returns the cartesian product of the domains of the variables
+// that are not fixed by the context of the model.
+func (m *Model) initialContexts() ([]*structpb.Struct, error) {
+	base := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	for name, v := range m.Context.GetFields() {
+		base.Fields[name] = v
+	}
+	contexts := []*structpb.Struct{base}
+	for _, v := range m.Variables {
+		if len(v.Domain) == 0 {
+			return nil, fmt.Errorf("variable %s has an empty domain", v.Name)
+		}
+		if _, ok := base.Fields[v.Name]; ok {
+			continue
+		}
+		var next []*structpb.Struct
+		for _, context := range contexts {
+			for _, value := range v.Domain {
+				c := proto.Clone(context).(*structpb.Struct)
+				if c.Fields == nil {
+					c.Fields = make(map[string]*structpb.Value)
+				}
+				c.Fields[v.Name] = value
+				next = append(next, c)
+			}
+		}
+		contexts = next
+	}
+	return contexts, nil
+}
+
+// checkDomains reports an error if a variable is outside its domain.
+func (m *Model) checkDomains(context *structpb.Struct) error {
+	for _, v := range m.Variables {
+		value, ok := context.GetFields()[v.Name]
+		if !ok {
+			return fmt.Errorf("variable %s is undefined", v.Name)
+		}
+		var found bool
+		for _, d := range v.Domain {
+			if proto.Equal(d, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("variable %s left its domain with value %v", v.Name, value.AsInterface())
+		}
+	}
+	return nil
+}
+
+// CheckInvariant checks that the invariant holds in every reachable state. If it
+// does not, the counterexample is a shortest trace to a violating state.
+func (m *Model) CheckInvariant(name string, invariant func(*sc.Machine) bool) (*Result, error) {
+	k, err := m.explore()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Property: name, Holds: true, LoopStart: -1, States: len(k.states)}
+	// States are numbered in breadth-first order, so the first violation is
+	// reached by a shortest path of parent links.
+	for i, s := range k.states {
+		if invariant(s.machine) {
+			continue
+		}
+		var path []int
+		for j := i; j >= 0; j = k.states[j].parent {
+			path = append([]int{j}, path...)
+		}
+		result.Holds = false
+		result.Counterexample = k.trace(path)
+		break
+	}
+	return result, nil
+}
+
+// trace converts a path of states into a trace of machines with accumulated step histories.
+func (k *kripke) trace(path []int) []*sc.Machine {
+	var (
+		machines []*sc.Machine
+		history  []*sc.Step
+	)
+	for n, i := range path {
+		if n > 0 {
+			if step := k.step(path[n-1], i); step != nil {
+				history = append(history, step)
+			}
+		}
+		machine := proto.Clone(k.states[i].machine).(*sc.Machine)
+		machine.StepHistory = append([]*sc.Step(nil), history...)
+		machines = append(machines, machine)
+	}
+	return machines
+}
+
+// step returns the step of the edge from i to j.
+func (k *kripke) step(i, j int) *sc.Step {
+	for _, e := range k.states[i].edges {
+		if e.to == j {
+			return e.step
+		}
+	}
+	return nil
+}
+
+func (s *state) String() string {
+	var labels []string
+	for _, ref := range s.machine.GetConfiguration().GetStates() {
+		labels = append(labels, ref.GetLabel())
+	}
+	return "{" + strings.Join(labels, ", ") + "}"
+}
+
+// stateKey returns a canonical key for a machine state and the event that led to it.
+func stateKey(machine *sc.Machine, event string) string {
+	var sb strings.Builder
+	for _, ref := range machine.GetConfiguration().GetStates() {
+		sb.WriteString(ref.GetLabel())
+		sb.WriteByte(0)
+	}
+	sb.WriteByte(0)
+	context, _ := proto.MarshalOptions{Deterministic: true}.Marshal(machine.GetContext())
+	sb.Write(context)
+	sb.WriteByte(0)
+	sb.WriteString(event)
+	return sb.String()
+}
+
+func appendUnique(s []int, i int) []int {
+	for _, j := range s {
+		if i == j {
+			return s
+		}
+	}
+	return append(s, i)
+}
@@ -0,0 +1,197 @@
+package modelcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
+	"github.com/tmc/sc/validation/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// serverChart serves requests, which may be cancelled while waiting.
+func serverChart() *semantics.Statechart {
+	return semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Idle", IsInitial: true},
+				{Label: "Waiting"},
+				{Label: "Serving"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "request", From: []string{"Idle"}, To: []string{"Waiting"}, Event: "REQUEST"},
+			{Label: "serve", From: []string{"Waiting"}, To: []string{"Serving"}, Event: "SERVE"},
+			{Label: "done", From: []string{"Serving"}, To: []string{"Idle"}, Event: "DONE"},
+			{Label: "cancel", From: []string{"Waiting"}, To: []string{"Idle"}, Event: "CANCEL"},
+		},
+	})
+}
+
+// counterChart counts up to a limit in one region while a lamp toggles in another.
+func counterChart(guard string) *semantics.Statechart {
+	return semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{{
+				Label:     "Running",
+				Type:      sc.StateTypeParallel,
+				IsInitial: true,
+				Children: []*sc.State{
+					{Label: "Counter", Children: []*sc.State{{Label: "Counting", IsInitial: true}}},
+					{Label: "Lamp", Children: []*sc.State{{Label: "Dark", IsInitial: true}, {Label: "Lit"}}},
+				},
+			}},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "inc", From: []string{"Counting"}, To: []string{"Counting"}, Event: "INC", Guard: &sc.Guard{Expression: guard}, Actions: []*sc.Action{{Label: "count = count + 1"}}},
+			{Label: "on", From: []string{"Dark"}, To: []string{"Lit"}, Event: "TOGGLE"},
+			{Label: "off", From: []string{"Lit"}, To: []string{"Dark"}, Event: "TOGGLE"},
+		},
+	})
+}
+
+func countDomain(n int) []*structpb.Value {
+	var domain []*structpb.Value
+	for i := 0; i < n; i++ {
+		domain = append(domain, structpb.NewNumberValue(float64(i)))
+	}
+	return domain
+}
+
+func TestCheckInvariant(t *testing.T) {
+	model := &Model{
+		Chart:     counterChart("count < 2"),
+		Variables: []Variable{{Name: "count", Domain: countDomain(3)}},
+	}
+	isActive := func(m *sc.Machine, label string) bool {
+		for _, s := range m.GetConfiguration().GetStates() {
+			if s.GetLabel() == label {
+				return true
+			}
+		}
+		return false
+	}
+
+	result, err := model.CheckInvariant("never Dark and Lit", func(m *sc.Machine) bool {
+		return !(isActive(m, "Dark") && isActive(m, "Lit"))
+	})
+	if err != nil {
+		t.Fatalf("CheckInvariant() error = %v", err)
+	}
+	if !result.Holds {
+		t.Errorf("CheckInvariant() = %+v, want holds", result)
+	}
+
+	result, err = model.CheckInvariant("lit below two", func(m *sc.Machine) bool {
+		return !(isActive(m, "Lit") && m.GetContext().GetFields()["count"].GetNumberValue() == 2)
+	})
+	if err != nil {
+		t.Fatalf("CheckInvariant() error = %v", err)
+	}
+	if result.Holds {
+		t.Fatal("CheckInvariant() holds, want violation")
+	}
+	// Two is one of the initial values, so the shortest counterexample only toggles the lamp.
+	if got := len(result.Counterexample); got != 2 {
+		t.Errorf("len(Counterexample) = %d, want 2", got)
+	}
+	if result.LoopStart != -1 {
+		t.Errorf("LoopStart = %d, want -1", result.LoopStart)
+	}
+	replay(t, model, result)
+}
+
+func TestModelDomainViolation(t *testing.T) {
+	model := &Model{
+		Chart:     counterChart("count < 3"),
+		Variables: []Variable{{Name: "count", Domain: countDomain(3)}},
+	}
+	if _, err := model.CheckInvariant("true", func(*sc.Machine) bool { return true }); err == nil {
+		t.Error("CheckInvariant() expected error for value outside the domain")
+	}
+}
+
+func TestModelFixedContext(t *testing.T) {
+	initial, err := structpb.NewStruct(map[string]interface{}{"count": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := &Model{
+		Chart:     counterChart("count < 2"),
+		Context:   initial,
+		Variables: []Variable{{Name: "count", Domain: countDomain(3)}},
+	}
+	result, err := model.CheckInvariant("count is two", func(m *sc.Machine) bool {
+		return m.GetContext().GetFields()["count"].GetNumberValue() == 2
+	})
+	if err != nil {
+		t.Fatalf("CheckInvariant() error = %v", err)
+	}
+	if !result.Holds {
+		t.Error("CheckInvariant() violated, want holds")
+	}
+	// Two lamp states, each reached by TOGGLE or initially.
+	if result.States != 3 {
+		t.Errorf("States = %d, want 3", result.States)
+	}
+}
+
+func TestModelStateLimit(t *testing.T) {
+	model := &Model{Chart: serverChart(), MaxStates: 2}
+	_, err := model.CheckInvariant("true", func(*sc.Machine) bool { return true })
+	if !errors.Is(err, ErrStateLimit) {
+		t.Errorf("CheckInvariant() error = %v, want ErrStateLimit", err)
+	}
+}
+
+// replay checks that the counterexample of a result is a valid run of the model:
+// replaying its events in the engine reproduces each machine, and the trace
+// passes validation.
+func replay(t *testing.T, model *Model, result *Result) {
+	t.Helper()
+	trace := result.Counterexample
+	if len(trace) == 0 {
+		t.Fatal("empty counterexample")
+	}
+	engine := semantics.NewEngine()
+	m, err := engine.NewMachine("model", model.Chart, trace[0].GetContext())
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	last := trace[len(trace)-1]
+	for i, step := range last.GetStepHistory() {
+		if _, err := engine.Step(m, step.GetEvents()[0].GetLabel()); err != nil {
+			t.Fatalf("replaying step %d: %v", i, err)
+		}
+		if diff := cmp.Diff(step.GetResultingConfiguration(), m.GetConfiguration(), cmp.Comparer(proto.Equal)); diff != "" {
+			t.Errorf("step %d: configuration mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+	if !proto.Equal(last.GetConfiguration(), m.GetConfiguration()) || !proto.Equal(last.GetContext(), m.GetContext()) {
+		t.Errorf("replayed machine = %v, want %v", m, last)
+	}
+	if result.LoopStart >= 0 {
+		loop := trace[result.LoopStart]
+		if !proto.Equal(loop.GetConfiguration(), last.GetConfiguration()) || !proto.Equal(loop.GetContext(), last.GetContext()) {
+			t.Errorf("counterexample does not loop back to index %d", result.LoopStart)
+		}
+	}
+
+	resp, err := validation.NewSemanticValidator().ValidateTrace(context.Background(), &validationv1.ValidateTraceRequest{
+		Chart: model.Chart.Statechart,
+		Trace: trace,
+	})
+	if err != nil {
+		t.Fatalf("ValidateTrace() error = %v", err)
+	}
+	for _, v := range resp.GetViolations() {
+		if v.GetSeverity() == validationv1.Severity_ERROR {
+			t.Errorf("ValidateTrace() violation: %v", v)
+		}
+	}
+}
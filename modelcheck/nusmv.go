@@ -0,0 +1,205 @@
+package modelcheck
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var nusmv = &dialect{
+	and: "&", or: "|", not: "!", eq: "=", neq: "!=", mod: "mod",
+	boolean: func(b bool) string {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	},
+}
+
+// ExportNuSMV writes the model as a NuSMV module and returns the mapping of its
+// symbols to the statechart. Properties can be added to the module as LTLSPEC,
+// CTLSPEC or INVARSPEC declarations over the active-state definitions "in_*",
+// the event variable and the context variables "v_*".
+func (m *Model) ExportNuSMV(w io.Writer) (*Mapping, error) {
+	e, err := m.encode("nusmv")
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	if err := e.writeNuSMV(&sb); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return nil, err
+	}
+	return e.mapping, nil
+}
+
+func (e *encoding) writeNuSMV(w *strings.Builder) error {
+	p := func(format string, args ...interface{}) { fmt.Fprintf(w, format+"\n", args...) }
+
+	p("-- Generated by github.com/tmc/sc/modelcheck. DO NOT EDIT.")
+	p("MODULE main")
+	p("VAR")
+	events := []string{"none"}
+	for _, event := range e.events {
+		events = append(events, e.eventSymbol(event))
+	}
+	p("  event : {%s};", strings.Join(events, ", "))
+	for _, r := range e.regions {
+		var children []string
+		for _, c := range r.Children {
+			children = append(children, e.stateSymbol(c))
+		}
+		p("  %s : {%s};", e.regionSymbol(r), strings.Join(children, ", "))
+	}
+	for _, v := range e.variables {
+		typ, err := e.nusmvType(v.domain)
+		if err != nil {
+			return err
+		}
+		p("  %s : %s;", e.variableSymbol(v.name), typ)
+	}
+
+	// Fire conditions and the values of variables after each action.
+	current := make(map[string]string)
+	for _, v := range e.variables {
+		current[v.name] = e.variableSymbol(v.name)
+	}
+	var (
+		fires   []string
+		actions []string
+	)
+	for i, in := range e.instances {
+		condition, err := e.enabled(in, nusmv)
+		if err != nil {
+			return err
+		}
+		for _, other := range e.higherConflicts(i) {
+			condition += " & !" + other.name
+		}
+		fires = append(fires, fmt.Sprintf("  %s := %s;", in.name, condition))
+		for _, a := range in.actions {
+			value, err := e.render(a.Value, nusmv, current)
+			if err != nil {
+				return fmt.Errorf("transition %s: %w", in.transition.Label, err)
+			}
+			v := e.variable(a.Target.Path)
+			name := e.symbol("value", fmt.Sprintf("%s\x00%d", v.name, len(actions)), e.variableSymbol(v.name)+"_"+in.name)
+			actions = append(actions, fmt.Sprintf("  %s := case %s : %s; TRUE : %s; esac;", name, in.name, value, current[v.name]))
+			current[v.name] = name
+		}
+	}
+
+	p("ASSIGN")
+	for _, r := range e.regions {
+		p("  init(%s) := %s;", e.regionSymbol(r), e.stateSymbol(defaultChild(r)))
+		updates, children := e.regionUpdates(r)
+		if len(updates) == 0 {
+			continue
+		}
+		p("  next(%s) := case", e.regionSymbol(r))
+		for i, in := range updates {
+			p("      %s : %s;", in.name, e.stateSymbol(children[i]))
+		}
+		p("      TRUE : %s;", e.regionSymbol(r))
+		p("    esac;")
+	}
+	for _, v := range e.variables {
+		var init []string
+		for _, value := range v.init {
+			s, err := e.renderValue(value, nusmv)
+			if err != nil {
+				return err
+			}
+			init = append(init, s)
+		}
+		if len(init) == 1 {
+			p("  init(%s) := %s;", e.variableSymbol(v.name), init[0])
+		} else {
+			p("  init(%s) := {%s};", e.variableSymbol(v.name), strings.Join(init, ", "))
+		}
+		p("  next(%s) := %s;", e.variableSymbol(v.name), current[v.name])
+	}
+
+	p("DEFINE")
+	for _, s := range e.states {
+		parent := e.parent[s]
+		switch {
+		case parent == nil:
+			p("  %s := TRUE;", e.activeSymbol(s))
+		case !e.isRegion(parent):
+			p("  %s := %s;", e.activeSymbol(s), e.activeSymbol(parent))
+		case parent == e.root:
+			p("  %s := %s = %s;", e.activeSymbol(s), e.regionSymbol(parent), e.stateSymbol(s))
+		default:
+			p("  %s := %s & %s = %s;", e.activeSymbol(s), e.activeSymbol(parent), e.regionSymbol(parent), e.stateSymbol(s))
+		}
+	}
+	p("  final := %s;", e.conjunction(e.nonFinal(), "!", " & ", "TRUE"))
+	var eventless []string
+	for _, in := range e.eventless() {
+		condition, err := e.source(in, nusmv)
+		if err != nil {
+			return err
+		}
+		eventless = append(eventless, "!("+condition+")")
+	}
+	if len(eventless) == 0 {
+		p("  stable := TRUE;")
+	} else {
+		p("  stable := %s;", strings.Join(eventless, " & "))
+	}
+	p("  stopped := final & stable;")
+	for _, f := range fires {
+		p("%s", f)
+	}
+	for _, a := range actions {
+		p("%s", a)
+	}
+
+	p("INVAR !stable -> event = none;")
+	p("INVAR stopped -> event = none;")
+	p("INVAR stable & !stopped -> event != none;")
+	return nil
+}
+
+// isRegion reports whether a state has a variable holding its active child.
+func (e *encoding) isRegion(s *sc.State) bool {
+	for _, r := range e.regions {
+		if r == s {
+			return true
+		}
+	}
+	return false
+}
+
+// conjunction joins the active-state symbols of states, each prefixed by prefix.
+func (e *encoding) conjunction(states []*sc.State, prefix, sep, empty string) string {
+	if len(states) == 0 {
+		return empty
+	}
+	var terms []string
+	for _, s := range states {
+		terms = append(terms, prefix+e.activeSymbol(s))
+	}
+	return strings.Join(terms, sep)
+}
+
+func (e *encoding) nusmvType(domain []*structpb.Value) (string, error) {
+	var values []string
+	for _, v := range domain {
+		if _, ok := v.GetKind().(*structpb.Value_BoolValue); ok {
+			return "boolean", nil
+		}
+		s, err := e.renderValue(v, nusmv)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, s)
+	}
+	return "{" + strings.Join(values, ", ") + "}", nil
+}
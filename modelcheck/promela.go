@@ -0,0 +1,232 @@
+package modelcheck
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var promela = &dialect{
+	and: "&&", or: "||", not: "!", eq: "==", neq: "!=", mod: "%",
+	boolean: func(b bool) string {
+		if b {
+			return "true"
+		}
+		return "false"
+	},
+}
+
+// ExportPromela writes the model as a Promela process and returns the mapping
+// of its symbols to the statechart. Properties can be checked as LTL formulas
+// over the active-state macros "in_*", the event variable and the context
+// variables "v_*"; leaving the domain of a variable fails an assertion. The
+// process prints the event of every step, so that "spin -t" replays trails in
+// a form ParseSpinTrace understands.
+func (m *Model) ExportPromela(w io.Writer) (*Mapping, error) {
+	e, err := m.encode("promela")
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	if err := e.writePromela(&sb); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return nil, err
+	}
+	return e.mapping, nil
+}
+
+func (e *encoding) writePromela(w *strings.Builder) error {
+	p := func(format string, args ...interface{}) { fmt.Fprintf(w, format+"\n", args...) }
+
+	// Render guards and actions first, since they may introduce string constants.
+	fires := make([]string, len(e.instances))
+	for i, in := range e.instances {
+		condition, err := e.enabled(in, promela)
+		if err != nil {
+			return err
+		}
+		for _, other := range e.higherConflicts(i) {
+			condition += " && !" + other.name
+		}
+		fires[i] = condition
+	}
+	// Promela executes the actions in sequence, so variables refer to their current values.
+	current := make(map[string]string)
+	for _, v := range e.variables {
+		current[v.name] = e.variableSymbol(v.name)
+	}
+	actions := make([][]string, len(e.instances))
+	for i, in := range e.instances {
+		for _, a := range in.actions {
+			value, err := e.render(a.Value, promela, current)
+			if err != nil {
+				return fmt.Errorf("transition %s: %w", in.transition.Label, err)
+			}
+			actions[i] = append(actions[i], fmt.Sprintf("%s = %s", e.variableSymbol(e.variable(a.Target.Path).name), value))
+		}
+	}
+	var eventless []string
+	for _, in := range e.eventless() {
+		condition, err := e.source(in, promela)
+		if err != nil {
+			return err
+		}
+		eventless = append(eventless, "!("+condition+")")
+	}
+
+	p("/* Generated by github.com/tmc/sc/modelcheck. DO NOT EDIT. */")
+	p("")
+	constants := []string{"none"}
+	for _, event := range e.events {
+		constants = append(constants, e.eventSymbol(event))
+	}
+	for _, s := range e.strings {
+		constants = append(constants, e.constantSymbol(s))
+	}
+	p("mtype = { %s };", strings.Join(constants, ", "))
+	p("")
+	for _, r := range e.regions {
+		for _, c := range r.Children {
+			p("#define %s %d", e.stateSymbol(c), e.index[c])
+		}
+	}
+	p("")
+	p("mtype event = none;")
+	for _, r := range e.regions {
+		p("byte %s = %s;", e.regionSymbol(r), e.stateSymbol(defaultChild(r)))
+	}
+	for _, v := range e.variables {
+		typ := map[string]string{"number": "int", "string": "mtype", "bool": "bool"}[v.kind]
+		p("%s %s;", typ, e.variableSymbol(v.name))
+	}
+	p("")
+	for _, s := range e.states {
+		parent := e.parent[s]
+		switch {
+		case parent == nil:
+			p("#define %s true", e.activeSymbol(s))
+		case !e.isRegion(parent):
+			p("#define %s %s", e.activeSymbol(s), e.activeSymbol(parent))
+		case parent == e.root:
+			p("#define %s (%s == %s)", e.activeSymbol(s), e.regionSymbol(parent), e.stateSymbol(s))
+		default:
+			p("#define %s (%s && %s == %s)", e.activeSymbol(s), e.activeSymbol(parent), e.regionSymbol(parent), e.stateSymbol(s))
+		}
+	}
+	p("#define final (%s)", e.conjunction(e.nonFinal(), "!", " && ", "true"))
+	if len(eventless) == 0 {
+		p("#define stable true")
+	} else {
+		p("#define stable (%s)", strings.Join(eventless, " && "))
+	}
+	p("#define stopped (final && stable)")
+	p("")
+
+	p("active proctype statechart()")
+	p("{")
+	var flags []string
+	for _, in := range e.instances {
+		flags = append(flags, in.name)
+	}
+	if len(flags) > 0 {
+		p("  bool %s;", strings.Join(flags, ", "))
+	}
+	p("")
+	if len(e.variables) > 0 {
+		choices, err := e.initialChoices()
+		if err != nil {
+			return err
+		}
+		writeChoices(p, "  ", choices)
+		p("")
+	}
+	p("  do")
+	p("  :: stopped -> break")
+	p("  :: else ->")
+	p("    if")
+	p("    :: !stable -> event = none")
+	if len(e.events) > 0 {
+		p("    :: else ->")
+		p("      if")
+		for _, event := range e.events {
+			p("      :: event = %s", e.eventSymbol(event))
+		}
+		p("      fi")
+	}
+	p("    fi;")
+	p("    printf(\"sc: event=%%e\\n\", event);")
+	p("    d_step {")
+	for i, in := range e.instances {
+		p("      %s = %s;", in.name, fires[i])
+	}
+	for i, in := range e.instances {
+		if len(actions[i]) == 0 {
+			continue
+		}
+		p("      if")
+		p("      :: %s -> %s", in.name, strings.Join(actions[i], "; "))
+		p("      :: else -> skip")
+		p("      fi;")
+	}
+	for _, v := range e.variables {
+		var terms []string
+		for _, value := range v.domain {
+			s, err := e.renderValue(value, promela)
+			if err != nil {
+				return err
+			}
+			terms = append(terms, fmt.Sprintf("%s == %s", e.variableSymbol(v.name), s))
+		}
+		p("      assert(%s);", strings.Join(terms, " || "))
+	}
+	for _, r := range e.regions {
+		updates, children := e.regionUpdates(r)
+		if len(updates) == 0 {
+			continue
+		}
+		p("      if")
+		for i, in := range updates {
+			p("      :: %s -> %s = %s", in.name, e.regionSymbol(r), e.stateSymbol(children[i]))
+		}
+		p("      :: else -> skip")
+		p("      fi;")
+	}
+	p("    }")
+	p("  od")
+	p("}")
+	return nil
+}
+
+// initialChoices returns, per variable, the assignments of its possible initial values.
+func (e *encoding) initialChoices() ([][]string, error) {
+	var choices [][]string
+	for _, v := range e.variables {
+		var choice []string
+		for _, value := range v.init {
+			s, err := e.renderValue(value, promela)
+			if err != nil {
+				return nil, err
+			}
+			choice = append(choice, fmt.Sprintf("%s = %s", e.variableSymbol(v.name), s))
+		}
+		choices = append(choices, choice)
+	}
+	return choices, nil
+}
+
+// writeChoices writes a sequence of nondeterministic choices.
+func writeChoices(p func(string, ...interface{}), indent string, choices [][]string) {
+	for _, choice := range choices {
+		if len(choice) == 1 {
+			p("%s%s;", indent, choice[0])
+			continue
+		}
+		p("%sif", indent)
+		for _, c := range choice {
+			p("%s:: %s", indent, c)
+		}
+		p("%sfi;", indent)
+	}
+}
@@ -0,0 +1,429 @@
+package modelcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Coverage is a coverage criterion for generated tests.
+type Coverage int
+
+const (
+	// StateCoverage requires every state to be active in some test.
+	StateCoverage Coverage = iota + 1
+	// TransitionCoverage requires every state to be active and every
+	// transition to fire in some test.
+	TransitionCoverage
+	// TransitionPairCoverage additionally requires every pair of transitions
+	// that can fire in consecutive steps to do so in some test.
+	TransitionPairCoverage
+)
+
+// String returns the name of the criterion.
+func (c Coverage) String() string {
+	switch c {
+	case StateCoverage:
+		return "state"
+	case TransitionCoverage:
+		return "transition"
+	case TransitionPairCoverage:
+		return "transition pair"
+	}
+	return fmt.Sprintf("Coverage(%d)", int(c))
+}
+
+// TestCase is a run of the model that covers one or more elements of the chart.
+type TestCase struct {
+	// Name describes the element the test was generated for.
+	Name string
+	// Events lists the events the test sends, in order.
+	Events []string
+	// Trace holds the machine before the first event and after each event.
+	// Each machine carries the steps leading to it in its history, so the
+	// trace can be checked with SemanticValidator.ValidateTrace.
+	Trace []*sc.Machine
+}
+
+// TestSuite is a set of tests achieving a coverage criterion.
+type TestSuite struct {
+	// Coverage is the criterion the suite achieves.
+	Coverage Coverage
+	// Tests lists the tests of the suite.
+	Tests []*TestCase
+	// UncoveredStates lists the states no run of the model activates.
+	UncoveredStates []string
+	// UncoveredTransitions lists the labels of the transitions that fire in no
+	// run of the model. Eventless transitions taken while a machine starts are
+	// not part of a step and count as uncovered.
+	UncoveredTransitions []string
+}
+
+// testGoal is an element of the chart a test suite has to cover.
+type testGoal struct {
+	name  string
+	label string
+	// uncovered collects the label of the goal if no path covers it.
+	uncovered *[]string
+	// path returns a shortest path covering the goal, or nil.
+	path func() []int
+	// covered reports whether a path covers the goal.
+	covered func(path []int) bool
+}
+
+// GenerateTests generates tests achieving the coverage criterion in the
+// explored state space. Every test is a shortest run covering an element not
+// covered by the tests before it. Elements with longer shortest runs are
+// considered first, since their runs tend to cover the elements on the way;
+// ties keep the document order of states and the declaration order of
+// transitions. Elements no run covers are reported as uncovered rather than
+// failing the generation.
+func (m *Model) GenerateTests(coverage Coverage) (*TestSuite, error) {
+	if coverage < StateCoverage || coverage > TransitionPairCoverage {
+		return nil, fmt.Errorf("unknown coverage criterion %v", coverage)
+	}
+	k, err := m.explore()
+	if err != nil {
+		return nil, err
+	}
+	dist, parent := k.distances()
+	pathTo := func(i int) []int {
+		var path []int
+		for j := i; j >= 0; j = parent[j] {
+			path = append([]int{j}, path...)
+		}
+		return path
+	}
+	fires := m.transitionIndex()
+
+	var goals []testGoal
+	suite := &TestSuite{Coverage: coverage}
+	for _, label := range chartStates(m.Chart) {
+		label := label
+		goals = append(goals, testGoal{
+			name:      "state " + label,
+			uncovered: &suite.UncoveredStates,
+			label:     label,
+			path: func() []int {
+				best := -1
+				for i, s := range k.states {
+					if s.active[label] && dist[i] >= 0 && (best < 0 || dist[i] < dist[best]) {
+						best = i
+					}
+				}
+				if best < 0 {
+					return nil
+				}
+				return pathTo(best)
+			},
+			covered: func(path []int) bool {
+				for _, i := range path {
+					if k.states[i].active[label] {
+						return true
+					}
+				}
+				return false
+			},
+		})
+	}
+	if coverage >= TransitionCoverage {
+		for n, t := range m.Chart.Transitions {
+			n, t := n, t
+			goals = append(goals, testGoal{
+				name:      "transition " + t.GetLabel(),
+				uncovered: &suite.UncoveredTransitions,
+				label:     t.GetLabel(),
+				path: func() []int {
+					bestFrom, bestTo := -1, -1
+					for i, s := range k.states {
+						if dist[i] < 0 || bestFrom >= 0 && dist[i] >= dist[bestFrom] {
+							continue
+						}
+						for _, e := range s.edges {
+							if fires(e.step, n) {
+								bestFrom, bestTo = i, e.to
+								break
+							}
+						}
+					}
+					if bestFrom < 0 {
+						return nil
+					}
+					return append(pathTo(bestFrom), bestTo)
+				},
+				covered: func(path []int) bool {
+					for i := 1; i < len(path); i++ {
+						if fires(k.step(path[i-1], path[i]), n) {
+							return true
+						}
+					}
+					return false
+				},
+			})
+		}
+	}
+	if coverage >= TransitionPairCoverage {
+		for _, pair := range k.transitionPairs(m.Chart.Transitions, fires) {
+			n1, n2 := pair[0], pair[1]
+			goals = append(goals, testGoal{
+				name: fmt.Sprintf("transitions %s, %s", m.Chart.Transitions[n1].GetLabel(), m.Chart.Transitions[n2].GetLabel()),
+				path: func() []int {
+					var best []int
+					bestDist := -1
+					for i, s := range k.states {
+						if dist[i] < 0 || bestDist >= 0 && dist[i] >= bestDist {
+							continue
+						}
+						for _, e1 := range s.edges {
+							if !fires(e1.step, n1) {
+								continue
+							}
+							for _, e2 := range k.states[e1.to].edges {
+								if fires(e2.step, n2) && (bestDist < 0 || dist[i] < bestDist) {
+									best, bestDist = append(pathTo(i), e1.to, e2.to), dist[i]
+								}
+							}
+						}
+					}
+					return best
+				},
+				covered: func(path []int) bool {
+					for i := 2; i < len(path); i++ {
+						if fires(k.step(path[i-2], path[i-1]), n1) && fires(k.step(path[i-1], path[i]), n2) {
+							return true
+						}
+					}
+					return false
+				},
+			})
+		}
+	}
+
+	shortest := make([][]int, len(goals))
+	order := make([]int, len(goals))
+	for i, goal := range goals {
+		shortest[i], order[i] = goal.path(), i
+		if shortest[i] == nil && goal.uncovered != nil {
+			*goal.uncovered = append(*goal.uncovered, goal.label)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(shortest[order[i]]) > len(shortest[order[j]])
+	})
+	var paths [][]int
+	for _, i := range order {
+		goal, path := goals[i], shortest[i]
+		if path == nil {
+			continue
+		}
+		var covered bool
+		for _, p := range paths {
+			if goal.covered(p) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		paths = append(paths, path)
+		test := &TestCase{Name: goal.name, Trace: k.trace(path)}
+		for _, i := range path[1:] {
+			test.Events = append(test.Events, k.states[i].event)
+		}
+		suite.Tests = append(suite.Tests, test)
+	}
+	return suite, nil
+}
+
+// distances returns the length of a shortest path from an initial state to
+// each state, or -1 if it is unreachable, and the predecessor of each state on
+// such a path.
+func (k *kripke) distances() (dist, parent []int) {
+	dist = make([]int, len(k.states))
+	parent = make([]int, len(k.states))
+	for i := range dist {
+		dist[i], parent[i] = -1, -1
+	}
+	var queue []int
+	for _, i := range k.initial {
+		dist[i] = 0
+		queue = append(queue, i)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, e := range k.states[current].edges {
+			if dist[e.to] < 0 {
+				dist[e.to], parent[e.to] = dist[current]+1, current
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	return dist, parent
+}
+
+// transitionPairs returns the pairs of transitions, as indices into
+// transitions, that fire in consecutive steps of some run.
+func (k *kripke) transitionPairs(transitions []*sc.Transition, fires func(*sc.Step, int) bool) [][2]int {
+	found := make(map[[2]int]bool)
+	for _, s := range k.states {
+		for _, e1 := range s.edges {
+			for _, e2 := range k.states[e1.to].edges {
+				for n1 := range transitions {
+					if !fires(e1.step, n1) {
+						continue
+					}
+					for n2 := range transitions {
+						if fires(e2.step, n2) {
+							found[[2]int{n1, n2}] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	var pairs [][2]int
+	for n1 := range transitions {
+		for n2 := range transitions {
+			if found[[2]int{n1, n2}] {
+				pairs = append(pairs, [2]int{n1, n2})
+			}
+		}
+	}
+	return pairs
+}
+
+// transitionIndex returns a function reporting whether a step fires the
+// transition at index n of the chart. Steps refer to copies of the
+// transitions of the chart, so transitions are compared by value.
+func (m *Model) transitionIndex() func(step *sc.Step, n int) bool {
+	index := make(map[*sc.Transition]int)
+	lookup := func(t *sc.Transition) int {
+		if n, ok := index[t]; ok {
+			return n
+		}
+		index[t] = -1
+		for n, u := range m.Chart.Transitions {
+			if proto.Equal(t, u) {
+				index[t] = n
+				break
+			}
+		}
+		return index[t]
+	}
+	return func(step *sc.Step, n int) bool {
+		for _, t := range step.GetTransitions() {
+			if lookup(t) == n {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// chartStates returns the labels of the states of the chart in document order,
+// without the root state.
+func chartStates(chart *semantics.Statechart) []string {
+	var labels []string
+	var visit func(s *sc.State)
+	visit = func(s *sc.State) {
+		if s != chart.RootState {
+			labels = append(labels, s.GetLabel())
+		}
+		for _, c := range s.GetChildren() {
+			visit(c)
+		}
+	}
+	visit(chart.RootState)
+	return labels
+}
+
+// WriteGoTests writes the tests of the suite as a table in a Go source file of
+// the given package, for use in a _test.go file of an implementation of the
+// chart. The table is a variable with the given name:
+//
+//	var name = []struct {
+//		Name           string
+//		Context        string     // Initial context as JSON.
+//		Events         []string   // Events to send, in order.
+//		Configurations [][]string // Active states initially and after each event.
+//	}{...}
+//
+// Configurations list the active states in document order, without the root state.
+func (s *TestSuite) WriteGoTests(w io.Writer, pkg, name string) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	var b bytes.Buffer
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format+"\n", args...) }
+	p("// Code generated by github.com/tmc/sc/modelcheck. DO NOT EDIT.")
+	p("")
+	p("package %s", pkg)
+	p("")
+	p("// %s lists tests achieving %s coverage of the statechart.", name, s.Coverage)
+	p("// Each test starts a machine with Context, sends Events in order and expects")
+	p("// the active states in Configurations initially and after each event.")
+	p("var %s = []struct {", name)
+	p("Name           string")
+	p("Context        string")
+	p("Events         []string")
+	p("Configurations [][]string")
+	p("}{")
+	for _, test := range s.Tests {
+		context, err := protojson.Marshal(test.Trace[0].GetContext())
+		if err != nil {
+			return err
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, context); err != nil {
+			return err
+		}
+		p("{")
+		p("Name: %q,", test.Name)
+		p("Context: %q,", compact.String())
+		p("Events: %s,", stringSlice(test.Events))
+		var configurations []string
+		for _, machine := range test.Trace {
+			var labels []string
+			for _, ref := range machine.GetConfiguration().GetStates() {
+				if ref.GetLabel() != semantics.RootState.String() {
+					labels = append(labels, ref.GetLabel())
+				}
+			}
+			configurations = append(configurations, strings.TrimPrefix(stringSlice(labels), "[]string"))
+		}
+		p("Configurations: [][]string{%s},", strings.Join(configurations, ", "))
+		p("},")
+	}
+	p("}")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// stringSlice returns a Go literal of a string slice.
+func stringSlice(s []string) string {
+	quoted := make([]string, len(s))
+	for i, v := range s {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
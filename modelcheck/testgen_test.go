@@ -0,0 +1,118 @@
+package modelcheck
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+)
+
+func TestGenerateTests(t *testing.T) {
+	tests := []struct {
+		coverage Coverage
+		want     map[string][]string
+	}{
+		{StateCoverage, map[string][]string{
+			"state Serving": {"REQUEST", "SERVE"},
+		}},
+		{TransitionCoverage, map[string][]string{
+			"transition done":   {"REQUEST", "SERVE", "DONE"},
+			"transition cancel": {"REQUEST", "CANCEL"},
+		}},
+		{TransitionPairCoverage, map[string][]string{
+			"transitions done, request":   {"REQUEST", "SERVE", "DONE", "REQUEST"},
+			"transitions cancel, request": {"REQUEST", "CANCEL", "REQUEST"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.coverage.String(), func(t *testing.T) {
+			model := &Model{Chart: serverChart()}
+			suite, err := model.GenerateTests(tt.coverage)
+			if err != nil {
+				t.Fatalf("GenerateTests() error = %v", err)
+			}
+			got := make(map[string][]string)
+			for _, test := range suite.Tests {
+				got[test.Name] = test.Events
+				if len(test.Trace) != len(test.Events)+1 {
+					t.Errorf("%s: %d machines for %d events", test.Name, len(test.Trace), len(test.Events))
+				}
+				replay(t, model, &Result{Counterexample: test.Trace, LoopStart: -1})
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("tests mismatch (-want +got):\n%s", diff)
+			}
+			if len(suite.UncoveredStates) != 0 || len(suite.UncoveredTransitions) != 0 {
+				t.Errorf("uncovered states %v and transitions %v", suite.UncoveredStates, suite.UncoveredTransitions)
+			}
+		})
+	}
+}
+
+func TestGenerateTestsUncovered(t *testing.T) {
+	chart := counterChart("count < 0")
+	chart.RootState.Children = append(chart.RootState.Children, &sc.State{Label: "Orphan"})
+	model := &Model{
+		Chart:     chart,
+		Variables: []Variable{{Name: "count", Domain: countDomain(1)}},
+	}
+	suite, err := model.GenerateTests(TransitionCoverage)
+	if err != nil {
+		t.Fatalf("GenerateTests() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"Orphan"}, suite.UncoveredStates); diff != "" {
+		t.Errorf("UncoveredStates mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"inc"}, suite.UncoveredTransitions); diff != "" {
+		t.Errorf("UncoveredTransitions mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := model.GenerateTests(Coverage(0)); err == nil {
+		t.Error("GenerateTests(0) expected error")
+	}
+}
+
+func TestWriteGoTests(t *testing.T) {
+	model := &Model{Chart: serverChart()}
+	suite, err := model.GenerateTests(TransitionCoverage)
+	if err != nil {
+		t.Fatalf("GenerateTests() error = %v", err)
+	}
+	var b bytes.Buffer
+	if err := suite.WriteGoTests(&b, "server_test", "serverTests"); err != nil {
+		t.Fatalf("WriteGoTests() error = %v", err)
+	}
+	want := `// Code generated by github.com/tmc/sc/modelcheck. DO NOT EDIT.
+
+package server_test
+
+// serverTests lists tests achieving transition coverage of the statechart.
+// Each test starts a machine with Context, sends Events in order and expects
+// the active states in Configurations initially and after each event.
+var serverTests = []struct {
+	Name           string
+	Context        string
+	Events         []string
+	Configurations [][]string
+}{
+	{
+		Name:           "transition done",
+		Context:        "{}",
+		Events:         []string{"REQUEST", "SERVE", "DONE"},
+		Configurations: [][]string{{"Idle"}, {"Waiting"}, {"Serving"}, {"Idle"}},
+	},
+	{
+		Name:           "transition cancel",
+		Context:        "{}",
+		Events:         []string{"REQUEST", "CANCEL"},
+		Configurations: [][]string{{"Idle"}, {"Waiting"}, {"Idle"}},
+	},
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteGoTests() mismatch (-want +got):\n%s", diff)
+	}
+	if err := suite.WriteGoTests(&b, "server-test", "tests"); err == nil {
+		t.Error("WriteGoTests() expected error for an invalid package name")
+	}
+}
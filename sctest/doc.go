@@ -0,0 +1,24 @@
+// Package sctest tests statecharts with random event sequences.
+//
+// A Generator produces sequences of events that are valid for a chart: each
+// event is chosen among the events that trigger a transition out of the
+// configuration the machine is in when the event is sent. Check runs such
+// sequences through a semantics.Engine and checks a set of invariants after
+// every step. When an invariant fails, the sequence is shrunk to a minimal
+// counterexample that still violates an invariant:
+//
+//	func TestCounter(t *testing.T) {
+//		sctest.Test(t, chart, &sctest.Config{Context: context},
+//			sctest.ValidConfiguration(chart),
+//			func(m *sc.Machine) error {
+//				if m.Context.Fields["count"].GetNumberValue() > 3 {
+//					return errors.New("count exceeds its limit")
+//				}
+//				return nil
+//			},
+//		)
+//	}
+//
+// Generators can also supply the arguments of properties checked with
+// testing/quick, through the Values method.
+package sctest
@@ -0,0 +1,358 @@
+package sctest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// DefaultMaxCount is the default number of sequences run by Check.
+	DefaultMaxCount = 100
+	// DefaultMaxLength is the default bound on the length of generated sequences.
+	DefaultMaxLength = 50
+)
+
+// Sequence is a sequence of events sent to a machine.
+type Sequence []string
+
+// Invariant checks a machine after its initial configuration is entered and
+// after every step. The steps leading to the machine are in its history.
+type Invariant func(machine *sc.Machine) error
+
+// ValidConfiguration returns an invariant that checks the configuration of
+// machines with semantics.ValidateConfiguration. Check and Run always check it.
+func ValidConfiguration(chart *semantics.Statechart) Invariant {
+	return func(machine *sc.Machine) error {
+		return semantics.ValidateConfiguration(chart, machine.GetConfiguration())
+	}
+}
+
+// Config configures the machines under test. A nil *Config uses the defaults.
+type Config struct {
+	// Context is the initial context of the machines.
+	Context *structpb.Struct
+	// Engine executes the chart. If nil, semantics.NewEngine is used.
+	Engine *semantics.Engine
+	// MaxCount is the number of sequences run by Check. If zero, DefaultMaxCount is used.
+	MaxCount int
+	// MaxLength bounds the length of generated sequences. If zero, DefaultMaxLength is used.
+	MaxLength int
+	// Seed seeds the random choice of events. If zero, a seed is derived from
+	// the current time and reported with failures.
+	Seed int64
+}
+
+func (c *Config) context() *structpb.Struct {
+	if c == nil {
+		return nil
+	}
+	return c.Context
+}
+
+func (c *Config) engine() *semantics.Engine {
+	if c == nil || c.Engine == nil {
+		return semantics.NewEngine()
+	}
+	return c.Engine
+}
+
+func (c *Config) maxCount() int {
+	if c == nil || c.MaxCount == 0 {
+		return DefaultMaxCount
+	}
+	return c.MaxCount
+}
+
+func (c *Config) maxLength() int {
+	if c == nil || c.MaxLength == 0 {
+		return DefaultMaxLength
+	}
+	return c.MaxLength
+}
+
+func (c *Config) seed() int64 {
+	if c == nil || c.Seed == 0 {
+		return time.Now().UnixNano()
+	}
+	return c.Seed
+}
+
+// Failure is a sequence of events after which a machine violates an invariant
+// or the engine fails to take a step.
+type Failure struct {
+	// Events is the sequence of events. The failure occurs on the last event,
+	// or in the initial configuration if Events is empty.
+	Events Sequence
+	// Machine is the machine after the last step that was taken.
+	Machine *sc.Machine
+	// Err is the error returned by the invariant or the engine.
+	Err error
+	// Seed is the seed of the run that found the failure. It is zero for
+	// failures found by Run.
+	Seed int64
+}
+
+func (f *Failure) Error() string {
+	var b strings.Builder
+	b.WriteString("sctest: ")
+	if len(f.Events) == 0 {
+		b.WriteString("initial configuration")
+	} else {
+		fmt.Fprintf(&b, "events %v", []string(f.Events))
+	}
+	if f.Machine != nil {
+		fmt.Fprintf(&b, " in configuration %v", configurationLabels(f.Machine))
+	}
+	if f.Seed != 0 {
+		fmt.Fprintf(&b, " (seed %d)", f.Seed)
+	}
+	fmt.Fprintf(&b, ": %v", f.Err)
+	return b.String()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Test runs Check and fails t with the minimal counterexample if an invariant is violated.
+func Test(t testing.TB, chart *semantics.Statechart, config *Config, invariants ...Invariant) {
+	t.Helper()
+	if err := Check(chart, config, invariants...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Check runs random valid event sequences through machines of the chart and
+// checks the invariants after every step. If an invariant is violated, Check
+// returns a *Failure with the shortest sequence it finds that still violates an
+// invariant. Other errors are returned if a machine cannot be created.
+func Check(chart *semantics.Statechart, config *Config, invariants ...Invariant) error {
+	r := newRunner(chart, config, invariants)
+	seed := config.seed()
+	rand := rand.New(rand.NewSource(seed))
+	for i := 0; i < config.maxCount(); i++ {
+		_, failure, err := r.walk(rand, config.maxLength())
+		if err != nil {
+			return err
+		}
+		if failure != nil {
+			failure = r.shrink(failure)
+			failure.Seed = seed
+			return failure
+		}
+	}
+	return nil
+}
+
+// Run sends the events to a new machine of the chart and checks the
+// invariants after every step. It returns a *Failure if an invariant is
+// violated. Events sent after the machine stops are ignored.
+func Run(chart *semantics.Statechart, config *Config, events Sequence, invariants ...Invariant) error {
+	r := newRunner(chart, config, invariants)
+	failure, err := r.run(events, false)
+	if err != nil {
+		return err
+	}
+	if failure != nil {
+		return failure
+	}
+	return nil
+}
+
+// Generator generates valid event sequences of a chart.
+type Generator struct {
+	runner *runner
+	config *Config
+}
+
+// NewGenerator returns a generator of event sequences for machines of the chart.
+func NewGenerator(chart *semantics.Statechart, config *Config) *Generator {
+	return &Generator{runner: newRunner(chart, config, nil), config: config}
+}
+
+// Generate returns a Sequence of at most size events, chosen at random among
+// the events enabled in the configuration reached by the previous events. The
+// sequence ends early if the machine stops or no event is enabled.
+func (g *Generator) Generate(rand *rand.Rand, size int) reflect.Value {
+	events, _, _ := g.runner.walk(rand, size)
+	return reflect.ValueOf(events)
+}
+
+// Values fills the arguments of a function checked with testing/quick with
+// sequences of at most the configured MaxLength events. Every argument of the
+// function must be a Sequence.
+//
+//	quick.Check(func(events sctest.Sequence) bool { ... }, &quick.Config{Values: g.Values})
+func (g *Generator) Values(args []reflect.Value, rand *rand.Rand) {
+	for i := range args {
+		args[i] = g.Generate(rand, g.config.maxLength())
+	}
+}
+
+// runner runs event sequences through machines of a chart.
+type runner struct {
+	chart      *semantics.Statechart
+	context    *structpb.Struct
+	engine     *semantics.Engine
+	invariants []Invariant
+}
+
+func newRunner(chart *semantics.Statechart, config *Config, invariants []Invariant) *runner {
+	return &runner{
+		chart:      chart,
+		context:    config.context(),
+		engine:     config.engine(),
+		invariants: append([]Invariant{ValidConfiguration(chart)}, invariants...),
+	}
+}
+
+// check checks the invariants of a machine reached by the events.
+func (r *runner) check(machine *sc.Machine, events Sequence) *Failure {
+	for _, invariant := range r.invariants {
+		if err := invariant(machine); err != nil {
+			return &Failure{Events: events, Machine: machine, Err: err}
+		}
+	}
+	return nil
+}
+
+// walk sends at most n random enabled events to a new machine, checking the
+// invariants after every step. It returns the events sent, which end with the
+// failing event if an invariant is violated.
+func (r *runner) walk(rand *rand.Rand, n int) (Sequence, *Failure, error) {
+	machine, err := r.engine.NewMachine("sctest", r.chart, r.context)
+	if err != nil {
+		return nil, nil, err
+	}
+	if failure := r.check(machine, nil); failure != nil {
+		return nil, failure, nil
+	}
+	var events Sequence
+	for len(events) < n && machine.State != sc.MachineStateStopped {
+		enabled := enabledEvents(r.chart, machine)
+		if len(enabled) == 0 {
+			break
+		}
+		events = append(events, enabled[rand.Intn(len(enabled))])
+		if failure := r.step(machine, events); failure != nil {
+			return events, failure, nil
+		}
+	}
+	return events, nil, nil
+}
+
+// run sends the events to a new machine, checking the invariants after every
+// step. If strict is set, sequences containing events that are not enabled
+// when they are sent do not fail.
+func (r *runner) run(events Sequence, strict bool) (*Failure, error) {
+	machine, err := r.engine.NewMachine("sctest", r.chart, r.context)
+	if err != nil {
+		return nil, err
+	}
+	if failure := r.check(machine, nil); failure != nil {
+		return failure, nil
+	}
+	for i, event := range events {
+		if machine.State == sc.MachineStateStopped {
+			if strict {
+				return nil, nil
+			}
+			break
+		}
+		if strict && !contains(enabledEvents(r.chart, machine), event) {
+			return nil, nil
+		}
+		if failure := r.step(machine, events[:i+1:i+1]); failure != nil {
+			return failure, nil
+		}
+	}
+	return nil, nil
+}
+
+// step sends the last of the events to the machine.
+func (r *runner) step(machine *sc.Machine, events Sequence) *Failure {
+	if _, err := r.engine.Step(machine, events[len(events)-1]); err != nil {
+		return &Failure{Events: events, Machine: machine, Err: err}
+	}
+	return r.check(machine, events)
+}
+
+// shrink removes runs of consecutive events from a failing sequence, halving
+// the length of the runs, rounding up, whenever none can be removed, until no single event
+// can be removed without the sequence becoming invalid or passing. Runs at
+// every offset are tried, so that pairs such as PAUSE RESUME are removed
+// together.
+func (r *runner) shrink(failure *Failure) *Failure {
+	for n := (len(failure.Events) + 1) / 2; n >= 1; {
+		if n > len(failure.Events) {
+			n = len(failure.Events)
+		}
+		removed := false
+		for i := 0; i+n <= len(failure.Events); {
+			events := failure.Events
+			candidate := append(events[:i:i], events[i+n:]...)
+			if f, err := r.run(candidate, true); err == nil && f != nil {
+				failure = f
+				removed = true
+				continue
+			}
+			i++
+		}
+		switch {
+		case removed:
+		case n == 1:
+			return failure
+		default:
+			n = (n + 1) / 2
+		}
+	}
+	return failure
+}
+
+// enabledEvents returns the events of the transitions whose sources are all
+// active in the machine, in the order of the transitions.
+func enabledEvents(chart *semantics.Statechart, machine *sc.Machine) []string {
+	active := make(map[string]bool)
+	for _, ref := range machine.GetConfiguration().GetStates() {
+		active[ref.GetLabel()] = true
+	}
+	var events []string
+	for _, t := range chart.Transitions {
+		if t.GetEvent() == "" || contains(events, t.GetEvent()) {
+			continue
+		}
+		enabled := len(t.GetFrom()) > 0
+		for _, from := range t.GetFrom() {
+			enabled = enabled && active[from]
+		}
+		if enabled {
+			events = append(events, t.GetEvent())
+		}
+	}
+	return events
+}
+
+func contains(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func configurationLabels(machine *sc.Machine) []string {
+	var labels []string
+	for _, ref := range machine.GetConfiguration().GetStates() {
+		labels = append(labels, ref.GetLabel())
+	}
+	return labels
+}
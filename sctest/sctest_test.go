@@ -0,0 +1,166 @@
+package sctest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var errOverflow = errors.New("count exceeds limit")
+
+// counterChart counts INC events up to a limit with the guard of its inc
+// transition. The other events only add noise to failing sequences.
+func counterChart(guard string) *semantics.Statechart {
+	return semantics.NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Counting", IsInitial: true},
+				{Label: "Paused"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "inc", From: []string{"Counting"}, To: []string{"Counting"}, Event: "INC", Guard: &sc.Guard{Expression: guard}, Actions: []*sc.Action{{Label: "count = count + 1"}}},
+			{Label: "dec", From: []string{"Counting"}, To: []string{"Counting"}, Event: "DEC", Guard: &sc.Guard{Expression: "count > 0"}, Actions: []*sc.Action{{Label: "count = count - 1"}}},
+			{Label: "pause", From: []string{"Counting"}, To: []string{"Paused"}, Event: "PAUSE"},
+			{Label: "resume", From: []string{"Paused"}, To: []string{"Counting"}, Event: "RESUME"},
+		},
+	})
+}
+
+func counterConfig(t *testing.T, seed int64) *Config {
+	t.Helper()
+	context, err := structpb.NewStruct(map[string]interface{}{"count": 0, "limit": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Config{Context: context, Seed: seed}
+}
+
+func withinLimit(machine *sc.Machine) error {
+	fields := machine.GetContext().GetFields()
+	if fields["count"].GetNumberValue() > fields["limit"].GetNumberValue() {
+		return errOverflow
+	}
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	Test(t, counterChart("count < limit"), counterConfig(t, 1), withinLimit)
+
+	for seed := int64(1); seed <= 5; seed++ {
+		err := Check(counterChart("count <= limit"), counterConfig(t, seed), withinLimit)
+		var failure *Failure
+		if !errors.As(err, &failure) {
+			t.Fatalf("seed %d: Check() error = %v, want *Failure", seed, err)
+		}
+		if !errors.Is(err, errOverflow) {
+			t.Errorf("seed %d: Check() error = %v, want %v", seed, err, errOverflow)
+		}
+		if want := (Sequence{"INC", "INC", "INC"}); !cmp.Equal(failure.Events, want) {
+			t.Errorf("seed %d: counterexample = %v, want %v", seed, failure.Events, want)
+		}
+		if failure.Seed != seed {
+			t.Errorf("seed %d: failure seed = %d", seed, failure.Seed)
+		}
+		if got := failure.Machine.GetContext().GetFields()["count"].GetNumberValue(); got != 3 {
+			t.Errorf("seed %d: count = %v, want 3", seed, got)
+		}
+		if !strings.Contains(err.Error(), "events [INC INC INC] in configuration [__root__ Counting]") {
+			t.Errorf("seed %d: error = %q", seed, err)
+		}
+	}
+}
+
+func TestCheckInitialConfiguration(t *testing.T) {
+	context, err := structpb.NewStruct(map[string]interface{}{"count": 3, "limit": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(counterChart("count < limit"), &Config{Context: context}, withinLimit)
+	var failure *Failure
+	if !errors.As(err, &failure) {
+		t.Fatalf("Check() error = %v, want *Failure", err)
+	}
+	if len(failure.Events) != 0 {
+		t.Errorf("counterexample = %v, want no events", failure.Events)
+	}
+}
+
+func TestCheckEngineError(t *testing.T) {
+	// The guard refers to a missing variable once an event is sent.
+	err := Check(counterChart("count < missing"), counterConfig(t, 1))
+	var failure *Failure
+	if !errors.As(err, &failure) {
+		t.Fatalf("Check() error = %v, want *Failure", err)
+	}
+	if want := (Sequence{"INC"}); !cmp.Equal(failure.Events, want) {
+		t.Errorf("counterexample = %v, want %v", failure.Events, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	chart := counterChart("count <= limit")
+	config := counterConfig(t, 0)
+	if err := Run(chart, config, Sequence{"INC", "DEC", "INC", "PAUSE", "INC", "RESUME", "INC"}, withinLimit); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	err := Run(chart, config, Sequence{"INC", "INC", "INC", "DEC"}, withinLimit)
+	var failure *Failure
+	if !errors.As(err, &failure) {
+		t.Fatalf("Run() error = %v, want *Failure", err)
+	}
+	if want := (Sequence{"INC", "INC", "INC"}); !cmp.Equal(failure.Events, want) {
+		t.Errorf("failing events = %v, want %v", failure.Events, want)
+	}
+	if failure.Seed != 0 {
+		t.Errorf("failure seed = %d, want 0", failure.Seed)
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	chart := counterChart("count < limit")
+	config := counterConfig(t, 0)
+	config.MaxLength = 20
+	g := NewGenerator(chart, config)
+	engine := semantics.NewEngine()
+	enabled := func(events Sequence) bool {
+		if len(events) > config.MaxLength {
+			return false
+		}
+		machine, err := engine.NewMachine("test", chart, config.Context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			if !contains(enabledEvents(chart, machine), event) {
+				return false
+			}
+			if _, err := engine.Step(machine, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return true
+	}
+	if err := quick.Check(enabled, &quick.Config{Values: g.Values}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidConfiguration(t *testing.T) {
+	chart := counterChart("count < limit")
+	invariant := ValidConfiguration(chart)
+	machine := &sc.Machine{Configuration: &sc.Configuration{States: []*sc.StateRef{{Label: "__root__"}, {Label: "Counting"}}}}
+	if err := invariant(machine); err != nil {
+		t.Errorf("invariant() error = %v", err)
+	}
+	machine.Configuration.States = append(machine.Configuration.States, &sc.StateRef{Label: "Paused"})
+	if err := invariant(machine); err == nil {
+		t.Error("invariant() error = nil, want error for two active children")
+	}
+}
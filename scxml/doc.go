@@ -0,0 +1,20 @@
+// Package scxml imports statecharts from SCXML documents.
+//
+// Import translates the structure of a document, its states, parallel states,
+// final states and transitions, into a statecharts.v1.Statechart, and its
+// data model into the initial context of machines. Expressions are not
+// ECMAScript: conditions, data expressions and assignments are written in the
+// expression language of the semantics engine, which covers the literals,
+// comparisons and arithmetic of simple ECMAScript expressions.
+//
+// Executable content other than <assign> and <log>, internal and external
+// events (<raise>, <send>), history, <invoke> and event descriptors with
+// wildcards are not supported; Import reports them with ErrUnsupported.
+// Event descriptors match event names exactly rather than by prefix.
+// Transitions of type "internal" become local transitions.
+//
+// Tests of the W3C SCXML Implementation Report Plan in their .txml form use
+// attributes of the conformance namespace in place of data model specific
+// expressions. Import translates the subset of them listed in the
+// documentation of Import, so the tests can be run without an XSLT step.
+package scxml
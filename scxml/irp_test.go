@@ -0,0 +1,203 @@
+package scxml
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+var update = flag.Bool("update", false, "update the list of unsupported IRP tests")
+
+// irpTestPattern matches the main documents of IRP tests, not the documents
+// they load.
+var irpTestPattern = regexp.MustCompile(`^test(\d+)\.txml$`)
+
+// irpManifestPattern matches the main documents of IRP tests in the manifest.
+var irpManifestPattern = regexp.MustCompile(`uri="(?:[^"]*/)?test(\d+)\.txml"`)
+
+// TestIRP runs the tests of the W3C SCXML Implementation Report Plan vendored
+// in testdata/irp and reports the outcome per test number. A test passes if
+// the machine reaches the final state "pass" without external events.
+//
+// The tests are those listed in testdata/irp/manifest.xml, each of which must
+// be vendored, or the .txml files found if there is no manifest. Tests listed
+// in testdata/irp/unsupported.txt are skipped when they fail and reported when
+// they start to pass or are not tests. Any other failure fails TestIRP. Run go
+// test -update to rewrite the list from the failing tests.
+func TestIRP(t *testing.T) {
+	dir := filepath.Join("testdata", "irp")
+	tests, err := irpTests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) == 0 {
+		t.Skip("no IRP tests in testdata/irp; see testdata/irp/README.md")
+	}
+	known, err := readUnsupported(filepath.Join(dir, "unsupported.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*update {
+		for number := range known {
+			if _, ok := slices.BinarySearch(tests, number); !ok {
+				t.Errorf("test %d is listed in unsupported.txt but is not an IRP test", number)
+			}
+		}
+	}
+
+	failures := make(map[int]error)
+	for _, number := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("test%d.txml", number))
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("test %d is in the manifest but not vendored: %v", number, err)
+			continue
+		}
+		err := runIRPTest(path)
+		if err != nil {
+			failures[number] = err
+		}
+		t.Run(strconv.Itoa(number), func(t *testing.T) {
+			reason, unsupported := known[number]
+			switch {
+			case *update:
+			case err == nil && unsupported:
+				t.Errorf("passes, but is listed as unsupported (%s); remove it from unsupported.txt", reason)
+			case err == nil:
+			case unsupported:
+				t.Skipf("unsupported: %s", reason)
+			default:
+				t.Error(err)
+			}
+		})
+	}
+	t.Logf("%d tests: %d passed, %d failed", len(tests), len(tests)-len(failures), len(failures))
+
+	if *update {
+		if err := writeUnsupported(filepath.Join(dir, "unsupported.txt"), failures); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// irpTests returns the numbers of the tests listed in the manifest.xml of dir,
+// or of the tests in dir if it has no manifest, in increasing order.
+func irpTests(dir string) ([]int, error) {
+	var numbers []int
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.xml"))
+	switch {
+	case err == nil:
+		for _, m := range irpManifestPattern.FindAllSubmatch(manifest, -1) {
+			n, _ := strconv.Atoi(string(m[1]))
+			numbers = append(numbers, n)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if m := irpTestPattern.FindStringSubmatch(e.Name()); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				numbers = append(numbers, n)
+			}
+		}
+	default:
+		return nil, err
+	}
+	sort.Ints(numbers)
+	return slices.Compact(numbers), nil
+}
+
+// runIRPTest imports a test, starts a machine and checks that it reaches the
+// final state "pass".
+func runIRPTest(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	doc, err := Import(f)
+	if err != nil {
+		return err
+	}
+	m, err := semantics.NewEngine().NewMachine(filepath.Base(path), semantics.NewStatechart(doc.Chart), doc.Context)
+	if err != nil {
+		return err
+	}
+	var labels []string
+	for _, ref := range m.GetConfiguration().GetStates() {
+		switch ref.GetLabel() {
+		case "pass":
+			return nil
+		case "fail":
+			return errors.New("reached the final state fail")
+		}
+		labels = append(labels, ref.GetLabel())
+	}
+	if m.State == sc.MachineStateStopped {
+		return fmt.Errorf("stopped in %v", labels)
+	}
+	return fmt.Errorf("waits for an event in %v", labels)
+}
+
+// readUnsupported reads the list of unsupported tests: lines holding a test
+// number and the reason it is not supported. Lines starting with # are comments.
+func readUnsupported(path string) (map[int]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	known := make(map[int]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		number, reason, _ := strings.Cut(line, " ")
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid test number %q", path, number)
+		}
+		known[n] = strings.TrimSpace(reason)
+	}
+	return known, s.Err()
+}
+
+// writeUnsupported writes the list of unsupported tests, keeping its leading comments.
+func writeUnsupported(path string, failures map[int]error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		b.WriteString(line)
+	}
+	var numbers []int
+	for n := range failures {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		reason := strings.TrimPrefix(failures[n].Error(), "scxml: ")
+		fmt.Fprintf(&b, "%d %s\n", n, strings.ReplaceAll(reason, "\n", " "))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,427 @@
+package scxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// Namespace is the namespace of SCXML elements.
+	Namespace = "http://www.w3.org/2005/07/scxml"
+	// ConformanceNamespace is the namespace of the attributes and elements
+	// used by the tests of the W3C SCXML Implementation Report Plan.
+	ConformanceNamespace = "http://www.w3.org/2005/scxml-conformance"
+)
+
+// ErrUnsupported is returned for documents that use SCXML features the
+// engine cannot execute.
+var ErrUnsupported = errors.New("unsupported SCXML feature")
+
+// Document is an imported SCXML document.
+type Document struct {
+	// Name is the name attribute of the <scxml> element.
+	Name string
+	// Chart is the statechart of the document.
+	Chart *sc.Statechart
+	// Context holds the initial values of the data model.
+	Context *structpb.Struct
+}
+
+// node is an element of an SCXML document.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []*node    `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name && a.Name.Space == "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// children returns the child elements in the SCXML namespace. Elements of
+// other namespaces are ignored, as the SCXML specification requires.
+func (n *node) children() []*node {
+	var result []*node
+	for _, c := range n.Nodes {
+		if c.XMLName.Space == Namespace || c.XMLName.Space == "" {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func unsupported(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, fmt.Sprintf(format, args...))
+}
+
+// Import reads an SCXML document.
+//
+// The following attributes of the conformance namespace are translated, where
+// N and M are variable numbers that name the variables VarN and VarM:
+//
+//	conf:targetpass, conf:targetfail  target="pass", target="fail"
+//	conf:id="N", conf:location="N"     id="VarN", location="VarN"
+//	conf:expr="value"                  expr="value"
+//	conf:true, conf:false              cond="true", cond="false"
+//	conf:idVal="N=value"               cond="VarN == value", also for != < <= > >=
+//	conf:compareIDVal="N<M"            cond="VarN < VarM", also for the other operators
+//	conf:VarEqVar="N M"                cond="VarN == VarM"
+//	conf:datamodel                     ignored
+//
+// The <conf:pass/> and <conf:fail/> elements become final states with the ids
+// "pass" and "fail". Other conformance attributes and elements are reported
+// with ErrUnsupported.
+func Import(r io.Reader) (*Document, error) {
+	var root node
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("scxml: %w", err)
+	}
+	if root.XMLName.Local != "scxml" {
+		return nil, fmt.Errorf("scxml: root element is <%s>, want <scxml>", root.XMLName.Local)
+	}
+	if err := conform(&root); err != nil {
+		return nil, fmt.Errorf("scxml: %w", err)
+	}
+	im := &importer{
+		ids:     make(map[string]bool),
+		events:  make(map[string]bool),
+		context: &structpb.Struct{Fields: make(map[string]*structpb.Value)},
+	}
+	if root.attr("binding") == "late" {
+		return nil, fmt.Errorf("scxml: %w", unsupported("late data binding"))
+	}
+	state, err := im.compound(&root, semantics.RootState.String())
+	if err != nil {
+		return nil, fmt.Errorf("scxml: %w", err)
+	}
+	for _, t := range im.chart.Transitions {
+		for _, to := range t.To {
+			if !im.ids[to] {
+				return nil, fmt.Errorf("scxml: transition %s: unknown target %q", t.Label, to)
+			}
+		}
+	}
+	im.chart.RootState = state
+	return &Document{Name: root.attr("name"), Chart: &im.chart, Context: im.context}, nil
+}
+
+// importer builds a statechart from an SCXML document.
+type importer struct {
+	chart   sc.Statechart
+	ids     map[string]bool
+	events  map[string]bool
+	context *structpb.Struct
+	// generated counts the ids generated for states without one.
+	generated int
+}
+
+// compound translates the <scxml> element or a <state>, <parallel> or <final>
+// element with the given id.
+func (im *importer) compound(n *node, id string) (*sc.State, error) {
+	if im.ids[id] {
+		return nil, fmt.Errorf("duplicate state id %q", id)
+	}
+	im.ids[id] = true
+	state := &sc.State{Label: id, Type: sc.StateTypeBasic}
+	switch n.XMLName.Local {
+	case "parallel":
+		state.Type = sc.StateTypeParallel
+	case "final":
+		state.IsFinal = true
+	}
+
+	// Transitions are added before those of descendants, so that the
+	// transitions of a chart are in document order of their sources.
+	count := 0
+	for _, c := range n.children() {
+		if c.XMLName.Local != "transition" {
+			continue
+		}
+		if n.XMLName.Local == "scxml" || n.XMLName.Local == "final" {
+			return nil, fmt.Errorf("<%s> %s has a <transition>", n.XMLName.Local, id)
+		}
+		var err error
+		if count, err = im.transition(c, id, count); err != nil {
+			return nil, err
+		}
+	}
+
+	initial := strings.Fields(n.attr("initial"))
+	for _, c := range n.children() {
+		switch c.XMLName.Local {
+		case "state", "parallel", "final":
+			if n.XMLName.Local == "final" {
+				return nil, fmt.Errorf("final state %s has a child <%s>", id, c.XMLName.Local)
+			}
+			childID := c.attr("id")
+			if childID == "" {
+				im.generated++
+				childID = fmt.Sprintf("__state%d", im.generated)
+			}
+			child, err := im.compound(c, childID)
+			if err != nil {
+				return nil, err
+			}
+			state.Children = append(state.Children, child)
+		case "transition":
+			// Translated above.
+		case "initial":
+			target, err := initialTarget(c)
+			if err != nil {
+				return nil, err
+			}
+			initial = target
+		case "datamodel":
+			if err := im.datamodel(c); err != nil {
+				return nil, err
+			}
+		case "onentry", "onexit":
+			if err := logOnly(c); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, unsupported("<%s>", c.XMLName.Local)
+		}
+	}
+	if len(state.Children) > 0 && state.Type == sc.StateTypeBasic {
+		state.Type = sc.StateTypeNormal
+	}
+	if state.Type == sc.StateTypeNormal {
+		if err := markInitial(state, initial); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// markInitial marks the default child of an OR-state: the child named by the
+// initial attribute or <initial> element, or else the first child.
+func markInitial(state *sc.State, initial []string) error {
+	if len(initial) == 0 {
+		state.Children[0].IsInitial = true
+		return nil
+	}
+	if len(initial) > 1 {
+		return unsupported("initial configuration %v of %s", initial, state.Label)
+	}
+	for _, child := range state.Children {
+		if child.Label == initial[0] {
+			child.IsInitial = true
+			return nil
+		}
+	}
+	return unsupported("initial state %s that is not a child of %s", initial[0], state.Label)
+}
+
+// initialTarget returns the target of the transition of an <initial> element.
+func initialTarget(n *node) ([]string, error) {
+	children := n.children()
+	if len(children) != 1 || children[0].XMLName.Local != "transition" {
+		return nil, fmt.Errorf("<initial> must hold a single <transition>")
+	}
+	if err := logOnly(children[0]); err != nil {
+		return nil, err
+	}
+	return strings.Fields(children[0].attr("target")), nil
+}
+
+// logOnly checks that executable content has no effect other than logging.
+func logOnly(n *node) error {
+	for _, c := range n.children() {
+		if c.XMLName.Local != "log" {
+			return unsupported("<%s> in <%s>", c.XMLName.Local, n.XMLName.Local)
+		}
+	}
+	return nil
+}
+
+// transition translates a <transition> of the state with the given id, which
+// has count transitions so far, and returns the new count. A transition with
+// several event descriptors becomes a transition per descriptor.
+func (im *importer) transition(n *node, source string, count int) (int, error) {
+	var kind sc.TransitionKind
+	switch typ := n.attr("type"); typ {
+	case "", "external":
+	case "internal":
+		// An SCXML internal transition is a local transition in UML terms.
+		kind = sc.TransitionKindLocal
+	default:
+		return 0, unsupported("transition of type %s from %s", typ, source)
+	}
+	var actions []*sc.Action
+	for _, c := range n.children() {
+		switch c.XMLName.Local {
+		case "assign":
+			location, expr := c.attr("location"), c.attr("expr")
+			if location == "" || expr == "" {
+				return 0, unsupported("<assign> without location and expr")
+			}
+			actions = append(actions, &sc.Action{Label: location + " = " + expr})
+		case "log":
+		default:
+			return 0, unsupported("<%s>", c.XMLName.Local)
+		}
+	}
+	var guard *sc.Guard
+	if cond := n.attr("cond"); cond != "" {
+		if _, err := semantics.ParseExpression(cond); err != nil {
+			return 0, fmt.Errorf("transition from %s: %w", source, err)
+		}
+		guard = &sc.Guard{Expression: cond}
+	}
+	events := strings.Fields(n.attr("event"))
+	if len(events) == 0 {
+		events = []string{""}
+	}
+	for _, event := range events {
+		event = strings.TrimSuffix(event, ".*")
+		if strings.Contains(event, "*") {
+			return 0, unsupported("event descriptor %q", event)
+		}
+		if event != "" && !im.events[event] {
+			im.events[event] = true
+			im.chart.Events = append(im.chart.Events, &sc.Event{Label: event})
+		}
+		count++
+		im.chart.Transitions = append(im.chart.Transitions, &sc.Transition{
+			Label:   fmt.Sprintf("%s.%d", source, count),
+			From:    []string{source},
+			To:      strings.Fields(n.attr("target")),
+			Event:   event,
+			Guard:   guard,
+			Actions: actions,
+			Kind:    kind,
+		})
+	}
+	return count, nil
+}
+
+// datamodel adds the values of the <data> elements of a <datamodel> to the
+// context. Data are bound early: all of them are initialized when the machine
+// starts, whatever state declares them.
+func (im *importer) datamodel(n *node) error {
+	for _, c := range n.children() {
+		if c.XMLName.Local != "data" {
+			return fmt.Errorf("<datamodel> has a child <%s>", c.XMLName.Local)
+		}
+		id := c.attr("id")
+		if id == "" {
+			return fmt.Errorf("<data> without id")
+		}
+		if c.attr("src") != "" || len(c.Nodes) > 0 || strings.TrimSpace(c.Text) != "" {
+			return unsupported("<data> %s with src or content", id)
+		}
+		value := structpb.NewNullValue()
+		if src := c.attr("expr"); src != "" {
+			expr, err := semantics.ParseExpression(src)
+			if err != nil {
+				return fmt.Errorf("<data> %s: %w", id, err)
+			}
+			value, err = semantics.Eval(expr, semantics.Env{Context: im.context})
+			if err != nil {
+				return fmt.Errorf("<data> %s: %w", id, err)
+			}
+		}
+		im.context.Fields[id] = value
+	}
+	return nil
+}
+
+var (
+	idValPattern     = regexp.MustCompile(`^(\d+)\s*(==|=|!=|<=|>=|<|>)\s*(.+)$`)
+	compareIDPattern = regexp.MustCompile(`^(\d+)\s*(==|=|!=|<=|>=|<|>)\s*(\d+)$`)
+)
+
+// conform translates the conformance attributes and elements of the IRP tests.
+func conform(n *node) error {
+	if n.XMLName.Space == ConformanceNamespace {
+		switch n.XMLName.Local {
+		case "pass", "fail":
+			n.Attrs = []xml.Attr{{Name: xml.Name{Local: "id"}, Value: n.XMLName.Local}}
+			n.XMLName = xml.Name{Space: Namespace, Local: "final"}
+			n.Nodes = nil
+			return nil
+		}
+		return unsupported("<conf:%s>", n.XMLName.Local)
+	}
+	attrs := n.Attrs[:0]
+	for _, a := range n.Attrs {
+		if a.Name.Space != ConformanceNamespace {
+			attrs = append(attrs, a)
+			continue
+		}
+		name, value, err := conformanceAttr(a.Name.Local, a.Value)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+		}
+	}
+	n.Attrs = attrs
+	for _, c := range n.Nodes {
+		if err := conform(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conformanceAttr translates a conformance attribute into an SCXML attribute.
+// It returns an empty name for attributes that are dropped.
+func conformanceAttr(name, value string) (string, string, error) {
+	switch name {
+	case "targetpass":
+		return "target", "pass", nil
+	case "targetfail":
+		return "target", "fail", nil
+	case "id", "location":
+		return name, "Var" + value, nil
+	case "expr":
+		return "expr", value, nil
+	case "true", "false":
+		return "cond", name, nil
+	case "idVal":
+		m := idValPattern.FindStringSubmatch(value)
+		if m == nil {
+			break
+		}
+		return "cond", fmt.Sprintf("Var%s %s %s", m[1], operator(m[2]), m[3]), nil
+	case "compareIDVal":
+		m := compareIDPattern.FindStringSubmatch(value)
+		if m == nil {
+			break
+		}
+		return "cond", fmt.Sprintf("Var%s %s Var%s", m[1], operator(m[2]), m[3]), nil
+	case "VarEqVar":
+		vars := strings.Fields(value)
+		if len(vars) != 2 {
+			break
+		}
+		return "cond", fmt.Sprintf("Var%s == Var%s", vars[0], vars[1]), nil
+	case "datamodel":
+		return "", "", nil
+	}
+	return "", "", unsupported("conformance attribute conf:%s=%q", name, value)
+}
+
+func operator(op string) string {
+	if op == "=" {
+		return "=="
+	}
+	return op
+}
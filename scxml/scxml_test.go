@@ -0,0 +1,323 @@
+package scxml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const microwave = `<?xml version="1.0"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" name="microwave" initial="off">
+  <datamodel>
+    <data id="cook_time" expr="3"/>
+    <data id="timer" expr="0"/>
+  </datamodel>
+  <state id="off">
+    <transition event="turn.on" target="on"/>
+  </state>
+  <parallel id="on">
+    <transition event="turn.off" target="off"/>
+    <state id="engine">
+      <initial><transition target="idle"/></initial>
+      <state id="cooking">
+        <onentry><log expr="'cooking'"/></onentry>
+        <transition event="time" cond="timer &lt; cook_time">
+          <assign location="timer" expr="timer + 1"/>
+        </transition>
+        <transition event="time" cond="timer >= cook_time" target="idle"/>
+      </state>
+      <state id="idle">
+        <transition event="door.close start.*" target="cooking"/>
+      </state>
+    </state>
+    <state id="door">
+      <state id="closed"/>
+      <state id="open"/>
+    </state>
+  </parallel>
+</scxml>`
+
+func importString(t *testing.T, src string) *Document {
+	t.Helper()
+	doc, err := Import(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	return doc
+}
+
+func TestImport(t *testing.T) {
+	doc := importString(t, microwave)
+	if doc.Name != "microwave" {
+		t.Errorf("Name = %q, want microwave", doc.Name)
+	}
+	want := &sc.Statechart{
+		RootState: &sc.State{
+			Label: "__root__",
+			Type:  sc.StateTypeNormal,
+			Children: []*sc.State{
+				{Label: "off", Type: sc.StateTypeBasic, IsInitial: true},
+				{Label: "on", Type: sc.StateTypeParallel, Children: []*sc.State{
+					{Label: "engine", Type: sc.StateTypeNormal, Children: []*sc.State{
+						{Label: "cooking", Type: sc.StateTypeBasic},
+						{Label: "idle", Type: sc.StateTypeBasic, IsInitial: true},
+					}},
+					{Label: "door", Type: sc.StateTypeNormal, Children: []*sc.State{
+						{Label: "closed", Type: sc.StateTypeBasic, IsInitial: true},
+						{Label: "open", Type: sc.StateTypeBasic},
+					}},
+				}},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "off.1", From: []string{"off"}, To: []string{"on"}, Event: "turn.on"},
+			{Label: "on.1", From: []string{"on"}, To: []string{"off"}, Event: "turn.off"},
+			{Label: "cooking.1", From: []string{"cooking"}, Event: "time", Guard: &sc.Guard{Expression: "timer < cook_time"}, Actions: []*sc.Action{{Label: "timer = timer + 1"}}},
+			{Label: "cooking.2", From: []string{"cooking"}, To: []string{"idle"}, Event: "time", Guard: &sc.Guard{Expression: "timer >= cook_time"}},
+			{Label: "idle.1", From: []string{"idle"}, To: []string{"cooking"}, Event: "door.close"},
+			{Label: "idle.2", From: []string{"idle"}, To: []string{"cooking"}, Event: "start"},
+		},
+		Events: []*sc.Event{{Label: "turn.on"}, {Label: "turn.off"}, {Label: "time"}, {Label: "door.close"}, {Label: "start"}},
+	}
+	if diff := cmp.Diff(want, doc.Chart, protocmp.Transform()); diff != "" {
+		t.Errorf("Chart mismatch (-want +got):\n%s", diff)
+	}
+	wantContext, err := structpb.NewStruct(map[string]interface{}{"cook_time": 3, "timer": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantContext, doc.Context, protocmp.Transform()); diff != "" {
+		t.Errorf("Context mismatch (-want +got):\n%s", diff)
+	}
+
+	chart := semantics.NewStatechart(doc.Chart)
+	if err := chart.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	engine := semantics.NewEngine()
+	m, err := engine.NewMachine("microwave", chart, doc.Context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	for _, event := range []string{"turn.on", "start", "time", "time", "time", "time"} {
+		if _, err := engine.Step(m, event); err != nil {
+			t.Fatalf("Step(%s) error = %v", event, err)
+		}
+	}
+	var got []string
+	for _, ref := range m.Configuration.States {
+		got = append(got, ref.Label)
+	}
+	if want := []string{"__root__", "on", "engine", "idle", "door", "closed"}; !cmp.Equal(got, want) {
+		t.Errorf("configuration = %v, want %v", got, want)
+	}
+	if timer := m.Context.Fields["timer"].GetNumberValue(); timer != 3 {
+		t.Errorf("timer = %v, want 3", timer)
+	}
+}
+
+func TestImportConformance(t *testing.T) {
+	doc := importString(t, `<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:conf="http://www.w3.org/2005/scxml-conformance" conf:datamodel="" version="1.0" initial="s0">
+  <datamodel>
+    <data conf:id="1" conf:expr="1"/>
+    <data conf:id="2" conf:expr="1"/>
+  </datamodel>
+  <state id="s0">
+    <transition conf:idVal="1=1" target="s1"/>
+    <transition conf:targetfail=""/>
+  </state>
+  <state id="s1">
+    <transition conf:VarEqVar="1 2" target="s2">
+      <assign conf:location="1" conf:expr="2"/>
+    </transition>
+    <transition conf:true="" conf:targetfail=""/>
+  </state>
+  <state id="s2">
+    <transition conf:compareIDVal="1&gt;2" conf:targetpass=""/>
+    <transition conf:targetfail=""/>
+  </state>
+  <conf:pass/>
+  <conf:fail/>
+</scxml>`)
+	var guards []string
+	for _, t := range doc.Chart.Transitions {
+		guards = append(guards, t.GetGuard().GetExpression()+" -> "+strings.Join(t.To, " "))
+	}
+	want := []string{
+		"Var1 == 1 -> s1",
+		" -> fail",
+		"Var1 == Var2 -> s2",
+		"true -> fail",
+		"Var1 > Var2 -> pass",
+		" -> fail",
+	}
+	if diff := cmp.Diff(want, guards); diff != "" {
+		t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+	}
+	if got := doc.Chart.Transitions[2].Actions[0].Label; got != "Var1 = 2" {
+		t.Errorf("assignment = %q, want Var1 = 2", got)
+	}
+	for _, final := range doc.Chart.RootState.Children[3:] {
+		if !final.IsFinal {
+			t.Errorf("state %s is not final", final.Label)
+		}
+	}
+}
+
+func TestImportUnsupported(t *testing.T) {
+	tests := map[string]string{
+		"raise":        `<state id="a"><onentry><raise event="e"/></onentry></state>`,
+		"send":         `<state id="a"><transition event="e"><send event="f"/></transition></state>`,
+		"history":      `<state id="a"><history id="h"/><state id="b"/></state>`,
+		"invoke":       `<state id="a"><invoke type="scxml"/></state>`,
+		"unknown type": `<state id="a"><transition event="e" type="local"/></state>`,
+		"wildcard":     `<state id="a"><transition event="*" target="a"/></state>`,
+		"deep initial": `<state id="a" initial="c"><state id="b"><state id="c"/></state></state>`,
+		"conf":         `<state id="a"><transition conf:isBound="1" target="a"/></state>`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := `<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:conf="http://www.w3.org/2005/scxml-conformance">` + body + `</scxml>`
+			_, err := Import(strings.NewReader(src))
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Import() error = %v, want %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestImportInternalTransition(t *testing.T) {
+	doc := importString(t, `<scxml xmlns="http://www.w3.org/2005/07/scxml">
+  <state id="a">
+    <state id="b"/>
+    <state id="c"/>
+    <transition event="e" type="internal" target="c"/>
+  </state>
+</scxml>`)
+	if got := doc.Chart.Transitions[0].Kind; got != sc.TransitionKindLocal {
+		t.Errorf("Kind = %v, want %v", got, sc.TransitionKindLocal)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := map[string]string{
+		"root":           `<state id="a"/>`,
+		"unknown target": `<scxml><state id="a"><transition target="b"/></state></scxml>`,
+		"duplicate id":   `<scxml><state id="a"/><state id="a"/></scxml>`,
+		"syntax":         `<scxml><state id="a"></scxml>`,
+		"cond":           `<scxml><state id="a"><transition cond="a &lt;" target="a"/></state></scxml>`,
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Import(strings.NewReader(src))
+			if err == nil {
+				t.Fatal("Import() error = nil")
+			}
+			if errors.Is(err, ErrUnsupported) {
+				t.Errorf("Import() error = %v, want an error other than %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
+
+func TestRunIRPTest(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"pass", `<state id="s0"><transition conf:true="" conf:targetpass=""/></state>`, ""},
+		{"fail", `<state id="s0"><transition conf:false="" conf:targetpass=""/><transition conf:targetfail=""/></state>`, "reached the final state fail"},
+		{"waits", `<state id="s0"><transition event="e" conf:targetpass=""/></state>`, "waits for an event"},
+		{"unsupported", `<state id="s0"><onentry><raise event="e"/></onentry></state>`, ErrUnsupported.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".txml")
+			src := `<scxml xmlns="http://www.w3.org/2005/07/scxml" xmlns:conf="http://www.w3.org/2005/scxml-conformance" initial="s0">` +
+				tt.body + `<conf:pass/><conf:fail/></scxml>`
+			if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := runIRPTest(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("runIRPTest() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runIRPTest() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIRPTests(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"test144.txml", "test147.txml", "test216sub1.txml", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := irpTests(dir)
+	if err != nil {
+		t.Fatalf("irpTests() error = %v", err)
+	}
+	if want := []int{144, 147}; !slices.Equal(got, want) {
+		t.Errorf("irpTests() without a manifest = %v, want %v", got, want)
+	}
+
+	manifest := `<assert><test id="147"><start uri="txml/test147.txml"/></test></assert>
+<assert><test id="216"><start uri="txml/test216.txml"/><dep uri="txml/test216sub1.txml"/></test></assert>
+<assert><test id="144"><start uri="txml/test144.txml"/></test></assert>`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.xml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = irpTests(dir)
+	if err != nil {
+		t.Fatalf("irpTests() error = %v", err)
+	}
+	if want := []int{144, 147, 216}; !slices.Equal(got, want) {
+		t.Errorf("irpTests() with a manifest = %v, want %v", got, want)
+	}
+}
+
+func TestUnsupportedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unsupported.txt")
+	if err := os.WriteFile(path, []byte("# comment\n# more\n1 old reason\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failures := map[int]error{
+		144: errors.New("scxml: unsupported SCXML feature: <raise> in <onentry>"),
+		3:   errors.New("waits for an event in [__root__ s0]"),
+	}
+	if err := writeUnsupported(path, failures); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# comment\n# more\n3 waits for an event in [__root__ s0]\n144 unsupported SCXML feature: <raise> in <onentry>\n"
+	if diff := cmp.Diff(want, string(data)); diff != "" {
+		t.Errorf("unsupported.txt mismatch (-want +got):\n%s", diff)
+	}
+	known, err := readUnsupported(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[int]string{3: "waits for an event in [__root__ s0]", 144: "unsupported SCXML feature: <raise> in <onentry>"}, known); diff != "" {
+		t.Errorf("readUnsupported() mismatch (-want +got):\n%s", diff)
+	}
+}
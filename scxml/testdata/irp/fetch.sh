@@ -0,0 +1,10 @@
+#!/bin/sh
+# fetch.sh vendors the tests of the W3C SCXML Implementation Report Plan into
+# this directory. Run it from this directory; it needs network access once.
+set -e
+base=https://www.w3.org/Voice/2013/scxml-irp
+curl -fsSL -o manifest.xml "$base/manifest.xml"
+grep -o 'uri="[^"]*\.txml"' manifest.xml | sed 's/uri="\(.*\)"/\1/' | sort -u |
+while read -r uri; do
+	curl -fsSL -o "$(basename "$uri")" "$base/$uri"
+done
@@ -1,124 +1,470 @@
 package semantics
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/tmc/sc"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
 )
 
+// Validate checks that the statechart is well-formed, as execution and
+// analysis require: that it violates none of the structural built-in rules.
+// The error is a *ValidationError with every violation.
 func (s *Statechart) Validate() error {
-	if err := s.validateRootState(); err != nil {
-		return fmt.Errorf("invalid root state: %w", err)
+	violations, err := Check(context.Background(), &Chart{Statechart: s.Statechart}, structuralRules()...)
+	if err != nil {
+		return err
 	}
-	if err := s.validateParentChildRelationships(); err != nil {
-		return fmt.Errorf("invalid parent-child relationship: %w", err)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
 	}
-	if err := s.validateNonOverlappingStateLabels(); err != nil {
-		return fmt.Errorf("overlapping state labels: %w", err)
+	return nil
+}
+
+// Violations checks all built-in rules against the statechart and returns
+// every violation: the errors that Validate reports and the warnings of the
+// behavioral rules. The exploration of the configuration graph stops when
+// the context is done, failing with its error.
+func (s *Statechart) Violations(ctx context.Context) ([]*validationv1.Violation, error) {
+	return Check(ctx, &Chart{Statechart: s.Statechart}, BuiltinRules()...)
+}
+
+// ValidationError reports the violations of rules by a statechart.
+type ValidationError struct {
+	Violations []*validationv1.Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = fmt.Sprintf("%s: %s", v.RuleId, v.Message)
 	}
-	if err := s.validateStateTypeAgreesWithChildren(); err != nil {
-		return fmt.Errorf("state type mismatch: %w", err)
+	return strings.Join(messages, "; ")
+}
+
+// The structural rules report nothing if there is no root state, except for
+// validateRootState.
+
+// validateRootState ensures that the root state exists and has the correct label.
+func validateRootState(c *Chart) []*validationv1.Violation {
+	if c.RootState == nil {
+		return []*validationv1.Violation{violation("root state is nil")}
 	}
-	if err := s.validateParentStatesHaveSingleDefaults(); err != nil {
-		return fmt.Errorf("multiple default states: %w", err)
+	if c.RootState.Label != RootState.String() {
+		return []*validationv1.Violation{violation(fmt.Sprintf("root state has an unexpected label of '%s' (expected '%s')", c.RootState.Label, RootState.String()), c.StatePath(c.RootState))}
 	}
 	return nil
 }
 
-func (s *Statechart) validateNonOverlappingStateLabels() error {
-	if s.RootState == nil {
-		return nil // This will be caught by validateRootState
-	}
-	labels := make(map[string]bool)
-	var checkLabels func(*sc.State) error
-	checkLabels = func(state *sc.State) error {
-		if labels[state.Label] {
-			return fmt.Errorf("duplicate state label: %s", state.Label)
+// validateUniqueStateLabels checks that all state labels are unique.
+// It reports each duplicated label once, at all of its states.
+func validateUniqueStateLabels(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	seen := make(map[string]bool)
+	c.walk(func(state *sc.State, path string) {
+		if seen[state.Label] {
+			return
+		}
+		seen[state.Label] = true
+		if paths := c.labelPaths(state.Label); len(paths) > 1 {
+			violations = append(violations, violation(fmt.Sprintf("duplicate state label: %s", state.Label), paths...))
+		}
+	})
+	return violations
+}
+
+// validateSingleDefaultChild ensures that XOR composite states have exactly one default child.
+func validateSingleDefaultChild(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	c.walk(func(state *sc.State, path string) {
+		if state.Type != sc.StateTypeNormal {
+			return
 		}
-		labels[state.Label] = true
+		defaultCount := 0
 		for _, child := range state.Children {
-			if err := checkLabels(child); err != nil {
-				return err
+			if child.IsInitial {
+				defaultCount++
 			}
 		}
+		if defaultCount != 1 {
+			violations = append(violations, violation(fmt.Sprintf("state %s has %d default states, should have exactly 1", state.Label, defaultCount), path))
+		}
+	})
+	return violations
+}
+
+// validateBasicHasNoChildren ensures that basic states have no children.
+func validateBasicHasNoChildren(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	c.walk(func(state *sc.State, path string) {
+		if state.Type == sc.StateTypeBasic && len(state.Children) > 0 {
+			violations = append(violations, violation(fmt.Sprintf("basic state %s has children", state.Label), path))
+		}
+	})
+	return violations
+}
+
+// validateCompoundHasChildren ensures that compound states have children.
+func validateCompoundHasChildren(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	c.walk(func(state *sc.State, path string) {
+		if (state.Type == sc.StateTypeNormal || state.Type == sc.StateTypeParallel) && len(state.Children) == 0 {
+			violations = append(violations, violation(fmt.Sprintf("compound state %s has no children", state.Label), path))
+		}
+	})
+	return violations
+}
+
+// validateForksAndJoins checks that the sources of a join can be active
+// together and that the targets of a fork lie in distinct orthogonal regions.
+func validateForksAndJoins(c *Chart) []*validationv1.Violation {
+	if c.RootState == nil {
 		return nil
 	}
-	return checkLabels(s.RootState)
+	s := c.semantics()
+	var violations []*validationv1.Violation
+	for _, t := range c.Transitions {
+		path := c.TransitionPath(t)
+		if len(t.From) > 1 {
+			from := make([]StateLabel, len(t.From))
+			for i, label := range t.From {
+				from[i] = StateLabel(label)
+			}
+			consistent, err := s.Consistent(from...)
+			if err != nil {
+				violations = append(violations, violation(fmt.Sprintf("transition %s: %v", t.Label, err), path))
+				continue
+			}
+			if !consistent {
+				violations = append(violations, violation(fmt.Sprintf("transition %s: sources %v cannot be active together", t.Label, t.From), path))
+			}
+		}
+	targets:
+		for i, to1 := range t.To {
+			for _, to2 := range t.To[i+1:] {
+				orthogonal, err := s.Orthogonal(StateLabel(to1), StateLabel(to2))
+				if err != nil {
+					violations = append(violations, violation(fmt.Sprintf("transition %s: %v", t.Label, err), path))
+					break targets
+				}
+				if !orthogonal {
+					violations = append(violations, violation(fmt.Sprintf("transition %s: targets %s and %s are not orthogonal", t.Label, to1, to2), path))
+				}
+			}
+		}
+	}
+	return violations
 }
 
-func (s *Statechart) validateRootState() error {
-	if s.RootState == nil {
-		return fmt.Errorf("root state is nil")
+// validateTransitionEndpoints checks that the sources and targets of
+// transitions are states of the chart. States of a submachine, such as
+// Payment.retry of the submachine state Payment, exist once it is inlined.
+func validateTransitionEndpoints(c *Chart) []*validationv1.Violation {
+	if c.RootState == nil {
+		return nil
 	}
-	if s.RootState.Label != RootState.String() {
-		return fmt.Errorf("root state has an unexpected label of '%s' (expected '%s')", s.RootState.Label, RootState.String())
+	submachines := make(map[string]bool)
+	c.walk(func(state *sc.State, path string) {
+		if state.Submachine != "" {
+			submachines[state.Label] = true
+		}
+	})
+	known := func(label string) bool {
+		if len(c.labelPaths(label)) > 0 {
+			return true
+		}
+		prefix, _, ok := strings.Cut(label, SubmachineSeparator)
+		return ok && submachines[prefix]
 	}
-	return nil
+	var violations []*validationv1.Violation
+	for _, t := range c.Transitions {
+		path := c.TransitionPath(t)
+		for i, label := range t.From {
+			if !known(label) {
+				violations = append(violations, violation(fmt.Sprintf("transition %s refers to unknown source state %s", t.Label, label), fmt.Sprintf("%s/from[%d]", path, i)))
+			}
+		}
+		for i, label := range t.To {
+			if !known(label) {
+				violations = append(violations, violation(fmt.Sprintf("transition %s refers to unknown target state %s", t.Label, label), fmt.Sprintf("%s/to[%d]", path, i)))
+			}
+		}
+	}
+	return violations
 }
 
-func (s *Statechart) validateStateTypeAgreesWithChildren() error {
-	var checkType func(*sc.State) error
-	checkType = func(state *sc.State) error {
-		switch state.Type {
-		case sc.StateTypeBasic:
-			if len(state.Children) > 0 {
-				return fmt.Errorf("basic state %s has children", state.Label)
+// validateInternalTransitions checks that internal transitions target no
+// state other than their sources, since they exit and enter nothing.
+func validateInternalTransitions(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	for _, t := range c.Transitions {
+		if t.Kind != sc.TransitionKindInternal {
+			continue
+		}
+		for _, to := range t.To {
+			if !slices.Contains(t.From, to) {
+				violations = append(violations, violation(fmt.Sprintf("internal transition %s targets %s, which is not one of its sources", t.Label, to), c.TransitionPath(t)))
 			}
-		case sc.StateTypeNormal, sc.StateTypeParallel:
-			if len(state.Children) == 0 {
-				return fmt.Errorf("compound state %s has no children", state.Label)
+		}
+	}
+	return violations
+}
+
+// validatePseudostates checks that choices and junctions are leaves that are
+// neither default nor final states, that they are left by eventless branches
+// with at most one else branch, and that every choice has a branch that is
+// always enabled. Else guards are only allowed on branches.
+func validatePseudostates(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	pseudostates := make(map[string]*sc.State)
+	c.walk(func(state *sc.State, path string) {
+		if !IsPseudostate(state) {
+			return
+		}
+		if len(state.Children) > 0 {
+			violations = append(violations, violation(fmt.Sprintf("pseudostate %s has children", state.Label), path))
+		}
+		if state.IsInitial {
+			violations = append(violations, violation(fmt.Sprintf("pseudostate %s is a default state", state.Label), path))
+		}
+		if state.IsFinal {
+			violations = append(violations, violation(fmt.Sprintf("pseudostate %s is final", state.Label), path))
+		}
+		pseudostates[state.Label] = state
+	})
+	branches := make(map[string]int)
+	elses := make(map[string]int)
+	unguarded := make(map[string]bool)
+	for _, t := range c.Transitions {
+		var source *sc.State
+		for _, from := range t.From {
+			if p, ok := pseudostates[from]; ok {
+				source = p
 			}
 		}
-		for _, child := range state.Children {
-			if err := checkType(child); err != nil {
-				return err
+		if source == nil {
+			if IsElse(t.Guard) {
+				violations = append(violations, violation(fmt.Sprintf("transition %s has an else guard but does not leave a pseudostate", t.Label), c.TransitionPath(t)))
 			}
+			continue
+		}
+		if len(t.From) > 1 {
+			violations = append(violations, violation(fmt.Sprintf("transition %s joins pseudostate %s with other sources", t.Label, source.Label), c.TransitionPath(t)))
+		}
+		if t.Event != "" {
+			violations = append(violations, violation(fmt.Sprintf("branch %s of pseudostate %s has event %s", t.Label, source.Label, t.Event), c.TransitionPath(t)))
+		}
+		branches[source.Label]++
+		switch {
+		case IsElse(t.Guard):
+			elses[source.Label]++
+			unguarded[source.Label] = true
+		case strings.TrimSpace(t.GetGuard().GetExpression()) == "":
+			unguarded[source.Label] = true
 		}
-		return nil
 	}
-	return checkType(s.RootState)
+	c.walk(func(state *sc.State, path string) {
+		p, ok := pseudostates[state.Label]
+		if !ok || p != state {
+			return
+		}
+		switch {
+		case branches[p.Label] == 0:
+			violations = append(violations, violation(fmt.Sprintf("pseudostate %s has no branches", p.Label), path))
+		case elses[p.Label] > 1:
+			violations = append(violations, violation(fmt.Sprintf("pseudostate %s has %d else branches", p.Label, elses[p.Label]), path))
+		case stateType(p) == sc.StateTypeChoice && !unguarded[p.Label]:
+			violations = append(violations, violation(fmt.Sprintf("choice %s has no else branch or unguarded branch, so it may have no enabled branch", p.Label), path))
+		}
+	})
+	return violations
 }
 
-func (s *Statechart) validateParentChildRelationships() error {
-	var checkRelationships func(*sc.State) error
-	checkRelationships = func(state *sc.State) error {
-		for _, child := range state.Children {
-			parent, err := s.GetParent(StateLabel(child.Label))
-			if err != nil {
-				return fmt.Errorf("failed to get parent of %s: %w", child.Label, err)
-			}
-			if parent != state {
-				return fmt.Errorf("inconsistent parent-child relationship for %s", child.Label)
+// validateSubmachines rejects submachine states, which must be expanded with
+// Inline before the chart can be executed or analyzed.
+func validateSubmachines(c *Chart) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	c.walk(func(state *sc.State, path string) {
+		if state.Submachine != "" {
+			violations = append(violations, violation(fmt.Sprintf("state %s refers to submachine %q and must be inlined", state.Label, state.Submachine), path))
+		}
+	})
+	return violations
+}
+
+// validateConnectionPoints checks the entry and exit points of a chart used as
+// a submachine. They are basic children of the root state. An entry point is
+// entered by the containing chart only and left through eventless branches;
+// an exit point is left by transitions of the containing chart only.
+func validateConnectionPoints(c *Chart) []*validationv1.Violation {
+	if c.RootState == nil {
+		return nil
+	}
+	var violations []*validationv1.Violation
+	points := make(map[string]sc.StateType)
+	for _, state := range c.RootState.Children {
+		t := stateType(state)
+		if t != sc.StateTypeEntryPoint && t != sc.StateTypeExitPoint {
+			continue
+		}
+		path := c.StatePath(state)
+		if len(state.Children) > 0 {
+			violations = append(violations, violation(fmt.Sprintf("connection point %s has children", state.Label), path))
+		}
+		if state.IsInitial {
+			violations = append(violations, violation(fmt.Sprintf("connection point %s is a default state", state.Label), path))
+		}
+		if state.IsFinal {
+			violations = append(violations, violation(fmt.Sprintf("connection point %s is final", state.Label), path))
+		}
+		points[state.Label] = t
+	}
+	c.walk(func(state *sc.State, path string) {
+		if t := stateType(state); (t == sc.StateTypeEntryPoint || t == sc.StateTypeExitPoint) && points[state.Label] != t {
+			violations = append(violations, violation(fmt.Sprintf("connection point %s is not a child of the root state", state.Label), path))
+		}
+	})
+	branches := make(map[string]int)
+	for _, t := range c.Transitions {
+		path := c.TransitionPath(t)
+		for _, to := range t.To {
+			if points[to] == sc.StateTypeEntryPoint {
+				violations = append(violations, violation(fmt.Sprintf("transition %s targets entry point %s, which only the containing chart may enter", t.Label, to), path))
 			}
-			if err := checkRelationships(child); err != nil {
-				return err
+		}
+		for _, from := range t.From {
+			switch points[from] {
+			case sc.StateTypeEntryPoint:
+				if len(t.From) > 1 {
+					violations = append(violations, violation(fmt.Sprintf("transition %s joins entry point %s with other sources", t.Label, from), path))
+				}
+				if t.Event != "" {
+					violations = append(violations, violation(fmt.Sprintf("branch %s of entry point %s has event %s", t.Label, from, t.Event), path))
+				}
+				branches[from]++
+			case sc.StateTypeExitPoint:
+				violations = append(violations, violation(fmt.Sprintf("transition %s leaves exit point %s, which only the containing chart may leave", t.Label, from), path))
 			}
 		}
-		return nil
 	}
-	return checkRelationships(s.RootState)
+	for _, state := range c.RootState.Children {
+		if stateType(state) == sc.StateTypeEntryPoint && branches[state.Label] == 0 {
+			violations = append(violations, violation(fmt.Sprintf("entry point %s has no branches", state.Label), c.StatePath(state)))
+		}
+	}
+	return violations
 }
 
-func (s *Statechart) validateParentStatesHaveSingleDefaults() error {
-	var checkDefaults func(*sc.State) error
-	checkDefaults = func(state *sc.State) error {
-		if state.Type == sc.StateTypeNormal {
-			defaultCount := 0
-			for _, child := range state.Children {
-				if child.IsInitial {
-					defaultCount++
-				}
+// validateInvokes checks that every invocation names a statechart and has an
+// ID that is unique within the chart, so that its child machine and the events
+// it sends back can be told apart. A reused ID is reported at every use after
+// the first, together with the first.
+func validateInvokes(c *Chart) []*validationv1.Violation {
+	type use struct{ state, path string }
+	var violations []*validationv1.Violation
+	ids := make(map[string]use)
+	c.walk(func(state *sc.State, statePath string) {
+		for i, invoke := range state.Invokes {
+			path := fmt.Sprintf("%s/invokes[%d]", statePath, i)
+			if invoke.StatechartId == "" {
+				violations = append(violations, violation(fmt.Sprintf("invoke %s of state %s has no statechart ID", invoke.Id, state.Label), path))
+			}
+			if invoke.Id == "" {
+				violations = append(violations, violation(fmt.Sprintf("state %s invokes %q without an ID", state.Label, invoke.StatechartId), path))
+				continue
 			}
-			if defaultCount != 1 {
-				return fmt.Errorf("state %s has %d default states, should have exactly 1", state.Label, defaultCount)
+			if first, ok := ids[invoke.Id]; ok {
+				violations = append(violations, violation(fmt.Sprintf("invoke ID %s is used by states %s and %s", invoke.Id, first.state, state.Label), first.path, path))
+				continue
 			}
+			ids[invoke.Id] = use{state.Label, path}
 		}
-		for _, child := range state.Children {
-			if err := checkDefaults(child); err != nil {
-				return err
+	})
+	return violations
+}
+
+// validateDeterministicTransitionSelection ensures that transitions are deterministic.
+// This is a simplified implementation - a full implementation would need to analyze
+// guards and potential conflicts. It reports each conflicting source state and
+// event once, at all of its transitions. It is not a built-in rule: conflicts
+// are resolved by priority, and LIVE_TRANSITIONS reports the transitions that
+// never win.
+func validateDeterministicTransitionSelection(c *Chart) []*validationv1.Violation {
+	type trigger struct{ source, event string }
+	var triggers []trigger
+	paths := make(map[trigger][]string)
+
+	for _, transition := range c.Transitions {
+		if transition.Event == "" {
+			continue // Empty event transitions are not considered here
+		}
+		for _, source := range transition.From {
+			t := trigger{source, transition.Event}
+			if paths[t] == nil {
+				triggers = append(triggers, t)
 			}
+			paths[t] = append(paths[t], c.TransitionPath(transition))
+		}
+	}
+
+	var violations []*validationv1.Violation
+	for _, t := range triggers {
+		if len(paths[t]) > 1 {
+			violations = append(violations, violation(fmt.Sprintf("non-deterministic transitions: multiple transitions from state '%s' on event '%s'", t.source, t.event), paths[t]...))
 		}
+	}
+	return violations
+}
+
+// validateConfigurationGraph reports a configuration graph that cannot be
+// explored, for which the other behavioral rules report nothing: one too
+// large to be explored, or one whose exploration fails although the chart
+// violates no structural rule. Violations of the structural rules are
+// reported by those rules.
+func validateConfigurationGraph(c *Chart) []*validationv1.Violation {
+	_, err := c.Reachability()
+	var verr *ValidationError
+	if err == nil || errors.As(err, &verr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return nil
 	}
-	return checkDefaults(s.RootState)
+	return []*validationv1.Violation{violation(fmt.Sprintf("%v; reachability, liveness and deadlocks were not checked", err), c.StatePath(c.RootState))}
+}
+
+// validateReachableStates ensures that every state is reachable from the initial configuration.
+func validateReachableStates(c *Chart, reachability *Reachability) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	for _, label := range reachability.UnreachableStates {
+		violations = append(violations, violation(fmt.Sprintf("unreachable state: %s", label), c.labelPaths(label.String())...))
+	}
+	return violations
+}
+
+// validateLiveTransitions ensures that every transition can fire in some reachable configuration.
+func validateLiveTransitions(c *Chart, reachability *Reachability) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	for _, dead := range reachability.DeadTransitions {
+		violations = append(violations, violation(fmt.Sprintf("transition '%s' can never fire (%s)", dead.Transition.Label, dead.Reason), c.TransitionPath(dead.Transition)))
+	}
+	return violations
+}
+
+// validateNoDeadlocks ensures that every reachable configuration without
+// outgoing transitions is final. A deadlock is located at the states of its
+// configuration.
+func validateNoDeadlocks(c *Chart, reachability *Reachability) []*validationv1.Violation {
+	var violations []*validationv1.Violation
+	for _, config := range reachability.Deadlocks {
+		labels := make([]string, len(config))
+		var paths []string
+		for i, label := range config {
+			labels[i] = label.String()
+			paths = append(paths, c.labelPaths(label.String())...)
+		}
+		violations = append(violations, violation(fmt.Sprintf("non-final configuration {%s} has no outgoing transitions", strings.Join(labels, ", ")), paths...))
+	}
+	return violations
 }
@@ -1,11 +1,33 @@
 package semantics
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
 	"github.com/tmc/sc"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
 )
 
+// check returns an error joining the messages of the violations of rules by
+// a statechart, or nil.
+func check(s *Statechart, rules ...func(*Chart) []*validationv1.Violation) error {
+	var messages []string
+	for _, rule := range rules {
+		for _, v := range rule(&Chart{Statechart: s.Statechart}) {
+			messages = append(messages, v.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -29,7 +51,7 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "overlapping state labels: duplicate state label: A",
+			errMsg:  "UNIQUE_STATE_LABELS: duplicate state label: A",
 		},
 		{
 			name: "Invalid statechart - missing initial state",
@@ -43,7 +65,7 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "multiple default states: state __root__ has 0 default states, should have exactly 1",
+			errMsg:  "SINGLE_DEFAULT_CHILD: state __root__ has 0 default states, should have exactly 1",
 		},
 		{
 			name: "Invalid statechart - basic state with children",
@@ -61,7 +83,7 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "state type mismatch: basic state A has children",
+			errMsg:  "BASIC_HAS_NO_CHILDREN: basic state A has children",
 		},
 		{
 			name: "Invalid statechart - compound state without children",
@@ -76,10 +98,10 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "state type mismatch: compound state A has no children",
+			errMsg:  "SINGLE_DEFAULT_CHILD: state A has 0 default states, should have exactly 1; COMPOUND_HAS_CHILDREN: compound state A has no children",
 		},
 		{
-			name: "Invalid statechart - inconsistent parent-child relationship",
+			name: "Invalid statechart - state in two places",
 			statechart: NewStatechart(&sc.Statechart{
 				RootState: &sc.State{
 					Children: []*sc.State{
@@ -94,7 +116,7 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "invalid parent-child relationship: inconsistent parent-child relationship for B",
+			errMsg:  "UNIQUE_STATE_LABELS: duplicate state label: B",
 		},
 		{
 			name: "Invalid statechart - multiple default states",
@@ -108,7 +130,7 @@ func TestValidate(t *testing.T) {
 				},
 			}),
 			wantErr: true,
-			errMsg:  "multiple default states: state __root__ has 2 default states, should have exactly 1",
+			errMsg:  "SINGLE_DEFAULT_CHILD: state __root__ has 2 default states, should have exactly 1",
 		},
 	}
 
@@ -153,9 +175,9 @@ func TestValidateNonOverlappingStateLabels(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.statechart.validateNonOverlappingStateLabels()
+			err := check(tt.statechart, validateUniqueStateLabels)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("validateNonOverlappingStateLabels() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("validateUniqueStateLabels() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -176,7 +198,7 @@ func TestValidateRootState(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.statechart.validateRootState()
+			err := check(tt.statechart, validateRootState)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("validateRootState() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -221,44 +243,9 @@ func TestValidateStateTypeAgreesWithChildren(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.statechart.validateStateTypeAgreesWithChildren()
+			err := check(tt.statechart, validateBasicHasNoChildren, validateCompoundHasChildren)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("validateStateTypeAgreesWithChildren() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestValidateParentChildRelationships(t *testing.T) {
-	tests := []struct {
-		name       string
-		statechart *Statechart
-		wantErr    bool
-	}{
-		{
-			name:       "Valid parent-child relationships",
-			statechart: exampleStatechart1,
-			wantErr:    false,
-		},
-		{
-			name: "Inconsistent parent-child relationship",
-			statechart: NewStatechart(&sc.Statechart{
-				RootState: &sc.State{
-					Children: []*sc.State{
-						{Label: "A", Children: []*sc.State{{Label: "B"}}},
-						{Label: "B"},
-					},
-				},
-			}),
-			wantErr: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := tt.statechart.validateParentChildRelationships()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("validateParentChildRelationships() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("validateBasicHasNoChildren() and validateCompoundHasChildren() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -305,10 +292,329 @@ func TestValidateParentStatesHaveSingleDefaults(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.statechart.validateParentStatesHaveSingleDefaults()
+			err := check(tt.statechart, validateSingleDefaultChild)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("validateParentStatesHaveSingleDefaults() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("validateSingleDefaultChild() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateForksAndJoins(t *testing.T) {
+	root := func() *sc.State {
+		return &sc.State{
+			Children: []*sc.State{
+				{Label: "Off", IsInitial: true},
+				{
+					Label: "On",
+					Type:  sc.StateTypeParallel,
+					Children: []*sc.State{
+						{Label: "X", Children: []*sc.State{{Label: "X1", IsInitial: true}, {Label: "X2"}}},
+						{Label: "Y", Children: []*sc.State{{Label: "Y1", IsInitial: true}, {Label: "Y2"}}},
+					},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		transition *sc.Transition
+		wantErr    string
+	}{
+		{"fork", &sc.Transition{Label: "t", From: []string{"Off"}, To: []string{"X2", "Y2"}}, ""},
+		{"join", &sc.Transition{Label: "t", From: []string{"X2", "Y2"}, To: []string{"Off"}}, ""},
+		{"join with ancestor", &sc.Transition{Label: "t", From: []string{"On", "X2"}, To: []string{"Off"}}, ""},
+		{"fork into one region", &sc.Transition{Label: "t", From: []string{"Off"}, To: []string{"X1", "X2"}}, "targets X1 and X2 are not orthogonal"},
+		{"fork to ancestor", &sc.Transition{Label: "t", From: []string{"Off"}, To: []string{"On", "X2"}}, "targets On and X2 are not orthogonal"},
+		{"exclusive sources", &sc.Transition{Label: "t", From: []string{"Off", "X1"}, To: []string{"Off"}}, "sources [Off X1] cannot be active together"},
+		{"unknown target", &sc.Transition{Label: "t", From: []string{"Off"}, To: []string{"X1", "Z"}}, "failed to find state Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{RootState: root(), Transitions: []*sc.Transition{tt.transition}})
+			err := chart.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInternalTransitions(t *testing.T) {
+	root := &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "B"}}}
+	tests := []struct {
+		name    string
+		to      []string
+		wantErr bool
+	}{
+		{"targetless", nil, false},
+		{"self", []string{"A"}, false},
+		{"other state", []string{"B"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState:   root,
+				Transitions: []*sc.Transition{{Label: "t", From: []string{"A"}, To: tt.to, Kind: sc.TransitionKindInternal}},
+			})
+			err := check(chart, validateInternalTransitions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateInternalTransitions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTransitionEndpoints(t *testing.T) {
+	root := &sc.State{Children: []*sc.State{
+		{Label: "A", IsInitial: true},
+		{Label: "Payment", Submachine: "payment"},
+	}}
+	tests := []struct {
+		name     string
+		from, to []string
+		wantErr  string
+	}{
+		{"known states", []string{"A"}, []string{"Payment"}, ""},
+		{"submachine state", []string{"Payment.declined"}, []string{"Payment.retry"}, ""},
+		{"unknown source", []string{"Nope"}, []string{"A"}, "transition t refers to unknown source state Nope"},
+		{"unknown target", []string{"A"}, []string{"A.retry"}, "transition t refers to unknown target state A.retry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState:   root,
+				Transitions: []*sc.Transition{{Label: "t", From: tt.from, To: tt.to}},
+			})
+			err := check(chart, validateTransitionEndpoints)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("validateTransitionEndpoints() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePseudostates(t *testing.T) {
+	guard := func(expression string) *sc.Guard { return &sc.Guard{Expression: expression} }
+	enter := &sc.Transition{Label: "enter", From: []string{"A"}, To: []string{"P"}, Event: "E"}
+	tests := []struct {
+		name        string
+		p           *sc.State
+		transitions []*sc.Transition
+		wantErr     string
+	}{
+		{"choice with else", &sc.State{Label: "P", Type: sc.StateTypeChoice}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}, Guard: guard("x > 1")},
+			{Label: "c", From: []string{"P"}, To: []string{"A"}, Guard: guard("else")},
+		}, ""},
+		{"choice with unguarded branch", &sc.State{Label: "P", Type: sc.StateTypeChoice}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}},
+		}, ""},
+		{"guarded junction", &sc.State{Label: "P", Type: sc.StateTypeJunction}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}, Guard: guard("x > 1")},
+		}, ""},
+		{"choice without else", &sc.State{Label: "P", Type: sc.StateTypeChoice}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}, Guard: guard("x > 1")},
+		}, "choice P has no else branch"},
+		{"no branches", &sc.State{Label: "P", Type: sc.StateTypeJunction}, nil, "pseudostate P has no branches"},
+		{"branch with event", &sc.State{Label: "P", Type: sc.StateTypeJunction}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}, Event: "E"},
+		}, "branch b of pseudostate P has event E"},
+		{"join", &sc.State{Label: "P", Type: sc.StateTypeJunction}, []*sc.Transition{
+			{Label: "b", From: []string{"P", "A"}, To: []string{"B"}},
+		}, "transition b joins pseudostate P"},
+		{"two else branches", &sc.State{Label: "P", Type: sc.StateTypeChoice}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}, Guard: guard("else")},
+			{Label: "c", From: []string{"P"}, To: []string{"A"}, Guard: guard("else")},
+		}, "pseudostate P has 2 else branches"},
+		{"else outside pseudostate", &sc.State{Label: "P", Type: sc.StateTypeJunction}, []*sc.Transition{
+			{Label: "b", From: []string{"P"}, To: []string{"B"}},
+			{Label: "c", From: []string{"B"}, To: []string{"A"}, Guard: guard("else")},
+		}, "transition c has an else guard"},
+		{"default", &sc.State{Label: "P", Type: sc.StateTypeChoice, IsInitial: true}, nil, "pseudostate P is a default state"},
+		{"final", &sc.State{Label: "P", Type: sc.StateTypeJunction, IsFinal: true}, nil, "pseudostate P is final"},
+		{"children", &sc.State{Label: "P", Type: sc.StateTypeJunction, Children: []*sc.State{{Label: "C", IsInitial: true}}}, nil, "pseudostate P has children"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState:   &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, tt.p, {Label: "B"}}},
+				Transitions: append([]*sc.Transition{enter}, tt.transitions...),
+			})
+			err := check(chart, validatePseudostates)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePseudostates() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePseudostates() error = %v, want an error containing %q", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestValidateSubmachines(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "S", Submachine: "payment"}}},
+	})
+	if err := chart.Validate(); err == nil || !strings.Contains(err.Error(), `state S refers to submachine "payment" and must be inlined`) {
+		t.Errorf("Validate() error = %v, want an error about the submachine state", err)
+	}
+}
+
+func TestValidateConnectionPoints(t *testing.T) {
+	entry := func(s *sc.State) *sc.State {
+		s.Type = sc.StateTypeEntryPoint
+		return s
+	}
+	branch := &sc.Transition{Label: "b", From: []string{"in"}, To: []string{"A"}}
+	tests := []struct {
+		name        string
+		states      []*sc.State
+		transitions []*sc.Transition
+		wantErr     string
+	}{
+		{"valid", []*sc.State{entry(&sc.State{Label: "in"}), {Label: "out", Type: sc.StateTypeExitPoint}}, []*sc.Transition{
+			branch,
+			{Label: "t", From: []string{"A"}, To: []string{"out"}, Event: "E"},
+		}, ""},
+		{"nested", []*sc.State{{Label: "C", Children: []*sc.State{{Label: "out", Type: sc.StateTypeExitPoint, IsInitial: true}}}}, nil, "connection point out is not a child of the root state"},
+		{"default", []*sc.State{entry(&sc.State{Label: "in", IsInitial: true})}, nil, "connection point in is a default state"},
+		{"final", []*sc.State{entry(&sc.State{Label: "in", IsFinal: true})}, nil, "connection point in is final"},
+		{"entry point without branches", []*sc.State{entry(&sc.State{Label: "in"})}, nil, "entry point in has no branches"},
+		{"entered from inside", []*sc.State{entry(&sc.State{Label: "in"})}, []*sc.Transition{
+			branch,
+			{Label: "t", From: []string{"A"}, To: []string{"in"}, Event: "E"},
+		}, "transition t targets entry point in"},
+		{"branch with event", []*sc.State{entry(&sc.State{Label: "in"})}, []*sc.Transition{
+			{Label: "b", From: []string{"in"}, To: []string{"A"}, Event: "E"},
+		}, "branch b of entry point in has event E"},
+		{"left from inside", []*sc.State{{Label: "out", Type: sc.StateTypeExitPoint}}, []*sc.Transition{
+			{Label: "t", From: []string{"out"}, To: []string{"A"}},
+		}, "transition t leaves exit point out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState:   &sc.State{Children: append([]*sc.State{{Label: "A", IsInitial: true}}, tt.states...)},
+				Transitions: tt.transitions,
+			})
+			err := check(chart, validateConnectionPoints)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateConnectionPoints() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateConnectionPoints() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInvokes(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []*sc.Invoke
+		wantErr string
+	}{
+		{"valid", []*sc.Invoke{{Id: "x", StatechartId: "worker"}}, []*sc.Invoke{{Id: "y", StatechartId: "worker"}}, ""},
+		{"no ID", []*sc.Invoke{{StatechartId: "worker"}}, nil, `state A invokes "worker" without an ID`},
+		{"no statechart", []*sc.Invoke{{Id: "x"}}, nil, "invoke x of state A has no statechart ID"},
+		{"duplicate ID", []*sc.Invoke{{Id: "x", StatechartId: "worker"}}, []*sc.Invoke{{Id: "x", StatechartId: "worker"}}, "invoke ID x is used by states A and B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState: &sc.State{Children: []*sc.State{
+					{Label: "A", IsInitial: true, Invokes: tt.a},
+					{Label: "B", Invokes: tt.b},
+				}},
+			})
+			err := check(chart, validateInvokes)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateInvokes() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateInvokes() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidateReportsAll checks that Validate reports every violation of the
+// structural rules, with their rules, severities and xpaths.
+func TestValidateReportsAll(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "A", IsInitial: true},
+			{Label: "A", Submachine: "payment"},
+			{Label: "P", Type: sc.StateTypeChoice},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "t", From: []string{"A"}, To: []string{"P"}, Kind: sc.TransitionKindInternal},
+		},
+	})
+	err := chart.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+	violationOf := func(id validationv1.RuleId, message string, xpath ...string) *validationv1.Violation {
+		return &validationv1.Violation{Rule: id, RuleId: id.String(), Severity: validationv1.Severity_ERROR, Message: message, Xpath: xpath}
+	}
+	want := []*validationv1.Violation{
+		violationOf(validationv1.RuleId_UNIQUE_STATE_LABELS, "duplicate state label: A", "/root_state/children[0]", "/root_state/children[1]"),
+		violationOf(validationv1.RuleId_INTERNAL_TRANSITIONS, "internal transition t targets P, which is not one of its sources", "/transitions[0]"),
+		violationOf(validationv1.RuleId_PSEUDOSTATES, "pseudostate P has no branches", "/root_state/children[2]"),
+		violationOf(validationv1.RuleId_INLINED_SUBMACHINES, `state A refers to submachine "payment" and must be inlined`, "/root_state/children[1]"),
+	}
+	if diff := cmp.Diff(want, verr.Violations, protocmp.Transform()); diff != "" {
+		t.Errorf("violations mismatch (-want +got):\n%s", diff)
+	}
+	if got := err.Error(); !strings.HasPrefix(got, "UNIQUE_STATE_LABELS: duplicate state label: A; INTERNAL_TRANSITIONS: ") {
+		t.Errorf("Validate() error = %q, want the violations with their rules", got)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	live := NewStatechart(&sc.Statechart{
+		RootState:   &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "B", IsFinal: true}}},
+		Transitions: []*sc.Transition{{Label: "t", From: []string{"A"}, To: []string{"B"}, Event: "E"}},
+	})
+	if got, err := live.Violations(context.Background()); err != nil || len(got) != 0 {
+		t.Errorf("Violations() of a live statechart = %v, %v", got, err)
+	}
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true, IsFinal: true}, {Label: "B", IsFinal: true}}},
+	})
+	if err := chart.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := []*validationv1.Violation{{
+		Rule:     validationv1.RuleId_REACHABLE_STATES,
+		RuleId:   "REACHABLE_STATES",
+		Severity: validationv1.Severity_WARNING,
+		Message:  "unreachable state: B",
+		Xpath:    []string{"/root_state/children[1]"},
+	}}
+	got, err := chart.Violations(context.Background())
+	if err != nil {
+		t.Fatalf("Violations() error = %v", err)
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Violations() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/tmc/sc"
-	pb "github.com/tmc/sc/gen/statecharts/v1"
 	validationv1 "github.com/tmc/sc/gen/validation/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -18,8 +17,10 @@ type ValidatorClient struct {
 }
 
 // NewValidatorClient creates a new client connection to the SemanticValidator service.
-func NewValidatorClient(target string) (*ValidatorClient, error) {
-	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// The connection is insecure unless the options set transport credentials.
+func NewValidatorClient(target string, opts ...grpc.DialOption) (*ValidatorClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(target, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to validator: %w", err)
 	}
@@ -39,170 +40,47 @@ func (c *ValidatorClient) Close() error {
 }
 
 // ValidateStatechart validates a statechart using the SemanticValidator service.
+// The service checks the same rules as Validate, and the error is likewise a
+// *ValidationError, listing the violations of error severity.
 func (c *ValidatorClient) ValidateStatechart(ctx context.Context, statechart *Statechart) error {
-	// Convert to proto statechart
-	protoStatechart := &pb.Statechart{
-		RootState:   convertStateToProto(statechart.RootState),
-		Transitions: make([]*pb.Transition, 0, len(statechart.Transitions)),
-		Events:      make([]*pb.Event, 0, len(statechart.Events)),
-	}
-
-	for _, t := range statechart.Transitions {
-		protoStatechart.Transitions = append(protoStatechart.Transitions, convertTransitionToProto(t))
-	}
-
-	for _, e := range statechart.Events {
-		protoStatechart.Events = append(protoStatechart.Events, convertEventToProto(e))
-	}
-
-	// Call the validator
 	resp, err := c.client.ValidateChart(ctx, &validationv1.ValidateChartRequest{
-		Chart: protoStatechart,
+		Chart: statechart.Statechart,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to validate chart: %w", err)
 	}
-
-	// Check for errors
-	if len(resp.Violations) > 0 {
-		errorMsg := "validation failed:"
-		for _, v := range resp.Violations {
-			if v.Severity == validationv1.Severity_ERROR {
-				errorMsg += fmt.Sprintf("\n  - %s: %s", v.Rule, v.Message)
-			}
-		}
-		return fmt.Errorf(errorMsg)
-	}
-
-	return nil
+	return violationsError(resp.Violations)
 }
 
 // ValidateTrace validates a statechart trace using the SemanticValidator service.
 func (c *ValidatorClient) ValidateTrace(ctx context.Context, statechart *Statechart, machines []*sc.Machine) error {
-	// Convert to proto statechart
-	protoStatechart := &pb.Statechart{
-		RootState:   convertStateToProto(statechart.RootState),
-		Transitions: make([]*pb.Transition, 0, len(statechart.Transitions)),
-		Events:      make([]*pb.Event, 0, len(statechart.Events)),
-	}
-
-	for _, t := range statechart.Transitions {
-		protoStatechart.Transitions = append(protoStatechart.Transitions, convertTransitionToProto(t))
-	}
-
-	for _, e := range statechart.Events {
-		protoStatechart.Events = append(protoStatechart.Events, convertEventToProto(e))
-	}
-
-	// Convert machines to proto machines
-	protoMachines := make([]*pb.Machine, 0, len(machines))
-	for _, m := range machines {
-		protoMachines = append(protoMachines, convertMachineToProto(m))
-	}
-
-	// Call the validator
 	resp, err := c.client.ValidateTrace(ctx, &validationv1.ValidateTraceRequest{
-		Chart: protoStatechart,
-		Trace: protoMachines,
+		Chart: statechart.Statechart,
+		Trace: machines,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to validate trace: %w", err)
 	}
-
-	// Check for errors
-	if len(resp.Violations) > 0 {
-		errorMsg := "validation failed:"
-		for _, v := range resp.Violations {
-			if v.Severity == validationv1.Severity_ERROR {
-				errorMsg += fmt.Sprintf("\n  - %s: %s", v.Rule, v.Message)
-			}
-		}
-		return fmt.Errorf(errorMsg)
-	}
-
-	return nil
-}
-
-// Helper functions to convert between proto and regular types
-
-func convertStateToProto(state *sc.State) *pb.State {
-	if state == nil {
-		return nil
-	}
-
-	result := &pb.State{
-		Label:     state.Label,
-		Type:      pb.StateType(state.Type),
-		IsInitial: state.IsInitial,
-		IsFinal:   state.IsFinal,
-		Children:  make([]*pb.State, 0, len(state.Children)),
-	}
-
-	for _, child := range state.Children {
-		result.Children = append(result.Children, convertStateToProto(child))
-	}
-
-	return result
+	return violationsError(resp.Violations)
 }
 
-func convertTransitionToProto(transition *sc.Transition) *pb.Transition {
-	if transition == nil {
-		return nil
-	}
-
-	result := &pb.Transition{
-		Label: transition.Label,
-		From:  transition.From,
-		To:    transition.To,
-		Event: transition.Event,
-	}
-
-	if transition.Guard != nil {
-		result.Guard = &pb.Guard{
-			Expression: transition.Guard.Expression,
+// violationsError returns a *ValidationError with the violations of error
+// severity, or nil if there are none. Warnings do not fail validation.
+func violationsError(violations []*validationv1.Violation) error {
+	var errs []*validationv1.Violation
+	for _, v := range violations {
+		if v.Severity == validationv1.Severity_ERROR {
+			errs = append(errs, v)
 		}
 	}
-
-	if len(transition.Actions) > 0 {
-		result.Actions = make([]*pb.Action, 0, len(transition.Actions))
-		for _, action := range transition.Actions {
-			result.Actions = append(result.Actions, &pb.Action{
-				Label: action.Label,
-			})
-		}
-	}
-
-	return result
-}
-
-func convertEventToProto(event *sc.Event) *pb.Event {
-	if event == nil {
+	if len(errs) == 0 {
 		return nil
 	}
-
-	return &pb.Event{
-		Label: event.Label,
-	}
-}
-
-func convertMachineToProto(machine *sc.Machine) *pb.Machine {
-	if machine == nil {
-		return nil
-	}
-
-	// This is a simplified conversion - a full implementation would convert
-	// all fields including step history, etc.
-	result := &pb.Machine{
-		Id:    machine.Id,
-		State: pb.MachineState(machine.State),
-	}
-
-	return result
+	return &ValidationError{Violations: errs}
 }
 
-// Validate calls the validator service to validate this statechart.
-// This method can replace the current Validate method in the Statechart struct
-// once the validator service is deployed.
+// ValidateWithService validates the statechart with the SemanticValidator
+// service, which checks the same rules as Validate and its behavioral rules.
 func (s *Statechart) ValidateWithService(ctx context.Context, validatorClient *ValidatorClient) error {
 	return validatorClient.ValidateStatechart(ctx, s)
 }
\ No newline at end of file
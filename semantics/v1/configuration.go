@@ -33,7 +33,7 @@ func ValidateConfiguration(statechart *Statechart, config *sc.Configuration) err
 			return nil // State not in configuration, which is valid
 		}
 
-		switch state.Type {
+		switch stateType(state) {
 		case sc.StateTypeParallel:
 			for _, child := range state.Children {
 				if child == nil {
@@ -161,6 +161,27 @@ func TestValidateConfiguration(t *testing.T) {
 	}
 }
 
+func TestValidateConfigurationUnspecifiedTypes(t *testing.T) {
+	// States without a type are OR-states if they have children, as in the engine.
+	statechart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Label: "__root__",
+			Children: []*sc.State{
+				{Label: "A", IsInitial: true},
+				{Label: "B"},
+			},
+		},
+	})
+	valid := &sc.Configuration{States: []*sc.StateRef{{Label: "__root__"}, {Label: "A"}}}
+	if err := ValidateConfiguration(statechart, valid); err != nil {
+		t.Errorf("ValidateConfiguration(%v) error = %v", valid, err)
+	}
+	invalid := &sc.Configuration{States: []*sc.StateRef{{Label: "__root__"}, {Label: "A"}, {Label: "B"}}}
+	if err := ValidateConfiguration(statechart, invalid); err == nil {
+		t.Errorf("ValidateConfiguration(%v) error = nil, want error", invalid)
+	}
+}
+
 func TestDefaultCompletionToplevel(t *testing.T) {
 	statechart := NewStatechart(&sc.Statechart{
 		RootState: &sc.State{
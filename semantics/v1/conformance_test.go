@@ -0,0 +1,146 @@
+package semantics
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestConformance runs the golden traces in testdata/conformance. Each case is
+// a directory holding:
+//
+//	chart.textproto  the statechart, in protobuf text format
+//	context.json     the initial context (optional)
+//	events.txt       the events to send, one per line
+//	want.txt         the expected trace
+//
+// The trace has a line for the initial configuration and a line per event with
+// the transitions fired and the resulting configuration, without the root
+// state. A final "stopped" line records that the machine stopped. Run go test
+// -update to rewrite want.txt from the engine.
+func TestConformance(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "conformance", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no conformance cases found")
+	}
+	for _, dir := range dirs {
+		name, _ := filepath.Rel(filepath.Join("testdata", "conformance"), dir)
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			got, err := conformanceTrace(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "want.txt")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading expected trace: %v (run go test -update to create it)", err)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("trace mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// conformanceTrace runs the events of a conformance case and returns its trace.
+func conformanceTrace(dir string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "chart.textproto"))
+	if err != nil {
+		return nil, err
+	}
+	chart := &sc.Statechart{}
+	if err := prototext.Unmarshal(data, chart); err != nil {
+		return nil, fmt.Errorf("chart.textproto: %w", err)
+	}
+	var context *structpb.Struct
+	if data, err := os.ReadFile(filepath.Join(dir, "context.json")); err == nil {
+		context = &structpb.Struct{}
+		if err := protojson.Unmarshal(data, context); err != nil {
+			return nil, fmt.Errorf("context.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	events, err := readEvents(filepath.Join(dir, "events.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	engine := NewEngine()
+	m, err := engine.NewMachine(filepath.Base(dir), NewStatechart(chart), context)
+	if err != nil {
+		return nil, fmt.Errorf("NewMachine() error = %w", err)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "initial: %s\n", traceConfiguration(m.Configuration))
+	for _, event := range events {
+		step, err := engine.Step(m, event)
+		if err != nil {
+			return nil, fmt.Errorf("Step(%s) error = %w", event, err)
+		}
+		fmt.Fprintf(&b, "%s:", event)
+		for i, t := range step.Transitions {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, " %s", t.Label)
+		}
+		fmt.Fprintf(&b, " -> %s\n", traceConfiguration(step.ResultingConfiguration))
+	}
+	if m.State == sc.MachineStateStopped {
+		b.WriteString("stopped\n")
+	}
+	return b.Bytes(), nil
+}
+
+// readEvents reads an event script, skipping blank lines and comments.
+func readEvents(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		events = append(events, line)
+	}
+	return events, s.Err()
+}
+
+// traceConfiguration formats a configuration without the root state.
+func traceConfiguration(config *sc.Configuration) string {
+	var labels []string
+	for _, ref := range config.GetStates() {
+		if ref.GetLabel() != RootState.String() {
+			labels = append(labels, ref.GetLabel())
+		}
+	}
+	return strings.Join(labels, " ")
+}
@@ -0,0 +1,381 @@
+package semantics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrCoverageMismatch is returned when merging the coverage of different statecharts.
+var ErrCoverageMismatch = errors.New("coverage of different statecharts")
+
+// Coverage collects the states entered, the transitions fired and the outcomes
+// of guards of the machines an Engine runs. Attach it to an engine by setting
+// Engine.Coverage; one collector may be shared by many engines and machines.
+//
+// Only the machines of the statechart the collector was created for are
+// recorded, and only steps that succeed: a step that fails leaves no trace, as
+// it leaves the machine unchanged.
+type Coverage struct {
+	chart *Statechart
+
+	mu         sync.Mutex
+	machines   int
+	entered    map[StateLabel]int
+	fired      []int
+	guardTrue  []int
+	guardFalse []int
+	copy       *sc.Statechart // The last copy of the statechart seen.
+}
+
+// NewCoverage creates a collector for machines of the statechart.
+func NewCoverage(chart *Statechart) *Coverage {
+	return &Coverage{
+		chart:      chart,
+		entered:    make(map[StateLabel]int),
+		fired:      make([]int, len(chart.Transitions)),
+		guardTrue:  make([]int, len(chart.Transitions)),
+		guardFalse: make([]int, len(chart.Transitions)),
+	}
+}
+
+// stepRecord collects what a step does until it succeeds. A nil record collects nothing.
+type stepRecord struct {
+	entered []StateLabel
+	fired   []*sc.Transition
+	guards  []guardOutcome
+}
+
+type guardOutcome struct {
+	transition *sc.Transition
+	holds      bool
+}
+
+func (r *stepRecord) enter(labels []StateLabel) {
+	if r != nil {
+		r.entered = append(r.entered, labels...)
+	}
+}
+
+func (r *stepRecord) fire(transitions []*sc.Transition) {
+	if r != nil {
+		r.fired = append(r.fired, transitions...)
+	}
+}
+
+func (r *stepRecord) guard(t *sc.Transition, holds bool) {
+	if r != nil && strings.TrimSpace(t.GetGuard().GetExpression()) != "" {
+		r.guards = append(r.guards, guardOutcome{transition: t, holds: holds})
+	}
+}
+
+// record adds a successful step, or the start of a machine, of a machine of chart.
+func (c *Coverage) record(chart *sc.Statechart, r *stepRecord, start bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.recognize(chart) {
+		return
+	}
+	// Transitions are identified by their position in the statechart.
+	index := make(map[*sc.Transition]int, len(chart.Transitions))
+	for i, t := range chart.Transitions {
+		index[t] = i
+	}
+	if start {
+		c.machines++
+	}
+	for _, label := range r.entered {
+		if label != StateLabel(c.chart.RootState.Label) {
+			c.entered[label]++
+		}
+	}
+	for _, t := range r.fired {
+		c.fired[index[t]]++
+	}
+	for _, g := range r.guards {
+		if g.holds {
+			c.guardTrue[index[g.transition]]++
+		} else {
+			c.guardFalse[index[g.transition]]++
+		}
+	}
+}
+
+// recognize reports whether chart is the statechart of the collector or a copy of it.
+func (c *Coverage) recognize(chart *sc.Statechart) bool {
+	if chart == c.chart.Statechart || chart == c.copy {
+		return true
+	}
+	if !proto.Equal(chart, c.chart.Statechart) {
+		return false
+	}
+	c.copy = chart
+	return true
+}
+
+// Merge adds the coverage collected by other, which must be for the same statechart.
+func (c *Coverage) Merge(other *Coverage) error {
+	if c == other {
+		return errors.New("cannot merge coverage with itself")
+	}
+	if !proto.Equal(c.chart.Statechart, other.chart.Statechart) {
+		return ErrCoverageMismatch
+	}
+	other.mu.Lock()
+	machines := other.machines
+	entered := make(map[StateLabel]int, len(other.entered))
+	for label, n := range other.entered {
+		entered[label] = n
+	}
+	fired := append([]int(nil), other.fired...)
+	guardTrue := append([]int(nil), other.guardTrue...)
+	guardFalse := append([]int(nil), other.guardFalse...)
+	other.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.machines += machines
+	for label, n := range entered {
+		c.entered[label] += n
+	}
+	for i := range c.fired {
+		c.fired[i] += fired[i]
+		c.guardTrue[i] += guardTrue[i]
+		c.guardFalse[i] += guardFalse[i]
+	}
+	return nil
+}
+
+// CoverageReport summarizes the coverage of a statechart.
+type CoverageReport struct {
+	// Machines is the number of machines started.
+	Machines int `json:"machines"`
+	// States lists the states of the chart in document order, without the root state.
+	States []StateCoverage `json:"states"`
+	// Transitions lists the transitions of the chart in declaration order.
+	Transitions []TransitionCoverage `json:"transitions"`
+	// StatesCovered is the number of states entered at least once.
+	StatesCovered int `json:"statesCovered"`
+	// TransitionsCovered is the number of transitions fired at least once.
+	TransitionsCovered int `json:"transitionsCovered"`
+	// GuardBranches is the number of guard outcomes, two per guarded transition.
+	GuardBranches int `json:"guardBranches"`
+	// GuardBranchesCovered is the number of guard outcomes observed at least once.
+	GuardBranchesCovered int `json:"guardBranchesCovered"`
+}
+
+// StateCoverage is the coverage of a state.
+type StateCoverage struct {
+	Label StateLabel `json:"label"`
+	// Entered is the number of times the state was entered, including when a machine started.
+	Entered int `json:"entered"`
+}
+
+// TransitionCoverage is the coverage of a transition.
+type TransitionCoverage struct {
+	Label string `json:"label"`
+	// Fired is the number of times the transition fired.
+	Fired int `json:"fired"`
+	// Guard is the guard expression, if any.
+	Guard string `json:"guard,omitempty"`
+	// GuardTrue and GuardFalse count the evaluations of the guard by outcome.
+	GuardTrue  int `json:"guardTrue,omitempty"`
+	GuardFalse int `json:"guardFalse,omitempty"`
+}
+
+// Report returns a summary of the coverage collected so far.
+func (c *Coverage) Report() *CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &CoverageReport{Machines: c.machines}
+	visitStates(c.chart.RootState, func(s *sc.State) error {
+		if s == c.chart.RootState {
+			return nil
+		}
+		n := c.entered[StateLabel(s.Label)]
+		r.States = append(r.States, StateCoverage{Label: StateLabel(s.Label), Entered: n})
+		if n > 0 {
+			r.StatesCovered++
+		}
+		return nil
+	})
+	for i, t := range c.chart.Transitions {
+		tc := TransitionCoverage{Label: t.Label, Fired: c.fired[i]}
+		if tc.Fired > 0 {
+			r.TransitionsCovered++
+		}
+		// Else guards are never evaluated, so they have no outcomes to cover.
+		if guard := strings.TrimSpace(t.GetGuard().GetExpression()); guard != "" && !IsElse(t.Guard) {
+			tc.Guard, tc.GuardTrue, tc.GuardFalse = guard, c.guardTrue[i], c.guardFalse[i]
+			r.GuardBranches += 2
+			if tc.GuardTrue > 0 {
+				r.GuardBranchesCovered++
+			}
+			if tc.GuardFalse > 0 {
+				r.GuardBranchesCovered++
+			}
+		}
+		r.Transitions = append(r.Transitions, tc)
+	}
+	return r
+}
+
+// WriteText writes the report as text: a summary followed by the uncovered
+// states, transitions and guard outcomes.
+func (r *CoverageReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "machines: %d\n", r.Machines)
+	fmt.Fprintf(&b, "states: %d/%d (%s)\n", r.StatesCovered, len(r.States), percent(r.StatesCovered, len(r.States)))
+	fmt.Fprintf(&b, "transitions: %d/%d (%s)\n", r.TransitionsCovered, len(r.Transitions), percent(r.TransitionsCovered, len(r.Transitions)))
+	fmt.Fprintf(&b, "guard branches: %d/%d (%s)\n", r.GuardBranchesCovered, r.GuardBranches, percent(r.GuardBranchesCovered, r.GuardBranches))
+	for _, s := range r.States {
+		if s.Entered == 0 {
+			fmt.Fprintf(&b, "uncovered state %s\n", s.Label)
+		}
+	}
+	for _, t := range r.Transitions {
+		if t.Fired == 0 {
+			fmt.Fprintf(&b, "uncovered transition %s\n", t.Label)
+		}
+		if t.Guard == "" {
+			continue
+		}
+		if t.GuardTrue == 0 {
+			fmt.Fprintf(&b, "uncovered guard outcome %s [%s] true\n", t.Label, t.Guard)
+		}
+		if t.GuardFalse == 0 {
+			fmt.Fprintf(&b, "uncovered guard outcome %s [%s] false\n", t.Label, t.Guard)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *CoverageReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "100%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+// Colors of the DOT rendering.
+const (
+	coveredColor   = "black"
+	uncoveredColor = "red"
+	partialColor   = "orange"
+)
+
+// WriteDOT writes the statechart in the Graphviz DOT language, annotated with
+// the coverage: composite states are clusters, uncovered states and
+// transitions are red and transitions with a guard outcome that was never
+// observed are orange. Every element is labeled with its count.
+func (c *Coverage) WriteDOT(w io.Writer) error {
+	r := c.Report()
+	entered := make(map[string]int)
+	for _, s := range r.States {
+		entered[string(s.Label)] = s.Entered
+	}
+	ids := make(map[string]string)
+	composite := make(map[string]bool)
+	visitStates(c.chart.RootState, func(s *sc.State) error {
+		ids[s.Label] = fmt.Sprintf("s%d", len(ids))
+		composite[s.Label] = len(s.Children) > 0
+		return nil
+	})
+	color := func(n int) string {
+		if n == 0 {
+			return uncoveredColor
+		}
+		return coveredColor
+	}
+
+	var b strings.Builder
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format+"\n", args...) }
+	p("digraph statechart {")
+	p("  compound=true;")
+	p("  node [shape=box, style=rounded];")
+	var visit func(s *sc.State, indent string)
+	visit = func(s *sc.State, indent string) {
+		id := ids[s.Label]
+		if len(s.Children) == 0 {
+			label, shape := fmt.Sprintf("%s (%d)", s.Label, entered[s.Label]), ""
+			switch {
+			case s.IsFinal:
+				shape = ", peripheries=2"
+			case stateType(s) == sc.StateTypeChoice:
+				shape = ", shape=diamond, style=solid"
+			case stateType(s) == sc.StateTypeJunction:
+				// Junctions are drawn as small filled circles labeled outside.
+				p("%s%s [label=\"\", xlabel=%q, shape=circle, style=filled, width=0.15, color=%s, fontcolor=%s];", indent, id, label, color(entered[s.Label]), color(entered[s.Label]))
+				return
+			}
+			p("%s%s [label=%q, color=%s, fontcolor=%s%s];", indent, id, label, color(entered[s.Label]), color(entered[s.Label]), shape)
+			return
+		}
+		style := "rounded"
+		if stateType(s) == sc.StateTypeParallel {
+			style = "dashed"
+		}
+		p("%ssubgraph cluster_%s {", indent, id)
+		p("%s  label=%q; style=%s; color=%s; fontcolor=%s;", indent, fmt.Sprintf("%s (%d)", s.Label, entered[s.Label]), style, color(entered[s.Label]), color(entered[s.Label]))
+		// Transitions from and to composite states attach to an invisible anchor.
+		p("%s  %s [shape=point, style=invis];", indent, id)
+		for _, child := range s.Children {
+			visit(child, indent+"  ")
+		}
+		p("%s}", indent)
+	}
+	for _, child := range c.chart.RootState.Children {
+		visit(child, "  ")
+	}
+	for i, t := range c.chart.Transitions {
+		tc := r.Transitions[i]
+		edgeColor := color(tc.Fired)
+		parts := []string{t.Label + ":"}
+		if t.Event != "" {
+			parts = append(parts, t.Event)
+		}
+		if IsElse(t.Guard) {
+			parts = append(parts, "["+ElseGuard+"]")
+		}
+		if tc.Guard != "" {
+			parts = append(parts, fmt.Sprintf("[%s] (%d true, %d false)", tc.Guard, tc.GuardTrue, tc.GuardFalse))
+			if tc.Fired > 0 && (tc.GuardTrue == 0 || tc.GuardFalse == 0) {
+				edgeColor = partialColor
+			}
+		}
+		label := fmt.Sprintf("%s (%d)", strings.Join(parts, " "), tc.Fired)
+		targets := t.To
+		if len(targets) == 0 {
+			targets = t.From
+		}
+		for _, from := range t.From {
+			for _, to := range targets {
+				attrs := fmt.Sprintf("label=%q, color=%s, fontcolor=%s", label, edgeColor, edgeColor)
+				if composite[from] {
+					attrs += ", ltail=cluster_" + ids[from]
+				}
+				if composite[to] {
+					attrs += ", lhead=cluster_" + ids[to]
+				}
+				p("  %s -> %s [%s];", ids[from], ids[to], attrs)
+			}
+		}
+	}
+	p("}")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
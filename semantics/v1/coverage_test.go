@@ -0,0 +1,185 @@
+package semantics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func runCounter(t *testing.T, engine *Engine, limit int, events ...string) {
+	t.Helper()
+	context, err := structpb.NewStruct(map[string]interface{}{"count": 0, "limit": limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := engine.NewMachine("counter", counterStatechart, context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	for _, event := range events {
+		if _, err := engine.Step(m, event); err != nil {
+			t.Fatalf("Step(%s) error = %v", event, err)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	coverage := NewCoverage(counterStatechart)
+	runCounter(t, &Engine{Coverage: coverage}, 2, "INC", "INC", "INC")
+
+	// Machines of copies of the chart are recorded; other charts are not.
+	other := NewCoverage(counterStatechart)
+	engine := &Engine{Coverage: other}
+	copied := NewStatechart(proto.Clone(counterStatechart.Statechart).(*sc.Statechart))
+	m, err := engine.NewMachine("copy", copied, nil)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	if _, err := engine.Step(m, "OTHER"); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if _, err := engine.NewMachine("turnstile", turnstileStatechart, nil); err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	if err := coverage.Merge(other); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	got := coverage.Report()
+	want := &CoverageReport{
+		Machines: 2,
+		States: []StateCoverage{
+			{Label: "Counting", Entered: 4},
+			{Label: "Full", Entered: 1},
+			{Label: "Done", Entered: 1},
+		},
+		Transitions: []TransitionCoverage{
+			{Label: "inc", Fired: 2, Guard: "count < limit", GuardTrue: 2, GuardFalse: 1},
+			{Label: "full", Fired: 1},
+			{Label: "finish", Fired: 1},
+		},
+		StatesCovered:        3,
+		TransitionsCovered:   3,
+		GuardBranches:        2,
+		GuardBranchesCovered: 2,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Report() mismatch (-want +got):\n%s", diff)
+	}
+
+	var b bytes.Buffer
+	if err := got.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	decoded := &CoverageReport{}
+	if err := json.Unmarshal(b.Bytes(), decoded); err != nil {
+		t.Fatalf("decoding JSON report: %v", err)
+	}
+	if diff := cmp.Diff(want, decoded); diff != "" {
+		t.Errorf("JSON report mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := coverage.Merge(NewCoverage(turnstileStatechart)); !errors.Is(err, ErrCoverageMismatch) {
+		t.Errorf("Merge() error = %v, want %v", err, ErrCoverageMismatch)
+	}
+}
+
+func TestCoverageFailedStep(t *testing.T) {
+	coverage := NewCoverage(counterStatechart)
+	engine := &Engine{
+		Coverage:      coverage,
+		ExecuteAction: func(*sc.Action, *structpb.Struct) error { return errors.New("failed") },
+	}
+	m, err := engine.NewMachine("counter", counterStatechart, nil)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	m.Context, _ = structpb.NewStruct(map[string]interface{}{"count": 0, "limit": 1})
+	if _, err := engine.Step(m, "INC"); err == nil {
+		t.Fatal("Step() error = nil, want the action error")
+	}
+	r := coverage.Report()
+	if r.Transitions[0].Fired != 0 || r.Transitions[0].GuardTrue != 0 || r.States[0].Entered != 1 {
+		t.Errorf("failed step was recorded: %+v", r)
+	}
+}
+
+func TestCoverageText(t *testing.T) {
+	coverage := NewCoverage(counterStatechart)
+	runCounter(t, &Engine{Coverage: coverage}, 5, "INC")
+	var b bytes.Buffer
+	if err := coverage.Report().WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	want := `machines: 1
+states: 1/3 (33.3%)
+transitions: 1/3 (33.3%)
+guard branches: 1/2 (50.0%)
+uncovered state Full
+uncovered state Done
+uncovered guard outcome inc [count < limit] false
+uncovered transition full
+uncovered transition finish
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("WriteText() mismatch (-want +got):\n%s", diff)
+	}
+
+	b.Reset()
+	if err := coverage.WriteDOT(&b); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+	for _, want := range []string{
+		`s1 [label="Counting (2)", color=black, fontcolor=black];`,
+		`s2 [label="Full (0)", color=red, fontcolor=red];`,
+		`s3 [label="Done (0)", color=red, fontcolor=red, peripheries=2];`,
+		`s1 -> s1 [label="inc: INC [count < limit] (1 true, 0 false) (1)", color=orange, fontcolor=orange];`,
+		`s2 -> s3 [label="finish: (0)", color=red, fontcolor=red];`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteDOT() output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestCoveragePseudostates(t *testing.T) {
+	chart := orderStatechart(sc.StateTypeChoice, &sc.Transition{Label: "paid", From: []string{"Discount"}, To: []string{"Small"}, Guard: &sc.Guard{Expression: "else"}})
+	coverage := NewCoverage(chart)
+	engine := &Engine{Coverage: coverage}
+	context, err := structpb.NewStruct(map[string]interface{}{"amount": 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := engine.NewMachine("order", chart, context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	if _, err := engine.Step(m, "SUBMIT"); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	// Only the guards of small and free have outcomes to cover.
+	if r := coverage.Report(); r.GuardBranches != 4 || r.GuardBranchesCovered != 1 {
+		t.Errorf("guard branches = %d/%d, want 1/4", r.GuardBranchesCovered, r.GuardBranches)
+	}
+
+	var b bytes.Buffer
+	if err := coverage.WriteDOT(&b); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+	for _, want := range []string{
+		`s2 [label="", xlabel="Size (1)", shape=circle, style=filled, width=0.15, color=black, fontcolor=black];`,
+		`s3 [label="Discount (0)", color=red, fontcolor=red, shape=diamond, style=solid];`,
+		`s2 -> s5 [label="large: [else] (1)", color=black, fontcolor=black];`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteDOT() output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
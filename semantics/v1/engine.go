@@ -0,0 +1,334 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxMicrosteps bounds the number of eventless microsteps taken after an event,
+// and the number of raised events processed in a step, so that cycles of
+// eventless transitions or raised events cannot run forever.
+const maxMicrosteps = 100
+
+var (
+	// ErrMachineStopped is returned when stepping a machine that has stopped.
+	ErrMachineStopped = errors.New("machine is stopped")
+	// ErrMicrostepLimit is returned when eventless transitions or raised events do not settle.
+	ErrMicrostepLimit = errors.New("step did not settle")
+)
+
+// BroadcastMode determines when the events raised by actions are sensed.
+type BroadcastMode int
+
+const (
+	// BroadcastNextStep processes a raised event after the eventless
+	// transitions following the microstep that raised it have settled, as if
+	// it was the next event sent to the machine. All raised events are
+	// processed, in the order they are raised, before Step returns.
+	BroadcastNextStep BroadcastMode = iota
+	// BroadcastSameStep makes a raised event visible in the microstep that
+	// raised it: the transitions it enables in the configuration the
+	// microstep started in fire together with the transition that raised it,
+	// unless they conflict with a transition that already fired.
+	BroadcastSameStep
+)
+
+// Engine executes machines according to the step semantics described in FORMAL_SEMANTICS.md.
+//
+// A step processes one event: the maximal set of non-conflicting enabled
+// transitions is selected in priority order and fired, after which transitions
+// without an event are fired until none is enabled. A machine stops once all of
+// its active basic states are final.
+//
+// An action of the form "raise EVENT" broadcasts EVENT to the whole chart, so
+// that orthogonal regions can synchronize. Guards can test the configuration a
+// microstep starts in with in(State). Transitions entering choice and junction
+// pseudostates continue through their branches within the same microstep; see
+// IsPseudostate.
+type Engine struct {
+	// EvaluateGuard evaluates the guard of a transition. If nil, EvaluateGuard is used.
+	EvaluateGuard func(guard *sc.Guard, context *structpb.Struct) (bool, error)
+	// ExecuteAction executes an action of a transition. If nil, ExecuteAction is used.
+	ExecuteAction func(action *sc.Action, context *structpb.Struct) error
+	// Coverage, if set, records the states entered, the transitions fired and
+	// the guard outcomes of the machines the engine runs.
+	Coverage *Coverage
+	// Broadcast determines when raised events are sensed. The default is BroadcastNextStep.
+	Broadcast BroadcastMode
+}
+
+// NewEngine creates an engine that evaluates guards and actions with the expression language.
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+func (e *Engine) evaluateGuard(guard *sc.Guard, context *structpb.Struct, active map[StateLabel]bool) (bool, error) {
+	if e.EvaluateGuard != nil {
+		return e.EvaluateGuard(guard, context)
+	}
+	return guardHolds(guard, Env{Context: context, Configuration: active})
+}
+
+func (e *Engine) executeAction(action *sc.Action, context *structpb.Struct) error {
+	if e.ExecuteAction != nil {
+		return e.ExecuteAction(action, context)
+	}
+	return ExecuteAction(action, context)
+}
+
+// NewMachine creates a running machine of the statechart in its initial configuration.
+// The context is copied; a nil context starts the machine with an empty one.
+func (e *Engine) NewMachine(id string, chart *Statechart, context *structpb.Struct) (*sc.Machine, error) {
+	x, err := chart.index()
+	if err != nil {
+		return nil, err
+	}
+	active, _ := x.configurationSet(nil)
+	if err := x.complete(active); err != nil {
+		return nil, fmt.Errorf("failed to compute initial configuration: %w", err)
+	}
+	if context == nil {
+		context = &structpb.Struct{}
+	} else {
+		context = proto.Clone(context).(*structpb.Struct)
+	}
+	rec := e.newRecord()
+	rec.enter(x.sorted(active))
+	active, _, raised, err := e.settle(x, chart.Transitions, active, context, rec)
+	if err != nil {
+		return nil, err
+	}
+	active, _, _, err = e.process(x, chart.Transitions, active, raised, context, rec)
+	if err != nil {
+		return nil, err
+	}
+	machine := &sc.Machine{
+		Id:            id,
+		State:         sc.MachineStateRunning,
+		Context:       context,
+		Statechart:    chart.Statechart,
+		Configuration: x.configuration(active),
+	}
+	if x.final(active) {
+		machine.State = sc.MachineStateStopped
+	}
+	if rec != nil {
+		e.Coverage.record(chart.Statechart, rec, true)
+	}
+	return machine, nil
+}
+
+// Step processes an event, updates the machine and appends the step to its history.
+// If the step fails, the machine is left unchanged.
+func (e *Engine) Step(machine *sc.Machine, event string) (*sc.Step, error) {
+	if machine.State == sc.MachineStateStopped {
+		return nil, ErrMachineStopped
+	}
+	chart := &Statechart{Statechart: machine.Statechart}
+	x, err := chart.index()
+	if err != nil {
+		return nil, err
+	}
+	active, err := x.machineConfigurationSet(machine.Configuration)
+	if err != nil {
+		return nil, err
+	}
+	context := proto.Clone(machine.Context).(*structpb.Struct)
+	if machine.Context == nil {
+		context = &structpb.Struct{}
+	}
+
+	rec := e.newRecord()
+	next, fired, events, err := e.process(x, chart.Transitions, active, []string{event}, context, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &sc.Step{
+		Events:                 events,
+		Transitions:            fired,
+		StartingConfiguration:  x.configuration(active),
+		ResultingConfiguration: x.configuration(next),
+		Context:                proto.Clone(context).(*structpb.Struct),
+	}
+	machine.Configuration = x.configuration(next)
+	machine.Context = context
+	machine.StepHistory = append(machine.StepHistory, step)
+	if x.final(next) {
+		machine.State = sc.MachineStateStopped
+	}
+	if rec != nil {
+		e.Coverage.record(chart.Statechart, rec, false)
+	}
+	return step, nil
+}
+
+// newRecord returns a record for the coverage of a step, or nil if the engine collects no coverage.
+func (e *Engine) newRecord() *stepRecord {
+	if e.Coverage == nil {
+		return nil
+	}
+	return &stepRecord{}
+}
+
+// process processes events in order, each followed by the eventless
+// transitions it enables. Events raised for the next step are appended to the
+// events to process. It returns the resulting configuration, the fired
+// transitions and the processed events.
+func (e *Engine) process(x *chartIndex, transitions []*sc.Transition, active map[StateLabel]bool, queue []string, context *structpb.Struct, rec *stepRecord) (map[StateLabel]bool, []*sc.Transition, []*sc.Event, error) {
+	var fired []*sc.Transition
+	var events []*sc.Event
+	for i := 0; i < len(queue); i++ {
+		if i > 0 && x.final(active) {
+			// A stopped machine senses no more events.
+			break
+		}
+		if i > maxMicrosteps {
+			return nil, nil, nil, fmt.Errorf("%w: more than %d raised events", ErrMicrostepLimit, maxMicrosteps)
+		}
+		events = append(events, &sc.Event{Label: queue[i]})
+		next, selected, raised, err := e.microstep(x, transitions, active, queue[i], context, rec)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fired = append(fired, selected...)
+		queue = append(queue, raised...)
+		next, settled, raised, err := e.settle(x, transitions, next, context, rec)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fired = append(fired, settled...)
+		queue = append(queue, raised...)
+		active = next
+	}
+	return active, fired, events, nil
+}
+
+// microstep selects and fires the transitions enabled by event, executing their
+// actions on the context, and then the branches of the pseudostates they
+// enter. Under BroadcastSameStep, the events raised by the actions of the
+// selected transitions enable further transitions of the same microstep;
+// events raised by branches are sensed in the next step. It returns the
+// resulting configuration, the fired transitions and the events raised for the
+// next step.
+func (e *Engine) microstep(x *chartIndex, transitions []*sc.Transition, active map[StateLabel]bool, event string, context *structpb.Struct, rec *stepRecord) (map[StateLabel]bool, []*sc.Transition, []string, error) {
+	var selected []*sc.Transition
+	var raised []string
+	junctions := make(map[StateLabel]*sc.Transition)
+	sensed := make(map[string]bool)
+	for pending := []string{event}; len(pending) > 0; pending = pending[1:] {
+		if sensed[pending[0]] {
+			continue
+		}
+		sensed[pending[0]] = true
+		// Transitions that already fired come first, so that the new ones are
+		// selected only if they do not conflict with them.
+		fired := len(selected)
+		candidates := append(selected[:fired:fired], x.enabled(transitions, active, pending[0])...)
+		var err error
+		selected, err = x.selectTransitions(candidates, active, func(t *sc.Transition) (bool, error) {
+			for _, u := range candidates[:fired] {
+				if t == u {
+					return true, nil
+				}
+			}
+			holds, err := e.evaluateGuard(t.Guard, context, active)
+			if err != nil {
+				return false, err
+			}
+			rec.guard(t, holds)
+			if !holds {
+				return false, nil
+			}
+			return e.selectJunctionBranches(x, transitions, t, context, active, rec, junctions)
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, t := range selected[fired:] {
+			more, err := e.execute(t, context)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if e.Broadcast == BroadcastSameStep {
+				pending = append(pending, more...)
+			} else {
+				raised = append(raised, more...)
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return active, nil, nil, nil
+	}
+	next, _, entered, err := x.fire(selected, active)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rec.enter(entered)
+	rec.fire(selected)
+	next, branches, more, err := e.leavePseudostates(x, transitions, next, context, rec, junctions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return next, append(selected, branches...), append(raised, more...), nil
+}
+
+// execute executes the actions of a transition on the context, except for
+// raise actions, and returns the events they raise.
+func (e *Engine) execute(t *sc.Transition, context *structpb.Struct) ([]string, error) {
+	var raised []string
+	for _, action := range t.Actions {
+		if ev, ok := RaisedEvent(action); ok {
+			raised = append(raised, ev)
+			continue
+		}
+		if err := e.executeAction(action, context); err != nil {
+			return nil, fmt.Errorf("transition %s: %w", t.Label, err)
+		}
+	}
+	return raised, nil
+}
+
+// settle fires eventless transitions until none is enabled. It returns the
+// resulting configuration, the fired transitions and the events raised for the
+// next step.
+func (e *Engine) settle(x *chartIndex, transitions []*sc.Transition, active map[StateLabel]bool, context *structpb.Struct, rec *stepRecord) (map[StateLabel]bool, []*sc.Transition, []string, error) {
+	var fired []*sc.Transition
+	var raised []string
+	for i := 0; i < maxMicrosteps; i++ {
+		next, selected, more, err := e.microstep(x, transitions, active, "", context, rec)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		raised = append(raised, more...)
+		if len(selected) == 0 {
+			return active, fired, raised, nil
+		}
+		fired = append(fired, selected...)
+		active = next
+	}
+	return nil, nil, nil, fmt.Errorf("%w: more than %d eventless microsteps", ErrMicrostepLimit, maxMicrosteps)
+}
+
+// configuration converts an active set to a machine configuration in document
+// order. Machine configurations include the root state.
+func (x *chartIndex) configuration(active map[StateLabel]bool) *sc.Configuration {
+	config := &sc.Configuration{}
+	for _, label := range x.sorted(active) {
+		config.States = append(config.States, &sc.StateRef{Label: label.String()})
+	}
+	return config
+}
+
+// machineConfigurationSet converts a machine configuration to an active set.
+func (x *chartIndex) machineConfigurationSet(config *sc.Configuration) (map[StateLabel]bool, error) {
+	var labels []StateLabel
+	for _, ref := range config.GetStates() {
+		labels = append(labels, StateLabel(ref.GetLabel()))
+	}
+	return x.configurationSet(labels)
+}
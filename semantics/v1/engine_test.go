@@ -0,0 +1,321 @@
+package semantics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// counterStatechart counts to a limit and then finishes through an eventless transition.
+var counterStatechart = NewStatechart(&sc.Statechart{
+	RootState: &sc.State{
+		Children: []*sc.State{
+			{Label: "Counting", IsInitial: true},
+			{Label: "Full"},
+			{Label: "Done", IsFinal: true},
+		},
+	},
+	Transitions: []*sc.Transition{
+		{Label: "inc", From: []string{"Counting"}, To: []string{"Counting"}, Event: "INC", Guard: &sc.Guard{Expression: "count < limit"}, Actions: []*sc.Action{{Label: "count = count + 1"}}},
+		{Label: "full", From: []string{"Counting"}, To: []string{"Full"}, Event: "INC"},
+		{Label: "finish", From: []string{"Full"}, To: []string{"Done"}},
+	},
+})
+
+func TestEngineStep(t *testing.T) {
+	engine := NewEngine()
+	context, err := structpb.NewStruct(map[string]interface{}{"count": 0, "limit": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := engine.NewMachine("counter", counterStatechart, context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	if got := configurationStrings(m.Configuration); !cmp.Equal(got, []string{"__root__", "Counting"}) {
+		t.Errorf("initial configuration = %v", got)
+	}
+
+	tests := []struct {
+		event       string
+		transitions []string
+		config      []string
+		count       float64
+		state       sc.MachineState
+	}{
+		{"INC", []string{"inc"}, []string{"__root__", "Counting"}, 1, sc.MachineStateRunning},
+		{"OTHER", nil, []string{"__root__", "Counting"}, 1, sc.MachineStateRunning},
+		{"INC", []string{"inc"}, []string{"__root__", "Counting"}, 2, sc.MachineStateRunning},
+		{"INC", []string{"full", "finish"}, []string{"__root__", "Done"}, 2, sc.MachineStateStopped},
+	}
+	for i, tt := range tests {
+		step, err := engine.Step(m, tt.event)
+		if err != nil {
+			t.Fatalf("step %d: Step(%s) error = %v", i, tt.event, err)
+		}
+		if diff := cmp.Diff(tt.transitions, transitionLabels(step.Transitions)); diff != "" {
+			t.Errorf("step %d: transitions mismatch (-want +got):\n%s", i, diff)
+		}
+		if diff := cmp.Diff(tt.config, configurationStrings(m.Configuration)); diff != "" {
+			t.Errorf("step %d: configuration mismatch (-want +got):\n%s", i, diff)
+		}
+		if got := m.Context.Fields["count"].GetNumberValue(); got != tt.count {
+			t.Errorf("step %d: count = %v, want %v", i, got, tt.count)
+		}
+		if m.State != tt.state {
+			t.Errorf("step %d: state = %v, want %v", i, m.State, tt.state)
+		}
+	}
+	if len(m.StepHistory) != len(tests) {
+		t.Errorf("len(StepHistory) = %d, want %d", len(m.StepHistory), len(tests))
+	}
+	if context.Fields["count"].GetNumberValue() != 0 {
+		t.Error("NewMachine() modified the given context")
+	}
+	if _, err := engine.Step(m, "INC"); !errors.Is(err, ErrMachineStopped) {
+		t.Errorf("Step() on stopped machine error = %v, want ErrMachineStopped", err)
+	}
+}
+
+func TestEngineStepErrorLeavesMachineUnchanged(t *testing.T) {
+	engine := NewEngine()
+	m, err := engine.NewMachine("counter", counterStatechart, nil)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	// The guard refers to variables that are not in the context.
+	if _, err := engine.Step(m, "INC"); err == nil {
+		t.Fatal("Step() expected error for undefined guard variable")
+	}
+	if diff := cmp.Diff([]string{"__root__", "Counting"}, configurationStrings(m.Configuration)); diff != "" {
+		t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+	}
+	if len(m.StepHistory) != 0 {
+		t.Errorf("len(StepHistory) = %d, want 0", len(m.StepHistory))
+	}
+}
+
+func TestEngineCustomGuards(t *testing.T) {
+	engine := &Engine{
+		EvaluateGuard: func(guard *sc.Guard, context *structpb.Struct) (bool, error) {
+			return guard.GetExpression() != "jammed", nil
+		},
+	}
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "A", IsInitial: true},
+				{Label: "B"},
+				{Label: "C"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "jammed", From: []string{"A"}, To: []string{"B"}, Event: "E", Guard: &sc.Guard{Expression: "jammed"}},
+			{Label: "free", From: []string{"A"}, To: []string{"C"}, Event: "E", Guard: &sc.Guard{Expression: "free"}},
+		},
+	})
+	m, err := engine.NewMachine("m", chart, nil)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	step, err := engine.Step(m, "E")
+	if err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"free"}, transitionLabels(step.Transitions)); diff != "" {
+		t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEngineEventlessCycle(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "A", IsInitial: true},
+				{Label: "B"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "ab", From: []string{"A"}, To: []string{"B"}},
+			{Label: "ba", From: []string{"B"}, To: []string{"A"}},
+		},
+	})
+	if _, err := NewEngine().NewMachine("m", chart, nil); !errors.Is(err, ErrMicrostepLimit) {
+		t.Errorf("NewMachine() error = %v, want ErrMicrostepLimit", err)
+	}
+}
+
+// crossingStatechart coordinates the regions of a pedestrian crossing: the
+// cars region raises CARS_STOPPED, which the walk and sound regions react to,
+// and the cars may only go again once pedestrians may no longer walk.
+var crossingStatechart = NewStatechart(&sc.Statechart{
+	RootState: &sc.State{
+		Children: []*sc.State{
+			{Label: "Crossing", Type: sc.StateTypeParallel, IsInitial: true, Children: []*sc.State{
+				{Label: "Cars", Children: []*sc.State{
+					{Label: "Green", IsInitial: true},
+					{Label: "Yellow"},
+					{Label: "Red"},
+				}},
+				{Label: "Pedestrians", Children: []*sc.State{
+					{Label: "DontWalk", IsInitial: true},
+					{Label: "Walk"},
+				}},
+				{Label: "Sound", Children: []*sc.State{
+					{Label: "Quiet", IsInitial: true},
+					{Label: "Beeping"},
+				}},
+			}},
+		},
+	},
+	Transitions: []*sc.Transition{
+		{Label: "slow", From: []string{"Green"}, To: []string{"Yellow"}, Event: "BUTTON"},
+		{Label: "stop", From: []string{"Yellow"}, To: []string{"Red"}, Event: "TIMER", Actions: []*sc.Action{{Label: "raise CARS_STOPPED"}}},
+		{Label: "go", From: []string{"Red"}, To: []string{"Green"}, Event: "TIMER", Guard: &sc.Guard{Expression: "in(DontWalk)"}},
+		{Label: "walk", From: []string{"DontWalk"}, To: []string{"Walk"}, Event: "CARS_STOPPED", Guard: &sc.Guard{Expression: "in(Red)"}},
+		{Label: "dont", From: []string{"Walk"}, To: []string{"DontWalk"}, Event: "TIMER", Actions: []*sc.Action{{Label: "raise WALK_DONE"}}},
+		{Label: "beep", From: []string{"Quiet"}, To: []string{"Beeping"}, Event: "CARS_STOPPED"},
+		{Label: "hush", From: []string{"Beeping"}, To: []string{"Quiet"}, Event: "WALK_DONE"},
+	},
+})
+
+func TestEngineBroadcast(t *testing.T) {
+	type step struct {
+		event       string
+		events      []string
+		transitions []string
+		config      []string
+	}
+	tests := []struct {
+		mode  BroadcastMode
+		steps []step
+	}{
+		{BroadcastNextStep, []step{
+			{"BUTTON", []string{"BUTTON"}, []string{"slow"}, []string{"Yellow", "DontWalk", "Quiet"}},
+			// CARS_STOPPED is sensed once the cars are red.
+			{"TIMER", []string{"TIMER", "CARS_STOPPED"}, []string{"stop", "walk", "beep"}, []string{"Red", "Walk", "Beeping"}},
+			// The cars wait for the pedestrians.
+			{"TIMER", []string{"TIMER", "WALK_DONE"}, []string{"dont", "hush"}, []string{"Red", "DontWalk", "Quiet"}},
+			{"TIMER", []string{"TIMER"}, []string{"go"}, []string{"Green", "DontWalk", "Quiet"}},
+		}},
+		{BroadcastSameStep, []step{
+			{"BUTTON", []string{"BUTTON"}, []string{"slow"}, []string{"Yellow", "DontWalk", "Quiet"}},
+			// CARS_STOPPED is sensed while the cars are still yellow, so walk is not enabled.
+			{"TIMER", []string{"TIMER"}, []string{"stop", "beep"}, []string{"Red", "DontWalk", "Beeping"}},
+			{"TIMER", []string{"TIMER"}, []string{"go"}, []string{"Green", "DontWalk", "Beeping"}},
+		}},
+	}
+	for _, tt := range tests {
+		engine := &Engine{Broadcast: tt.mode}
+		m, err := engine.NewMachine("crossing", crossingStatechart, nil)
+		if err != nil {
+			t.Fatalf("NewMachine() error = %v", err)
+		}
+		for i, want := range tt.steps {
+			got, err := engine.Step(m, want.event)
+			if err != nil {
+				t.Fatalf("mode %d, step %d: Step(%s) error = %v", tt.mode, i, want.event, err)
+			}
+			var events []string
+			for _, e := range got.Events {
+				events = append(events, e.Label)
+			}
+			if diff := cmp.Diff(want.events, events); diff != "" {
+				t.Errorf("mode %d, step %d: events mismatch (-want +got):\n%s", tt.mode, i, diff)
+			}
+			if diff := cmp.Diff(want.transitions, transitionLabels(got.Transitions)); diff != "" {
+				t.Errorf("mode %d, step %d: transitions mismatch (-want +got):\n%s", tt.mode, i, diff)
+			}
+			config := []string{"__root__", "Crossing", "Cars", want.config[0], "Pedestrians", want.config[1], "Sound", want.config[2]}
+			if diff := cmp.Diff(config, configurationStrings(m.Configuration)); diff != "" {
+				t.Errorf("mode %d, step %d: configuration mismatch (-want +got):\n%s", tt.mode, i, diff)
+			}
+		}
+	}
+}
+
+func TestEngineRaiseCycle(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}}},
+		Transitions: []*sc.Transition{
+			{Label: "ping", From: []string{"A"}, To: []string{"A"}, Event: "PING", Actions: []*sc.Action{{Label: "raise PING"}, {Label: "n = n + 1"}}},
+		},
+	})
+	context, err := structpb.NewStruct(map[string]interface{}{"n": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	m, err := engine.NewMachine("m", chart, context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	if _, err := engine.Step(m, "PING"); !errors.Is(err, ErrMicrostepLimit) {
+		t.Errorf("Step() error = %v, want ErrMicrostepLimit", err)
+	}
+
+	// In the same step, an event enables each transition at most once.
+	engine.Broadcast = BroadcastSameStep
+	if _, err := engine.Step(m, "PING"); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if n := m.Context.Fields["n"].GetNumberValue(); n != 1 {
+		t.Errorf("n = %v, want 1", n)
+	}
+}
+
+func configurationStrings(config *sc.Configuration) []string {
+	var labels []string
+	for _, s := range config.GetStates() {
+		labels = append(labels, s.GetLabel())
+	}
+	return labels
+}
+
+func TestEngineTransitionKinds(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "S", IsInitial: true, Children: []*sc.State{{Label: "A", IsInitial: true}, {Label: "B"}}},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "external", From: []string{"S"}, To: []string{"B"}, Event: "EXTERNAL"},
+			{Label: "local", From: []string{"S"}, To: []string{"A"}, Event: "LOCAL", Kind: sc.TransitionKindLocal},
+			{Label: "internal", From: []string{"S"}, Event: "INTERNAL", Kind: sc.TransitionKindInternal, Actions: []*sc.Action{{Label: "n = 1"}}},
+		},
+	})
+	tests := []struct {
+		event  string
+		config []string
+		// entered counts the entries of S and A, including the initial one.
+		entered [2]int
+	}{
+		{"EXTERNAL", []string{"__root__", "S", "B"}, [2]int{2, 1}},
+		{"LOCAL", []string{"__root__", "S", "A"}, [2]int{1, 2}},
+		{"INTERNAL", []string{"__root__", "S", "A"}, [2]int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			engine := &Engine{Coverage: NewCoverage(chart)}
+			m, err := engine.NewMachine("m", chart, nil)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			if _, err := engine.Step(m, tt.event); err != nil {
+				t.Fatalf("Step(%s) error = %v", tt.event, err)
+			}
+			if diff := cmp.Diff(tt.config, configurationStrings(m.Configuration)); diff != "" {
+				t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+			}
+			entered := make(map[StateLabel]int)
+			for _, s := range engine.Coverage.Report().States {
+				entered[s.Label] = s.Entered
+			}
+			if got := [2]int{entered["S"], entered["A"]}; got != tt.entered {
+				t.Errorf("entries of S and A = %v, want %v", got, tt.entered)
+			}
+		})
+	}
+}
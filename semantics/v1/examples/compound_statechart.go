@@ -256,6 +256,7 @@ func CompoundStatechart() *semantics.Statechart {
 				To:    []string{"Standby"},
 				Event: "EMERGENCY",
 			},
+			// A join: the failure is only detected while both sensors are active.
 			{
 				Label: "SensorFailure",
 				From:  []string{"RadarActive", "CameraOn"},
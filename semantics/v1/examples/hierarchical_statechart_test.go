@@ -57,4 +57,19 @@ func TestHierarchicalStatechart(t *testing.T) {
 	if err != nil || orthogonal {
 		t.Errorf("Expected Idle and Armed to NOT be orthogonal")
 	}
+
+	// Test reachability: every state and transition of the alarm system is live
+	reachability, err := chart.Reachability()
+	if err != nil {
+		t.Fatalf("Error computing reachability: %v", err)
+	}
+	if len(reachability.UnreachableStates) != 0 {
+		t.Errorf("Expected all states to be reachable, got unreachable %v", reachability.UnreachableStates)
+	}
+	if len(reachability.DeadTransitions) != 0 {
+		t.Errorf("Expected all transitions to be live, got %d dead transitions", len(reachability.DeadTransitions))
+	}
+	if len(reachability.Deadlocks) != 0 {
+		t.Errorf("Expected no deadlocks, got %v", reachability.Deadlocks)
+	}
 }
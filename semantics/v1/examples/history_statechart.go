@@ -144,8 +144,14 @@ func HistoryStatechart() *semantics.Statechart {
 				Event: "ADVANCED",
 			},
 			{
-				Label: "BackToGeneral",
-				From:  []string{"Display", "Advanced"},
+				Label: "DisplayToGeneral",
+				From:  []string{"Display"},
+				To:    []string{"General"},
+				Event: "GENERAL",
+			},
+			{
+				Label: "AdvancedToGeneral",
+				From:  []string{"Advanced"},
 				To:    []string{"General"},
 				Event: "GENERAL",
 			},
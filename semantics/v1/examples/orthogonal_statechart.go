@@ -34,7 +34,8 @@ func OrthogonalStatechart() *semantics.Statechart {
 				{
 					Label: "PlaybackControl",
 					// Use the ORTHOGONAL alias for demonstrating academic terminology compatibility
-					Type: sc.StateTypeOrthogonal,
+					Type:      sc.StateTypeOrthogonal,
+					IsInitial: true,
 					Children: []*sc.State{
 						{
 							Label: "PlaybackState",
@@ -90,7 +91,13 @@ func OrthogonalStatechart() *semantics.Statechart {
 			},
 			{
 				Label: "Stop",
-				From:  []string{"Playing", "Paused"},
+				From:  []string{"Playing"},
+				To:    []string{"Stopped"},
+				Event: "STOP",
+			},
+			{
+				Label: "StopPaused",
+				From:  []string{"Paused"},
 				To:    []string{"Stopped"},
 				Event: "STOP",
 			},
@@ -2,6 +2,11 @@ package examples
 
 import (
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"github.com/tmc/sc/semantics/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestOrthogonalStatechart(t *testing.T) {
@@ -79,3 +84,86 @@ func TestOrthogonalStatechart(t *testing.T) {
 	// Verify orthogonal state through orthogonality test (since findState is not exposed)
 	// We already tested orthogonality relations which confirms the states are properly defined
 }
+
+// coordinatedMediaPlayer extends OrthogonalStatechart so that its regions
+// coordinate: stopping raises STOPPED, which mutes the volume while playback
+// is stopped, and playing unmutes it unless playback was already playing.
+func coordinatedMediaPlayer() *semantics.Statechart {
+	chart := proto.Clone(OrthogonalStatechart().Statechart).(*sc.Statechart)
+	for _, t := range chart.Transitions {
+		if t.Event == "STOP" {
+			t.Actions = append(t.Actions, &sc.Action{Label: "raise STOPPED"})
+		}
+	}
+	chart.Transitions = append(chart.Transitions,
+		&sc.Transition{Label: "MuteOnStop", From: []string{"Normal"}, To: []string{"Muted"}, Event: "STOPPED", Guard: &sc.Guard{Expression: "in(Stopped)"}},
+		&sc.Transition{Label: "UnmuteOnPlay", From: []string{"Muted"}, To: []string{"Normal"}, Event: "PLAY", Guard: &sc.Guard{Expression: "!in(Playing)"}},
+	)
+	chart.Events = append(chart.Events, &sc.Event{Label: "STOPPED"})
+	return semantics.NewStatechart(chart)
+}
+
+func TestOrthogonalStatechartCoordination(t *testing.T) {
+	type step struct {
+		event       string
+		events      []string
+		transitions []string
+		config      []string
+	}
+	tests := []struct {
+		name  string
+		mode  semantics.BroadcastMode
+		steps []step
+	}{
+		{"next step", semantics.BroadcastNextStep, []step{
+			{"PLAY", []string{"PLAY"}, []string{"Play"}, []string{"Playing", "Normal"}},
+			// STOPPED is sensed once playback has stopped, so the volume mutes.
+			{"STOP", []string{"STOP", "STOPPED"}, []string{"Stop", "MuteOnStop"}, []string{"Stopped", "Muted"}},
+			// Both regions react to PLAY, testing the configuration before the step.
+			{"PLAY", []string{"PLAY"}, []string{"Resume", "UnmuteOnPlay"}, []string{"Playing", "Normal"}},
+		}},
+		{"same step", semantics.BroadcastSameStep, []step{
+			{"PLAY", []string{"PLAY"}, []string{"Play"}, []string{"Playing", "Normal"}},
+			// STOPPED is sensed while playback is still playing, so the volume stays.
+			{"STOP", []string{"STOP"}, []string{"Stop"}, []string{"Stopped", "Normal"}},
+			{"MUTE", []string{"MUTE"}, []string{"Mute"}, []string{"Stopped", "Muted"}},
+			{"PLAY", []string{"PLAY"}, []string{"Resume", "UnmuteOnPlay"}, []string{"Playing", "Normal"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &semantics.Engine{Broadcast: tt.mode}
+			m, err := engine.NewMachine("player", coordinatedMediaPlayer(), nil)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			for i, want := range tt.steps {
+				got, err := engine.Step(m, want.event)
+				if err != nil {
+					t.Fatalf("step %d: Step(%s) error = %v", i, want.event, err)
+				}
+				var events, transitions []string
+				for _, e := range got.Events {
+					events = append(events, e.Label)
+				}
+				for _, tr := range got.Transitions {
+					transitions = append(transitions, tr.Label)
+				}
+				if diff := cmp.Diff(want.events, events); diff != "" {
+					t.Errorf("step %d: events mismatch (-want +got):\n%s", i, diff)
+				}
+				if diff := cmp.Diff(want.transitions, transitions); diff != "" {
+					t.Errorf("step %d: transitions mismatch (-want +got):\n%s", i, diff)
+				}
+				var config []string
+				for _, ref := range m.Configuration.States {
+					config = append(config, ref.Label)
+				}
+				wantConfig := []string{semantics.RootState.String(), "PlaybackControl", "PlaybackState", want.config[0], "VolumeControl", want.config[1]}
+				if diff := cmp.Diff(wantConfig, config); diff != "" {
+					t.Errorf("step %d: configuration mismatch (-want +got):\n%s", i, diff)
+				}
+			}
+		})
+	}
+}
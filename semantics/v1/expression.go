@@ -0,0 +1,734 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ErrNotAssignment is returned by ParseAssignments for action labels that are
+// not assignments. Such actions are opaque to the engine.
+var ErrNotAssignment = errors.New("not an assignment")
+
+// Expr is a node of a guard or assignment expression.
+//
+// Expressions are a small, side-effect free language over the machine context:
+//
+//	literals     1, 2.5, "text", 'text', true, false, null
+//	variables    count, context.count, context.order.total
+//	operators    ! - * / % + - < <= > >= == != && ||
+//	functions    in(State), in("State")
+//
+// Variables refer to fields of the context; the "context." prefix is optional.
+// The condition in(State) holds if State is in the configuration of the machine.
+type Expr interface {
+	// String returns the expression in source form.
+	String() string
+}
+
+// Literal is a constant: a number, string, boolean or null.
+type Literal struct {
+	Value *structpb.Value
+}
+
+// Ident is a reference to a context variable, given as a path of field names.
+type Ident struct {
+	Path []string
+}
+
+// Unary is a unary operation: "!" or "-".
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+// Binary is a binary operation.
+type Binary struct {
+	Op   string
+	X, Y Expr
+}
+
+// Call is a call of a built-in function. The only built-in function is in,
+// which tests whether a state is active; other calls parse but fail to evaluate.
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Assignment assigns the value of an expression to a context variable.
+type Assignment struct {
+	Target *Ident
+	Value  Expr
+}
+
+func (e *Literal) String() string {
+	switch v := e.Value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return strconv.Quote(v.StringValue)
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(v.NumberValue, 'g', -1, 64)
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	}
+	return "null"
+}
+
+func (e *Ident) String() string { return strings.Join(e.Path, ".") }
+
+func (e *Unary) String() string { return e.Op + e.X.String() }
+
+func (e *Binary) String() string {
+	return "(" + e.X.String() + " " + e.Op + " " + e.Y.String() + ")"
+}
+
+func (e *Call) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return e.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (a *Assignment) String() string { return a.Target.String() + " = " + a.Value.String() }
+
+// ParseExpression parses a guard expression.
+func ParseExpression(src string) (Expr, error) {
+	p, err := newExprParser(src)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("expression %q: unexpected %q", src, p.peek().text)
+	}
+	return e, nil
+}
+
+// ParseAssignments parses an action label of the form "x = expr; y = expr".
+// It returns ErrNotAssignment if the label does not have that form.
+func ParseAssignments(src string) ([]*Assignment, error) {
+	if !strings.Contains(src, "=") {
+		return nil, ErrNotAssignment
+	}
+	p, err := newExprParser(src)
+	if err != nil {
+		return nil, ErrNotAssignment
+	}
+	var result []*Assignment
+	for {
+		target, ok := p.parsePrimaryIdent()
+		if !ok || !p.peek().isOp("=") {
+			return nil, ErrNotAssignment
+		}
+		p.next()
+		value, err := p.parseOr()
+		if err != nil {
+			return nil, fmt.Errorf("action %q: %w", src, err)
+		}
+		result = append(result, &Assignment{Target: target, Value: value})
+		switch t := p.next(); {
+		case t.kind == tokEOF:
+			return result, nil
+		case t.isOp(";"):
+			if p.peek().kind == tokEOF {
+				return result, nil
+			}
+		default:
+			return nil, fmt.Errorf("action %q: unexpected %q", src, t.text)
+		}
+	}
+}
+
+// Env is the environment an expression is evaluated in.
+type Env struct {
+	// Context holds the values of variables.
+	Context *structpb.Struct
+	// Configuration holds the active states for in() conditions. If nil,
+	// in() fails to evaluate.
+	Configuration map[StateLabel]bool
+}
+
+// InState returns the state tested by a call of in. The state is given as a
+// name or a string literal.
+func InState(call *Call) (StateLabel, error) {
+	if call.Func != "in" {
+		return "", fmt.Errorf("%s is not a call of in", call)
+	}
+	if len(call.Args) == 1 {
+		switch arg := call.Args[0].(type) {
+		case *Ident:
+			if len(arg.Path) == 1 {
+				return StateLabel(arg.Path[0]), nil
+			}
+		case *Literal:
+			if s, ok := arg.Value.GetKind().(*structpb.Value_StringValue); ok {
+				return StateLabel(s.StringValue), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%s: in takes a single state", call)
+}
+
+// Eval evaluates an expression.
+func Eval(e Expr, env Env) (*structpb.Value, error) {
+	switch e := e.(type) {
+	case *Literal:
+		return e.Value, nil
+	case *Ident:
+		v, ok := lookupPath(env.Context, e.Path)
+		if !ok {
+			return nil, fmt.Errorf("undefined variable %s", e)
+		}
+		return v, nil
+	case *Unary:
+		x, err := Eval(e.X, env)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Op {
+		case "!":
+			return structpb.NewBoolValue(!truthy(x)), nil
+		case "-":
+			n, err := number(x, e)
+			if err != nil {
+				return nil, err
+			}
+			return structpb.NewNumberValue(-n), nil
+		}
+	case *Binary:
+		return evalBinary(e, env)
+	case *Call:
+		if e.Func != "in" {
+			return nil, fmt.Errorf("unknown function %s", e.Func)
+		}
+		state, err := InState(e)
+		if err != nil {
+			return nil, err
+		}
+		if env.Configuration == nil {
+			return nil, fmt.Errorf("%s: no configuration", e)
+		}
+		return structpb.NewBoolValue(env.Configuration[state]), nil
+	}
+	return nil, fmt.Errorf("invalid expression %v", e)
+}
+
+func evalBinary(e *Binary, env Env) (*structpb.Value, error) {
+	x, err := Eval(e.X, env)
+	if err != nil {
+		return nil, err
+	}
+	// Logical operators short-circuit.
+	switch e.Op {
+	case "&&":
+		if !truthy(x) {
+			return structpb.NewBoolValue(false), nil
+		}
+		y, err := Eval(e.Y, env)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewBoolValue(truthy(y)), nil
+	case "||":
+		if truthy(x) {
+			return structpb.NewBoolValue(true), nil
+		}
+		y, err := Eval(e.Y, env)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewBoolValue(truthy(y)), nil
+	}
+	y, err := Eval(e.Y, env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Op {
+	case "==":
+		return structpb.NewBoolValue(equal(x, y)), nil
+	case "!=":
+		return structpb.NewBoolValue(!equal(x, y)), nil
+	case "+":
+		if xs, ok := x.GetKind().(*structpb.Value_StringValue); ok {
+			return structpb.NewStringValue(xs.StringValue + toString(y)), nil
+		}
+	case "<", "<=", ">", ">=":
+		xs, xok := x.GetKind().(*structpb.Value_StringValue)
+		ys, yok := y.GetKind().(*structpb.Value_StringValue)
+		if xok && yok {
+			return structpb.NewBoolValue(compare(e.Op, strings.Compare(xs.StringValue, ys.StringValue))), nil
+		}
+	}
+	a, err := number(x, e)
+	if err != nil {
+		return nil, err
+	}
+	b, err := number(y, e)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Op {
+	case "+":
+		return structpb.NewNumberValue(a + b), nil
+	case "-":
+		return structpb.NewNumberValue(a - b), nil
+	case "*":
+		return structpb.NewNumberValue(a * b), nil
+	case "/":
+		if b == 0 {
+			return nil, fmt.Errorf("%s: division by zero", e)
+		}
+		return structpb.NewNumberValue(a / b), nil
+	case "%":
+		if b == 0 {
+			return nil, fmt.Errorf("%s: division by zero", e)
+		}
+		return structpb.NewNumberValue(math.Mod(a, b)), nil
+	case "<", "<=", ">", ">=":
+		c := 0
+		if a < b {
+			c = -1
+		} else if a > b {
+			c = 1
+		}
+		return structpb.NewBoolValue(compare(e.Op, c)), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.Op)
+}
+
+func compare(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func number(v *structpb.Value, e Expr) (float64, error) {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue, nil
+	case *structpb.Value_BoolValue:
+		if k.BoolValue {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s: %s is not a number", e, toString(v))
+}
+
+func truthy(v *structpb.Value) bool {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_BoolValue:
+		return k.BoolValue
+	case *structpb.Value_NumberValue:
+		return k.NumberValue != 0
+	case *structpb.Value_StringValue:
+		return k.StringValue != ""
+	case *structpb.Value_NullValue, nil:
+		return false
+	}
+	return true
+}
+
+func equal(x, y *structpb.Value) bool {
+	switch xk := x.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		yk, ok := y.GetKind().(*structpb.Value_NumberValue)
+		return ok && xk.NumberValue == yk.NumberValue
+	case *structpb.Value_StringValue:
+		yk, ok := y.GetKind().(*structpb.Value_StringValue)
+		return ok && xk.StringValue == yk.StringValue
+	case *structpb.Value_BoolValue:
+		yk, ok := y.GetKind().(*structpb.Value_BoolValue)
+		return ok && xk.BoolValue == yk.BoolValue
+	case *structpb.Value_NullValue, nil:
+		switch y.GetKind().(type) {
+		case *structpb.Value_NullValue, nil:
+			return true
+		}
+	}
+	return false
+}
+
+func toString(v *structpb.Value) string {
+	if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+		return s.StringValue
+	}
+	return (&Literal{Value: v}).String()
+}
+
+// lookupPath resolves a variable path in a context.
+func lookupPath(context *structpb.Struct, path []string) (*structpb.Value, bool) {
+	if len(path) > 1 && path[0] == "context" {
+		path = path[1:]
+	}
+	current := context
+	for i, name := range path {
+		v, ok := current.GetFields()[name]
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return v, true
+		}
+		current = v.GetStructValue()
+	}
+	return nil, false
+}
+
+// assignPath sets a variable path in a context, creating intermediate structs as needed.
+func assignPath(context *structpb.Struct, path []string, v *structpb.Value) error {
+	if len(path) > 1 && path[0] == "context" {
+		path = path[1:]
+	}
+	current := context
+	for i, name := range path {
+		if current.Fields == nil {
+			current.Fields = make(map[string]*structpb.Value)
+		}
+		if i == len(path)-1 {
+			current.Fields[name] = v
+			return nil
+		}
+		next, ok := current.Fields[name]
+		if !ok {
+			next = structpb.NewStructValue(&structpb.Struct{})
+			current.Fields[name] = next
+		}
+		if next.GetStructValue() == nil {
+			return fmt.Errorf("cannot assign %s: %s is not a struct", strings.Join(path, "."), name)
+		}
+		current = next.GetStructValue()
+	}
+	return nil
+}
+
+// EvaluateGuard evaluates a guard expression against a context. A nil guard or
+// an empty expression holds.
+func EvaluateGuard(guard *sc.Guard, context *structpb.Struct) (bool, error) {
+	return guardHolds(guard, Env{Context: context})
+}
+
+func guardHolds(guard *sc.Guard, env Env) (bool, error) {
+	if guard == nil || strings.TrimSpace(guard.Expression) == "" {
+		return true, nil
+	}
+	e, err := ParseExpression(guard.Expression)
+	if err != nil {
+		return false, err
+	}
+	v, err := Eval(e, env)
+	if err != nil {
+		return false, fmt.Errorf("guard %q: %w", guard.Expression, err)
+	}
+	return truthy(v), nil
+}
+
+// ExecuteAction executes an action against a context. Actions whose labels are
+// assignments, such as "count = count + 1", update the context; other actions,
+// including those that raise events, have no effect on it.
+func ExecuteAction(action *sc.Action, context *structpb.Struct) error {
+	return applyAction(action, Env{Context: context})
+}
+
+// RaisedEvent returns the event raised by an action of the form "raise EVENT".
+func RaisedEvent(action *sc.Action) (string, bool) {
+	fields := strings.Fields(action.GetLabel())
+	if len(fields) != 2 || fields[0] != "raise" {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// SentEvent returns the event and the target of an action of the form
+// "send EVENT to TARGET". The engine does not deliver sent events; a runtime
+// hosting several machines does.
+func SentEvent(action *sc.Action) (event, target string, ok bool) {
+	fields := strings.Fields(action.GetLabel())
+	if len(fields) != 4 || fields[0] != "send" || fields[2] != "to" {
+		return "", "", false
+	}
+	return fields[1], fields[3], true
+}
+
+func applyAction(action *sc.Action, env Env) error {
+	assignments, err := ParseAssignments(action.Label)
+	if errors.Is(err, ErrNotAssignment) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		v, err := Eval(a.Value, env)
+		if err != nil {
+			return fmt.Errorf("action %q: %w", action.Label, err)
+		}
+		if err := assignPath(env.Context, a.Target.Path, v); err != nil {
+			return fmt.Errorf("action %q: %w", action.Label, err)
+		}
+	}
+	return nil
+}
+
+// Lexing and parsing.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// isOp reports whether the token is the operator or punctuation op, not a
+// string literal with the same text.
+func (t token) isOp(op string) bool { return t.kind == tokOp && t.text == op }
+
+type exprParser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func newExprParser(src string) (*exprParser, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", src, err)
+	}
+	return &exprParser{src: src, tokens: tokens}, nil
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, string(rs[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, string(rs[i:j])})
+			i = j
+		case r == '"' || r == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+			i = j + 1
+		default:
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					tokens = append(tokens, token{tokOp, two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("!-+*/%<>=().,;", r) {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, token{tokOp, string(r)})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+func (p *exprParser) peek() token { return p.tokens[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) expect(text string) error {
+	if t := p.next(); !t.isOp(text) {
+		return fmt.Errorf("expression %q: expected %q, got %q", p.src, text, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseBinary(ops []string, operand func() (Expr, error)) (Expr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range ops {
+			if t.isOp(op) {
+				matched = true
+			}
+		}
+		if !matched {
+			return x, nil
+		}
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &Binary{Op: t.text, X: x, Y: y}
+	}
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	return p.parseBinary([]string{"&&"}, p.parseComparison)
+}
+
+func (p *exprParser) parseComparison() (Expr, error) {
+	return p.parseBinary([]string{"==", "!=", "<", "<=", ">", ">="}, p.parseSum)
+}
+
+func (p *exprParser) parseSum() (Expr, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseTerm)
+}
+
+func (p *exprParser) parseTerm() (Expr, error) {
+	return p.parseBinary([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+func (p *exprParser) parseUnary() (Expr, error) {
+	if t := p.peek(); t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: t.text, X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimaryIdent() (*Ident, bool) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return nil, false
+	}
+	p.next()
+	id := &Ident{Path: []string{t.text}}
+	for p.peek().isOp(".") {
+		p.next()
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, false
+		}
+		id.Path = append(id.Path, t.text)
+	}
+	return id, true
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q: invalid number %q", p.src, t.text)
+		}
+		return &Literal{Value: structpb.NewNumberValue(n)}, nil
+	case tokString:
+		p.next()
+		return &Literal{Value: structpb.NewStringValue(t.text)}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &Literal{Value: structpb.NewBoolValue(t.text == "true")}, nil
+		case "null":
+			p.next()
+			return &Literal{Value: structpb.NewNullValue()}, nil
+		}
+		if p.tokens[p.pos+1].isOp("(") {
+			p.next()
+			p.next()
+			call := &Call{Func: t.text}
+			for !p.peek().isOp(")") {
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				call.Args = append(call.Args, arg)
+				if !p.peek().isOp(",") {
+					break
+				}
+				p.next()
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return call, nil
+		}
+		id, ok := p.parsePrimaryIdent()
+		if !ok {
+			return nil, fmt.Errorf("expression %q: invalid variable", p.src)
+		}
+		return id, nil
+	case tokOp:
+		if t.text == "(" {
+			p.next()
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("expression %q: unexpected end of expression", p.src)
+	}
+	return nil, fmt.Errorf("expression %q: unexpected %q", p.src, t.text)
+}
@@ -0,0 +1,243 @@
+package semantics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestEvaluateGuardExpressions(t *testing.T) {
+	context, err := structpb.NewStruct(map[string]interface{}{
+		"count": 3,
+		"name":  "door",
+		"open":  true,
+		"order": map[string]interface{}{"total": 12.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"count > 2", true, false},
+		{"count >= 4", false, false},
+		{"context.count == 3", true, false},
+		{"count * 2 - 1 == 5", true, false},
+		{"(count + 1) % 2 == 0", true, false},
+		{"name == 'door' && open", true, false},
+		{"!open || count < 0", false, false},
+		{"order.total > 10", true, false},
+		{"name + count == \"door3\"", true, false},
+		{"-count < 0", true, false},
+		{"missing > 0", false, true},
+		{"count / 0", false, true},
+		{"name * 2", false, true},
+		{"count >", false, true},
+		{"size(name)", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvaluateGuard(&sc.Guard{Expression: tt.expr}, context)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvaluateGuard(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateGuard(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionString(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"a + b * c", "(a + (b * c))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"!(a == 'x')", "!(a == \"x\")"},
+		{"context.a.b <= 2.5", "(context.a.b <= 2.5)"},
+		{`in(")")`, `in(")")`},
+		{`f(",", "(")`, `f(",", "(")`},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpression(tt.src)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q) error = %v", tt.src, err)
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("ParseExpression(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		context map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "increment",
+			action:  "count = count + 1",
+			context: map[string]interface{}{"count": 1},
+			want:    map[string]interface{}{"count": 2},
+		},
+		{
+			name:    "multiple assignments",
+			action:  "a = 1; context.b = a + 1;",
+			context: map[string]interface{}{},
+			want:    map[string]interface{}{"a": 1, "b": 2},
+		},
+		{
+			name:    "nested assignment",
+			action:  "order.total = 10",
+			context: map[string]interface{}{},
+			want:    map[string]interface{}{"order": map[string]interface{}{"total": 10}},
+		},
+		{
+			name:    "opaque action",
+			action:  "notifyUser",
+			context: map[string]interface{}{"count": 1},
+			want:    map[string]interface{}{"count": 1},
+		},
+		{
+			name:    "undefined variable",
+			action:  "count = missing",
+			context: map[string]interface{}{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			context, err := structpb.NewStruct(tt.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ExecuteAction(&sc.Action{Label: tt.action}, context)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExecuteAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want, err := structpb.NewStruct(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, context, protocmp.Transform()); diff != "" {
+				t.Errorf("context mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseAssignmentsNotAssignment(t *testing.T) {
+	for _, src := range []string{"log", "count == 1", "1 = 2", `x "=" 1`, `x "." y = 1`} {
+		if _, err := ParseAssignments(src); !errors.Is(err, ErrNotAssignment) {
+			t.Errorf("ParseAssignments(%q) error = %v, want ErrNotAssignment", src, err)
+		}
+	}
+}
+
+func TestParseStringOperators(t *testing.T) {
+	for _, src := range []string{`a "==" b`, `(a ")"`, `f(a "," b)`, `a "." b`} {
+		if e, err := ParseExpression(src); err == nil {
+			t.Errorf("ParseExpression(%q) = %s, want error", src, e)
+		}
+	}
+	if a, err := ParseAssignments(`x = 1 ";" y = 2`); err == nil {
+		t.Errorf("ParseAssignments(%q) = %v, want error", `x = 1 ";" y = 2`, a)
+	}
+}
+
+func TestEvalIn(t *testing.T) {
+	env := Env{
+		Context:       &structpb.Struct{Fields: map[string]*structpb.Value{"count": structpb.NewNumberValue(1)}},
+		Configuration: map[StateLabel]bool{"Playing": true, "Muted": true},
+	}
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{"in(Playing)", true, false},
+		{"in('Muted') && count == 1", true, false},
+		{"!in(Paused)", true, false},
+		{"in(Playing, Muted)", false, true},
+		{"in(a.b)", false, true},
+		{"in(1)", false, true},
+		{`in(")")`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+			got, err := Eval(e, env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if err == nil && truthy(got) != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+
+	// Without a configuration, as in EvaluateGuard, in() fails.
+	if _, err := EvaluateGuard(&sc.Guard{Expression: "in(Playing)"}, env.Context); err == nil {
+		t.Error("EvaluateGuard(in(Playing)) error = nil, want error")
+	}
+}
+
+func TestRaisedEvent(t *testing.T) {
+	tests := []struct {
+		label string
+		event string
+		ok    bool
+	}{
+		{"raise DONE", "DONE", true},
+		{"  raise   DONE ", "DONE", true},
+		{"raise", "", false},
+		{"raise A B", "", false},
+		{"raise = 1", "", false},
+		{"count = count + 1", "", false},
+	}
+	for _, tt := range tests {
+		event, ok := RaisedEvent(&sc.Action{Label: tt.label})
+		if event != tt.event || ok != tt.ok {
+			t.Errorf("RaisedEvent(%q) = %q, %v, want %q, %v", tt.label, event, ok, tt.event, tt.ok)
+		}
+	}
+}
+
+func TestSentEvent(t *testing.T) {
+	tests := []struct {
+		label         string
+		event, target string
+		ok            bool
+	}{
+		{"send DONE to parent", "DONE", "parent", true},
+		{" send  PING  to worker ", "PING", "worker", true},
+		{"send DONE", "", "", false},
+		{"send DONE parent", "", "", false},
+		{"send DONE to", "", "", false},
+		{"raise DONE", "", "", false},
+	}
+	for _, tt := range tests {
+		event, target, ok := SentEvent(&sc.Action{Label: tt.label})
+		if event != tt.event || target != tt.target || ok != tt.ok {
+			t.Errorf("SentEvent(%q) = %q, %q, %v, want %q, %q, %v", tt.label, event, target, ok, tt.event, tt.target, tt.ok)
+		}
+	}
+}
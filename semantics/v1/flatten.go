@@ -0,0 +1,361 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// DefaultMaxFlatStates is the default bound on the number of states of a flattened statechart.
+const DefaultMaxFlatStates = 10000
+
+// ErrStateExplosion is returned when flattening a statechart would produce
+// more states than allowed, or when exploring it would visit more
+// configurations than allowed.
+var ErrStateExplosion = errors.New("semantics: state explosion")
+
+// FlatStatechart is a statechart without hierarchy or orthogonality that
+// behaves like the statechart it was flattened from.
+//
+// Every child of the root of Chart is a basic state standing for one legal
+// configuration of the original statechart. Every transition of Chart stands
+// for one outcome of a microstep of the original: the set of transitions
+// selected in a configuration for an event and a given outcome of their
+// guards. The guard of a flat transition is the conjunction of those guard
+// outcomes and its actions are the actions of the selected transitions in the
+// order in which they are executed. Eventless transitions stay eventless, so a
+// machine of the flat chart settles them in the same way and each of its
+// macro-steps corresponds to a macro-step of the original. Conditions in(State)
+// are decided by the configuration a flat state stands for. Events raised
+// under BroadcastSameStep can enable transitions of the original that the flat
+// chart does not combine, so the correspondence holds for BroadcastNextStep only.
+type FlatStatechart struct {
+	// Chart is the flat statechart.
+	Chart *Statechart
+	// Configurations maps each flat state to the configuration it stands for,
+	// in document order and without the root state.
+	Configurations map[StateLabel][]StateLabel
+	// Transitions maps each flat transition to the transitions of the original
+	// statechart that it fires, in priority order.
+	Transitions map[*sc.Transition][]*sc.Transition
+
+	states map[string]StateLabel
+}
+
+// State returns the flat state standing for the given configuration of the original statechart.
+func (f *FlatStatechart) State(config []StateLabel) (StateLabel, bool) {
+	label, ok := f.states[configurationKey(withoutRoot(config))]
+	return label, ok
+}
+
+// Flatten flattens the statechart, failing with ErrStateExplosion if it has
+// more than DefaultMaxFlatStates legal configurations.
+func Flatten(chart *sc.Statechart) (*FlatStatechart, error) {
+	return FlattenLimit(chart, DefaultMaxFlatStates)
+}
+
+// FlattenLimit flattens the statechart, failing with ErrStateExplosion if it
+// has more than maxStates legal configurations.
+//
+// The states of the flat statechart are all legal configurations of the
+// original, whether reachable or not: the consistent configurations that are
+// closed under default completion. The number of legal configurations is
+// computed before they are enumerated, so that charts exceeding the limit fail
+// without exhausting memory. Charts with choice or junction pseudostates are
+// not supported.
+func FlattenLimit(chart *sc.Statechart, maxStates int) (*FlatStatechart, error) {
+	s := NewStatechart(chart)
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	err := visitStates(s.RootState, func(state *sc.State) error {
+		if IsPseudostate(state) {
+			return fmt.Errorf("pseudostate %s cannot be flattened", state.Label)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	x, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	if n := x.countConfigurations(x.root, maxStates+1); n > maxStates {
+		return nil, fmt.Errorf("%w: more than %d configurations", ErrStateExplosion, maxStates)
+	}
+	initial, _ := x.configurationSet(nil)
+	if err := x.complete(initial); err != nil {
+		return nil, err
+	}
+
+	f := &FlatStatechart{
+		Chart: NewStatechart(&sc.Statechart{
+			Events:    s.Events,
+			RootState: &sc.State{Type: sc.StateTypeNormal},
+		}),
+		Configurations: make(map[StateLabel][]StateLabel),
+		Transitions:    make(map[*sc.Transition][]*sc.Transition),
+		states:         make(map[string]StateLabel),
+	}
+	initialKey := configurationKey(x.configurationLabels(initial))
+	configurations := x.configurations(x.root)
+	for _, active := range configurations {
+		config := x.configurationLabels(active)
+		label := flatLabel(x, active)
+		f.Configurations[label] = config
+		f.states[configurationKey(config)] = label
+		f.Chart.RootState.Children = append(f.Chart.RootState.Children, &sc.State{
+			Label:     string(label),
+			Type:      sc.StateTypeBasic,
+			IsInitial: configurationKey(config) == initialKey,
+			IsFinal:   x.final(active),
+		})
+	}
+
+	events := s.alphabet()
+	for _, active := range configurations {
+		from := flatLabel(x, active)
+		for _, event := range events {
+			candidates := x.enabled(s.Transitions, active, event)
+			outcomes, err := x.outcomes(candidates, active)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range outcomes {
+				next, _, _, err := x.fire(o.selected, active)
+				if err != nil {
+					return nil, err
+				}
+				t := &sc.Transition{
+					Label: fmt.Sprintf("%s/%s", from, strings.Join(transitionLabels(o.selected), "+")),
+					From:  []string{string(from)},
+					To:    []string{string(flatLabel(x, next))},
+					Event: event,
+				}
+				if len(o.guards) > 0 {
+					t.Guard = &sc.Guard{Expression: strings.Join(o.guards, " && ")}
+				}
+				for _, selected := range o.selected {
+					t.Actions = append(t.Actions, selected.Actions...)
+				}
+				f.Chart.Transitions = append(f.Chart.Transitions, t)
+				f.Transitions[t] = o.selected
+			}
+		}
+	}
+	return f, nil
+}
+
+// countConfigurations returns the number of legal configurations of the
+// subtree rooted at state, saturating at limit.
+func (x *chartIndex) countConfigurations(state *sc.State, limit int) int {
+	switch stateType(state) {
+	case sc.StateTypeParallel:
+		n := 1
+		for _, child := range state.Children {
+			n *= x.countConfigurations(child, limit)
+			if n >= limit {
+				return limit
+			}
+		}
+		return n
+	case sc.StateTypeNormal:
+		n := 0
+		for _, child := range state.Children {
+			n += x.countConfigurations(child, limit)
+			if n >= limit {
+				return limit
+			}
+		}
+		return n
+	}
+	return 1
+}
+
+// configurations enumerates the legal configurations of the subtree rooted at
+// state: an AND-state together with a configuration of each of its children,
+// or an OR-state together with a configuration of one of its children.
+func (x *chartIndex) configurations(state *sc.State) []map[StateLabel]bool {
+	label := StateLabel(state.Label)
+	switch stateType(state) {
+	case sc.StateTypeParallel:
+		result := []map[StateLabel]bool{{label: true}}
+		for _, child := range state.Children {
+			var next []map[StateLabel]bool
+			children := x.configurations(child)
+			for _, partial := range result {
+				for _, c := range children {
+					merged := make(map[StateLabel]bool, len(partial)+len(c))
+					for l := range partial {
+						merged[l] = true
+					}
+					for l := range c {
+						merged[l] = true
+					}
+					next = append(next, merged)
+				}
+			}
+			result = next
+		}
+		return result
+	case sc.StateTypeNormal:
+		var result []map[StateLabel]bool
+		for _, child := range state.Children {
+			for _, c := range x.configurations(child) {
+				c[label] = true
+				result = append(result, c)
+			}
+		}
+		return result
+	}
+	return []map[StateLabel]bool{{label: true}}
+}
+
+// outcome is a possible result of selecting transitions: the selected
+// transitions and the guard outcomes that lead to their selection.
+type outcome struct {
+	selected []*sc.Transition
+	guards   []string
+}
+
+// outcomes returns the outcomes of selecting transitions from candidates, which
+// must be in priority order, for every outcome of the guards that matter. The
+// guard of a candidate matters only if it does not conflict with a transition
+// selected before it. Outcomes that select no transition are omitted.
+func (x *chartIndex) outcomes(candidates []*sc.Transition, active map[StateLabel]bool) ([]outcome, error) {
+	var (
+		result []outcome
+		walk   func(i int, selected []*sc.Transition, guards []string) error
+	)
+	walk = func(i int, selected []*sc.Transition, guards []string) error {
+		if i == len(candidates) {
+			if len(selected) > 0 {
+				result = append(result, outcome{selected: selected, guards: guards})
+			}
+			return nil
+		}
+		t := candidates[i]
+		for _, u := range selected {
+			c, err := x.conflict(t, u, active)
+			if err != nil {
+				return err
+			}
+			if c {
+				return walk(i+1, selected, guards)
+			}
+		}
+		taken := append(selected[:len(selected):len(selected)], t)
+		if t.Guard == nil || t.Guard.Expression == "" {
+			return walk(i+1, taken, guards)
+		}
+		expr, known, holds, err := resolveIn(t.Guard.Expression, active)
+		if err != nil {
+			return fmt.Errorf("transition %s: %w", t.Label, err)
+		}
+		if known {
+			if holds {
+				return walk(i+1, taken, guards)
+			}
+			return walk(i+1, selected, guards)
+		}
+		guard := "(" + expr + ")"
+		if err := walk(i+1, taken, append(guards[:len(guards):len(guards)], guard)); err != nil {
+			return err
+		}
+		return walk(i+1, selected, append(guards[:len(guards):len(guards)], "!"+guard))
+	}
+	if err := walk(0, nil, nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// flatLabel returns the label of the flat state standing for a configuration:
+// the labels of its basic states in document order, joined by "+".
+func flatLabel(x *chartIndex, active map[StateLabel]bool) StateLabel {
+	var basic []string
+	for _, label := range x.sorted(active) {
+		if stateType(x.states[label]) == sc.StateTypeBasic {
+			basic = append(basic, string(label))
+		}
+	}
+	return StateLabel(strings.Join(basic, "+"))
+}
+
+// transitionLabels returns the labels of the given transitions.
+func transitionLabels(transitions []*sc.Transition) []string {
+	var labels []string
+	for _, t := range transitions {
+		labels = append(labels, t.Label)
+	}
+	return labels
+}
+
+// withoutRoot returns the configuration without the root state.
+func withoutRoot(config []StateLabel) []StateLabel {
+	var result []StateLabel
+	for _, label := range config {
+		if label != RootState {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// resolveIn replaces the in() conditions of a guard by their value in the
+// configuration. If the guard then refers to no variables, it is decided: known
+// is set and holds is its value.
+func resolveIn(guard string, active map[StateLabel]bool) (expr string, known, holds bool, err error) {
+	e, err := ParseExpression(guard)
+	if err != nil {
+		return "", false, false, err
+	}
+	e, resolved, variables, err := substituteIn(e, active)
+	if err != nil {
+		return "", false, false, err
+	}
+	if !resolved {
+		return guard, false, false, nil
+	}
+	if variables {
+		return e.String(), false, false, nil
+	}
+	v, err := Eval(e, Env{})
+	if err != nil {
+		return "", false, false, err
+	}
+	return e.String(), true, truthy(v), nil
+}
+
+// substituteIn replaces the in() conditions of an expression by literals. It
+// reports whether there were any and whether the expression refers to variables.
+func substituteIn(e Expr, active map[StateLabel]bool) (result Expr, resolved, variables bool, err error) {
+	switch e := e.(type) {
+	case *Ident:
+		return e, false, true, nil
+	case *Unary:
+		x, resolved, variables, err := substituteIn(e.X, active)
+		return &Unary{Op: e.Op, X: x}, resolved, variables, err
+	case *Binary:
+		x, rx, vx, err := substituteIn(e.X, active)
+		if err != nil {
+			return nil, false, false, err
+		}
+		y, ry, vy, err := substituteIn(e.Y, active)
+		return &Binary{Op: e.Op, X: x, Y: y}, rx || ry, vx || vy, err
+	case *Call:
+		if e.Func == "in" {
+			state, err := InState(e)
+			if err != nil {
+				return nil, false, false, err
+			}
+			return &Literal{Value: structpb.NewBoolValue(active[state])}, true, false, nil
+		}
+		return e, false, true, nil
+	}
+	return e, false, false, nil
+}
@@ -0,0 +1,218 @@
+package semantics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFlatten(t *testing.T) {
+	flat, err := Flatten(turnstileStatechart.Statechart)
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+
+	var states []string
+	for _, s := range flat.Chart.RootState.Children {
+		if len(s.Children) != 0 {
+			t.Errorf("flat state %s has children", s.Label)
+		}
+		if s.IsInitial {
+			states = append(states, s.Label+" (initial)")
+			continue
+		}
+		states = append(states, s.Label)
+	}
+	wantStates := []string{
+		"Off (initial)",
+		"Blocked+Ready",
+		"Blocked+Card Entered",
+		"Blocked+Turnstile Unblocked",
+		"Unblocked+Ready",
+		"Unblocked+Card Entered",
+		"Unblocked+Turnstile Unblocked",
+	}
+	if diff := cmp.Diff(wantStates, states); diff != "" {
+		t.Errorf("states mismatch (-want +got):\n%s", diff)
+	}
+	want := CreateStateLabels("On", "Turnstile Control", "Unblocked", "Card Reader Control", "Card Entered")
+	if diff := cmp.Diff(want, flat.Configurations["Unblocked+Card Entered"]); diff != "" {
+		t.Errorf("Configurations mismatch (-want +got):\n%s", diff)
+	}
+	if got, ok := flat.State(append([]StateLabel{RootState}, want...)); !ok || got != "Unblocked+Card Entered" {
+		t.Errorf("State() = %q, %v", got, ok)
+	}
+
+	type flatTransition struct {
+		Label, To, Event, Guard string
+		Fires                   []string
+	}
+	var got []flatTransition
+	for _, tr := range flat.Chart.Transitions {
+		if tr.From[0] != "Blocked+Ready" && tr.From[0] != "Blocked+Turnstile Unblocked" {
+			continue
+		}
+		got = append(got, flatTransition{tr.Label, tr.To[0], tr.Event, tr.GetGuard().GetExpression(), transitionLabels(flat.Transitions[tr])})
+	}
+	wantTransitions := []flatTransition{
+		{"Blocked+Ready/jam", "Unblocked+Ready", "TURN_OFF", "", []string{"jam"}},
+		{"Blocked+Ready/card", "Blocked+Card Entered", "CARD", "", []string{"card"}},
+		{"Blocked+Ready/unblock", "Unblocked+Ready", "UNBLOCK", "", []string{"unblock"}},
+		{"Blocked+Ready/restart", "Blocked+Ready", "RESTART", "", []string{"restart"}},
+		{"Blocked+Turnstile Unblocked/jam", "Unblocked+Turnstile Unblocked", "TURN_OFF", "", []string{"jam"}},
+		{"Blocked+Turnstile Unblocked/unblock+reset_reader", "Unblocked+Ready", "UNBLOCK", "", []string{"unblock", "reset_reader"}},
+		{"Blocked+Turnstile Unblocked/restart", "Blocked+Ready", "RESTART", "", []string{"restart"}},
+	}
+	if diff := cmp.Diff(wantTransitions, got); diff != "" {
+		t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlattenGuardOutcomes(t *testing.T) {
+	flat, err := Flatten(counterStatechart.Statechart)
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	var got []string
+	for _, tr := range flat.Chart.Transitions {
+		got = append(got, tr.Label+" ["+tr.GetGuard().GetExpression()+"]")
+	}
+	want := []string{
+		"Counting/inc [(count < limit)]",
+		"Counting/full [!(count < limit)]",
+		"Full/finish []",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlattenIn(t *testing.T) {
+	flat, err := Flatten(crossingStatechart.Statechart)
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	labels := make(map[string]bool)
+	for _, tr := range flat.Chart.Transitions {
+		if g := tr.GetGuard().GetExpression(); g != "" {
+			t.Errorf("transition %s has guard %q, want in() conditions decided", tr.Label, g)
+		}
+		labels[tr.Label] = true
+	}
+	// go requires DontWalk and walk requires Red.
+	for label, want := range map[string]bool{
+		"Red+DontWalk+Quiet/go":          true,
+		"Red+Walk+Quiet/go":              false,
+		"Red+Walk+Quiet/dont":            true,
+		"Red+DontWalk+Quiet/walk+beep":   true,
+		"Green+DontWalk+Quiet/walk+beep": false,
+		"Green+DontWalk+Quiet/beep":      true,
+	} {
+		if labels[label] != want {
+			t.Errorf("transition %s present = %v, want %v", label, labels[label], want)
+		}
+	}
+}
+
+func TestFlattenEquivalence(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   *Statechart
+		context map[string]interface{}
+		events  []string
+	}{
+		{"counter", counterStatechart, map[string]interface{}{"count": 0, "limit": 2}, []string{"INC", "OTHER", "INC", "INC", "INC"}},
+		{"turnstile", turnstileStatechart, nil, []string{"TURN_ON", "CARD", "CARD_OK", "UNBLOCK", "PASS", "TURN_OFF", "TURN_OFF", "TURN_ON", "RESTART"}},
+		{"crossing", crossingStatechart, nil, []string{"TIMER", "BUTTON", "TIMER", "TIMER", "BUTTON", "TIMER", "TIMER"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flat, err := Flatten(tt.chart.Statechart)
+			if err != nil {
+				t.Fatalf("Flatten() error = %v", err)
+			}
+			context, err := structpb.NewStruct(tt.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := NewEngine()
+			original, err := engine.NewMachine("original", tt.chart, context)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			flattened, err := engine.NewMachine("flat", flat.Chart, context)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			compare := func(step int) {
+				t.Helper()
+				var config []StateLabel
+				for _, label := range configurationStrings(original.Configuration) {
+					config = append(config, StateLabel(label))
+				}
+				want, ok := flat.State(config)
+				if !ok {
+					t.Fatalf("step %d: no flat state for %v", step, config)
+				}
+				if diff := cmp.Diff([]string{"__root__", string(want)}, configurationStrings(flattened.Configuration)); diff != "" {
+					t.Errorf("step %d: configuration mismatch (-want +got):\n%s", step, diff)
+				}
+				if diff := cmp.Diff(original.Context.AsMap(), flattened.Context.AsMap()); diff != "" {
+					t.Errorf("step %d: context mismatch (-want +got):\n%s", step, diff)
+				}
+				if original.State != flattened.State {
+					t.Errorf("step %d: state = %v, want %v", step, flattened.State, original.State)
+				}
+			}
+			compare(0)
+			for i, event := range tt.events {
+				if original.State == sc.MachineStateStopped {
+					break
+				}
+				if _, err := engine.Step(original, event); err != nil {
+					t.Fatalf("step %d: Step(%s) error = %v", i+1, event, err)
+				}
+				if _, err := engine.Step(flattened, event); err != nil {
+					t.Fatalf("step %d: flat Step(%s) error = %v", i+1, event, err)
+				}
+				compare(i + 1)
+			}
+		})
+	}
+}
+
+func TestFlattenStateExplosion(t *testing.T) {
+	region := func(name string) *sc.State {
+		return &sc.State{Label: name, Children: []*sc.State{
+			{Label: name + "1", IsInitial: true},
+			{Label: name + "2"},
+			{Label: name + "3"},
+		}}
+	}
+	chart := &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "Product", Type: sc.StateTypeParallel, IsInitial: true, Children: []*sc.State{region("A"), region("B"), region("C")}},
+		}},
+	}
+	if _, err := FlattenLimit(chart, 20); !errors.Is(err, ErrStateExplosion) {
+		t.Errorf("FlattenLimit(20) error = %v, want %v", err, ErrStateExplosion)
+	}
+	flat, err := FlattenLimit(chart, 27)
+	if err != nil {
+		t.Fatalf("FlattenLimit(27) error = %v", err)
+	}
+	if got := len(flat.Configurations); got != 27 {
+		t.Errorf("got %d configurations, want 27", got)
+	}
+}
+
+func TestFlattenPseudostates(t *testing.T) {
+	chart := orderStatechart(sc.StateTypeChoice, &sc.Transition{Label: "paid", From: []string{"Discount"}, To: []string{"Small"}, Guard: &sc.Guard{Expression: "else"}})
+	if _, err := Flatten(chart.Statechart); err == nil || !strings.Contains(err.Error(), "pseudostate Size cannot be flattened") {
+		t.Errorf("Flatten() error = %v, want an error about pseudostate Size", err)
+	}
+}
@@ -0,0 +1,145 @@
+package semantics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ElseGuard is the guard expression of the branch of a pseudostate that is
+// taken when no other branch is enabled.
+const ElseGuard = "else"
+
+// IsElse reports whether a guard is the else guard.
+func IsElse(guard *sc.Guard) bool {
+	return strings.TrimSpace(guard.GetExpression()) == ElseGuard
+}
+
+// IsPseudostate reports whether a state is a choice or junction pseudostate.
+//
+// A pseudostate is a transient vertex that joins transition segments into a
+// compound transition. It is entered like a state, but left within the same
+// microstep through the first of its outgoing transitions, its branches,
+// whose guard holds, or else through its else branch. A junction selects its
+// branch before the compound transition fires, so its guards see the
+// configuration and context the microstep started with; a transition leading
+// to a junction without an enabled branch is not enabled. A choice selects its
+// branch once the transition entering it has fired, so its guards see the
+// effects of that transition's actions; a choice without an enabled branch is
+// an error.
+func IsPseudostate(state *sc.State) bool {
+	switch stateType(state) {
+	case sc.StateTypeChoice, sc.StateTypeJunction:
+		return true
+	}
+	return false
+}
+
+// branch selects the branch taken from a pseudostate: the first transition
+// leaving it whose guard holds, or its else branch. It returns nil if no branch
+// is enabled.
+func (e *Engine) branch(transitions []*sc.Transition, pseudostate StateLabel, context *structpb.Struct, active map[StateLabel]bool, rec *stepRecord) (*sc.Transition, error) {
+	var otherwise *sc.Transition
+	for _, t := range transitions {
+		if len(t.From) != 1 || StateLabel(t.From[0]) != pseudostate {
+			continue
+		}
+		if IsElse(t.Guard) {
+			if otherwise == nil {
+				otherwise = t
+			}
+			continue
+		}
+		holds, err := e.evaluateGuard(t.Guard, context, active)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate guard of %s: %w", t.Label, err)
+		}
+		rec.guard(t, holds)
+		if holds {
+			return t, nil
+		}
+	}
+	return otherwise, nil
+}
+
+// selectJunctionBranches selects the branches of the junctions t leads to,
+// following branches that lead to further junctions, and adds them to
+// junctions. It reports whether every junction has an enabled branch; if not,
+// t is not enabled.
+func (e *Engine) selectJunctionBranches(x *chartIndex, transitions []*sc.Transition, t *sc.Transition, context *structpb.Struct, active map[StateLabel]bool, rec *stepRecord, junctions map[StateLabel]*sc.Transition) (bool, error) {
+	visited := make(map[StateLabel]bool)
+	var visit func(t *sc.Transition) (bool, error)
+	visit = func(t *sc.Transition) (bool, error) {
+		for _, to := range t.To {
+			label := StateLabel(to)
+			state, ok := x.states[label]
+			if !ok || stateType(state) != sc.StateTypeJunction {
+				continue
+			}
+			if visited[label] {
+				return false, fmt.Errorf("transition %s: junction %s is part of a cycle", t.Label, label)
+			}
+			visited[label] = true
+			b, err := e.branch(transitions, label, context, active, rec)
+			if err != nil || b == nil {
+				return false, err
+			}
+			junctions[label] = b
+			if ok, err := visit(b); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return visit(t)
+}
+
+// leavePseudostates fires branches of the active pseudostates until none is
+// active. Junctions take the branches selected before the microstep, if any;
+// choices select theirs in the current configuration and context. It returns
+// the resulting configuration, the fired branches and the events they raise.
+func (e *Engine) leavePseudostates(x *chartIndex, transitions []*sc.Transition, active map[StateLabel]bool, context *structpb.Struct, rec *stepRecord, junctions map[StateLabel]*sc.Transition) (map[StateLabel]bool, []*sc.Transition, []string, error) {
+	var fired []*sc.Transition
+	var raised []string
+	for i := 0; ; i++ {
+		var pseudostate StateLabel
+		for _, label := range x.sorted(active) {
+			if IsPseudostate(x.states[label]) {
+				pseudostate = label
+				break
+			}
+		}
+		if pseudostate == "" {
+			return active, fired, raised, nil
+		}
+		if i >= maxMicrosteps {
+			return nil, nil, nil, fmt.Errorf("%w: more than %d pseudostates traversed", ErrMicrostepLimit, maxMicrosteps)
+		}
+		t, ok := junctions[pseudostate]
+		delete(junctions, pseudostate)
+		if !ok {
+			var err error
+			if t, err = e.branch(transitions, pseudostate, context, active, rec); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		if t == nil {
+			return nil, nil, nil, fmt.Errorf("pseudostate %s has no enabled branch", pseudostate)
+		}
+		more, err := e.execute(t, context)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		raised = append(raised, more...)
+		next, _, entered, err := x.fire([]*sc.Transition{t}, active)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rec.enter(entered)
+		rec.fire([]*sc.Transition{t})
+		fired = append(fired, t)
+		active = next
+	}
+}
@@ -0,0 +1,139 @@
+package semantics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// orderStatechart routes an order through a junction on its amount and a
+// choice on the discount its first branch grants.
+func orderStatechart(kind sc.StateType, branches ...*sc.Transition) *Statechart {
+	return NewStatechart(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "Draft", IsInitial: true},
+			{Label: "Size", Type: sc.StateTypeJunction},
+			{Label: "Discount", Type: kind},
+			{Label: "Small"},
+			{Label: "Large"},
+			{Label: "Free"},
+		}},
+		Transitions: append([]*sc.Transition{
+			{Label: "submit", From: []string{"Draft"}, To: []string{"Size"}, Event: "SUBMIT"},
+			{Label: "small", From: []string{"Size"}, To: []string{"Discount"}, Guard: &sc.Guard{Expression: "amount < 100"}, Actions: []*sc.Action{{Label: "amount = amount - 50"}}},
+			{Label: "large", From: []string{"Size"}, To: []string{"Large"}, Guard: &sc.Guard{Expression: "else"}},
+			{Label: "free", From: []string{"Discount"}, To: []string{"Free"}, Guard: &sc.Guard{Expression: "amount <= 0"}},
+		}, branches...),
+	})
+}
+
+func TestEnginePseudostates(t *testing.T) {
+	otherwise := &sc.Transition{Label: "paid", From: []string{"Discount"}, To: []string{"Small"}, Guard: &sc.Guard{Expression: "else"}}
+	tests := []struct {
+		name        string
+		kind        sc.StateType
+		amount      float64
+		transitions []string
+		config      string
+	}{
+		{"junction to large", sc.StateTypeChoice, 500, []string{"submit", "large"}, "Large"},
+		{"choice sees actions", sc.StateTypeChoice, 30, []string{"submit", "small", "free"}, "Free"},
+		{"choice else", sc.StateTypeChoice, 80, []string{"submit", "small", "paid"}, "Small"},
+		// As a junction, Discount sees the amount before the small branch lowers it.
+		{"junction before actions", sc.StateTypeJunction, 30, []string{"submit", "small", "paid"}, "Small"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := orderStatechart(tt.kind, otherwise)
+			if err := chart.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			context, err := structpb.NewStruct(map[string]interface{}{"amount": tt.amount})
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := NewEngine()
+			m, err := engine.NewMachine("order", chart, context)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			step, err := engine.Step(m, "SUBMIT")
+			if err != nil {
+				t.Fatalf("Step() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.transitions, transitionLabels(step.Transitions)); diff != "" {
+				t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff([]string{"__root__", tt.config}, configurationStrings(m.Configuration)); diff != "" {
+				t.Errorf("configuration mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnginePseudostateErrors(t *testing.T) {
+	t.Run("junction without enabled branch", func(t *testing.T) {
+		chart := NewStatechart(&sc.Statechart{
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "A", IsInitial: true},
+				{Label: "J", Type: sc.StateTypeJunction},
+				{Label: "B"},
+				{Label: "C"},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "to_junction", From: []string{"A"}, To: []string{"J"}, Event: "GO"},
+				{Label: "never", From: []string{"J"}, To: []string{"B"}, Guard: &sc.Guard{Expression: "false"}},
+				{Label: "fallback", From: []string{"A"}, To: []string{"C"}, Event: "GO"},
+			},
+		})
+		engine := NewEngine()
+		m, err := engine.NewMachine("m", chart, nil)
+		if err != nil {
+			t.Fatalf("NewMachine() error = %v", err)
+		}
+		step, err := engine.Step(m, "GO")
+		if err != nil {
+			t.Fatalf("Step() error = %v", err)
+		}
+		// The transition to the junction is not enabled, so the next one fires.
+		if diff := cmp.Diff([]string{"fallback"}, transitionLabels(step.Transitions)); diff != "" {
+			t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+		}
+	})
+	tests := []struct {
+		name    string
+		kind    sc.StateType
+		back    string
+		wantErr string
+	}{
+		{"choice without enabled branch", sc.StateTypeChoice, "false", "pseudostate P has no enabled branch"},
+		{"junction cycle", sc.StateTypeJunction, "true", "junction P is part of a cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := NewStatechart(&sc.Statechart{
+				RootState: &sc.State{Children: []*sc.State{
+					{Label: "A", IsInitial: true},
+					{Label: "P", Type: tt.kind},
+					{Label: "Q", Type: sc.StateTypeJunction},
+				}},
+				Transitions: []*sc.Transition{
+					{Label: "enter", From: []string{"A"}, To: []string{"P"}, Event: "GO"},
+					{Label: "forth", From: []string{"P"}, To: []string{"Q"}, Guard: &sc.Guard{Expression: tt.back}},
+					{Label: "back", From: []string{"Q"}, To: []string{"P"}},
+				},
+			})
+			engine := NewEngine()
+			m, err := engine.NewMachine("m", chart, nil)
+			if err != nil {
+				t.Fatalf("NewMachine() error = %v", err)
+			}
+			if _, err := engine.Step(m, "GO"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Step() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
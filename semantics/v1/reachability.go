@@ -0,0 +1,246 @@
+package semantics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tmc/sc"
+)
+
+// maxGuardAssumptions bounds the number of guarded transitions whose outcomes
+// are enumerated for a single configuration and event. Guards beyond the bound
+// are assumed to hold.
+const maxGuardAssumptions = 10
+
+// DefaultMaxConfigurations is the default bound on the number of configurations explored by Reachability.
+const DefaultMaxConfigurations = 10000
+
+// DeadTransitionReason describes why a transition can never fire.
+type DeadTransitionReason int
+
+const (
+	// SourceUnreachable means that no reachable configuration contains a source of the transition.
+	SourceUnreachable DeadTransitionReason = iota + 1
+	// Shadowed means that whenever the transition is enabled, a conflicting
+	// transition of higher priority is enabled and fires instead.
+	Shadowed
+)
+
+// String returns a description of the reason.
+func (r DeadTransitionReason) String() string {
+	switch r {
+	case SourceUnreachable:
+		return "source unreachable"
+	case Shadowed:
+		return "shadowed by a higher-priority transition"
+	}
+	return fmt.Sprintf("DeadTransitionReason(%d)", int(r))
+}
+
+// DeadTransition is a transition that can never fire.
+type DeadTransition struct {
+	Transition *sc.Transition
+	Reason     DeadTransitionReason
+}
+
+// Reachability is the result of exploring the configuration graph of a statechart.
+type Reachability struct {
+	// Configurations lists the reachable configurations in the order they were discovered,
+	// starting with the initial configuration.
+	Configurations [][]StateLabel
+	// UnreachableStates lists the states that are not part of any reachable configuration.
+	UnreachableStates []StateLabel
+	// DeadTransitions lists the transitions that can never fire.
+	DeadTransitions []DeadTransition
+	// Deadlocks lists the reachable configurations that enable no transition
+	// but are not final. A configuration is final when all of its basic states are final.
+	Deadlocks [][]StateLabel
+}
+
+// Reachability explores the configuration graph of the statechart, failing
+// with ErrStateExplosion if it has more than DefaultMaxConfigurations
+// reachable configurations.
+func (s *Statechart) Reachability() (*Reachability, error) {
+	return s.ReachabilityLimit(context.Background(), DefaultMaxConfigurations)
+}
+
+// ReachabilityLimit explores the configuration graph of the statechart from
+// its initial configuration and reports unreachable states, dead transitions
+// and deadlocks. It fails with ErrStateExplosion if the statechart has more
+// than maxConfigurations reachable configurations, and with the error of the
+// context if it is done before the exploration is.
+//
+// Guards are treated as unknown: every outcome of the guards of the enabled
+// transitions is considered possible. Transitions without an event are treated
+// as spontaneous and are explored like any other event. Pseudostates are
+// explored like basic states left through their branches, so the
+// configurations include the transient ones holding a pseudostate.
+func (s *Statechart) ReachabilityLimit(ctx context.Context, maxConfigurations int) (*Reachability, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	x, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	initial, _ := x.configurationSet(nil)
+	if err := x.complete(initial); err != nil {
+		return nil, err
+	}
+
+	events := s.alphabet()
+	result := &Reachability{}
+	seen := map[string]bool{configurationKey(x.sorted(initial)): true}
+	queue := []map[StateLabel]bool{initial}
+	reachedStates := make(map[StateLabel]bool)
+	enabledSomewhere := make(map[*sc.Transition]bool)
+	fired := make(map[*sc.Transition]bool)
+
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		active := queue[0]
+		queue = queue[1:]
+		result.Configurations = append(result.Configurations, x.configurationLabels(active))
+		for label := range active {
+			reachedStates[label] = true
+		}
+
+		var outgoing bool
+		for _, event := range events {
+			candidates := x.enabled(s.Transitions, active, event)
+			if len(candidates) == 0 {
+				continue
+			}
+			outgoing = true
+			for _, t := range candidates {
+				enabledSomewhere[t] = true
+			}
+			for _, assumption := range guardAssumptions(candidates) {
+				selected, err := x.selectTransitions(candidates, active, func(t *sc.Transition) (bool, error) {
+					return assumption[t], nil
+				})
+				if err != nil {
+					return nil, err
+				}
+				if len(selected) == 0 {
+					continue
+				}
+				for _, t := range selected {
+					fired[t] = true
+				}
+				next, _, _, err := x.fire(selected, active)
+				if err != nil {
+					return nil, err
+				}
+				key := configurationKey(x.sorted(next))
+				if !seen[key] {
+					if len(seen) >= maxConfigurations {
+						return nil, fmt.Errorf("%w: more than %d configurations", ErrStateExplosion, maxConfigurations)
+					}
+					seen[key] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		if !outgoing && !x.final(active) {
+			result.Deadlocks = append(result.Deadlocks, x.configurationLabels(active))
+		}
+	}
+
+	for _, label := range x.sorted(allStates(x)) {
+		if !reachedStates[label] {
+			result.UnreachableStates = append(result.UnreachableStates, label)
+		}
+	}
+	for _, t := range s.Transitions {
+		switch {
+		case !enabledSomewhere[t]:
+			result.DeadTransitions = append(result.DeadTransitions, DeadTransition{Transition: t, Reason: SourceUnreachable})
+		case !fired[t]:
+			result.DeadTransitions = append(result.DeadTransitions, DeadTransition{Transition: t, Reason: Shadowed})
+		}
+	}
+	return result, nil
+}
+
+// alphabet returns the events of the statechart together with the events used
+// by its transitions, and the empty event if any transition is spontaneous.
+func (s *Statechart) alphabet() []string {
+	seen := make(map[string]bool)
+	var events []string
+	add := func(event string) {
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	for _, e := range s.Events {
+		add(e.Label)
+	}
+	for _, t := range s.Transitions {
+		add(t.Event)
+	}
+	return events
+}
+
+// guardAssumptions enumerates the possible outcomes of the guards of the given
+// transitions. Unguarded transitions are always enabled.
+func guardAssumptions(transitions []*sc.Transition) []map[*sc.Transition]bool {
+	var guarded []*sc.Transition
+	base := make(map[*sc.Transition]bool)
+	for _, t := range transitions {
+		if t.Guard != nil && t.Guard.Expression != "" && len(guarded) < maxGuardAssumptions {
+			guarded = append(guarded, t)
+			continue
+		}
+		base[t] = true
+	}
+	assumptions := make([]map[*sc.Transition]bool, 0, 1<<len(guarded))
+	for bits := 0; bits < 1<<len(guarded); bits++ {
+		assumption := make(map[*sc.Transition]bool, len(transitions))
+		for t := range base {
+			assumption[t] = true
+		}
+		for i, t := range guarded {
+			assumption[t] = bits&(1<<i) != 0
+		}
+		assumptions = append(assumptions, assumption)
+	}
+	return assumptions
+}
+
+// final reports whether every active basic state is final.
+func (x *chartIndex) final(active map[StateLabel]bool) bool {
+	for label := range active {
+		state := x.states[label]
+		if stateType(state) == sc.StateTypeBasic && !state.IsFinal {
+			return false
+		}
+	}
+	return true
+}
+
+// allStates returns the set of all states except the root.
+func allStates(x *chartIndex) map[StateLabel]bool {
+	set := make(map[StateLabel]bool, len(x.states))
+	for label, state := range x.states {
+		if state != x.root {
+			set[label] = true
+		}
+	}
+	return set
+}
+
+// configurationKey returns a canonical key for a configuration.
+func configurationKey(config []StateLabel) string {
+	labels := make([]string, len(config))
+	for i, label := range config {
+		labels[i] = string(label)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, "\x00")
+}
@@ -0,0 +1,148 @@
+package semantics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+)
+
+func TestReachability(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "Idle", IsInitial: true},
+				{
+					Label: "Busy",
+					Children: []*sc.State{
+						{Label: "Working", IsInitial: true},
+						{Label: "Stuck"},
+					},
+				},
+				{Label: "Done", IsFinal: true},
+				{Label: "Orphan"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "start", From: []string{"Idle"}, To: []string{"Busy"}, Event: "GO"},
+			{Label: "finish", From: []string{"Working"}, To: []string{"Done"}, Event: "FINISH", Guard: &sc.Guard{Expression: "ready"}},
+			{Label: "jam", From: []string{"Working"}, To: []string{"Stuck"}, Event: "JAM"},
+			{Label: "abort", From: []string{"Busy"}, To: []string{"Idle"}, Event: "ABORT"},
+			{Label: "shadowed", From: []string{"Working"}, To: []string{"Done"}, Event: "JAM"},
+			{Label: "from_orphan", From: []string{"Orphan"}, To: []string{"Idle"}, Event: "GO"},
+			{Label: "second_start", From: []string{"Idle"}, To: []string{"Done"}, Event: "GO"},
+		},
+	})
+
+	got, err := chart.Reachability()
+	if err != nil {
+		t.Fatalf("Reachability() error = %v", err)
+	}
+
+	wantConfigurations := [][]StateLabel{
+		CreateStateLabels("Idle"),
+		CreateStateLabels("Busy", "Working"),
+		CreateStateLabels("Done"),
+		CreateStateLabels("Busy", "Stuck"),
+	}
+	if diff := cmp.Diff(wantConfigurations, got.Configurations); diff != "" {
+		t.Errorf("Configurations mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(CreateStateLabels("Orphan"), got.UnreachableStates); diff != "" {
+		t.Errorf("UnreachableStates mismatch (-want +got):\n%s", diff)
+	}
+
+	var dead []string
+	for _, d := range got.DeadTransitions {
+		dead = append(dead, d.Transition.Label+": "+d.Reason.String())
+	}
+	wantDead := []string{
+		"shadowed: shadowed by a higher-priority transition",
+		"from_orphan: source unreachable",
+		"second_start: shadowed by a higher-priority transition",
+	}
+	if diff := cmp.Diff(wantDead, dead); diff != "" {
+		t.Errorf("DeadTransitions mismatch (-want +got):\n%s", diff)
+	}
+
+	// Stuck can still be aborted and Done is final, so there are no deadlocks.
+	if diff := cmp.Diff([][]StateLabel(nil), got.Deadlocks); diff != "" {
+		t.Errorf("Deadlocks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReachabilityDeadlock(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "A", IsInitial: true},
+				{Label: "B"},
+			},
+		},
+		Transitions: []*sc.Transition{
+			{Label: "t1", From: []string{"A"}, To: []string{"B"}, Event: "E"},
+		},
+	})
+	got, err := chart.Reachability()
+	if err != nil {
+		t.Fatalf("Reachability() error = %v", err)
+	}
+	if diff := cmp.Diff([][]StateLabel{CreateStateLabels("B")}, got.Deadlocks); diff != "" {
+		t.Errorf("Deadlocks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReachabilityInvalidChart(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Type: sc.StateTypeNormal,
+			Children: []*sc.State{
+				{Label: "A"},
+				{Label: "B"},
+			},
+		},
+	})
+	if _, err := chart.Reachability(); err == nil {
+		t.Error("Reachability() expected error for chart without default state")
+	}
+}
+
+// regions returns a statechart of n orthogonal regions of two states each,
+// which has 2^n reachable configurations.
+func regions(n int) *Statechart {
+	chart := &sc.Statechart{RootState: &sc.State{Children: []*sc.State{{Label: "P", IsInitial: true, Type: sc.StateTypeParallel}}}}
+	for i := 0; i < n; i++ {
+		off, on := fmt.Sprintf("Off%d", i), fmt.Sprintf("On%d", i)
+		chart.RootState.Children[0].Children = append(chart.RootState.Children[0].Children, &sc.State{
+			Label:    fmt.Sprintf("R%d", i),
+			Children: []*sc.State{{Label: off, IsInitial: true}, {Label: on}},
+		})
+		chart.Transitions = append(chart.Transitions, &sc.Transition{
+			Label: fmt.Sprintf("toggle%d", i), From: []string{off}, To: []string{on}, Event: fmt.Sprintf("E%d", i),
+		})
+	}
+	return NewStatechart(chart)
+}
+
+func TestReachabilityLimit(t *testing.T) {
+	chart := regions(4)
+	got, err := chart.ReachabilityLimit(context.Background(), 16)
+	if err != nil {
+		t.Fatalf("ReachabilityLimit(16) error = %v", err)
+	}
+	if len(got.Configurations) != 16 {
+		t.Errorf("ReachabilityLimit(16) explored %d configurations, want 16", len(got.Configurations))
+	}
+	if _, err := chart.ReachabilityLimit(context.Background(), 15); !errors.Is(err, ErrStateExplosion) {
+		t.Errorf("ReachabilityLimit(15) error = %v, want %v", err, ErrStateExplosion)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := regions(30).ReachabilityLimit(ctx, 1<<30); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReachabilityLimit() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
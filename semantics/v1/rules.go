@@ -0,0 +1,262 @@
+package semantics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tmc/sc"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// Rule is a validation rule of statecharts. The same rules are checked by
+// Validate in process and by the SemanticValidator service.
+type Rule interface {
+	// ID identifies the rule in violations and severity overrides. The IDs
+	// of built-in rules are the names of their RuleId values.
+	ID() string
+	// Description describes what the rule requires of statecharts.
+	Description() string
+	// DefaultSeverity is the severity of violations of the rule unless a
+	// request overrides it.
+	DefaultSeverity() validationv1.Severity
+	// Check returns every violation of the rule by a statechart, with the
+	// xpaths of the offending elements. Check sets their rule and severity.
+	Check(chart *Chart) []*validationv1.Violation
+}
+
+// Chart is a statechart under validation. It caches analyses shared by rules.
+type Chart struct {
+	*sc.Statechart
+	// MaxConfigurations bounds the number of configurations explored by
+	// Reachability. If zero, DefaultMaxConfigurations is used.
+	MaxConfigurations int
+
+	ctx          context.Context
+	explored     bool
+	reachability *Reachability
+	err          error
+
+	indexed     bool
+	states      map[*sc.State]string
+	transitions map[*sc.Transition]string
+	labels      map[string][]string
+}
+
+// Reachability returns the exploration of the configuration graph of the
+// statechart. It fails if the statechart is not well-formed enough to be
+// explored, with ErrStateExplosion if it has more than MaxConfigurations
+// reachable configurations, or if the context of Check is done.
+func (c *Chart) Reachability() (*Reachability, error) {
+	if !c.explored {
+		c.explored = true
+		ctx := c.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		maxConfigurations := c.MaxConfigurations
+		if maxConfigurations == 0 {
+			maxConfigurations = DefaultMaxConfigurations
+		}
+		c.reachability, c.err = c.semantics().ReachabilityLimit(ctx, maxConfigurations)
+	}
+	return c.reachability, c.err
+}
+
+// semantics returns the statechart to analyze. Unlike NewStatechart, it
+// leaves the root state as it is, so that rules can check it.
+func (c *Chart) semantics() *Statechart {
+	return &Statechart{Statechart: c.Statechart}
+}
+
+// StatePath returns the xpath of a state of the statechart, such as
+// /root_state/children[2]/children[0], or "" if it is not one of its states.
+func (c *Chart) StatePath(state *sc.State) string {
+	c.index()
+	return c.states[state]
+}
+
+// TransitionPath returns the xpath of a transition of the statechart, such
+// as /transitions[3], or "" if it is not one of its transitions.
+func (c *Chart) TransitionPath(transition *sc.Transition) string {
+	c.index()
+	return c.transitions[transition]
+}
+
+// labelPaths returns the xpaths of the states with a label.
+func (c *Chart) labelPaths(label string) []string {
+	c.index()
+	return c.labels[label]
+}
+
+// index records the xpaths of the states and transitions of the statechart.
+func (c *Chart) index() {
+	if c.indexed {
+		return
+	}
+	c.indexed = true
+	c.states = make(map[*sc.State]string)
+	c.transitions = make(map[*sc.Transition]string)
+	c.labels = make(map[string][]string)
+	c.walk(func(state *sc.State, path string) {
+		c.states[state] = path
+		c.labels[state.Label] = append(c.labels[state.Label], path)
+	})
+	for i, transition := range c.Transitions {
+		c.transitions[transition] = fmt.Sprintf("/transitions[%d]", i)
+	}
+}
+
+// walk calls visit for every state of the statechart and its xpath, parents
+// before their children.
+func (c *Chart) walk(visit func(state *sc.State, path string)) {
+	var walk func(*sc.State, string)
+	walk = func(state *sc.State, path string) {
+		if state == nil {
+			return
+		}
+		visit(state, path)
+		for i, child := range state.Children {
+			walk(child, fmt.Sprintf("%s/children[%d]", path, i))
+		}
+	}
+	walk(c.RootState, "/root_state")
+}
+
+// NewRule returns a rule checked by a function.
+func NewRule(id, description string, severity validationv1.Severity, check func(*Chart) []*validationv1.Violation) Rule {
+	return &rule{id: id, description: description, severity: severity, check: check}
+}
+
+// rule is a rule checked by a function.
+type rule struct {
+	id          string
+	description string
+	severity    validationv1.Severity
+	check       func(*Chart) []*validationv1.Violation
+}
+
+func (r *rule) ID() string                             { return r.id }
+func (r *rule) Description() string                    { return r.description }
+func (r *rule) DefaultSeverity() validationv1.Severity { return r.severity }
+func (r *rule) Check(chart *Chart) []*validationv1.Violation {
+	return r.check(chart)
+}
+
+// Check checks rules against a statechart and returns every violation, with
+// its rule and the default severity of the rule. It fails with the error of
+// the context if the context is done before every rule is checked.
+func Check(ctx context.Context, chart *Chart, rules ...Rule) ([]*validationv1.Violation, error) {
+	chart.ctx = ctx
+	defer func() { chart.ctx = nil }()
+	var violations []*validationv1.Violation
+	for _, rule := range rules {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id := rule.ID()
+		for _, v := range rule.Check(chart) {
+			v.Rule = validationv1.RuleId(validationv1.RuleId_value[id])
+			v.RuleId = id
+			v.Severity = rule.DefaultSeverity()
+			violations = append(violations, v)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+// BuiltinRules returns the built-in rules, in the order in which they are
+// checked: the structural rules, which Validate checks, followed by the
+// behavioral rules, which explore the configuration graph. Charts whose
+// configuration graph is too large to explore violate CONFIGURATION_GRAPH
+// instead of the other behavioral rules.
+func BuiltinRules() []Rule {
+	return append(structuralRules(), behavioralRules()...)
+}
+
+// structuralRules returns the rules of well-formed statecharts. Their
+// violations are errors.
+func structuralRules() []Rule {
+	structural := func(id validationv1.RuleId, check func(*Chart) []*validationv1.Violation) Rule {
+		return NewRule(id.String(), ruleDescription(id), validationv1.Severity_ERROR, check)
+	}
+	return []Rule{
+		structural(validationv1.RuleId_ROOT_STATE, validateRootState),
+		structural(validationv1.RuleId_UNIQUE_STATE_LABELS, validateUniqueStateLabels),
+		structural(validationv1.RuleId_SINGLE_DEFAULT_CHILD, validateSingleDefaultChild),
+		structural(validationv1.RuleId_BASIC_HAS_NO_CHILDREN, validateBasicHasNoChildren),
+		structural(validationv1.RuleId_COMPOUND_HAS_CHILDREN, validateCompoundHasChildren),
+		structural(validationv1.RuleId_TRANSITION_ENDPOINTS, validateTransitionEndpoints),
+		structural(validationv1.RuleId_FORKS_AND_JOINS, validateForksAndJoins),
+		structural(validationv1.RuleId_INTERNAL_TRANSITIONS, validateInternalTransitions),
+		structural(validationv1.RuleId_PSEUDOSTATES, validatePseudostates),
+		structural(validationv1.RuleId_INLINED_SUBMACHINES, validateSubmachines),
+		structural(validationv1.RuleId_CONNECTION_POINTS, validateConnectionPoints),
+		structural(validationv1.RuleId_INVOKES, validateInvokes),
+	}
+}
+
+// behavioralRules returns the rules checked on the configuration graph of
+// well-formed statecharts. Their violations are warnings.
+func behavioralRules() []Rule {
+	behavioral := func(id validationv1.RuleId, check func(*Chart, *Reachability) []*validationv1.Violation) Rule {
+		return NewRule(id.String(), ruleDescription(id), validationv1.Severity_WARNING, func(c *Chart) []*validationv1.Violation {
+			if r, err := c.Reachability(); err == nil {
+				return check(c, r)
+			}
+			return nil
+		})
+	}
+	return []Rule{
+		NewRule(validationv1.RuleId_CONFIGURATION_GRAPH.String(), ruleDescription(validationv1.RuleId_CONFIGURATION_GRAPH), validationv1.Severity_WARNING, validateConfigurationGraph),
+		behavioral(validationv1.RuleId_REACHABLE_STATES, validateReachableStates),
+		behavioral(validationv1.RuleId_LIVE_TRANSITIONS, validateLiveTransitions),
+		behavioral(validationv1.RuleId_NO_DEADLOCKS, validateNoDeadlocks),
+	}
+}
+
+// ruleDescription returns the description of a RuleId.
+func ruleDescription(id validationv1.RuleId) string {
+	switch id {
+	case validationv1.RuleId_UNIQUE_STATE_LABELS:
+		return "All state labels must be unique."
+	case validationv1.RuleId_SINGLE_DEFAULT_CHILD:
+		return "XOR composite states must have exactly one default child."
+	case validationv1.RuleId_BASIC_HAS_NO_CHILDREN:
+		return "Basic states cannot have children."
+	case validationv1.RuleId_COMPOUND_HAS_CHILDREN:
+		return "Compound states must have children."
+	case validationv1.RuleId_REACHABLE_STATES:
+		return "Every state must be reachable from the initial configuration."
+	case validationv1.RuleId_LIVE_TRANSITIONS:
+		return "Every transition must be able to fire in some reachable configuration."
+	case validationv1.RuleId_NO_DEADLOCKS:
+		return "Every reachable configuration without outgoing transitions must be final."
+	case validationv1.RuleId_ROOT_STATE:
+		return "The root state must exist and be labeled __root__."
+	case validationv1.RuleId_FORKS_AND_JOINS:
+		return "The sources of a transition must be able to be active together and its targets must be orthogonal."
+	case validationv1.RuleId_INTERNAL_TRANSITIONS:
+		return "Internal transitions must target no state other than their sources."
+	case validationv1.RuleId_PSEUDOSTATES:
+		return "Choices and junctions must be leaves left by eventless branches, with at most one else branch."
+	case validationv1.RuleId_INLINED_SUBMACHINES:
+		return "Submachine states must be inlined before the statechart is executed or analyzed."
+	case validationv1.RuleId_CONNECTION_POINTS:
+		return "Entry and exit points must be children of the root state, entered and left only by the containing chart."
+	case validationv1.RuleId_INVOKES:
+		return "Invocations must name a statechart and have IDs unique within the statechart."
+	case validationv1.RuleId_TRANSITION_ENDPOINTS:
+		return "The sources and targets of transitions must be states of the statechart."
+	case validationv1.RuleId_CONFIGURATION_GRAPH:
+		return "The configuration graph must be small enough to be explored by the behavioral rules."
+	}
+	return ""
+}
+
+// violation returns a violation of the elements at the xpaths.
+func violation(message string, xpath ...string) *validationv1.Violation {
+	return &validationv1.Violation{Message: message, Xpath: xpath}
+}
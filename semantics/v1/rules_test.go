@@ -0,0 +1,158 @@
+package semantics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/tmc/sc"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// TestRulesReportAllViolations checks that rules report every violation, not
+// only the first, at the paths of the offending elements.
+func TestRulesReportAllViolations(t *testing.T) {
+	chart := &Chart{Statechart: &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Children: []*sc.State{
+			{Label: "A", IsInitial: true, Type: sc.StateTypeBasic, Children: []*sc.State{{Label: "A1"}}},
+			{Label: "B", Type: sc.StateTypeBasic, Children: []*sc.State{{Label: "A"}}},
+			{Label: "C", Type: sc.StateTypeNormal, Children: []*sc.State{{Label: "B"}, {Label: "C1"}}},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "t1", From: []string{"A"}, To: []string{"C"}, Event: "e"},
+			{Label: "t2", From: []string{"A"}, To: []string{"B"}, Event: "e"},
+			{Label: "t3", From: []string{"C1"}, To: []string{"A"}, Event: "f"},
+			{Label: "t4", From: []string{"A", "C1"}, To: []string{"B"}, Event: "f"},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		check func(*Chart) []*validationv1.Violation
+		want  []*validationv1.Violation
+	}{
+		{"unique state labels", validateUniqueStateLabels, []*validationv1.Violation{
+			violation("duplicate state label: A", "/root_state/children[0]", "/root_state/children[1]/children[0]"),
+			violation("duplicate state label: B", "/root_state/children[1]", "/root_state/children[2]/children[0]"),
+		}},
+		{"single default child", validateSingleDefaultChild, []*validationv1.Violation{
+			violation("state C has 0 default states, should have exactly 1", "/root_state/children[2]"),
+		}},
+		{"basic has no children", validateBasicHasNoChildren, []*validationv1.Violation{
+			violation("basic state A has children", "/root_state/children[0]"),
+			violation("basic state B has children", "/root_state/children[1]"),
+		}},
+		{"deterministic transition selection", validateDeterministicTransitionSelection, []*validationv1.Violation{
+			violation("non-deterministic transitions: multiple transitions from state 'A' on event 'e'", "/transitions[0]", "/transitions[1]"),
+			violation("non-deterministic transitions: multiple transitions from state 'C1' on event 'f'", "/transitions[2]", "/transitions[3]"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.check(chart), protocmp.Transform()); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBehavioralRulesReportAllViolations(t *testing.T) {
+	chart := &Chart{Statechart: &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Children: []*sc.State{
+			{Label: "A", IsInitial: true},
+			{Label: "B"},
+			{Label: "C"},
+			{Label: "D"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "t1", From: []string{"A"}, To: []string{"B"}, Event: "e"},
+			{Label: "t2", From: []string{"A"}, To: []string{"C"}, Event: "e"},
+			{Label: "t3", From: []string{"D"}, To: []string{"A"}, Event: "e"},
+		},
+	}}
+	r, err := chart.Reachability()
+	if err != nil {
+		t.Fatalf("Reachability() error = %v", err)
+	}
+
+	want := []*validationv1.Violation{
+		violation("unreachable state: C", "/root_state/children[2]"),
+		violation("unreachable state: D", "/root_state/children[3]"),
+	}
+	if diff := cmp.Diff(want, validateReachableStates(chart, r), protocmp.Transform()); diff != "" {
+		t.Errorf("validateReachableStates() mismatch (-want +got):\n%s", diff)
+	}
+	want = []*validationv1.Violation{
+		violation("transition 't2' can never fire (shadowed by a higher-priority transition)", "/transitions[1]"),
+		violation("transition 't3' can never fire (source unreachable)", "/transitions[2]"),
+	}
+	if diff := cmp.Diff(want, validateLiveTransitions(chart, r), protocmp.Transform()); diff != "" {
+		t.Errorf("validateLiveTransitions() mismatch (-want +got):\n%s", diff)
+	}
+	want = []*validationv1.Violation{
+		violation("non-final configuration {B} has no outgoing transitions", "/root_state/children[1]"),
+	}
+	if diff := cmp.Diff(want, validateNoDeadlocks(chart, r), protocmp.Transform()); diff != "" {
+		t.Errorf("validateNoDeadlocks() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestChartPaths(t *testing.T) {
+	inner := &sc.State{Label: "Inner", IsInitial: true}
+	chart := &Chart{Statechart: &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Children: []*sc.State{
+			{Label: "Outer", IsInitial: true, Children: []*sc.State{inner}},
+		}},
+		Transitions: []*sc.Transition{{Label: "t", From: []string{"Inner"}, To: []string{"Outer"}}},
+	}}
+	if got, want := chart.StatePath(inner), "/root_state/children[0]/children[0]"; got != want {
+		t.Errorf("StatePath(Inner) = %q, want %q", got, want)
+	}
+	if got := chart.StatePath(&sc.State{Label: "Inner"}); got != "" {
+		t.Errorf("StatePath() of a state of another chart = %q, want empty", got)
+	}
+	if got, want := chart.TransitionPath(chart.Transitions[0]), "/transitions[0]"; got != want {
+		t.Errorf("TransitionPath(t) = %q, want %q", got, want)
+	}
+}
+
+func TestChartReachability(t *testing.T) {
+	chart := &Chart{Statechart: &sc.Statechart{RootState: &sc.State{Label: "__root__", Children: []*sc.State{{Label: "A", IsInitial: true}}}}}
+	first, err := chart.Reachability()
+	if err != nil {
+		t.Fatalf("Reachability() error = %v", err)
+	}
+	if again, _ := chart.Reachability(); again != first {
+		t.Error("Reachability() is not explored once and cached")
+	}
+	if _, err := (&Chart{Statechart: &sc.Statechart{}}).Reachability(); err == nil {
+		t.Error("Reachability() of a statechart without a root state succeeded")
+	}
+}
+
+func TestConfigurationGraph(t *testing.T) {
+	chart := &Chart{Statechart: regions(4).Statechart, MaxConfigurations: 8}
+	got, err := Check(context.Background(), chart, BuiltinRules()...)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	want := []*validationv1.Violation{{
+		Rule:     validationv1.RuleId_CONFIGURATION_GRAPH,
+		RuleId:   "CONFIGURATION_GRAPH",
+		Severity: validationv1.Severity_WARNING,
+		Message:  "semantics: state explosion: more than 8 configurations; reachability, liveness and deadlocks were not checked",
+		Xpath:    []string{"/root_state"},
+	}}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Check() mismatch (-want +got):\n%s", diff)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Check(ctx, &Chart{Statechart: regions(30).Statechart}, BuiltinRules()...); !errors.Is(err, context.Canceled) {
+		t.Errorf("Check() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
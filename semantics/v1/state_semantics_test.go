@@ -246,6 +246,7 @@ func TestOrthogonal(t *testing.T) {
 		{"invalid", exampleStatechart1, StateLabel("this state does not exist"), StateLabel("On"), false, true},
 		{"not orthogonal", exampleStatechart1, StateLabel("On"), StateLabel("Off"), false, false},
 		{"orthogonal", exampleStatechart1, StateLabel("Blocked"), StateLabel("Card Reader Control"), true, false},
+		{"ancestrally related", exampleStatechart1, StateLabel("On"), StateLabel("Blocked"), false, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -242,6 +242,13 @@ func (s *Statechart) Orthogonal(state1, state2 StateLabel) (bool, error) {
 	if state1Obj == state2Obj {
 		return false, nil
 	}
+	related, err := s.AncestrallyRelated(state1, state2)
+	if err != nil {
+		return false, err
+	}
+	if related {
+		return false, nil
+	}
 	lca, err := s.LeastCommonAncestor(state1, state2)
 	if err != nil {
 		return false, fmt.Errorf("failed to find least common ancestor: %w", err)
@@ -0,0 +1,450 @@
+package semantics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tmc/sc"
+)
+
+// chartIndex caches the structural lookups needed to compute steps.
+// The per-call helpers on Statechart walk the tree on every lookup; the step
+// computations below perform many lookups per transition, so they build an
+// index once instead.
+type chartIndex struct {
+	root   *sc.State
+	states map[StateLabel]*sc.State
+	parent map[StateLabel]*sc.State
+	depth  map[StateLabel]int
+	order  map[StateLabel]int // Document (pre-)order position.
+}
+
+// index builds a chartIndex for the statechart.
+func (s *Statechart) index() (*chartIndex, error) {
+	if s.RootState == nil {
+		return nil, fmt.Errorf("root state is nil")
+	}
+	x := &chartIndex{
+		root:   s.RootState,
+		states: make(map[StateLabel]*sc.State),
+		parent: make(map[StateLabel]*sc.State),
+		depth:  make(map[StateLabel]int),
+		order:  make(map[StateLabel]int),
+	}
+	var visit func(state, parent *sc.State, depth int) error
+	visit = func(state, parent *sc.State, depth int) error {
+		label := StateLabel(state.Label)
+		if _, ok := x.states[label]; ok {
+			return fmt.Errorf("duplicate state label: %s", label)
+		}
+		x.states[label] = state
+		x.parent[label] = parent
+		x.depth[label] = depth
+		x.order[label] = len(x.order)
+		for _, child := range state.Children {
+			if err := visit(child, state, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(s.RootState, nil, 0); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// stateType returns the type of a state, inferring it from the state's
+// children when unspecified in the same way Normalize does.
+func stateType(state *sc.State) sc.StateType {
+	if state.Type != sc.StateTypeUnspecified {
+		return state.Type
+	}
+	if len(state.Children) == 0 {
+		return sc.StateTypeBasic
+	}
+	return sc.StateTypeNormal
+}
+
+// lookup returns the state with the given label.
+func (x *chartIndex) lookup(label StateLabel) (*sc.State, error) {
+	state, ok := x.states[label]
+	if !ok {
+		return nil, fmt.Errorf("state '%s' not found", label)
+	}
+	return state, nil
+}
+
+// isDescendant reports whether state is a proper descendant of ancestor.
+func (x *chartIndex) isDescendant(state StateLabel, ancestor *sc.State) bool {
+	for p := x.parent[state]; p != nil; p = x.parent[StateLabel(p.Label)] {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns the labels of a state set in document order.
+func (x *chartIndex) sorted(set map[StateLabel]bool) []StateLabel {
+	result := make([]StateLabel, 0, len(set))
+	for label := range set {
+		result = append(result, label)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return x.order[result[i]] < x.order[result[j]]
+	})
+	return result
+}
+
+// configurationSet converts a configuration to a set, checking that every
+// state exists. The root state is always included.
+func (x *chartIndex) configurationSet(config []StateLabel) (map[StateLabel]bool, error) {
+	set := map[StateLabel]bool{StateLabel(x.root.Label): true}
+	for _, label := range config {
+		if _, err := x.lookup(label); err != nil {
+			return nil, err
+		}
+		set[label] = true
+	}
+	return set, nil
+}
+
+// configurationLabels returns a configuration set in document order, without the root state.
+func (x *chartIndex) configurationLabels(set map[StateLabel]bool) []StateLabel {
+	var result []StateLabel
+	for _, label := range x.sorted(set) {
+		if label != StateLabel(x.root.Label) {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// complete closes an active set under default completion: every active AND-state
+// has all of its children active and every active OR-state has exactly one.
+func (x *chartIndex) complete(active map[StateLabel]bool) error {
+	var visit func(state *sc.State) error
+	visit = func(state *sc.State) error {
+		switch stateType(state) {
+		case sc.StateTypeParallel:
+			for _, child := range state.Children {
+				active[StateLabel(child.Label)] = true
+			}
+		case sc.StateTypeNormal:
+			var activeChildren int
+			for _, child := range state.Children {
+				if active[StateLabel(child.Label)] {
+					activeChildren++
+				}
+			}
+			if activeChildren > 1 {
+				return fmt.Errorf("OR-state %s has %d active children: %w", state.Label, activeChildren, ErrInconsistent)
+			}
+			if activeChildren == 0 {
+				defaultChild := findDefaultChild(state)
+				if defaultChild == nil {
+					return fmt.Errorf("OR-state %s has no default child", state.Label)
+				}
+				active[StateLabel(defaultChild.Label)] = true
+			}
+		}
+		for _, child := range state.Children {
+			if active[StateLabel(child.Label)] {
+				if err := visit(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return visit(x.root)
+}
+
+// source returns the deepest source of t if all of its sources are active in
+// the configuration. A transition listing several sources is a join: it is
+// enabled only when all of them are active.
+func (x *chartIndex) source(t *sc.Transition, active map[StateLabel]bool) (StateLabel, bool) {
+	var source StateLabel
+	for _, from := range t.From {
+		label := StateLabel(from)
+		if !active[label] {
+			return "", false
+		}
+		if source == "" || x.depth[label] > x.depth[source] {
+			source = label
+		}
+	}
+	return source, source != ""
+}
+
+// domain returns the state whose active descendants t exits: the least OR-state
+// that is a proper ancestor of every source and every target. A local
+// transition from an OR-state to its descendants exits only the descendants
+// of its source, which is its domain. It returns nil for targetless and
+// internal transitions, which exit and enter nothing.
+func (x *chartIndex) domain(t *sc.Transition) (*sc.State, error) {
+	if len(t.To) == 0 || t.Kind == sc.TransitionKindInternal {
+		return nil, nil
+	}
+	for _, to := range t.To {
+		if _, err := x.lookup(StateLabel(to)); err != nil {
+			return nil, fmt.Errorf("transition %s: %w", t.Label, err)
+		}
+	}
+	if len(t.From) == 0 {
+		return x.root, nil
+	}
+	if t.Kind == sc.TransitionKindLocal && len(t.From) == 1 {
+		source := x.states[StateLabel(t.From[0])]
+		if source != nil && stateType(source) == sc.StateTypeNormal && x.containsAll(source, t.To) {
+			return source, nil
+		}
+	}
+	for anc := x.parent[StateLabel(t.From[0])]; anc != nil; anc = x.parent[StateLabel(anc.Label)] {
+		if anc != x.root && stateType(anc) != sc.StateTypeNormal {
+			continue
+		}
+		if x.containsAll(anc, t.From) && x.containsAll(anc, t.To) {
+			return anc, nil
+		}
+	}
+	return x.root, nil
+}
+
+// containsAll reports whether all labels are proper descendants of ancestor.
+func (x *chartIndex) containsAll(ancestor *sc.State, labels []string) bool {
+	for _, label := range labels {
+		if !x.isDescendant(StateLabel(label), ancestor) {
+			return false
+		}
+	}
+	return true
+}
+
+// exitSet returns the states exited when t fires in the active configuration.
+func (x *chartIndex) exitSet(t *sc.Transition, active map[StateLabel]bool) (map[StateLabel]bool, error) {
+	exit := make(map[StateLabel]bool)
+	domain, err := x.domain(t)
+	if err != nil || domain == nil {
+		return exit, err
+	}
+	for label := range active {
+		if x.isDescendant(label, domain) {
+			exit[label] = true
+		}
+	}
+	return exit, nil
+}
+
+// enabled returns the transitions triggered by event in the active configuration, in priority order.
+//
+// Transitions whose deepest source is deeper in the hierarchy take priority;
+// transitions whose sources are at the same depth keep the order in which they
+// are declared.
+// Guards are not evaluated.
+func (x *chartIndex) enabled(transitions []*sc.Transition, active map[StateLabel]bool, event string) []*sc.Transition {
+	var result []*sc.Transition
+	for _, t := range transitions {
+		if t.Event != event {
+			continue
+		}
+		if _, ok := x.source(t, active); ok {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		si, _ := x.source(result[i], active)
+		sj, _ := x.source(result[j], active)
+		return x.depth[si] > x.depth[sj]
+	})
+	return result
+}
+
+// conflict reports whether two transitions exit a common state.
+func (x *chartIndex) conflict(t1, t2 *sc.Transition, active map[StateLabel]bool) (bool, error) {
+	_, ok1 := x.source(t1, active)
+	_, ok2 := x.source(t2, active)
+	if !ok1 || !ok2 {
+		return false, nil
+	}
+	exit1, err := x.exitSet(t1, active)
+	if err != nil {
+		return false, err
+	}
+	exit2, err := x.exitSet(t2, active)
+	if err != nil {
+		return false, err
+	}
+	// A targetless transition exits nothing but still requires its sources to remain active.
+	for _, from := range t1.From {
+		exit1[StateLabel(from)] = true
+	}
+	for _, from := range t2.From {
+		exit2[StateLabel(from)] = true
+	}
+	for label := range exit1 {
+		if exit2[label] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// selectTransitions returns a maximal set of non-conflicting transitions from
+// candidates, which must be in priority order. A candidate is taken if its
+// guard holds and it does not conflict with a transition taken before it.
+func (x *chartIndex) selectTransitions(candidates []*sc.Transition, active map[StateLabel]bool, guard func(*sc.Transition) (bool, error)) ([]*sc.Transition, error) {
+	var selected []*sc.Transition
+	for _, t := range candidates {
+		if guard != nil {
+			ok, err := guard(t)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate guard of %s: %w", t.Label, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		conflicting := false
+		for _, u := range selected {
+			c, err := x.conflict(t, u, active)
+			if err != nil {
+				return nil, err
+			}
+			if c {
+				conflicting = true
+				break
+			}
+		}
+		if !conflicting {
+			selected = append(selected, t)
+		}
+	}
+	return selected, nil
+}
+
+// fire executes a set of non-conflicting transitions on the active configuration.
+// It returns the resulting configuration together with the exited and entered
+// states, in the order in which they are exited (deepest first) and entered
+// (outermost first).
+func (x *chartIndex) fire(transitions []*sc.Transition, active map[StateLabel]bool) (next map[StateLabel]bool, exited, entered []StateLabel, err error) {
+	exit := make(map[StateLabel]bool)
+	next = make(map[StateLabel]bool)
+	for label := range active {
+		next[label] = true
+	}
+	var targets []StateLabel
+	for _, t := range transitions {
+		if _, ok := x.source(t, active); !ok {
+			return nil, nil, nil, fmt.Errorf("transition %s is not enabled", t.Label)
+		}
+		e, err := x.exitSet(t, active)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		domain, err := x.domain(t)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for label := range e {
+			exit[label] = true
+		}
+		if domain == nil {
+			continue
+		}
+		for _, to := range t.To {
+			for label := StateLabel(to); ; {
+				targets = append(targets, label)
+				p := x.parent[label]
+				if p == nil || p == domain {
+					break
+				}
+				label = StateLabel(p.Label)
+			}
+		}
+	}
+	for label := range exit {
+		delete(next, label)
+	}
+	for _, label := range targets {
+		next[label] = true
+	}
+	if err := x.complete(next); err != nil {
+		return nil, nil, nil, err
+	}
+
+	exited = x.sorted(exit)
+	for i, j := 0, len(exited)-1; i < j; i, j = i+1, j-1 {
+		exited[i], exited[j] = exited[j], exited[i]
+	}
+	enteredSet := make(map[StateLabel]bool)
+	for label := range next {
+		if !active[label] || exit[label] {
+			enteredSet[label] = true
+		}
+	}
+	return next, exited, x.sorted(enteredSet), nil
+}
+
+// InitialConfiguration returns the configuration a machine of the statechart
+// starts in: the default completion of the root state, in document order and
+// without the root state itself.
+func (s *Statechart) InitialConfiguration() ([]StateLabel, error) {
+	x, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	active, _ := x.configurationSet(nil)
+	if err := x.complete(active); err != nil {
+		return nil, err
+	}
+	return x.configurationLabels(active), nil
+}
+
+// EnabledTransitions returns the transitions triggered by event in the given
+// configuration, in priority order. Guards are not evaluated. Transitions
+// without an event are returned for the empty event.
+func (s *Statechart) EnabledTransitions(config []StateLabel, event string) ([]*sc.Transition, error) {
+	x, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	active, err := x.configurationSet(config)
+	if err != nil {
+		return nil, err
+	}
+	return x.enabled(s.Transitions, active, event), nil
+}
+
+// Conflicting reports whether two transitions enabled in the given configuration
+// conflict, that is, whether firing one exits the source or scope of the other.
+func (s *Statechart) Conflicting(config []StateLabel, t1, t2 *sc.Transition) (bool, error) {
+	x, err := s.index()
+	if err != nil {
+		return false, err
+	}
+	active, err := x.configurationSet(config)
+	if err != nil {
+		return false, err
+	}
+	return x.conflict(t1, t2, active)
+}
+
+// Microstep fires a set of non-conflicting transitions in the given configuration
+// and returns the resulting configuration, in document order and without the root state.
+func (s *Statechart) Microstep(config []StateLabel, transitions ...*sc.Transition) ([]StateLabel, error) {
+	x, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	active, err := x.configurationSet(config)
+	if err != nil {
+		return nil, err
+	}
+	next, _, _, err := x.fire(transitions, active)
+	if err != nil {
+		return nil, err
+	}
+	return x.configurationLabels(next), nil
+}
@@ -0,0 +1,282 @@
+package semantics
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tmc/sc"
+)
+
+// turnstileStatechart extends exampleStatechart1 with transitions.
+var turnstileStatechart = NewStatechart(&sc.Statechart{
+	RootState: &sc.State{
+		Children: []*sc.State{
+			{Label: "Off", IsInitial: true},
+			{
+				Label: "On",
+				Type:  sc.StateTypeParallel,
+				Children: []*sc.State{
+					{
+						Label: "Turnstile Control",
+						Children: []*sc.State{
+							{Label: "Blocked", IsInitial: true},
+							{Label: "Unblocked"},
+						},
+					},
+					{
+						Label: "Card Reader Control",
+						Children: []*sc.State{
+							{Label: "Ready", IsInitial: true},
+							{Label: "Card Entered"},
+							{Label: "Turnstile Unblocked"},
+						},
+					},
+				},
+			},
+		},
+	},
+	Transitions: []*sc.Transition{
+		{Label: "turn_on", From: []string{"Off"}, To: []string{"On"}, Event: "TURN_ON"},
+		{Label: "turn_off", From: []string{"On"}, To: []string{"Off"}, Event: "TURN_OFF"},
+		{Label: "card", From: []string{"Ready"}, To: []string{"Card Entered"}, Event: "CARD"},
+		{Label: "card_ok", From: []string{"Card Entered"}, To: []string{"Turnstile Unblocked"}, Event: "CARD_OK"},
+		{Label: "unblock", From: []string{"Blocked"}, To: []string{"Unblocked"}, Event: "UNBLOCK"},
+		{Label: "reset_reader", From: []string{"Turnstile Unblocked"}, To: []string{"Ready"}, Event: "UNBLOCK"},
+		{Label: "pass", From: []string{"Unblocked"}, To: []string{"Blocked"}, Event: "PASS"},
+		{Label: "jam", From: []string{"Blocked"}, To: []string{"Unblocked"}, Event: "TURN_OFF"},
+		{Label: "restart", From: []string{"On"}, To: []string{"On"}, Event: "RESTART"},
+	},
+})
+
+func TestInitialConfiguration(t *testing.T) {
+	got, err := turnstileStatechart.InitialConfiguration()
+	if err != nil {
+		t.Fatalf("InitialConfiguration() error = %v", err)
+	}
+	if diff := cmp.Diff(CreateStateLabels("Off"), got); diff != "" {
+		t.Errorf("InitialConfiguration() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEnabledTransitions(t *testing.T) {
+	on := CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Ready")
+	tests := []struct {
+		name   string
+		config []StateLabel
+		event  string
+		want   []string
+	}{
+		{"Off on TURN_ON", CreateStateLabels("Off"), "TURN_ON", []string{"turn_on"}},
+		{"Off on TURN_OFF", CreateStateLabels("Off"), "TURN_OFF", nil},
+		{"deeper source first", on, "TURN_OFF", []string{"jam", "turn_off"}},
+		{"unknown event", on, "UNKNOWN", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := turnstileStatechart.EnabledTransitions(tt.config, tt.event)
+			if err != nil {
+				t.Fatalf("EnabledTransitions() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, transitionLabels(got)); diff != "" {
+				t.Errorf("EnabledTransitions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConflicting(t *testing.T) {
+	config := CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Turnstile Unblocked")
+	transitions := transitionsByLabel(turnstileStatechart)
+	tests := []struct {
+		name   string
+		t1, t2 string
+		want   bool
+	}{
+		{"orthogonal regions", "unblock", "reset_reader", false},
+		{"nested sources", "jam", "turn_off", true},
+		{"same transition", "unblock", "unblock", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := turnstileStatechart.Conflicting(config, transitions[tt.t1], transitions[tt.t2])
+			if err != nil {
+				t.Fatalf("Conflicting() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Conflicting() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMicrostep(t *testing.T) {
+	transitions := transitionsByLabel(turnstileStatechart)
+	tests := []struct {
+		name        string
+		config      []StateLabel
+		transitions []string
+		want        []StateLabel
+		wantErr     bool
+	}{
+		{
+			name:        "enter parallel state",
+			config:      CreateStateLabels("Off"),
+			transitions: []string{"turn_on"},
+			want:        CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Ready"),
+		},
+		{
+			name:        "exit parallel state",
+			config:      CreateStateLabels("On", "Turnstile Control", "Unblocked", "Card Reader Control", "Card Entered"),
+			transitions: []string{"turn_off"},
+			want:        CreateStateLabels("Off"),
+		},
+		{
+			name:        "orthogonal transitions",
+			config:      CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Turnstile Unblocked"),
+			transitions: []string{"unblock", "reset_reader"},
+			want:        CreateStateLabels("On", "Turnstile Control", "Unblocked", "Card Reader Control", "Ready"),
+		},
+		{
+			name:        "self transition resets regions",
+			config:      CreateStateLabels("On", "Turnstile Control", "Unblocked", "Card Reader Control", "Card Entered"),
+			transitions: []string{"restart"},
+			want:        CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Ready"),
+		},
+		{
+			name:        "transition not enabled",
+			config:      CreateStateLabels("Off"),
+			transitions: []string{"pass"},
+			wantErr:     true,
+		},
+		{
+			name:        "unknown state",
+			config:      CreateStateLabels("Nowhere"),
+			transitions: nil,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fire []*sc.Transition
+			for _, label := range tt.transitions {
+				fire = append(fire, transitions[label])
+			}
+			got, err := turnstileStatechart.Microstep(tt.config, fire...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Microstep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Microstep() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectTransitions(t *testing.T) {
+	x, err := turnstileStatechart.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := x.configurationSet(CreateStateLabels("On", "Turnstile Control", "Blocked", "Card Reader Control", "Turnstile Unblocked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		event string
+		guard func(*sc.Transition) (bool, error)
+		want  []string
+	}{
+		{"orthogonal transitions fire together", "UNBLOCK", nil, []string{"unblock", "reset_reader"}},
+		{"inner transition preempts outer", "TURN_OFF", nil, []string{"jam"}},
+		{"outer transition fires when inner guard fails", "TURN_OFF", func(t *sc.Transition) (bool, error) {
+			return t.Label != "jam", nil
+		}, []string{"turn_off"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := x.enabled(turnstileStatechart.Transitions, active, tt.event)
+			got, err := x.selectTransitions(candidates, active, tt.guard)
+			if err != nil {
+				t.Fatalf("selectTransitions() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, transitionLabels(got)); diff != "" {
+				t.Errorf("selectTransitions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func transitionsByLabel(chart *Statechart) map[string]*sc.Transition {
+	result := make(map[string]*sc.Transition)
+	for _, t := range chart.Transitions {
+		result[t.Label] = t
+	}
+	return result
+}
+
+func TestTransitionKinds(t *testing.T) {
+	chart := NewStatechart(&sc.Statechart{
+		RootState: &sc.State{
+			Children: []*sc.State{
+				{Label: "S", IsInitial: true, Children: []*sc.State{
+					{Label: "A", IsInitial: true},
+					{Label: "B"},
+				}},
+				{Label: "P", Type: sc.StateTypeParallel, Children: []*sc.State{
+					{Label: "X", Children: []*sc.State{{Label: "X1", IsInitial: true}, {Label: "X2"}}},
+					{Label: "Y", Children: []*sc.State{{Label: "Y1", IsInitial: true}}},
+				}},
+			},
+		},
+	})
+	tests := []struct {
+		name    string
+		config  []StateLabel
+		from    string
+		to      []string
+		kind    sc.TransitionKind
+		exited  []StateLabel
+		entered []StateLabel
+	}{
+		{"external self", CreateStateLabels("S", "A"), "S", []string{"S"}, sc.TransitionKindExternal, CreateStateLabels("A", "S"), CreateStateLabels("S", "A")},
+		{"unspecified self", CreateStateLabels("S", "A"), "S", []string{"S"}, sc.TransitionKindUnspecified, CreateStateLabels("A", "S"), CreateStateLabels("S", "A")},
+		{"external to child", CreateStateLabels("S", "A"), "S", []string{"B"}, sc.TransitionKindExternal, CreateStateLabels("A", "S"), CreateStateLabels("S", "B")},
+		{"local to child", CreateStateLabels("S", "A"), "S", []string{"B"}, sc.TransitionKindLocal, CreateStateLabels("A"), CreateStateLabels("B")},
+		{"local to active child", CreateStateLabels("S", "A"), "S", []string{"A"}, sc.TransitionKindLocal, CreateStateLabels("A"), CreateStateLabels("A")},
+		{"local self", CreateStateLabels("S", "A"), "S", []string{"S"}, sc.TransitionKindLocal, CreateStateLabels("A", "S"), CreateStateLabels("S", "A")},
+		{"local from parallel", CreateStateLabels("P", "X", "X1", "Y", "Y1"), "P", []string{"X2"}, sc.TransitionKindLocal, CreateStateLabels("Y1", "Y", "X1", "X", "P"), CreateStateLabels("P", "X", "X2", "Y", "Y1")},
+		{"local to sibling", CreateStateLabels("S", "A"), "A", []string{"B"}, sc.TransitionKindLocal, CreateStateLabels("A"), CreateStateLabels("B")},
+		{"internal", CreateStateLabels("S", "A"), "S", nil, sc.TransitionKindInternal, nil, nil},
+		{"internal self", CreateStateLabels("S", "A"), "S", []string{"S"}, sc.TransitionKindInternal, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := chart.index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			active, err := x.configurationSet(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transition := &sc.Transition{Label: "t", From: []string{tt.from}, To: tt.to, Kind: tt.kind}
+			next, exited, entered, err := x.fire([]*sc.Transition{transition}, active)
+			if err != nil {
+				t.Fatalf("fire() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.exited, exited, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("exited mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.entered, entered, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("entered mismatch (-want +got):\n%s", diff)
+			}
+			if tt.kind == sc.TransitionKindInternal {
+				if diff := cmp.Diff(x.configurationLabels(active), x.configurationLabels(next)); diff != "" {
+					t.Errorf("configuration changed (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package semantics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SubmachineSeparator separates the label of a submachine state from the
+// labels of the states and transitions inlined into it.
+const SubmachineSeparator = "."
+
+// Inline expands the submachine states of a statechart: states whose
+// Submachine field names a statechart of the registry. It returns a new
+// statechart in which every submachine state is a compound state holding the
+// states of the referenced statechart and the chart holds its transitions
+// and events. Submachines may themselves contain submachine states, but not
+// refer to themselves.
+//
+// The labels of inlined states and transitions are prefixed with the label of
+// the submachine state and SubmachineSeparator, so that a chart may use the
+// same submachine more than once: the state Card of a submachine state Payment
+// becomes Payment.Card, and in(Card) conditions of the submachine become
+// in("Payment.Card"). The IDs of invocations are prefixed likewise. Events
+// and the context are shared with the containing chart.
+//
+// The containing chart enters a submachine through its default state or
+// through one of its entry points, by targeting, say, Payment.retry; it leaves
+// it through transitions from the submachine state or from one of its exit
+// points, such as Payment.declined. Inlined entry and exit points become
+// junctions, so a compound transition through them fires in one microstep.
+//
+// Inline validates the expanded statechart, including that every state a
+// transition refers to exists.
+func Inline(chart *sc.Statechart, registry *sc.StatechartRegistry) (*sc.Statechart, error) {
+	out := proto.Clone(chart).(*sc.Statechart)
+	if out.RootState == nil {
+		return nil, errors.New("statechart has no root state")
+	}
+	if err := inline(out, registry, nil); err != nil {
+		return nil, err
+	}
+	labels := make(map[string]bool)
+	visitStates(out.RootState, func(state *sc.State) error {
+		labels[state.Label] = true
+		return nil
+	})
+	for _, t := range out.Transitions {
+		for _, label := range append(slices.Clip(t.From), t.To...) {
+			if !labels[label] {
+				return nil, fmt.Errorf("transition %s refers to unknown state %s", t.Label, label)
+			}
+		}
+	}
+	if err := NewStatechart(out).Validate(); err != nil {
+		return nil, fmt.Errorf("inlined statechart: %w", err)
+	}
+	return out, nil
+}
+
+// inline expands the submachine states of chart in place. The stack holds the
+// IDs of the submachines being expanded, to detect cycles.
+func inline(chart *sc.Statechart, registry *sc.StatechartRegistry, stack []string) error {
+	events := make(map[string]bool)
+	for _, e := range chart.Events {
+		events[e.Label] = true
+	}
+	return visitStates(chart.RootState, func(state *sc.State) error {
+		id := state.Submachine
+		if id == "" {
+			return nil
+		}
+		if len(state.Children) > 0 {
+			return fmt.Errorf("submachine state %s has children", state.Label)
+		}
+		for _, other := range stack {
+			if other == id {
+				return fmt.Errorf("submachine %q refers to itself through state %s", id, state.Label)
+			}
+		}
+		sub, ok := registry.GetStatecharts()[id]
+		if !ok {
+			return fmt.Errorf("state %s: submachine %q is not registered", state.Label, id)
+		}
+		sub = proto.Clone(sub).(*sc.Statechart)
+		if sub.RootState == nil {
+			return fmt.Errorf("submachine %q has no root state", id)
+		}
+		if err := inline(sub, registry, append(stack, id)); err != nil {
+			return fmt.Errorf("submachine %q: %w", id, err)
+		}
+
+		prefix := state.Label + SubmachineSeparator
+		visitStates(sub.RootState, func(s *sc.State) error {
+			if s != sub.RootState {
+				s.Label = prefix + s.Label
+			}
+			for _, invoke := range s.Invokes {
+				invoke.Id = prefix + invoke.Id
+			}
+			if t := s.Type; t == sc.StateTypeEntryPoint || t == sc.StateTypeExitPoint {
+				s.Type = sc.StateTypeJunction
+			}
+			return nil
+		})
+		// visitStates goes on with the inlined children, which hold no
+		// submachine states any more.
+		state.Type = stateType(sub.RootState)
+		state.Children = sub.RootState.Children
+		state.Invokes = append(state.Invokes, sub.RootState.Invokes...)
+		state.Submachine = ""
+
+		for _, t := range sub.Transitions {
+			t.Label = prefix + t.Label
+			for i := range t.From {
+				t.From[i] = prefix + t.From[i]
+			}
+			for i := range t.To {
+				t.To[i] = prefix + t.To[i]
+			}
+			if err := qualifyGuard(t.Guard, prefix); err != nil {
+				return fmt.Errorf("submachine %q: transition %s: %w", id, t.Label, err)
+			}
+			chart.Transitions = append(chart.Transitions, t)
+		}
+		for _, e := range sub.Events {
+			if !events[e.Label] {
+				events[e.Label] = true
+				chart.Events = append(chart.Events, e)
+			}
+		}
+		return nil
+	})
+}
+
+// qualifyGuard prefixes the states tested by the in() conditions of a guard.
+// Guards without in() conditions are left as written.
+func qualifyGuard(guard *sc.Guard, prefix string) error {
+	expr := strings.TrimSpace(guard.GetExpression())
+	if expr == "" || IsElse(guard) || !strings.Contains(expr, "in") {
+		return nil
+	}
+	e, err := ParseExpression(expr)
+	if err != nil {
+		return err
+	}
+	var qualified bool
+	var visit func(e Expr) error
+	visit = func(e Expr) error {
+		switch e := e.(type) {
+		case *Unary:
+			return visit(e.X)
+		case *Binary:
+			if err := visit(e.X); err != nil {
+				return err
+			}
+			return visit(e.Y)
+		case *Call:
+			if e.Func != "in" {
+				for _, arg := range e.Args {
+					if err := visit(arg); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			label, err := InState(e)
+			if err != nil {
+				return err
+			}
+			e.Args = []Expr{&Literal{Value: structpb.NewStringValue(prefix + label.String())}}
+			qualified = true
+		}
+		return nil
+	}
+	if err := visit(e); err != nil {
+		return err
+	}
+	if qualified {
+		guard.Expression = e.String()
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package semantics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// paymentStatechart authorizes a payment. The containing chart may enter it
+// through the entry point retry and leave it through the exit point declined.
+func paymentStatechart() *sc.Statechart {
+	return &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "retry", Type: sc.StateTypeEntryPoint},
+			{Label: "Authorizing", IsInitial: true, Invokes: []*sc.Invoke{{Id: "gateway", StatechartId: "gateway"}}},
+			{Label: "Authorized", IsFinal: true},
+			{Label: "declined", Type: sc.StateTypeExitPoint},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "count", From: []string{"retry"}, To: []string{"Authorizing"}, Actions: []*sc.Action{{Label: "attempts = attempts + 1"}}},
+			{Label: "approve", From: []string{"Authorizing"}, To: []string{"Authorized"}, Event: "APPROVE", Guard: &sc.Guard{Expression: "!in(Authorized)"}},
+			{Label: "decline", From: []string{"Authorizing"}, To: []string{"declined"}, Event: "DECLINE"},
+		},
+		Events: []*sc.Event{{Label: "APPROVE"}, {Label: "DECLINE"}},
+	}
+}
+
+func checkoutStatechart() *sc.Statechart {
+	return &sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "Cart", IsInitial: true},
+			{Label: "Payment", Submachine: "payment"},
+			{Label: "Failed"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "pay", From: []string{"Cart"}, To: []string{"Payment"}, Event: "PAY"},
+			{Label: "fail", From: []string{"Payment.declined"}, To: []string{"Failed"}},
+			{Label: "retry", From: []string{"Failed"}, To: []string{"Payment.retry"}, Event: "RETRY"},
+		},
+		Events: []*sc.Event{{Label: "PAY"}, {Label: "RETRY"}, {Label: "APPROVE"}},
+	}
+}
+
+func TestInline(t *testing.T) {
+	registry := &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{"payment": paymentStatechart()}}
+	chart, err := Inline(checkoutStatechart(), registry)
+	if err != nil {
+		t.Fatalf("Inline() error = %v", err)
+	}
+	payment := chart.RootState.Children[1]
+	var labels []string
+	for _, s := range payment.Children {
+		labels = append(labels, s.Label+":"+stateType(s).String())
+	}
+	want := []string{
+		"Payment.retry:STATE_TYPE_JUNCTION",
+		"Payment.Authorizing:STATE_TYPE_BASIC",
+		"Payment.Authorized:STATE_TYPE_BASIC",
+		"Payment.declined:STATE_TYPE_JUNCTION",
+	}
+	if diff := cmp.Diff(want, labels); diff != "" {
+		t.Errorf("states mismatch (-want +got):\n%s", diff)
+	}
+	if got := payment.Children[1].Invokes[0].Id; got != "Payment.gateway" {
+		t.Errorf("invoke ID = %q, want Payment.gateway", got)
+	}
+	if payment.Submachine != "" || stateType(payment) != sc.StateTypeNormal {
+		t.Errorf("Payment = %v, want a compound state", payment)
+	}
+	if diff := cmp.Diff([]string{"pay", "fail", "retry", "Payment.count", "Payment.approve", "Payment.decline"}, transitionLabels(chart.Transitions)); diff != "" {
+		t.Errorf("transitions mismatch (-want +got):\n%s", diff)
+	}
+	if got := chart.Transitions[4].Guard.Expression; got != `!in("Payment.Authorized")` {
+		t.Errorf("guard = %q, want the in() condition qualified", got)
+	}
+	var events []string
+	for _, e := range chart.Events {
+		events = append(events, e.Label)
+	}
+	if diff := cmp.Diff([]string{"PAY", "RETRY", "APPROVE", "DECLINE"}, events); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(paymentStatechart(), registry.Statecharts["payment"], protocmp.Transform()); diff != "" {
+		t.Errorf("Inline() modified the registry (-want +got):\n%s", diff)
+	}
+
+	context, err := structpb.NewStruct(map[string]interface{}{"attempts": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine()
+	m, err := engine.NewMachine("checkout", NewStatechart(chart), context)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	steps := []struct {
+		event       string
+		transitions []string
+		config      []string
+	}{
+		{"PAY", []string{"pay"}, []string{"__root__", "Payment", "Payment.Authorizing"}},
+		{"DECLINE", []string{"Payment.decline", "fail"}, []string{"__root__", "Failed"}},
+		{"RETRY", []string{"retry", "Payment.count"}, []string{"__root__", "Payment", "Payment.Authorizing"}},
+		{"APPROVE", []string{"Payment.approve"}, []string{"__root__", "Payment", "Payment.Authorized"}},
+	}
+	for _, s := range steps {
+		step, err := engine.Step(m, s.event)
+		if err != nil {
+			t.Fatalf("Step(%s) error = %v", s.event, err)
+		}
+		if diff := cmp.Diff(s.transitions, transitionLabels(step.Transitions)); diff != "" {
+			t.Errorf("Step(%s) transitions mismatch (-want +got):\n%s", s.event, diff)
+		}
+		if diff := cmp.Diff(s.config, configurationStrings(m.Configuration)); diff != "" {
+			t.Errorf("Step(%s) configuration mismatch (-want +got):\n%s", s.event, diff)
+		}
+	}
+	if got := m.Context.Fields["attempts"].GetNumberValue(); got != 1 {
+		t.Errorf("attempts = %v, want 1", got)
+	}
+}
+
+func TestInlineNested(t *testing.T) {
+	registry := &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"payment": paymentStatechart(),
+		"order": {
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "Open", IsInitial: true},
+				{Label: "Payment", Submachine: "payment"},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "pay", From: []string{"Open"}, To: []string{"Payment"}, Event: "PAY"},
+				{Label: "reopen", From: []string{"Payment.declined"}, To: []string{"Open"}},
+			},
+		},
+	}}
+	chart, err := Inline(&sc.Statechart{
+		RootState: &sc.State{Children: []*sc.State{
+			{Label: "First", Submachine: "order", IsInitial: true},
+			{Label: "Second", Submachine: "order"},
+		}},
+	}, registry)
+	if err != nil {
+		t.Fatalf("Inline() error = %v", err)
+	}
+	var labels []string
+	visitStates(chart.RootState, func(s *sc.State) error {
+		if s != chart.RootState {
+			labels = append(labels, s.Label)
+		}
+		return nil
+	})
+	want := []string{
+		"First", "First.Open", "First.Payment", "First.Payment.retry", "First.Payment.Authorizing", "First.Payment.Authorized", "First.Payment.declined",
+		"Second", "Second.Open", "Second.Payment", "Second.Payment.retry", "Second.Payment.Authorizing", "Second.Payment.Authorized", "Second.Payment.declined",
+	}
+	if diff := cmp.Diff(want, labels); diff != "" {
+		t.Errorf("states mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestInlineErrors(t *testing.T) {
+	registry := &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"payment": paymentStatechart(),
+		"loop":    {RootState: &sc.State{Children: []*sc.State{{Label: "Again", Submachine: "loop", IsInitial: true}}}},
+	}}
+	chart := func(sub *sc.State, transitions ...*sc.Transition) *sc.Statechart {
+		return &sc.Statechart{
+			RootState:   &sc.State{Children: []*sc.State{{Label: "A", IsInitial: true}, sub}},
+			Transitions: transitions,
+		}
+	}
+	tests := []struct {
+		name    string
+		chart   *sc.Statechart
+		wantErr string
+	}{
+		{"unregistered", chart(&sc.State{Label: "S", Submachine: "missing"}), `state S: submachine "missing" is not registered`},
+		{"cycle", chart(&sc.State{Label: "S", Submachine: "loop"}), `submachine "loop" refers to itself through state Again`},
+		{"children", chart(&sc.State{Label: "S", Submachine: "payment", Children: []*sc.State{{Label: "C"}}}), "submachine state S has children"},
+		{"unknown entry point", chart(&sc.State{Label: "S", Submachine: "payment"},
+			&sc.Transition{Label: "t", From: []string{"A"}, To: []string{"S.start"}, Event: "E"},
+			&sc.Transition{Label: "u", From: []string{"S.declined"}, To: []string{"A"}}), "transition t refers to unknown state S.start"},
+		{"exit point without branch", chart(&sc.State{Label: "S", Submachine: "payment"}), "pseudostate S.declined has no branches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Inline(tt.chart, registry)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Inline() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
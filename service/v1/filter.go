@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tmc/sc"
+)
+
+const (
+	// DefaultPageSize is the number of resources listed when a request gives no page size.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest number of resources listed at once.
+	MaxPageSize = 1000
+)
+
+// validateID checks that an ID can be the final segment of a resource name.
+func validateID(id string) error {
+	switch {
+	case id == "":
+		return errors.New("must not be empty")
+	case strings.Contains(id, "/"):
+		return fmt.Errorf("%q must not contain a slash", id)
+	}
+	return nil
+}
+
+// parseName returns the ID in a resource name of the form collection/{id}.
+func parseName(collection, name string) (string, error) {
+	id, ok := strings.CutPrefix(name, collection+"/")
+	if !ok {
+		return "", status.Errorf(codes.InvalidArgument, "name %q does not have the form %s/{id}", name, collection)
+	}
+	if err := validateID(id); err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "name %q: ID %v", name, err)
+	}
+	return id, nil
+}
+
+// pageSize returns the number of resources to list for the page size of a request.
+func pageSize(size int32) (int, error) {
+	switch {
+	case size < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "page size %d is negative", size)
+	case size == 0:
+		return DefaultPageSize, nil
+	case size > MaxPageSize:
+		return MaxPageSize, nil
+	}
+	return int(size), nil
+}
+
+// pageToken returns the token of the page following the resource with the
+// given ID. It records the filter, which the next request must repeat.
+func pageToken(filter, last string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(filter + "\x00" + last))
+}
+
+// parsePageToken returns the ID of the last resource of the previous page, or
+// the empty string for the first page.
+func parsePageToken(token, filter string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	f, last, ok := strings.Cut(string(b), "\x00")
+	switch {
+	case !ok:
+		return "", status.Error(codes.InvalidArgument, "invalid page token")
+	case f != filter:
+		return "", status.Error(codes.InvalidArgument, "page token was issued for a different filter")
+	}
+	return last, nil
+}
+
+// filter is a conjunction of terms machines must match.
+type filter []term
+
+// term is a comparison of a field of a machine with a value.
+type term struct {
+	field, value string
+}
+
+var (
+	andPattern  = regexp.MustCompile(`\s+AND\s+`)
+	termPattern = regexp.MustCompile(`^(\w+)\s*([=:])\s*("(?:[^"\\]|\\.)*"|[^\s"]+)$`)
+)
+
+// parseFilter parses the filter of a ListMachinesRequest.
+func parseFilter(s string) (filter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var f filter
+	for _, t := range andPattern.Split(strings.TrimSpace(s), -1) {
+		m := termPattern.FindStringSubmatch(t)
+		if m == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid filter term %q", t)
+		}
+		field, op, value := m[1], m[2], m[3]
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid filter term %q: %v", t, err)
+			}
+			value = unquoted
+		}
+		switch {
+		case field == "statechart" && op == "=":
+		case field == "state" && op == "=":
+			if value != "RUNNING" && value != "STOPPED" {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid filter term %q: state must be RUNNING or STOPPED", t)
+			}
+		case field == "configuration" && op == ":":
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "invalid filter term %q: unsupported field or operator", t)
+		}
+		f = append(f, term{field: field, value: value})
+	}
+	return f, nil
+}
+
+// matches reports whether a machine matches every term of the filter.
+func (f filter) matches(m *machine) bool {
+	for _, t := range f {
+		var ok bool
+		switch t.field {
+		case "statechart":
+			ok = m.statechartID == t.value
+		case "state":
+			ok = t.value == "RUNNING" && m.State == sc.MachineStateRunning ||
+				t.value == "STOPPED" && m.State == sc.MachineStateStopped
+		case "configuration":
+			for _, ref := range m.GetConfiguration().GetStates() {
+				ok = ok || ref.Label == t.value
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   filter
+	}{
+		{"", nil},
+		{"statechart = light", filter{{"statechart", "light"}}},
+		{`configuration:"Payment.Authorizing"  AND  state=STOPPED`, filter{{"configuration", "Payment.Authorizing"}, {"state", "STOPPED"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseFilter(tt.filter)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error = %v", tt.filter, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(term{})); diff != "" {
+			t.Errorf("parseFilter(%q) mismatch (-want +got):\n%s", tt.filter, diff)
+		}
+	}
+	for _, bad := range []string{"light", "state = PAUSED", "configuration = On", "owner = me", `statechart = "light`, "statechart = light OR state = RUNNING"} {
+		if _, err := parseFilter(bad); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parseFilter(%q) error = %v, want %v", bad, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	token := pageToken("state = RUNNING", "m-1")
+	if got, err := parsePageToken(token, "state = RUNNING"); err != nil || got != "m-1" {
+		t.Errorf("parsePageToken() = %q, %v, want m-1", got, err)
+	}
+	if _, err := parsePageToken(token, ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("parsePageToken() with another filter error = %v, want %v", err, codes.InvalidArgument)
+	}
+	if got, err := parsePageToken("", "x"); err != nil || got != "" {
+		t.Errorf("parsePageToken() of the first page = %q, %v, want the empty string", got, err)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+)
+
+// MachinesCollection is the collection of machines in resource names.
+const MachinesCollection = "machines"
+
+// GetMachine returns a machine.
+func (s *StatechartService) GetMachine(ctx context.Context, req *pb.GetMachineRequest) (*sc.Machine, error) {
+	id, err := parseName(MachinesCollection, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.machines[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "machine %q not found", id)
+	}
+	return proto.Clone(m.Machine).(*sc.Machine), nil
+}
+
+// ListMachines lists the machines matching the filter of the request in
+// increasing order of ID.
+func (s *StatechartService) ListMachines(ctx context.Context, req *pb.ListMachinesRequest) (*pb.ListMachinesResponse, error) {
+	size, err := pageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	after, err := parsePageToken(req.GetPageToken(), req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.ListMachinesResponse{}
+	for _, m := range s.sorted() {
+		if m.Id <= after || !f.matches(m) {
+			continue
+		}
+		if len(resp.Machines) == size {
+			resp.NextPageToken = pageToken(req.GetFilter(), resp.Machines[size-1].Id)
+			break
+		}
+		resp.Machines = append(resp.Machines, proto.Clone(m.Machine).(*sc.Machine))
+	}
+	return resp, nil
+}
+
+// DeleteMachine deletes a machine. Watch streams that name the machine end
+// with codes.NotFound, so that they do not follow a new machine with the
+// same ID.
+func (s *StatechartService) DeleteMachine(ctx context.Context, req *pb.DeleteMachineRequest) (*emptypb.Empty, error) {
+	id, err := parseName(MachinesCollection, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.machines[id]; !ok {
+		return nil, status.Errorf(codes.NotFound, "machine %q not found", id)
+	}
+	delete(s.machines, id)
+	for w := range s.watchers {
+		if w.err == nil && slices.Contains(w.req.GetMachineIds(), id) {
+			w.end(status.Errorf(codes.NotFound, "machine %q was deleted", id))
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+)
+
+// listMachines lists the IDs of the machines matching a filter, page by page.
+func listMachines(t *testing.T, client pb.StatechartServiceClient, filter string, size int32) [][]string {
+	t.Helper()
+	var pages [][]string
+	req := &pb.ListMachinesRequest{Filter: filter, PageSize: size}
+	for {
+		resp, err := client.ListMachines(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ListMachines(%q) error = %v", filter, err)
+		}
+		var ids []string
+		for _, m := range resp.Machines {
+			if m.Name != MachinesCollection+"/"+m.Id {
+				t.Errorf("ListMachines(%q) machine %s has name %q", filter, m.Id, m.Name)
+			}
+			ids = append(ids, m.Id)
+		}
+		pages = append(pages, ids)
+		if resp.NextPageToken == "" {
+			return pages
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func TestListMachines(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	ctx := context.Background()
+	context0, err := structpb.NewStruct(map[string]interface{}{"count": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(statechartID, id string) {
+		if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: statechartID, MachineId: id, Context: context0}); err != nil {
+			t.Fatalf("CreateMachine(%s) error = %v", id, err)
+		}
+	}
+	create("light", "kitchen")
+	create("light", "hall")
+	create("light", "porch")
+	create("door", "front")
+	step(t, client, "kitchen", "TOGGLE")
+
+	tests := []struct {
+		filter string
+		size   int32
+		want   [][]string
+	}{
+		{"", 0, [][]string{{"front", "hall", "kitchen", "porch"}}},
+		{"", 3, [][]string{{"front", "hall", "kitchen"}, {"porch"}}},
+		{`statechart = "light"`, 2, [][]string{{"hall", "kitchen"}, {"porch"}}},
+		{"configuration:On", 0, [][]string{{"kitchen"}}},
+		{`configuration:"Off" AND statechart=light`, 0, [][]string{{"hall", "porch"}}},
+		{"state = STOPPED", 0, [][]string{nil}},
+		{"state = RUNNING AND configuration:Closed", 0, [][]string{{"front"}}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, listMachines(t, client, tt.filter, tt.size)); diff != "" {
+			t.Errorf("ListMachines(%q, %d) mismatch (-want +got):\n%s", tt.filter, tt.size, diff)
+		}
+	}
+
+	resp, err := client.ListMachines(ctx, &pb.ListMachinesRequest{PageSize: 1, Filter: "statechart = light"})
+	if err != nil {
+		t.Fatalf("ListMachines() error = %v", err)
+	}
+	if _, err := client.ListMachines(ctx, &pb.ListMachinesRequest{PageToken: resp.NextPageToken}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListMachines() with a page token of another filter error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestGetAndDeleteMachine(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	ctx := context.Background()
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "light", MachineId: "hall"}); err != nil {
+		t.Fatalf("CreateMachine() error = %v", err)
+	}
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "door", MachineId: "hall"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateMachine() of an existing ID error = %v, want %v", err, codes.AlreadyExists)
+	}
+	m, err := client.GetMachine(ctx, &pb.GetMachineRequest{Name: "machines/hall"})
+	if err != nil {
+		t.Fatalf("GetMachine() error = %v", err)
+	}
+	if m.Name != "machines/hall" || m.Id != "hall" || m.Configuration.States[1].Label != "Off" {
+		t.Errorf("GetMachine() = %v, want machines/hall in Off", m)
+	}
+	if _, err := client.DeleteMachine(ctx, &pb.DeleteMachineRequest{Name: "machines/hall"}); err != nil {
+		t.Fatalf("DeleteMachine() error = %v", err)
+	}
+	if _, err := client.GetMachine(ctx, &pb.GetMachineRequest{Name: "machines/hall"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetMachine() of a deleted machine error = %v, want %v", err, codes.NotFound)
+	}
+	if _, err := client.DeleteMachine(ctx, &pb.DeleteMachineRequest{Name: "machines/hall"}); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteMachine() of a deleted machine error = %v, want %v", err, codes.NotFound)
+	}
+	if _, err := client.GetMachine(ctx, &pb.GetMachineRequest{Name: "statecharts/light"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetMachine() of a statechart name error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
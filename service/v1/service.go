@@ -0,0 +1,432 @@
+// Package service implements the StatechartService.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	"github.com/tmc/sc/semantics/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+// DefaultWatchBuffer is the default number of responses buffered for a Watch
+// stream before the watcher is considered too slow.
+const DefaultWatchBuffer = 256
+
+// StatechartService implements the StatechartService: it hosts machines of
+// the statecharts of a registry, steps them and streams their steps to
+// watchers. It is safe for concurrent use.
+type StatechartService struct {
+	pb.UnimplementedStatechartServiceServer
+
+	// Engine steps the machines. If nil, semantics.NewEngine is used.
+	Engine *semantics.Engine
+	// Validator validates statecharts before they are registered, so that
+	// the rules an application registers with it apply. If nil,
+	// validation.NewSemanticValidator is used.
+	Validator *validation.SemanticValidator
+	// WatchBuffer is the number of responses buffered for a Watch stream. A
+	// watcher that falls further behind is disconnected with
+	// codes.ResourceExhausted and may resume from the steps it missed. If
+	// zero, DefaultWatchBuffer is used.
+	WatchBuffer int
+
+	mu       sync.Mutex
+	registry *sc.StatechartRegistry
+	machines map[string]*machine
+	nextID   int
+	watchers map[*watcher]bool
+}
+
+// machine is a machine hosted by the service.
+type machine struct {
+	*sc.Machine
+	statechartID string
+}
+
+// watcher is a Watch stream.
+type watcher struct {
+	req     *pb.WatchRequest
+	updates chan *pb.WatchResponse
+	// err is set, and updates closed, when the service ends the stream: when
+	// the watcher falls behind or a machine it names is deleted.
+	err error
+}
+
+// NewStatechartService creates a service for the statecharts of the
+// registry. Statecharts registered with the service are added to the
+// registry; a nil registry starts the service with an empty one.
+func NewStatechartService(registry *sc.StatechartRegistry) *StatechartService {
+	if registry == nil {
+		registry = &sc.StatechartRegistry{}
+	}
+	if registry.Statecharts == nil {
+		registry.Statecharts = make(map[string]*sc.Statechart)
+	}
+	return &StatechartService{registry: registry}
+}
+
+// CreateMachine creates a machine of a registered statechart, in its initial
+// configuration. Submachine states of the statechart are inlined. Unless the
+// request gives the ID of the machine, it is generated from the ID of the
+// statechart.
+func (s *StatechartService) CreateMachine(ctx context.Context, req *pb.CreateMachineRequest) (*pb.CreateMachineResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chart, ok := s.registry.GetStatecharts()[req.GetStatechartId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "statechart %q not found", req.GetStatechartId())
+	}
+	id := req.GetMachineId()
+	if id != "" {
+		if err := validateID(id); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "machine ID: %v", err)
+		}
+		if _, ok := s.machines[id]; ok {
+			return nil, status.Errorf(codes.AlreadyExists, "machine %q already exists", id)
+		}
+	}
+	chart, err := semantics.Inline(chart, s.registry)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "statechart %q: %v", req.GetStatechartId(), err)
+	}
+	for req.GetMachineId() == "" && (id == "" || s.machines[id] != nil) {
+		s.nextID++
+		id = fmt.Sprintf("%s-%d", req.GetStatechartId(), s.nextID)
+	}
+	m, err := s.engine().NewMachine(id, semantics.NewStatechart(chart), req.GetContext())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "statechart %q: %v", req.GetStatechartId(), err)
+	}
+	m.Name = MachinesCollection + "/" + id
+	if s.machines == nil {
+		s.machines = make(map[string]*machine)
+	}
+	s.machines[id] = &machine{Machine: m, statechartID: req.GetStatechartId()}
+	s.publish(s.machines[id], -1)
+	return &pb.CreateMachineResponse{Machine: proto.Clone(m).(*sc.Machine)}, nil
+}
+
+// Step steps a machine with an event. The fields of the context of the
+// request are set in the context of the machine for the step. A failed step
+// leaves the machine unchanged and is reported in the result of the response.
+//
+// A request without a machine ID addresses the machine of its statechart,
+// which must have exactly one machine.
+func (s *StatechartService) Step(ctx context.Context, req *pb.StepRequest) (*pb.StepResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.addressed(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStepIndex(m, req.ExpectedStepIndex); err != nil {
+		return nil, err
+	}
+	next, err := s.run(m, req.GetContext(), []string{req.GetEvent()})
+	if err != nil {
+		return &pb.StepResponse{
+			Machine: proto.Clone(m.Machine).(*sc.Machine),
+			Result:  stepStatus(err).Proto(),
+		}, nil
+	}
+	s.commit(m, next)
+	return &pb.StepResponse{
+		Machine: proto.Clone(next).(*sc.Machine),
+		Result:  status.New(codes.OK, "").Proto(),
+	}, nil
+}
+
+// BatchStep steps a machine with a sequence of events, each in a step of its
+// own. If a step fails, the machine is left unchanged and the failure is
+// reported in the result of the response; watchers only see the steps of
+// batches that succeed.
+func (s *StatechartService) BatchStep(ctx context.Context, req *pb.BatchStepRequest) (*pb.BatchStepResponse, error) {
+	if len(req.GetEvents()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "events are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.machines[req.GetMachineId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "machine %q not found", req.GetMachineId())
+	}
+	if err := checkStepIndex(m, req.ExpectedStepIndex); err != nil {
+		return nil, err
+	}
+	next, err := s.run(m, req.GetContext(), req.GetEvents())
+	if err != nil {
+		return &pb.BatchStepResponse{
+			Machine: proto.Clone(m.Machine).(*sc.Machine),
+			Result:  stepStatus(err).Proto(),
+		}, nil
+	}
+	first := len(m.StepHistory)
+	s.commit(m, next)
+	resp := &pb.BatchStepResponse{
+		Machine: proto.Clone(next).(*sc.Machine),
+		Result:  status.New(codes.OK, "").Proto(),
+	}
+	for _, step := range next.StepHistory[first:] {
+		resp.Steps = append(resp.Steps, proto.Clone(step).(*sc.Step))
+	}
+	return resp, nil
+}
+
+// addressed returns the machine a StepRequest addresses. The caller holds the mutex.
+func (s *StatechartService) addressed(req *pb.StepRequest) (*machine, error) {
+	if id := req.GetMachineId(); id != "" {
+		m, ok := s.machines[id]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "machine %q not found", id)
+		}
+		return m, nil
+	}
+	statechartID := req.GetStatechartId()
+	if statechartID == "" {
+		return nil, status.Error(codes.InvalidArgument, "machine ID is required")
+	}
+	var found []*machine
+	for _, m := range s.machines {
+		if m.statechartID == statechartID {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, status.Errorf(codes.NotFound, "statechart %q has no machine", statechartID)
+	case 1:
+		return found[0], nil
+	}
+	return nil, status.Errorf(codes.FailedPrecondition, "statechart %q has %d machines; address one by its ID", statechartID, len(found))
+}
+
+// checkStepIndex checks that the next step of a machine gets the expected
+// index in its step history, if one is given.
+func checkStepIndex(m *machine, expected *int64) error {
+	if expected != nil && *expected != int64(len(m.StepHistory)) {
+		return status.Errorf(codes.Aborted, "machine %q is at step index %d, not %d", m.Id, len(m.StepHistory), *expected)
+	}
+	return nil
+}
+
+// run steps a copy of a machine with the events, after setting the fields of
+// the context in its context, and returns the copy. The caller holds the mutex.
+func (s *StatechartService) run(m *machine, context *structpb.Struct, events []string) (*sc.Machine, error) {
+	next := proto.Clone(m.Machine).(*sc.Machine)
+	if fields := context.GetFields(); len(fields) > 0 {
+		if next.Context.GetFields() == nil {
+			next.Context = &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+		}
+		for k, v := range fields {
+			next.Context.Fields[k] = proto.Clone(v).(*structpb.Value)
+		}
+	}
+	for i, event := range events {
+		if _, err := s.engine().Step(next, event); err != nil {
+			if len(events) > 1 {
+				return nil, fmt.Errorf("event %d (%s): %w", i, event, err)
+			}
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// commit replaces a machine by its stepped copy and publishes the new steps.
+// The caller holds the mutex.
+func (s *StatechartService) commit(m *machine, next *sc.Machine) {
+	first := len(m.StepHistory)
+	m.Machine = next
+	for i := first; i < len(next.StepHistory); i++ {
+		s.publish(m, int64(i))
+	}
+}
+
+// stepStatus returns the result of a failed step.
+func stepStatus(err error) *status.Status {
+	if errors.Is(err, semantics.ErrMachineStopped) {
+		return status.New(codes.OutOfRange, err.Error())
+	}
+	return status.New(codes.FailedPrecondition, err.Error())
+}
+
+// Watch streams the steps of the selected machines. It first sends the steps
+// in their step histories from the start step index on, and then each new
+// step as it is taken, until the client cancels the stream. A stream naming a
+// machine that is deleted ends with codes.NotFound after the steps the
+// machine took before.
+func (s *StatechartService) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.WatchResponse]) error {
+	s.mu.Lock()
+	for _, id := range req.GetMachineIds() {
+		if _, ok := s.machines[id]; !ok {
+			s.mu.Unlock()
+			return status.Errorf(codes.NotFound, "machine %q not found", id)
+		}
+	}
+	// Registering the watcher while holding the lock makes the history and
+	// the updates meet without gaps or duplicates.
+	w := &watcher{req: req, updates: make(chan *pb.WatchResponse, s.watchBuffer())}
+	if s.watchers == nil {
+		s.watchers = make(map[*watcher]bool)
+	}
+	s.watchers[w] = true
+	var history []*pb.WatchResponse
+	for _, m := range s.sorted() {
+		if !w.watches(m) {
+			continue
+		}
+		for i := w.start(m.Id); i >= 0 && i < int64(len(m.StepHistory)); i++ {
+			history = append(history, response(m.Id, i, m.StepHistory[i], stateAfter(m, i)))
+		}
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	for _, resp := range history {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case resp, ok := <-w.updates:
+			if !ok {
+				return w.err
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *StatechartService) engine() *semantics.Engine {
+	if s.Engine == nil {
+		return semantics.NewEngine()
+	}
+	return s.Engine
+}
+
+func (s *StatechartService) validator() *validation.SemanticValidator {
+	if s.Validator != nil {
+		return s.Validator
+	}
+	return validation.NewSemanticValidator()
+}
+
+func (s *StatechartService) watchBuffer() int {
+	if s.WatchBuffer <= 0 {
+		return DefaultWatchBuffer
+	}
+	return s.WatchBuffer
+}
+
+// sorted returns the machines in increasing order of ID. The caller holds the mutex.
+func (s *StatechartService) sorted() []*machine {
+	ids := make([]string, 0, len(s.machines))
+	for id := range s.machines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	machines := make([]*machine, len(ids))
+	for i, id := range ids {
+		machines[i] = s.machines[id]
+	}
+	return machines
+}
+
+// publish sends the step of a machine with the given index, or the creation
+// of the machine for index -1, to the watchers following it. Watchers whose
+// buffers are full are disconnected. The caller holds the mutex.
+func (s *StatechartService) publish(m *machine, index int64) {
+	for w := range s.watchers {
+		if w.err != nil || !w.watches(m) || index >= 0 && index < w.start(m.Id) {
+			continue
+		}
+		var resp *pb.WatchResponse
+		if index < 0 {
+			resp = &pb.WatchResponse{
+				MachineId:     m.Id,
+				StepIndex:     -1,
+				Configuration: proto.Clone(m.Configuration).(*pb.Configuration),
+				State:         m.State,
+			}
+		} else {
+			resp = response(m.Id, index, m.StepHistory[index], stateAfter(m, index))
+		}
+		select {
+		case w.updates <- resp:
+		default:
+			w.end(status.Error(codes.ResourceExhausted, "watcher fell behind; resume from the last step received"))
+		}
+	}
+}
+
+// end ends the stream of the watcher with an error once it has sent the
+// buffered updates. The caller holds the mutex.
+func (w *watcher) end(err error) {
+	w.err = err
+	close(w.updates)
+}
+
+// watches reports whether the watcher follows the machine.
+func (w *watcher) watches(m *machine) bool {
+	if id := w.req.GetStatechartId(); id != "" && id != m.statechartID {
+		return false
+	}
+	ids := w.req.GetMachineIds()
+	if len(ids) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if id == m.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// start returns the index of the first step the watcher follows for a machine.
+func (w *watcher) start(id string) int64 {
+	if i, ok := w.req.GetStartStepIndexes()[id]; ok {
+		return i
+	}
+	return w.req.GetStartStepIndex()
+}
+
+// stateAfter returns the state of a machine after the step with the given
+// index: only the last step may have stopped it.
+func stateAfter(m *machine, index int64) sc.MachineState {
+	if index == int64(len(m.StepHistory))-1 {
+		return m.State
+	}
+	return sc.MachineStateRunning
+}
+
+func response(id string, index int64, step *sc.Step, state sc.MachineState) *pb.WatchResponse {
+	return &pb.WatchResponse{
+		MachineId:     id,
+		StepIndex:     index,
+		Step:          proto.Clone(step).(*pb.Step),
+		Configuration: proto.Clone(step.ResultingConfiguration).(*pb.Configuration),
+		State:         state,
+	}
+}
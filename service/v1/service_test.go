@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+)
+
+// registry holds a light that counts how often it is switched on, and a door.
+func registry() *sc.StatechartRegistry {
+	return &sc.StatechartRegistry{Statecharts: map[string]*sc.Statechart{
+		"light": {
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "Off", IsInitial: true},
+				{Label: "On"},
+				{Label: "Broken", IsFinal: true},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "on", From: []string{"Off"}, To: []string{"On"}, Event: "TOGGLE", Actions: []*sc.Action{{Label: "count = count + 1"}}},
+				{Label: "off", From: []string{"On"}, To: []string{"Off"}, Event: "TOGGLE"},
+				{Label: "break", From: []string{"On"}, To: []string{"Broken"}, Event: "BREAK"},
+			},
+		},
+		"door": {
+			RootState: &sc.State{Children: []*sc.State{
+				{Label: "Closed", IsInitial: true},
+				{Label: "Open"},
+			}},
+			Transitions: []*sc.Transition{
+				{Label: "open", From: []string{"Closed"}, To: []string{"Open"}, Event: "OPEN"},
+			},
+		},
+	}}
+}
+
+// newClient serves the service over an in-memory connection.
+func newClient(t *testing.T, s *StatechartService) pb.StatechartServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterStatechartServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewStatechartServiceClient(conn)
+}
+
+func createMachine(t *testing.T, client pb.StatechartServiceClient, statechartID string) string {
+	t.Helper()
+	context0, err := structpb.NewStruct(map[string]interface{}{"count": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.CreateMachine(context.Background(), &pb.CreateMachineRequest{StatechartId: statechartID, Context: context0})
+	if err != nil {
+		t.Fatalf("CreateMachine(%s) error = %v", statechartID, err)
+	}
+	return resp.Machine.Id
+}
+
+func step(t *testing.T, client pb.StatechartServiceClient, machineID, event string) *pb.StepResponse {
+	t.Helper()
+	resp, err := client.Step(context.Background(), &pb.StepRequest{MachineId: machineID, Event: event})
+	if err != nil {
+		t.Fatalf("Step(%s, %s) error = %v", machineID, event, err)
+	}
+	return resp
+}
+
+// received describes a WatchResponse as "machine index transition... -> configuration".
+func received(t *testing.T, stream grpc.ServerStreamingClient[pb.WatchResponse]) string {
+	t.Helper()
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	s := fmt.Sprintf("%s %d", resp.MachineId, resp.StepIndex)
+	if resp.StepIndex < 0 {
+		s = resp.MachineId + " created"
+	}
+	for _, tr := range resp.GetStep().GetTransitions() {
+		s += " " + tr.Label
+	}
+	s += " ->"
+	for _, ref := range resp.Configuration.States {
+		s += " " + ref.Label
+	}
+	if resp.State == sc.MachineStateStopped {
+		s += " (stopped)"
+	}
+	return s
+}
+
+func TestWatch(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	id := createMachine(t, client, "light")
+	step(t, client, id, "TOGGLE")
+	step(t, client, id, "TOGGLE")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &pb.WatchRequest{MachineIds: []string{id}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	got := []string{received(t, stream), received(t, stream)}
+	step(t, client, id, "TOGGLE")
+	step(t, client, id, "BREAK")
+	got = append(got, received(t, stream), received(t, stream))
+	want := []string{
+		"light-1 0 on -> __root__ On",
+		"light-1 1 off -> __root__ Off",
+		"light-1 2 on -> __root__ On",
+		"light-1 3 break -> __root__ Broken (stopped)",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Watch() mismatch (-want +got):\n%s", diff)
+	}
+
+	// A client that received the first two steps resumes from the third.
+	stream, err = client.Watch(ctx, &pb.WatchRequest{MachineIds: []string{id}, StartStepIndexes: map[string]int64{id: 2}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	got = []string{received(t, stream), received(t, stream)}
+	if diff := cmp.Diff(want[2:], got); diff != "" {
+		t.Errorf("resumed Watch() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWatchFilter(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &pb.WatchRequest{StatechartId: "light", StartStepIndex: -1})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	door := createMachine(t, client, "door")
+	light := createMachine(t, client, "light")
+	step(t, client, door, "OPEN")
+	step(t, client, light, "TOGGLE")
+	got := []string{received(t, stream), received(t, stream)}
+	want := []string{
+		"light-2 created -> __root__ Off",
+		"light-2 0 on -> __root__ On",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Watch() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWatchLagging(t *testing.T) {
+	s := NewStatechartService(registry())
+	s.WatchBuffer = 1
+	if _, err := s.CreateMachine(context.Background(), &pb.CreateMachineRequest{StatechartId: "door"}); err != nil {
+		t.Fatalf("CreateMachine() error = %v", err)
+	}
+	w := &watcher{req: &pb.WatchRequest{}, updates: make(chan *pb.WatchResponse, s.watchBuffer())}
+	s.watchers = map[*watcher]bool{w: true}
+	if _, err := s.Step(context.Background(), &pb.StepRequest{MachineId: "door-1", Event: "OPEN"}); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if _, err := s.CreateMachine(context.Background(), &pb.CreateMachineRequest{StatechartId: "light"}); err != nil {
+		t.Fatalf("CreateMachine() error = %v", err)
+	}
+	if status.Code(w.err) != codes.ResourceExhausted {
+		t.Fatal("watcher with a full buffer is not lagging")
+	}
+	if _, ok := <-w.updates; !ok {
+		t.Error("buffered update was dropped")
+	}
+	if _, ok := <-w.updates; ok {
+		t.Error("updates of a lagging watcher are not closed")
+	}
+}
+
+func TestWatchDeletedMachine(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "door", MachineId: "hall"}); err != nil {
+		t.Fatalf("CreateMachine() error = %v", err)
+	}
+	named, err := client.Watch(ctx, &pb.WatchRequest{MachineIds: []string{"hall"}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	all, err := client.Watch(ctx, &pb.WatchRequest{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	step(t, client, "hall", "OPEN")
+	for _, stream := range []grpc.ServerStreamingClient[pb.WatchResponse]{named, all} {
+		if got, want := received(t, stream), "hall 0 open -> __root__ Open"; got != want {
+			t.Errorf("Watch() = %q, want %q", got, want)
+		}
+	}
+
+	if _, err := client.DeleteMachine(ctx, &pb.DeleteMachineRequest{Name: "machines/hall"}); err != nil {
+		t.Fatalf("DeleteMachine() error = %v", err)
+	}
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "light", MachineId: "hall"}); err != nil {
+		t.Fatalf("CreateMachine() error = %v", err)
+	}
+	if resp, err := named.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("Recv() after the machine was deleted = %v, %v; want %v", resp, err, codes.NotFound)
+	}
+	// Streams that do not name the machine follow the new one.
+	if got, want := received(t, all), "hall created -> __root__ Off"; got != want {
+		t.Errorf("Watch() of all machines = %q, want %q", got, want)
+	}
+}
+
+func TestStep(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	id := createMachine(t, client, "light")
+	extra, err := structpb.NewStruct(map[string]interface{}{"count": 41, "by": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Step(context.Background(), &pb.StepRequest{MachineId: id, Event: "TOGGLE", Context: extra})
+	if err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if got := resp.Machine.Context.AsMap(); got["count"] != 42.0 || got["by"] != "test" {
+		t.Errorf("context = %v, want the request context applied before the step", got)
+	}
+
+	step(t, client, id, "BREAK")
+	resp = step(t, client, id, "TOGGLE")
+	if got := codes.Code(resp.Result.Code); got != codes.OutOfRange {
+		t.Errorf("Step() of a stopped machine result = %v, want %v", got, codes.OutOfRange)
+	}
+
+	if _, err := client.Step(context.Background(), &pb.StepRequest{Event: "OPEN"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Step() without a machine ID error = %v, want %v", err, codes.InvalidArgument)
+	}
+	if _, err := client.Step(context.Background(), &pb.StepRequest{MachineId: "missing", Event: "OPEN"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Step() of a missing machine error = %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestStepStatechartID(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	if _, err := client.Step(context.Background(), &pb.StepRequest{StatechartId: "door", Event: "OPEN"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Step() without a machine error = %v, want %v", err, codes.NotFound)
+	}
+	createMachine(t, client, "door")
+	resp, err := client.Step(context.Background(), &pb.StepRequest{StatechartId: "door", Event: "OPEN"})
+	if err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if got := codes.Code(resp.Result.Code); got != codes.OK {
+		t.Errorf("Step() of the only machine result = %v, want %v", got, codes.OK)
+	}
+	createMachine(t, client, "door")
+	if _, err := client.Step(context.Background(), &pb.StepRequest{StatechartId: "door", Event: "OPEN"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Step() with two machines error = %v, want %v", err, codes.FailedPrecondition)
+	}
+}
+
+func TestStepExpectedIndex(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	id := createMachine(t, client, "light")
+	step(t, client, id, "TOGGLE")
+
+	// A client that has not seen the first step expects the wrong index.
+	if _, err := client.Step(context.Background(), &pb.StepRequest{MachineId: id, Event: "TOGGLE", ExpectedStepIndex: proto.Int64(0)}); status.Code(err) != codes.Aborted {
+		t.Errorf("Step() at a stale index error = %v, want %v", err, codes.Aborted)
+	}
+	resp, err := client.Step(context.Background(), &pb.StepRequest{MachineId: id, Event: "TOGGLE", ExpectedStepIndex: proto.Int64(1)})
+	if err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if got := len(resp.Machine.StepHistory); got != 2 {
+		t.Errorf("step history length = %d, want 2", got)
+	}
+	if _, err := client.BatchStep(context.Background(), &pb.BatchStepRequest{MachineId: id, Events: []string{"TOGGLE"}, ExpectedStepIndex: proto.Int64(1)}); status.Code(err) != codes.Aborted {
+		t.Errorf("BatchStep() at a stale index error = %v, want %v", err, codes.Aborted)
+	}
+}
+
+func TestBatchStep(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	id := createMachine(t, client, "light")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Watch(ctx, &pb.WatchRequest{MachineIds: []string{id}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	resp, err := client.BatchStep(context.Background(), &pb.BatchStepRequest{MachineId: id, Events: []string{"TOGGLE", "TOGGLE", "TOGGLE"}})
+	if err != nil {
+		t.Fatalf("BatchStep() error = %v", err)
+	}
+	if got := codes.Code(resp.Result.Code); got != codes.OK {
+		t.Fatalf("BatchStep() result = %v, want %v", got, codes.OK)
+	}
+	var labels []string
+	for _, s := range resp.Steps {
+		for _, tr := range s.Transitions {
+			labels = append(labels, tr.Label)
+		}
+	}
+	if diff := cmp.Diff([]string{"on", "off", "on"}, labels); diff != "" {
+		t.Errorf("BatchStep() steps mismatch (-want +got):\n%s", diff)
+	}
+	got := []string{received(t, stream), received(t, stream), received(t, stream)}
+	want := []string{
+		"light-1 0 on -> __root__ On",
+		"light-1 1 off -> __root__ Off",
+		"light-1 2 on -> __root__ On",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Watch() mismatch (-want +got):\n%s", diff)
+	}
+
+	// The light breaks on the second event, so the third fails and none of
+	// the batch is taken.
+	resp, err = client.BatchStep(context.Background(), &pb.BatchStepRequest{MachineId: id, Events: []string{"TOGGLE", "TOGGLE", "BREAK", "TOGGLE"}})
+	if err != nil {
+		t.Fatalf("BatchStep() error = %v", err)
+	}
+	if got := codes.Code(resp.Result.Code); got != codes.OutOfRange {
+		t.Errorf("failed BatchStep() result = %v, want %v", got, codes.OutOfRange)
+	}
+	if got := len(resp.Machine.StepHistory); got != 3 || len(resp.Steps) != 0 {
+		t.Errorf("failed BatchStep() returned %d steps and a history of %d, want 0 and 3", len(resp.Steps), got)
+	}
+	m, err := client.GetMachine(context.Background(), &pb.GetMachineRequest{Name: "machines/" + id})
+	if err != nil {
+		t.Fatalf("GetMachine() error = %v", err)
+	}
+	if got := len(m.StepHistory); got != 3 || m.State != sc.MachineStateRunning {
+		t.Errorf("after a failed batch, machine has %d steps in state %v, want 3 in %v", got, m.State, sc.MachineStateRunning)
+	}
+	step(t, client, id, "BREAK")
+	if got := received(t, stream); got != "light-1 3 break -> __root__ Broken (stopped)" {
+		t.Errorf("Watch() after a failed batch = %q, want the next step only", got)
+	}
+
+	if _, err := client.BatchStep(context.Background(), &pb.BatchStepRequest{MachineId: id}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchStep() without events error = %v, want %v", err, codes.InvalidArgument)
+	}
+	if _, err := client.BatchStep(context.Background(), &pb.BatchStepRequest{MachineId: "missing", Events: []string{"TOGGLE"}}); status.Code(err) != codes.NotFound {
+		t.Errorf("BatchStep() of a missing machine error = %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestServiceErrors(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	if _, err := client.CreateMachine(context.Background(), &pb.CreateMachineRequest{StatechartId: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("CreateMachine() error = %v, want %v", err, codes.NotFound)
+	}
+	stream, err := client.Watch(context.Background(), &pb.WatchRequest{MachineIds: []string{"missing"}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("Recv() error = %v, want %v", err, codes.NotFound)
+	}
+}
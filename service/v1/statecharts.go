@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+// StatechartsCollection is the collection of registered statecharts in resource names.
+const StatechartsCollection = "statecharts"
+
+// RegisterStatechart validates a statechart with the rules of the Validator
+// and registers it. A statechart with violations of error
+// severity is rejected with codes.InvalidArgument and an errdetails.BadRequest
+// listing them. Its submachine states need not be inlined, but they must
+// refer to registered statecharts with which the statechart inlines, as
+// CreateMachine requires; otherwise it is rejected with
+// codes.FailedPrecondition. With validate_only, nothing is registered and the
+// returned statechart has no name.
+func (s *StatechartService) RegisterStatechart(ctx context.Context, req *pb.RegisterStatechartRequest) (*pb.RegisteredStatechart, error) {
+	id := req.GetStatechartId()
+	if err := validateID(id); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "statechart ID: %v", err)
+	}
+	if req.GetStatechart() == nil {
+		return nil, status.Error(codes.InvalidArgument, "statechart is required")
+	}
+	resp, err := s.validator().ValidateChart(ctx, &validationv1.ValidateChartRequest{
+		Chart:       req.GetStatechart(),
+		IgnoreRules: []validationv1.RuleId{validationv1.RuleId_INLINED_SUBMACHINES},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, v := range resp.GetViolations() {
+		if v.GetSeverity() == validationv1.Severity_ERROR {
+			field := "statechart"
+			if xpath := v.GetXpath(); len(xpath) > 0 {
+				field += strings.ReplaceAll(xpath[0], "/", ".")
+			}
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: fmt.Sprintf("%s: %s", v.GetRuleId(), v.GetMessage()),
+			})
+		}
+	}
+	if len(violations) > 0 {
+		st, err := status.New(codes.InvalidArgument, fmt.Sprintf("statechart %q violates %d validation rules", id, len(violations))).
+			WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "attaching violations: %v", err)
+		}
+		return nil, st.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.registry.Statecharts[id]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "statechart %q already exists", id)
+	}
+	if _, err := semantics.Inline(req.GetStatechart(), s.registry); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "statechart %q: %v", id, err)
+	}
+	chart := proto.Clone(req.GetStatechart()).(*sc.Statechart)
+	if req.GetValidateOnly() {
+		return &pb.RegisteredStatechart{Statechart: chart}, nil
+	}
+	s.registry.Statecharts[id] = chart
+	return registered(id, chart), nil
+}
+
+// GetStatechart returns a registered statechart.
+func (s *StatechartService) GetStatechart(ctx context.Context, req *pb.GetStatechartRequest) (*pb.RegisteredStatechart, error) {
+	id, err := parseName(StatechartsCollection, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chart, ok := s.registry.Statecharts[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "statechart %q not found", id)
+	}
+	return registered(id, chart), nil
+}
+
+// ListStatecharts lists the registered statecharts in increasing order of ID.
+func (s *StatechartService) ListStatecharts(ctx context.Context, req *pb.ListStatechartsRequest) (*pb.ListStatechartsResponse, error) {
+	size, err := pageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	after, err := parsePageToken(req.GetPageToken(), "")
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.registry.Statecharts))
+	for id := range s.registry.Statecharts {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	resp := &pb.ListStatechartsResponse{}
+	if len(ids) > size {
+		ids = ids[:size]
+		resp.NextPageToken = pageToken("", ids[size-1])
+	}
+	for _, id := range ids {
+		resp.Statecharts = append(resp.Statecharts, registered(id, s.registry.Statecharts[id]))
+	}
+	return resp, nil
+}
+
+// registered returns a copy of a registered statechart.
+func registered(id string, chart *sc.Statechart) *pb.RegisteredStatechart {
+	return &pb.RegisteredStatechart{
+		Name:       StatechartsCollection + "/" + id,
+		Statechart: proto.Clone(chart).(*sc.Statechart),
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+func fanStatechart() *sc.Statechart {
+	return &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Children: []*sc.State{
+			{Label: "Still", IsInitial: true},
+			{Label: "Spinning"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "start", From: []string{"Still"}, To: []string{"Spinning"}, Event: "START"},
+			{Label: "stop", From: []string{"Spinning"}, To: []string{"Still"}, Event: "STOP"},
+		},
+	}
+}
+
+func TestRegisterStatechart(t *testing.T) {
+	client := newClient(t, NewStatechartService(nil))
+	ctx := context.Background()
+	got, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "fan", Statechart: fanStatechart()})
+	if err != nil {
+		t.Fatalf("RegisterStatechart() error = %v", err)
+	}
+	want := &pb.RegisteredStatechart{Name: "statecharts/fan", Statechart: fanStatechart()}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("RegisterStatechart() mismatch (-want +got):\n%s", diff)
+	}
+	got, err = client.GetStatechart(ctx, &pb.GetStatechartRequest{Name: "statecharts/fan"})
+	if err != nil {
+		t.Fatalf("GetStatechart() error = %v", err)
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("GetStatechart() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "fan"}); err != nil {
+		t.Errorf("CreateMachine() of the registered statechart error = %v", err)
+	}
+
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "fan", Statechart: fanStatechart()}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("RegisterStatechart() of an existing ID error = %v, want %v", err, codes.AlreadyExists)
+	}
+	draft, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "draft", Statechart: fanStatechart(), ValidateOnly: true})
+	if err != nil {
+		t.Errorf("RegisterStatechart(validate_only) error = %v", err)
+	} else if draft.Name != "" {
+		t.Errorf("RegisterStatechart(validate_only) name = %q, want none", draft.Name)
+	}
+	if _, err := client.GetStatechart(ctx, &pb.GetStatechartRequest{Name: "statecharts/draft"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetStatechart() of a validated statechart error = %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestRegisterStatechartViolations(t *testing.T) {
+	client := newClient(t, NewStatechartService(nil))
+	chart := fanStatechart()
+	chart.RootState.Children[1].Label = "Still"
+	_, err := client.RegisterStatechart(context.Background(), &pb.RegisterStatechartRequest{StatechartId: "fan", Statechart: chart})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("RegisterStatechart() error = %v, want %v", err, codes.InvalidArgument)
+	}
+	var descriptions []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				descriptions = append(descriptions, v.Field+": "+v.Description)
+			}
+		}
+	}
+	// The transitions of the fan still refer to the renamed state.
+	want := []string{
+		"statechart.root_state.children[0]: UNIQUE_STATE_LABELS: ",
+		"statechart.transitions[0].to[0]: TRANSITION_ENDPOINTS: ",
+		"statechart.transitions[1].from[0]: TRANSITION_ENDPOINTS: ",
+	}
+	if len(descriptions) != len(want) {
+		t.Fatalf("violations = %q, want %d", descriptions, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(descriptions[i], prefix) {
+			t.Errorf("violation %d = %q, want prefix %q", i, descriptions[i], prefix)
+		}
+	}
+}
+
+// TestRegisterStatechartSubmachines checks that registration accepts exactly
+// the statecharts that CreateMachine can inline.
+func TestRegisterStatechartSubmachines(t *testing.T) {
+	client := newClient(t, NewStatechartService(nil))
+	ctx := context.Background()
+	room := &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Children: []*sc.State{
+			{Label: "Idle", IsInitial: true},
+			{Label: "Fan", Submachine: "fan"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "cool", From: []string{"Idle"}, To: []string{"Fan.Spinning"}, Event: "HOT"},
+		},
+	}
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "room", Statechart: room}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("RegisterStatechart() with an unregistered submachine error = %v, want %v", err, codes.FailedPrecondition)
+	}
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "fan", Statechart: fanStatechart()}); err != nil {
+		t.Fatalf("RegisterStatechart(fan) error = %v", err)
+	}
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "room", Statechart: room}); err != nil {
+		t.Fatalf("RegisterStatechart(room) error = %v", err)
+	}
+	if _, err := client.CreateMachine(ctx, &pb.CreateMachineRequest{StatechartId: "room"}); err != nil {
+		t.Errorf("CreateMachine() of the registered statechart error = %v", err)
+	}
+
+	room.Transitions[0].To = []string{"Fan.Nope"}
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "den", Statechart: room}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("RegisterStatechart() with an unknown submachine state error = %v, want %v", err, codes.FailedPrecondition)
+	}
+	broken := fanStatechart()
+	broken.Transitions[1].To = []string{"Nope"}
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "broken", Statechart: broken}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RegisterStatechart() with an unknown state error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestRegisterStatechartCustomRules(t *testing.T) {
+	s := NewStatechartService(nil)
+	s.Validator = validation.NewSemanticValidator()
+	err := s.Validator.Register(validation.NewRule("acme.STOP_EVENT", "Statecharts must handle STOP.", validationv1.Severity_ERROR,
+		func(c *validation.Chart) []*validationv1.Violation {
+			for _, t := range c.Transitions {
+				if t.Event == "STOP" {
+					return nil
+				}
+			}
+			return []*validationv1.Violation{{Message: "no transition on STOP"}}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, s)
+	ctx := context.Background()
+	if _, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "fan", Statechart: fanStatechart()}); err != nil {
+		t.Errorf("RegisterStatechart() of a statechart following the rule error = %v", err)
+	}
+	chart := fanStatechart()
+	chart.Transitions = chart.Transitions[:1]
+	_, err = client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "runaway", Statechart: chart})
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || len(st.Details()) != 1 ||
+		!strings.Contains(st.Details()[0].(*errdetails.BadRequest).String(), "acme.STOP_EVENT: no transition on STOP") {
+		t.Errorf("RegisterStatechart() violating the rule error = %v with details %v, want the acme.STOP_EVENT violation", err, st.Details())
+	}
+}
+
+func TestStatechartErrors(t *testing.T) {
+	client := newClient(t, NewStatechartService(nil))
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"register without ID", func() error {
+			_, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{Statechart: fanStatechart()})
+			return err
+		}, codes.InvalidArgument},
+		{"register ID with slash", func() error {
+			_, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "a/b", Statechart: fanStatechart()})
+			return err
+		}, codes.InvalidArgument},
+		{"register without statechart", func() error {
+			_, err := client.RegisterStatechart(ctx, &pb.RegisterStatechartRequest{StatechartId: "fan"})
+			return err
+		}, codes.InvalidArgument},
+		{"get malformed name", func() error {
+			_, err := client.GetStatechart(ctx, &pb.GetStatechartRequest{Name: "fan"})
+			return err
+		}, codes.InvalidArgument},
+		{"get unknown", func() error {
+			_, err := client.GetStatechart(ctx, &pb.GetStatechartRequest{Name: "statecharts/fan"})
+			return err
+		}, codes.NotFound},
+		{"list negative page size", func() error {
+			_, err := client.ListStatecharts(ctx, &pb.ListStatechartsRequest{PageSize: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"list invalid page token", func() error {
+			_, err := client.ListStatecharts(ctx, &pb.ListStatechartsRequest{PageToken: "!"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); status.Code(err) != tt.want {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestListStatecharts(t *testing.T) {
+	client := newClient(t, NewStatechartService(registry()))
+	for _, id := range []string{"fan", "amp"} {
+		if _, err := client.RegisterStatechart(context.Background(), &pb.RegisterStatechartRequest{StatechartId: id, Statechart: fanStatechart()}); err != nil {
+			t.Fatalf("RegisterStatechart(%s) error = %v", id, err)
+		}
+	}
+	var pages [][]string
+	req := &pb.ListStatechartsRequest{PageSize: 3}
+	for {
+		resp, err := client.ListStatecharts(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ListStatecharts() error = %v", err)
+		}
+		var names []string
+		for _, c := range resp.Statecharts {
+			names = append(names, c.Name)
+		}
+		pages = append(pages, names)
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	want := [][]string{
+		{"statecharts/amp", "statecharts/door", "statecharts/fan"},
+		{"statecharts/light"},
+	}
+	if diff := cmp.Diff(want, pages); diff != "" {
+		t.Errorf("ListStatecharts() pages mismatch (-want +got):\n%s", diff)
+	}
+}
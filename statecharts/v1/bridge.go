@@ -4,6 +4,7 @@ package v1
 import (
 	"github.com/tmc/sc"
 	pb "github.com/tmc/sc/gen/statecharts/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 // Statechart is aliased from the generated protobuf package
@@ -74,17 +75,22 @@ func fromNativeState(state *sc.State) *State {
 	}
 
 	result := &State{
-		Label:     state.Label,
-		Type:      pb.StateType(state.Type),
-		IsInitial: state.IsInitial,
-		IsFinal:   state.IsFinal,
-		Children:  make([]*State, 0, len(state.Children)),
+		Label:      state.Label,
+		Type:       pb.StateType(state.Type),
+		IsInitial:  state.IsInitial,
+		IsFinal:    state.IsFinal,
+		Submachine: state.Submachine,
+		Children:   make([]*State, 0, len(state.Children)),
 	}
 
 	for _, child := range state.Children {
 		result.Children = append(result.Children, fromNativeState(child))
 	}
 
+	for _, invoke := range state.Invokes {
+		result.Invokes = append(result.Invokes, proto.Clone(invoke).(*pb.Invoke))
+	}
+
 	return result
 }
 
@@ -94,17 +100,22 @@ func toNativeState(state *State) *sc.State {
 	}
 
 	result := &sc.State{
-		Label:     state.Label,
-		Type:      sc.StateType(state.Type),
-		IsInitial: state.IsInitial,
-		IsFinal:   state.IsFinal,
-		Children:  make([]*sc.State, 0, len(state.Children)),
+		Label:      state.Label,
+		Type:       sc.StateType(state.Type),
+		IsInitial:  state.IsInitial,
+		IsFinal:    state.IsFinal,
+		Submachine: state.Submachine,
+		Children:   make([]*sc.State, 0, len(state.Children)),
 	}
 
 	for _, child := range state.Children {
 		result.Children = append(result.Children, toNativeState(child))
 	}
 
+	for _, invoke := range state.Invokes {
+		result.Invokes = append(result.Invokes, proto.Clone(invoke).(*sc.Invoke))
+	}
+
 	return result
 }
 
@@ -118,6 +129,7 @@ func fromNativeTransition(transition *sc.Transition) *Transition {
 		From:  transition.From,
 		To:    transition.To,
 		Event: transition.Event,
+		Kind:  pb.TransitionKind(transition.Kind),
 	}
 
 	if transition.Guard != nil {
@@ -148,6 +160,7 @@ func toNativeTransition(transition *Transition) *sc.Transition {
 		From:  transition.From,
 		To:    transition.To,
 		Event: transition.Event,
+		Kind:  sc.TransitionKind(transition.Kind),
 	}
 
 	if transition.Guard != nil {
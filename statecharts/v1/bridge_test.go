@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/sc"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestBridgeRoundTrip(t *testing.T) {
+	chart := &sc.Statechart{
+		RootState: &sc.State{Label: "__root__", Type: sc.StateTypeNormal, Children: []*sc.State{
+			{Label: "A", Type: sc.StateTypeBasic, IsInitial: true, Invokes: []*sc.Invoke{{Id: "w", StatechartId: "worker"}}},
+			{Label: "B", Type: sc.StateTypeBasic, IsFinal: true},
+			{Label: "C", Type: sc.StateTypeBasic, Submachine: "payment"},
+		}},
+		Transitions: []*sc.Transition{
+			{Label: "t", From: []string{"A"}, To: []string{"B"}, Event: "E", Guard: &sc.Guard{Expression: "x > 1"}, Actions: []*sc.Action{{Label: "x = 0"}}},
+			{Label: "u", From: []string{"A"}, Event: "F", Kind: sc.TransitionKindInternal},
+		},
+		Events: []*sc.Event{{Label: "E"}, {Label: "F"}},
+	}
+	if diff := cmp.Diff(chart, ToNative(FromNative(chart)), protocmp.Transform()); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
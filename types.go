@@ -8,6 +8,9 @@ type StateType = v1.StateType
 // MachineState encodes the high-level state of a statechart.
 type MachineState = v1.MachineState
 
+// TransitionKind determines which states a transition exits and enters.
+type TransitionKind = v1.TransitionKind
+
 // Statechart defines a Statechart.
 type Statechart = v1.Statechart
 
@@ -35,15 +38,35 @@ type Configuration = v1.Configuration
 // Machine describes an instance of a Statechart.
 type Machine = v1.Machine
 
+// Step describes a step carried out by a Machine.
+type Step = v1.Step
+
+// Invoke defines a child machine started while a state is active.
+type Invoke = v1.Invoke
+
+// StatechartRegistry maps IDs to Statecharts, such as those used as submachines.
+type StatechartRegistry = v1.StatechartRegistry
+
 const (
 	StateTypeUnspecified = v1.StateType_STATE_TYPE_UNSPECIFIED
 	StateTypeBasic       = v1.StateType_STATE_TYPE_BASIC
 	StateTypeNormal      = v1.StateType_STATE_TYPE_NORMAL
 	StateTypeParallel    = v1.StateType_STATE_TYPE_PARALLEL
+	StateTypeChoice      = v1.StateType_STATE_TYPE_CHOICE
+	StateTypeJunction    = v1.StateType_STATE_TYPE_JUNCTION
+	StateTypeEntryPoint  = v1.StateType_STATE_TYPE_ENTRY_POINT
+	StateTypeExitPoint   = v1.StateType_STATE_TYPE_EXIT_POINT
 	// StateTypeOrthogonal is an alias for StateTypeParallel for compatibility with academic literature
 	StateTypeOrthogonal  = v1.StateType_STATE_TYPE_ORTHOGONAL
 )
 
+const (
+	TransitionKindUnspecified = v1.TransitionKind_TRANSITION_KIND_UNSPECIFIED
+	TransitionKindExternal    = v1.TransitionKind_TRANSITION_KIND_EXTERNAL
+	TransitionKindInternal    = v1.TransitionKind_TRANSITION_KIND_INTERNAL
+	TransitionKindLocal       = v1.TransitionKind_TRANSITION_KIND_LOCAL
+)
+
 const (
 	MachineStateUnspecified = v1.MachineState_MACHINE_STATE_UNSPECIFIED
 	MachineStateRunning     = v1.MachineState_MACHINE_STATE_RUNNING
@@ -0,0 +1,57 @@
+package validation_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/validation/v1"
+)
+
+// An organization requires state labels in PascalCase.
+func ExampleSemanticValidator_Register() {
+	pascalCase := regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	naming := validation.NewRule("acme.STATE_NAMING", "State labels must be PascalCase.", validationv1.Severity_ERROR,
+		func(c *validation.Chart) []*validationv1.Violation {
+			var violations []*validationv1.Violation
+			var visit func(*sc.State)
+			visit = func(state *sc.State) {
+				if state != c.RootState && !pascalCase.MatchString(state.Label) {
+					violations = append(violations, &validationv1.Violation{
+						Message: fmt.Sprintf("state %q is not PascalCase", state.Label),
+						Xpath:   []string{c.StatePath(state)},
+					})
+				}
+				for _, child := range state.Children {
+					visit(child)
+				}
+			}
+			visit(c.RootState)
+			return violations
+		})
+
+	validator := validation.NewSemanticValidator()
+	if err := validator.Register(naming); err != nil {
+		panic(err)
+	}
+	resp, err := validator.ValidateChart(context.Background(), &validationv1.ValidateChartRequest{
+		Chart: &pb.Statechart{RootState: &pb.State{Label: "__root__", Children: []*pb.State{
+			{Label: "Idle", IsInitial: true, IsFinal: true},
+			{Label: "waiting_for_input", IsFinal: true},
+		}}},
+		IgnoreRules: []validationv1.RuleId{validationv1.RuleId_REACHABLE_STATES},
+	})
+	if err != nil {
+		panic(err)
+	}
+	for _, v := range resp.Violations {
+		fmt.Println(v.RuleId, v.Severity, v.Message, v.Xpath)
+	}
+	fmt.Println(resp.Status.Message)
+	// Output:
+	// acme.STATE_NAMING ERROR state "waiting_for_input" is not PascalCase [/root_state/children[1]]
+	// validation failed
+}
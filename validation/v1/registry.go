@@ -0,0 +1,24 @@
+package validation
+
+import (
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+// Rule is a validation rule of statecharts. The validator checks the rules of
+// the semantics package, which Statechart.Validate checks in process.
+type Rule = semantics.Rule
+
+// Chart is a statechart under validation. It caches analyses shared by rules.
+type Chart = semantics.Chart
+
+// NewRule returns a rule checked by a function.
+func NewRule(id, description string, severity validationv1.Severity, check func(*Chart) []*validationv1.Violation) Rule {
+	return semantics.NewRule(id, description, severity, check)
+}
+
+// BuiltinRules returns the rules of the SemanticValidator, in the order in
+// which they are checked.
+func BuiltinRules() []Rule {
+	return semantics.BuiltinRules()
+}
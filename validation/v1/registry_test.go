@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"github.com/tmc/sc"
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// maxDepth is a rule limiting the nesting of states.
+func maxDepth(max int) Rule {
+	return NewRule("acme.MAX_DEPTH", fmt.Sprintf("States must not be nested more than %d deep.", max), validationv1.Severity_WARNING,
+		func(c *Chart) []*validationv1.Violation {
+			var violations []*validationv1.Violation
+			var visit func(*sc.State, int)
+			visit = func(state *sc.State, depth int) {
+				if depth > max {
+					violations = append(violations, &validationv1.Violation{Message: fmt.Sprintf("state %s is nested %d deep", state.Label, depth)})
+				}
+				for _, child := range state.Children {
+					visit(child, depth+1)
+				}
+			}
+			visit(c.RootState, 0)
+			return violations
+		})
+}
+
+// nested is a statechart with states nested two deep.
+func nested() *pb.Statechart {
+	return &pb.Statechart{
+		RootState: &pb.State{Label: "__root__", Children: []*pb.State{
+			{Label: "Outer", IsInitial: true, Children: []*pb.State{
+				{Label: "Inner", IsInitial: true, IsFinal: true},
+			}},
+		}},
+	}
+}
+
+func TestBuiltinRules(t *testing.T) {
+	var ids []string
+	for _, rule := range NewSemanticValidator().Rules() {
+		ids = append(ids, rule.ID())
+		if _, ok := validationv1.RuleId_value[rule.ID()]; !ok {
+			t.Errorf("built-in rule %s is not a RuleId", rule.ID())
+		}
+		if rule.Description() == "" {
+			t.Errorf("built-in rule %s has no description", rule.ID())
+		}
+	}
+	want := []string{
+		"ROOT_STATE", "UNIQUE_STATE_LABELS", "SINGLE_DEFAULT_CHILD", "BASIC_HAS_NO_CHILDREN", "COMPOUND_HAS_CHILDREN", "TRANSITION_ENDPOINTS",
+		"FORKS_AND_JOINS", "INTERNAL_TRANSITIONS", "PSEUDOSTATES", "INLINED_SUBMACHINES", "CONNECTION_POINTS", "INVOKES",
+		"CONFIGURATION_GRAPH", "REACHABLE_STATES", "LIVE_TRANSITIONS", "NO_DEADLOCKS",
+	}
+	if diff := cmp.Diff(want, ids); diff != "" {
+		t.Errorf("built-in rules mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	validator := NewSemanticValidator()
+	n := len(validator.Rules())
+	if err := validator.Register(maxDepth(1), NewRule("UNIQUE_STATE_LABELS", "", validationv1.Severity_ERROR, nil)); err == nil {
+		t.Error("Register() of a duplicate ID succeeded")
+	}
+	if err := validator.Register(NewRule("", "", validationv1.Severity_ERROR, nil)); err == nil {
+		t.Error("Register() of an empty ID succeeded")
+	}
+	if got := len(validator.Rules()); got != n {
+		t.Errorf("failed Register() calls added %d rules", got-n)
+	}
+	if err := validator.Register(maxDepth(1)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := validator.Register(maxDepth(2)); err == nil {
+		t.Error("Register() of a registered ID succeeded")
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	validator := NewSemanticValidator()
+	if err := validator.Register(maxDepth(1)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := validator.ValidateChart(context.Background(), &validationv1.ValidateChartRequest{Chart: nested()})
+	if err != nil {
+		t.Fatalf("ValidateChart() error = %v", err)
+	}
+	want := []*validationv1.Violation{{
+		RuleId:   "acme.MAX_DEPTH",
+		Severity: validationv1.Severity_WARNING,
+		Message:  "state Inner is nested 2 deep",
+	}}
+	if diff := cmp.Diff(want, resp.Violations, protocmp.Transform()); diff != "" {
+		t.Errorf("violations mismatch (-want +got):\n%s", diff)
+	}
+	if codes.Code(resp.Status.Code) != codes.OK {
+		t.Errorf("status = %v, want OK for warnings", resp.Status)
+	}
+}
+
+func TestSeverityOverrides(t *testing.T) {
+	validator := NewSemanticValidator()
+	if err := validator.Register(maxDepth(1)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	resp, err := validator.ValidateChart(ctx, &validationv1.ValidateChartRequest{
+		Chart:             nested(),
+		SeverityOverrides: map[string]validationv1.Severity{"acme.MAX_DEPTH": validationv1.Severity_ERROR},
+	})
+	if err != nil {
+		t.Fatalf("ValidateChart() error = %v", err)
+	}
+	if len(resp.Violations) != 1 || resp.Violations[0].Severity != validationv1.Severity_ERROR || codes.Code(resp.Status.Code) != codes.FailedPrecondition {
+		t.Errorf("ValidateChart() with an ERROR override = %v, %v; want a failing ERROR violation", resp.Status, resp.Violations)
+	}
+
+	// Built-in rules are overridden, and ignored, by the names of their RuleIds.
+	twins := &pb.Statechart{RootState: &pb.State{Label: "__root__", Children: []*pb.State{
+		{Label: "A", IsInitial: true, IsFinal: true},
+		{Label: "A"},
+	}}}
+	trace, err := validator.ValidateTrace(ctx, &validationv1.ValidateTraceRequest{
+		Chart:             twins,
+		IgnoreRules:       []validationv1.RuleId{validationv1.RuleId_REACHABLE_STATES},
+		SeverityOverrides: map[string]validationv1.Severity{"UNIQUE_STATE_LABELS": validationv1.Severity_INFO},
+	})
+	if err != nil {
+		t.Fatalf("ValidateTrace() error = %v", err)
+	}
+	want := []*validationv1.Violation{{
+		Rule:     validationv1.RuleId_UNIQUE_STATE_LABELS,
+		RuleId:   "UNIQUE_STATE_LABELS",
+		Severity: validationv1.Severity_INFO,
+		Message:  "duplicate state label: A",
+		Xpath:    []string{"/root_state/children[0]", "/root_state/children[1]"},
+	}}
+	if diff := cmp.Diff(want, trace.Violations, protocmp.Transform()); diff != "" {
+		t.Errorf("violations mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, overrides := range []map[string]validationv1.Severity{
+		{"acme.UNKNOWN": validationv1.Severity_ERROR},
+		{"acme.MAX_DEPTH": validationv1.Severity_SEVERITY_UNSPECIFIED},
+	} {
+		_, err := validator.ValidateChart(ctx, &validationv1.ValidateChartRequest{Chart: nested(), SeverityOverrides: overrides})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ValidateChart() with overrides %v error = %v, want %v", overrides, err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// source is the text of a statechart with the offsets of its elements by xpath.
+type source struct {
+	text    string
+	offsets map[string]int
+}
+
+// parseSource parses a statechart in a text format and locates its elements.
+func parseSource(src *validationv1.ChartSource) (*pb.Statechart, *source, error) {
+	chart := &pb.Statechart{}
+	var locate func(string) (map[string]int, error)
+	switch src.GetFormat() {
+	case validationv1.SourceFormat_JSON:
+		if err := protojson.Unmarshal([]byte(src.GetText()), chart); err != nil {
+			return nil, nil, err
+		}
+		locate = locateJSON
+	case validationv1.SourceFormat_TEXTPROTO:
+		if err := prototext.Unmarshal([]byte(src.GetText()), chart); err != nil {
+			return nil, nil, err
+		}
+		locate = locateText
+	default:
+		return nil, nil, fmt.Errorf("unsupported source format %v", src.GetFormat())
+	}
+	offsets, err := locate(src.GetText())
+	if err != nil {
+		return nil, nil, err
+	}
+	return chart, &source{text: src.GetText(), offsets: offsets}, nil
+}
+
+// locate returns the locations of the xpaths that are elements of the text.
+func (s *source) locate(xpaths []string) []*validationv1.SourceLocation {
+	var locations []*validationv1.SourceLocation
+	for _, xpath := range xpaths {
+		offset, ok := s.offsets[xpath]
+		if !ok {
+			continue
+		}
+		before := s.text[:offset]
+		line := strings.Count(before, "\n") + 1
+		column := offset - strings.LastIndexByte(before, '\n')
+		locations = append(locations, &validationv1.SourceLocation{Xpath: xpath, Line: int32(line), Column: int32(column)})
+	}
+	return locations
+}
+
+// statechartDescriptor describes the messages whose fields are located.
+var statechartDescriptor = (&pb.Statechart{}).ProtoReflect().Descriptor()
+
+// field returns the field of a message named in a text format, by its JSON,
+// text or proto name, or nil.
+func field(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if md == nil {
+		return nil
+	}
+	fields := md.Fields()
+	if fd := fields.ByJSONName(name); fd != nil {
+		return fd
+	}
+	if fd := fields.ByTextName(name); fd != nil {
+		return fd
+	}
+	return fields.ByName(protoreflect.Name(name))
+}
+
+// elementOf returns the message descriptor of the elements of a field whose
+// fields are located, or nil.
+func elementOf(fd protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+	if fd == nil || fd.IsMap() {
+		return nil
+	}
+	return fd.Message()
+}
+
+// locateJSON returns the offsets of the elements of a statechart in its
+// protobuf JSON encoding: the offset of the name of a field, or of its value
+// in a list.
+func locateJSON(text string) (map[string]int, error) {
+	offsets := make(map[string]int)
+	dec := json.NewDecoder(strings.NewReader(text))
+	// next returns the offset of the next token.
+	next := func() int {
+		offset := int(dec.InputOffset())
+		for offset < len(text) && strings.IndexByte(" \t\r\n:,", text[offset]) >= 0 {
+			offset++
+		}
+		return offset
+	}
+	var value func(path string, md protoreflect.MessageDescriptor) error
+	value = func(path string, md protoreflect.MessageDescriptor) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				offset := next()
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := tok.(string)
+				fd := field(md, name)
+				if fd == nil {
+					if err := value("", nil); err != nil {
+						return err
+					}
+					continue
+				}
+				child := path + "/" + string(fd.Name())
+				offsets[child] = offset
+				if !fd.IsList() {
+					if err := value(child, elementOf(fd)); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := list(dec, func(i int) error {
+					offsets[fmt.Sprintf("%s[%d]", child, i)] = next()
+					return value(fmt.Sprintf("%s[%d]", child, i), elementOf(fd))
+				}); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for dec.More() {
+				if err := value("", nil); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	if err := value("", statechartDescriptor); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// list decodes a JSON array, or null, calling element for each of its
+// elements to decode them.
+func list(dec *json.Decoder, element func(i int) error) error {
+	tok, err := dec.Token()
+	if err != nil || tok != json.Delim('[') {
+		return err
+	}
+	for i := 0; dec.More(); i++ {
+		if err := element(i); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// locateText returns the offsets of the elements of a statechart in the
+// protobuf text format: the offset of the name of a field, or of its value in
+// a list.
+func locateText(text string) (map[string]int, error) {
+	s := &textScanner{text: text, offsets: make(map[string]int)}
+	if err := s.message("", statechartDescriptor, 0); err != nil {
+		return nil, err
+	}
+	return s.offsets, nil
+}
+
+// textScanner scans the protobuf text format, recording the offsets of fields.
+type textScanner struct {
+	text    string
+	pos     int
+	offsets map[string]int
+}
+
+// skip skips whitespace and comments.
+func (s *textScanner) skip() {
+	for s.pos < len(s.text) {
+		switch c := s.text[s.pos]; {
+		case c == '#':
+			for s.pos < len(s.text) && s.text[s.pos] != '\n' {
+				s.pos++
+			}
+		case strings.IndexByte(" \t\r\n\v\f", c) >= 0:
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next byte after whitespace and comments, or 0 at the end.
+func (s *textScanner) peek() byte {
+	s.skip()
+	if s.pos == len(s.text) {
+		return 0
+	}
+	return s.text[s.pos]
+}
+
+// errorf returns an error at the current position.
+func (s *textScanner) errorf(format string, args ...any) error {
+	before := s.text[:s.pos]
+	line := strings.Count(before, "\n") + 1
+	column := s.pos - strings.LastIndexByte(before, '\n')
+	return fmt.Errorf("%d:%d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+// message scans the fields of a message up to its closing delimiter, or to
+// the end of the text if end is 0.
+func (s *textScanner) message(path string, md protoreflect.MessageDescriptor, end byte) error {
+	counts := make(map[protoreflect.FieldNumber]int)
+	for {
+		switch c := s.peek(); c {
+		case end:
+			if end != 0 {
+				s.pos++
+			}
+			return nil
+		case 0:
+			return s.errorf("unexpected end of text")
+		}
+		offset := s.pos
+		name, err := s.name()
+		if err != nil {
+			return err
+		}
+		fd := field(md, name)
+		if s.peek() == ':' {
+			s.pos++
+		}
+		var child string
+		if fd != nil {
+			child = path + "/" + string(fd.Name())
+		}
+		switch {
+		case fd == nil || !fd.IsList():
+			if fd != nil {
+				s.offsets[child] = offset
+			}
+			err = s.value(child, elementOf(fd))
+		case s.peek() == '[':
+			s.pos++
+			if _, ok := s.offsets[child]; !ok {
+				s.offsets[child] = offset
+			}
+			err = s.list(func() error {
+				element := fmt.Sprintf("%s[%d]", child, counts[fd.Number()])
+				counts[fd.Number()]++
+				s.offsets[element] = s.pos
+				return s.value(element, elementOf(fd))
+			})
+		default:
+			element := fmt.Sprintf("%s[%d]", child, counts[fd.Number()])
+			counts[fd.Number()]++
+			s.offsets[element] = offset
+			if _, ok := s.offsets[child]; !ok {
+				s.offsets[child] = offset
+			}
+			err = s.value(element, elementOf(fd))
+		}
+		if err != nil {
+			return err
+		}
+		if c := s.peek(); c == ',' || c == ';' {
+			s.pos++
+		}
+	}
+}
+
+// list scans the elements of a list after its opening bracket.
+func (s *textScanner) list(element func() error) error {
+	for {
+		switch s.peek() {
+		case ']':
+			s.pos++
+			return nil
+		case 0:
+			return s.errorf("unexpected end of text")
+		}
+		if err := element(); err != nil {
+			return err
+		}
+		if s.peek() == ',' {
+			s.pos++
+		}
+	}
+}
+
+// value scans the value of a field: a message, a scalar or a list of them.
+func (s *textScanner) value(path string, md protoreflect.MessageDescriptor) error {
+	switch c := s.peek(); c {
+	case 0:
+		return s.errorf("unexpected end of text")
+	case '[':
+		s.pos++
+		return s.list(func() error { return s.value("", nil) })
+	case '{':
+		s.pos++
+		return s.message(path, md, '}')
+	case '<':
+		s.pos++
+		return s.message(path, md, '>')
+	case '"', '\'':
+		for c := s.peek(); c == '"' || c == '\''; c = s.peek() {
+			if err := s.quoted(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case '-':
+		s.pos++
+		s.skip()
+	}
+	if s.ident() == "" {
+		return s.errorf("unexpected %q", s.text[s.pos:s.pos+1])
+	}
+	return nil
+}
+
+// name scans the name of a field: an identifier, or an extension or Any
+// type URL in brackets.
+func (s *textScanner) name() (string, error) {
+	if s.peek() == '[' {
+		i := strings.IndexByte(s.text[s.pos:], ']')
+		if i < 0 {
+			return "", s.errorf("unterminated field name")
+		}
+		name := s.text[s.pos : s.pos+i+1]
+		s.pos += i + 1
+		return name, nil
+	}
+	if name := s.ident(); name != "" {
+		return name, nil
+	}
+	return "", s.errorf("expected a field name")
+}
+
+// ident scans an identifier or a number.
+func (s *textScanner) ident() string {
+	start := s.pos
+	for s.pos < len(s.text) {
+		c := s.text[s.pos]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '+' || c == '-') {
+			break
+		}
+		s.pos++
+	}
+	return s.text[start:s.pos]
+}
+
+// quoted scans a quoted string.
+func (s *textScanner) quoted() error {
+	quote := s.text[s.pos]
+	for i := s.pos + 1; i < len(s.text); i++ {
+		switch s.text[i] {
+		case '\\':
+			i++
+		case quote:
+			s.pos = i + 1
+			return nil
+		case '\n':
+			return s.errorf("unterminated string")
+		}
+	}
+	return s.errorf("unterminated string")
+}
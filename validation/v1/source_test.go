@@ -0,0 +1,134 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+)
+
+// twinsJSON and twinsText are a statechart with two states labeled A, in
+// both text formats.
+const (
+	twinsJSON = `{
+  "rootState": {
+    "label": "__root__",
+    "children": [
+      {"label": "A", "isInitial": true, "isFinal": true},
+      {"label": "B", "isFinal": true},
+      {"label": "A"}
+    ]
+  },
+  "transitions": [{"label": "t", "from": ["A"], "to": ["B"], "event": "go"}]
+}`
+	twinsText = `# Two states labeled A.
+root_state {
+  label: "__root__"
+  children { label: "A" is_initial: true is_final: true }
+  children: [{ label: "B" is_final: true }, < label: 'A' >]
+}
+transitions {
+  label: "t" from: ["A"]
+  to: "B"
+  event: "go"
+}
+`
+)
+
+func TestLocateJSON(t *testing.T) {
+	offsets, err := locateJSON(twinsJSON)
+	if err != nil {
+		t.Fatalf("locateJSON() error = %v", err)
+	}
+	src := &source{text: twinsJSON, offsets: offsets}
+	got := src.locate([]string{"/root_state", "/root_state/children[0]", "/root_state/children[2]", "/root_state/children[2]/label", "/transitions[0]/to", "/events"})
+	want := []*validationv1.SourceLocation{
+		{Xpath: "/root_state", Line: 2, Column: 3},
+		{Xpath: "/root_state/children[0]", Line: 5, Column: 7},
+		{Xpath: "/root_state/children[2]", Line: 7, Column: 7},
+		{Xpath: "/root_state/children[2]/label", Line: 7, Column: 8},
+		{Xpath: "/transitions[0]/to", Line: 10, Column: 49},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("locate() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLocateText(t *testing.T) {
+	offsets, err := locateText(twinsText)
+	if err != nil {
+		t.Fatalf("locateText() error = %v", err)
+	}
+	src := &source{text: twinsText, offsets: offsets}
+	got := src.locate([]string{"/root_state", "/root_state/children[0]", "/root_state/children[1]", "/root_state/children[2]", "/root_state/children[2]/label", "/transitions[0]/to"})
+	want := []*validationv1.SourceLocation{
+		{Xpath: "/root_state", Line: 2, Column: 1},
+		{Xpath: "/root_state/children[0]", Line: 4, Column: 3},
+		{Xpath: "/root_state/children[1]", Line: 5, Column: 14},
+		{Xpath: "/root_state/children[2]", Line: 5, Column: 45},
+		{Xpath: "/root_state/children[2]/label", Line: 5, Column: 47},
+		{Xpath: "/transitions[0]/to", Line: 9, Column: 3},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("locate() mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, text := range []string{`root_state {`, `label: "unterminated`, `root_state { children [ }`} {
+		if _, err := locateText(text); err == nil {
+			t.Errorf("locateText(%q) succeeded", text)
+		}
+	}
+}
+
+func TestValidateChartSource(t *testing.T) {
+	validator := NewSemanticValidator()
+	ctx := context.Background()
+	for _, src := range []*validationv1.ChartSource{
+		{Format: validationv1.SourceFormat_JSON, Text: twinsJSON},
+		{Format: validationv1.SourceFormat_TEXTPROTO, Text: twinsText},
+	} {
+		resp, err := validator.ValidateChart(ctx, &validationv1.ValidateChartRequest{Source: src})
+		if err != nil {
+			t.Fatalf("ValidateChart() of %v error = %v", src.Format, err)
+		}
+		if len(resp.Violations) == 0 || resp.Violations[0].RuleId != "UNIQUE_STATE_LABELS" {
+			t.Fatalf("ValidateChart() of %v violations = %v, want UNIQUE_STATE_LABELS first", src.Format, resp.Violations)
+		}
+		v := resp.Violations[0]
+		var xpaths []string
+		for _, l := range v.Locations {
+			xpaths = append(xpaths, l.Xpath)
+		}
+		if diff := cmp.Diff(v.Xpath, xpaths); diff != "" {
+			t.Errorf("ValidateChart() of %v locations mismatch (-xpath +located):\n%s", src.Format, diff)
+		}
+	}
+
+	resp, err := validator.ValidateChart(ctx, &validationv1.ValidateChartRequest{Chart: &pb.Statechart{RootState: &pb.State{Label: "__root__"}}})
+	if err != nil {
+		t.Fatalf("ValidateChart() error = %v", err)
+	}
+	for _, v := range resp.Violations {
+		if len(v.Locations) != 0 {
+			t.Errorf("violation %v of a chart without source has locations", v)
+		}
+	}
+
+	for _, req := range []*validationv1.ValidateChartRequest{
+		{},
+		{Chart: &pb.Statechart{}, Source: &validationv1.ChartSource{Format: validationv1.SourceFormat_JSON, Text: "{}"}},
+		{Source: &validationv1.ChartSource{Text: "{}"}},
+		{Source: &validationv1.ChartSource{Format: validationv1.SourceFormat_JSON, Text: "{"}},
+		{Source: &validationv1.ChartSource{Format: validationv1.SourceFormat_TEXTPROTO, Text: "root_state {"}},
+	} {
+		if _, err := validator.ValidateChart(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ValidateChart(%v) error = %v, want %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
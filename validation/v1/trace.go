@@ -0,0 +1,135 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/tmc/sc/gen/statecharts/v1"
+	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
+)
+
+// validateTrace replays a trace of machines of a statechart with the step
+// engine. Each machine must follow from the previous one by the step at the
+// end of its history, or be unchanged if its history has no new step, as in
+// the traces of the model checker and the test generator. The step must
+// process the same events, its input event followed by the events it raised.
+// The first machine must be in a legal configuration, and in the initial
+// configuration if its history is empty. It returns a violation of
+// TRACE_STEPS at each machine that does not follow.
+func validateTrace(statechart *pb.Statechart, trace []*pb.Machine) []*validationv1.Violation {
+	engine := semantics.NewEngine()
+	chart := semantics.NewStatechart(statechart)
+	var violations []*validationv1.Violation
+	report := func(i int, format string, args ...any) {
+		violations = append(violations, &validationv1.Violation{
+			Rule:     validationv1.RuleId_TRACE_STEPS,
+			RuleId:   validationv1.RuleId_TRACE_STEPS.String(),
+			Severity: validationv1.Severity_ERROR,
+			Message:  fmt.Sprintf("machine %d: %s", i, fmt.Sprintf(format, args...)),
+			Xpath:    []string{fmt.Sprintf("/trace[%d]", i)},
+		})
+	}
+	if len(trace) > 0 {
+		first := trace[0]
+		if err := semantics.ValidateConfiguration(chart, rootedConfiguration(statechart, first.GetConfiguration())); err != nil {
+			report(0, "configuration %s is not legal: %v", configurationString(first.GetConfiguration()), err)
+		} else if len(first.GetStepHistory()) == 0 {
+			initial, err := engine.NewMachine("", chart, first.GetContext())
+			if err != nil {
+				report(0, "initial configuration cannot be computed: %v", err)
+			} else if !sameConfiguration(initial.GetConfiguration(), first.GetConfiguration()) {
+				report(0, "configuration %s is not the initial configuration %s", configurationString(first.GetConfiguration()), configurationString(initial.GetConfiguration()))
+			}
+		}
+	}
+	for i := 1; i < len(trace); i++ {
+		prev, next := trace[i-1], trace[i]
+		if len(next.GetStepHistory()) <= len(prev.GetStepHistory()) {
+			if !sameConfiguration(prev.GetConfiguration(), next.GetConfiguration()) {
+				report(i, "configuration %s changed from %s without a step", configurationString(next.GetConfiguration()), configurationString(prev.GetConfiguration()))
+			}
+			continue
+		}
+		step := next.GetStepHistory()[len(next.GetStepHistory())-1]
+		if len(step.GetEvents()) == 0 {
+			report(i, "last step has no event")
+			continue
+		}
+		event := step.GetEvents()[0].GetLabel()
+		machine := &pb.Machine{
+			State:         prev.GetState(),
+			Context:       prev.GetContext(),
+			Statechart:    statechart,
+			Configuration: prev.GetConfiguration(),
+		}
+		replayed, err := engine.Step(machine, event)
+		if err != nil {
+			report(i, "event %q cannot be processed in %s: %v", event, configurationString(prev.GetConfiguration()), err)
+			continue
+		}
+		if !slices.Equal(eventLabels(replayed.GetEvents()), eventLabels(step.GetEvents())) {
+			report(i, "event %q processes events %s, not %s", event, eventsString(replayed.GetEvents()), eventsString(step.GetEvents()))
+			continue
+		}
+		if !sameConfiguration(machine.GetConfiguration(), next.GetConfiguration()) {
+			report(i, "event %q leads from %s to %s, not %s", event, configurationString(prev.GetConfiguration()), configurationString(machine.GetConfiguration()), configurationString(next.GetConfiguration()))
+			continue
+		}
+		if next.GetContext() != nil && !proto.Equal(machine.GetContext(), next.GetContext()) {
+			report(i, "event %q leads to context %v, not %v", event, machine.GetContext(), next.GetContext())
+		}
+	}
+	return violations
+}
+
+// rootedConfiguration returns a configuration with the root state of a
+// statechart added if it is missing, as the step engine adds it.
+func rootedConfiguration(statechart *pb.Statechart, config *pb.Configuration) *pb.Configuration {
+	root := statechart.GetRootState().GetLabel()
+	for _, state := range config.GetStates() {
+		if state.GetLabel() == root {
+			return config
+		}
+	}
+	states := append([]*pb.StateRef{{Label: root}}, config.GetStates()...)
+	return &pb.Configuration{States: states}
+}
+
+// eventLabels returns the labels of events in order.
+func eventLabels(events []*pb.Event) []string {
+	labels := make([]string, 0, len(events))
+	for _, event := range events {
+		labels = append(labels, event.GetLabel())
+	}
+	return labels
+}
+
+// eventsString formats events as the list of their labels.
+func eventsString(events []*pb.Event) string {
+	return "[" + strings.Join(eventLabels(events), ", ") + "]"
+}
+
+// configurationLabels returns the sorted labels of the states of a configuration.
+func configurationLabels(config *pb.Configuration) []string {
+	labels := make([]string, 0, len(config.GetStates()))
+	for _, state := range config.GetStates() {
+		labels = append(labels, state.GetLabel())
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// sameConfiguration reports whether two configurations have the same states.
+func sameConfiguration(a, b *pb.Configuration) bool {
+	return slices.Equal(configurationLabels(a), configurationLabels(b))
+}
+
+// configurationString formats a configuration as the set of its states.
+func configurationString(config *pb.Configuration) string {
+	return "{" + strings.Join(configurationLabels(config), ", ") + "}"
+}
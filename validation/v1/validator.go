@@ -3,236 +3,198 @@ package validation
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sync"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
-	"github.com/tmc/sc"
 	pb "github.com/tmc/sc/gen/statecharts/v1"
 	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
 )
 
-// NewSemanticValidator creates a new SemanticValidator service.
+// NewSemanticValidator creates a new SemanticValidator service checking the
+// built-in rules.
 func NewSemanticValidator() *SemanticValidator {
-	return &SemanticValidator{}
+	s := &SemanticValidator{}
+	if err := s.Register(BuiltinRules()...); err != nil {
+		panic(err)
+	}
+	return s
 }
 
-// SemanticValidator implements the SemanticValidator service.
+// SemanticValidator implements the SemanticValidator service. Register it
+// with validationv1.RegisterSemanticValidatorServer to serve it over gRPC.
+// It is safe for concurrent use.
 type SemanticValidator struct {
-	// This would normally include validationv1.UnimplementedSemanticValidatorServer
-	// but we'll implement directly for now
+	validationv1.UnimplementedSemanticValidatorServer
+
+	mu    sync.RWMutex
+	rules []Rule
+	ids   map[string]bool
 }
 
-// ValidateChart validates a statechart.
-func (s *SemanticValidator) ValidateChart(ctx context.Context, req *validationv1.ValidateChartRequest) (*validationv1.ValidateChartResponse, error) {
-	chart := req.GetChart()
-	if chart == nil {
-		return nil, status.Error(codes.InvalidArgument, "chart is required")
+var _ validationv1.SemanticValidatorServer = (*SemanticValidator)(nil)
+
+// Register adds rules to those the validator checks, such as naming
+// conventions of an organization. Rule IDs must be unique; if one is not,
+// none of the rules are added.
+func (s *SemanticValidator) Register(rules ...Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make(map[string]bool)
+	for _, rule := range rules {
+		switch id := rule.ID(); {
+		case id == "":
+			return fmt.Errorf("rule has an empty ID")
+		case s.ids[id] || ids[id]:
+			return fmt.Errorf("rule %s is already registered", id)
+		default:
+			ids[id] = true
+		}
 	}
-
-	// Convert to native statechart
-	statechart := convertProtoToStatechart(chart)
-
-	// Ignore rules from request
-	ignoreRules := make(map[validationv1.RuleId]bool)
-	for _, rule := range req.GetIgnoreRules() {
-		ignoreRules[rule] = true
+	if s.ids == nil {
+		s.ids = make(map[string]bool)
+	}
+	for id := range ids {
+		s.ids[id] = true
 	}
+	s.rules = append(s.rules, rules...)
+	return nil
+}
 
-	// Run validation rules
-	violations := s.validateChart(statechart, ignoreRules)
+// Rules returns the rules the validator checks, in the order in which they
+// are checked.
+func (s *SemanticValidator) Rules() []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Rule(nil), s.rules...)
+}
 
-	// Convert response
-	resp := &validationv1.ValidateChartResponse{
-		Violations: violations,
+// ValidateChart validates a statechart.
+func (s *SemanticValidator) ValidateChart(ctx context.Context, req *validationv1.ValidateChartRequest) (*validationv1.ValidateChartResponse, error) {
+	chart, src, err := statechart(req.GetChart(), req.GetSource())
+	if err != nil {
+		return nil, err
 	}
-
-	// Set status based on violations
-	if len(violations) > 0 {
-		for _, v := range violations {
-			if v.Severity == validationv1.Severity_ERROR {
-				resp.Status = status.New(codes.FailedPrecondition, "validation failed").Proto()
-				return resp, nil
-			}
-		}
-		resp.Status = status.New(codes.OK, "validation passed with warnings").Proto()
-	} else {
-		resp.Status = status.New(codes.OK, "validation passed").Proto()
+	violations, err := s.validateChart(ctx, chart, src, req.GetIgnoreRules(), req.GetSeverityOverrides())
+	if err != nil {
+		return nil, err
 	}
-
-	return resp, nil
+	return &validationv1.ValidateChartResponse{
+		Status:     result(violations).Proto(),
+		Violations: violations,
+	}, nil
 }
 
-// ValidateTrace validates a statechart trace.
+// ValidateTrace validates a statechart and a trace of its machines, such as a
+// counterexample of the model checker. Unless the statechart has violations
+// of error severity or TRACE_STEPS is ignored, the trace is replayed with the
+// step engine and every machine that does not follow from the previous one
+// is reported as an error of TRACE_STEPS.
 func (s *SemanticValidator) ValidateTrace(ctx context.Context, req *validationv1.ValidateTraceRequest) (*validationv1.ValidateTraceResponse, error) {
-	chart := req.GetChart()
-	if chart == nil {
-		return nil, status.Error(codes.InvalidArgument, "chart is required")
+	chart, src, err := statechart(req.GetChart(), req.GetSource())
+	if err != nil {
+		return nil, err
 	}
-
-	// Convert to native statechart
-	statechart := convertProtoToStatechart(chart)
-
-	// Ignore rules from request
-	ignoreRules := make(map[validationv1.RuleId]bool)
-	for _, rule := range req.GetIgnoreRules() {
-		ignoreRules[rule] = true
+	violations, err := s.validateChart(ctx, chart, src, req.GetIgnoreRules(), req.GetSeverityOverrides())
+	if err != nil {
+		return nil, err
 	}
-
-	// Run validation rules
-	violations := s.validateChart(statechart, ignoreRules)
-
-	// Additional validation for the trace would go here
-	// For now we just validate the chart
-
-	// Convert response
-	resp := &validationv1.ValidateTraceResponse{
-		Violations: violations,
+	if result(violations).Code() == codes.OK && !slices.Contains(req.GetIgnoreRules(), validationv1.RuleId_TRACE_STEPS) {
+		violations = append(violations, validateTrace(newChart(chart).Statechart, req.GetTrace())...)
 	}
+	return &validationv1.ValidateTraceResponse{
+		Status:     result(violations).Proto(),
+		Violations: violations,
+	}, nil
+}
 
-	// Set status based on violations
-	if len(violations) > 0 {
-		for _, v := range violations {
-			if v.Severity == validationv1.Severity_ERROR {
-				resp.Status = status.New(codes.FailedPrecondition, "validation failed").Proto()
-				return resp, nil
-			}
+// statechart returns the statechart of a request, given either as a chart or
+// as source, and its source if any.
+func statechart(chart *pb.Statechart, src *validationv1.ChartSource) (*pb.Statechart, *source, error) {
+	switch {
+	case chart != nil && src != nil:
+		return nil, nil, status.Error(codes.InvalidArgument, "chart and source are mutually exclusive")
+	case src != nil:
+		chart, text, err := parseSource(src)
+		if err != nil {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "source: %v", err)
 		}
-		resp.Status = status.New(codes.OK, "validation passed with warnings").Proto()
-	} else {
-		resp.Status = status.New(codes.OK, "validation passed").Proto()
+		return chart, text, nil
+	case chart == nil:
+		return nil, nil, status.Error(codes.InvalidArgument, "chart is required")
 	}
-
-	return resp, nil
+	return chart, nil, nil
 }
 
-// validateChart applies all validation rules to a statechart.
-func (s *SemanticValidator) validateChart(statechart *sc.Statechart, ignoreRules map[validationv1.RuleId]bool) []*validationv1.Violation {
-	var violations []*validationv1.Violation
-
-	// Apply each rule if not ignored
-	if !ignoreRules[validationv1.RuleId_UNIQUE_STATE_LABELS] {
-		if err := validateUniqueStateLabels(statechart); err != nil {
-			violations = append(violations, &validationv1.Violation{
-				Rule:     validationv1.RuleId_UNIQUE_STATE_LABELS,
-				Severity: validationv1.Severity_ERROR,
-				Message:  err.Error(),
-			})
+// validateChart checks the registered rules that are not ignored against a
+// statechart. The severities of violations are those of their rules unless
+// overridden, and they are located in the source of the statechart, if any.
+// It fails with the status of the context if the context is done first.
+func (s *SemanticValidator) validateChart(ctx context.Context, statechart *pb.Statechart, src *source, ignore []validationv1.RuleId, overrides map[string]validationv1.Severity) ([]*validationv1.Violation, error) {
+	rules := s.Rules()
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		ids[rule.ID()] = true
+	}
+	for id, severity := range overrides {
+		switch {
+		case !ids[id]:
+			return nil, status.Errorf(codes.InvalidArgument, "severity override for unknown rule %s", id)
+		case severity == validationv1.Severity_SEVERITY_UNSPECIFIED:
+			return nil, status.Errorf(codes.InvalidArgument, "severity override for rule %s is unspecified", id)
 		}
 	}
-
-	if !ignoreRules[validationv1.RuleId_SINGLE_DEFAULT_CHILD] {
-		if err := validateSingleDefaultChild(statechart); err != nil {
-			violations = append(violations, &validationv1.Violation{
-				Rule:     validationv1.RuleId_SINGLE_DEFAULT_CHILD,
-				Severity: validationv1.Severity_ERROR,
-				Message:  err.Error(),
-			})
-		}
+	ignored := make(map[string]bool)
+	for _, id := range ignore {
+		ignored[id.String()] = true
 	}
 
-	if !ignoreRules[validationv1.RuleId_BASIC_HAS_NO_CHILDREN] {
-		if err := validateBasicHasNoChildren(statechart); err != nil {
-			violations = append(violations, &validationv1.Violation{
-				Rule:     validationv1.RuleId_BASIC_HAS_NO_CHILDREN,
-				Severity: validationv1.Severity_ERROR,
-				Message:  err.Error(),
-			})
+	var checked []Rule
+	for _, rule := range rules {
+		if !ignored[rule.ID()] {
+			checked = append(checked, rule)
 		}
 	}
-
-	if !ignoreRules[validationv1.RuleId_COMPOUND_HAS_CHILDREN] {
-		if err := validateCompoundHasChildren(statechart); err != nil {
-			violations = append(violations, &validationv1.Violation{
-				Rule:     validationv1.RuleId_COMPOUND_HAS_CHILDREN,
-				Severity: validationv1.Severity_ERROR,
-				Message:  err.Error(),
-			})
-		}
-	}
-
-	// Add more rules as needed
-
-	return violations
-}
-
-// convertProtoToStatechart converts a proto statechart to a native statechart.
-// This is a simplified conversion for validation purposes.
-func convertProtoToStatechart(protoChart *pb.Statechart) *sc.Statechart {
-	if protoChart == nil {
-		return nil
-	}
-
-	statechart := &sc.Statechart{
-		RootState:   convertState(protoChart.RootState),
-		Transitions: make([]*sc.Transition, 0, len(protoChart.Transitions)),
-		Events:      make([]*sc.Event, 0, len(protoChart.Events)),
+	violations, err := semantics.Check(ctx, newChart(statechart), checked...)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
 	}
-
-	for _, t := range protoChart.Transitions {
-		statechart.Transitions = append(statechart.Transitions, convertTransition(t))
-	}
-
-	for _, e := range protoChart.Events {
-		statechart.Events = append(statechart.Events, convertEvent(e))
+	for _, v := range violations {
+		if severity, ok := overrides[v.RuleId]; ok {
+			v.Severity = severity
+		}
+		if src != nil {
+			v.Locations = src.locate(v.Xpath)
+		}
 	}
-
-	return statechart
+	return violations, nil
 }
 
-func convertState(protoState *pb.State) *sc.State {
-	if protoState == nil {
-		return nil
-	}
-
-	state := &sc.State{
-		Label:     protoState.Label,
-		Type:      sc.StateType(protoState.Type),
-		IsInitial: protoState.IsInitial,
-		IsFinal:   protoState.IsFinal,
-		Children:  make([]*sc.State, 0, len(protoState.Children)),
-	}
-
-	for _, child := range protoState.Children {
-		state.Children = append(state.Children, convertState(child))
+// newChart returns a copy of a statechart to validate. As for statecharts
+// created with semantics.NewStatechart, the label of the root state may be
+// omitted.
+func newChart(statechart *pb.Statechart) *Chart {
+	statechart = proto.Clone(statechart).(*pb.Statechart)
+	if statechart.RootState != nil && statechart.RootState.Label == "" {
+		statechart.RootState.Label = semantics.RootState.String()
 	}
-
-	return state
+	return &Chart{Statechart: statechart}
 }
 
-func convertTransition(protoTransition *pb.Transition) *sc.Transition {
-	if protoTransition == nil {
-		return nil
-	}
-
-	transition := &sc.Transition{
-		Label: protoTransition.Label,
-		From:  protoTransition.From,
-		To:    protoTransition.To,
-		Event: protoTransition.Event,
-	}
-
-	if protoTransition.Guard != nil {
-		transition.Guard = &sc.Guard{
-			Expression: protoTransition.Guard.Expression,
+// result returns the overall status of a validation with the violations.
+func result(violations []*validationv1.Violation) *status.Status {
+	for _, v := range violations {
+		if v.Severity == validationv1.Severity_ERROR {
+			return status.New(codes.FailedPrecondition, "validation failed")
 		}
 	}
-
-	for _, a := range protoTransition.Actions {
-		transition.Actions = append(transition.Actions, &sc.Action{
-			Label: a.Label,
-		})
+	if len(violations) > 0 {
+		return status.New(codes.OK, "validation passed with warnings")
 	}
-
-	return transition
+	return status.New(codes.OK, "validation passed")
 }
-
-func convertEvent(protoEvent *pb.Event) *sc.Event {
-	if protoEvent == nil {
-		return nil
-	}
-
-	return &sc.Event{
-		Label: protoEvent.Label,
-	}
-}
\ No newline at end of file
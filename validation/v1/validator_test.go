@@ -4,10 +4,13 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	pb "github.com/tmc/sc/gen/statecharts/v1"
 	validationv1 "github.com/tmc/sc/gen/validation/v1"
+	"github.com/tmc/sc/semantics/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestValidateChart(t *testing.T) {
@@ -33,8 +36,9 @@ func TestValidateChart(t *testing.T) {
 							IsInitial: true,
 						},
 						{
-							Label: "B",
-							Type:  pb.StateType_STATE_TYPE_BASIC,
+							Label:   "B",
+							Type:    pb.StateType_STATE_TYPE_BASIC,
+							IsFinal: true,
 						},
 					},
 				},
@@ -140,6 +144,29 @@ func TestValidateChart(t *testing.T) {
 			wantViolations: 1,
 			wantCode:       codes.FailedPrecondition,
 		},
+		{
+			name: "Invalid statechart - submachine state",
+			chart: &pb.Statechart{
+				RootState: &pb.State{
+					Label: "__root__",
+					Type:  pb.StateType_STATE_TYPE_NORMAL,
+					Children: []*pb.State{
+						{
+							Label:     "A",
+							Type:      pb.StateType_STATE_TYPE_BASIC,
+							IsInitial: true,
+							IsFinal:   true,
+						},
+						{
+							Label:      "S",
+							Submachine: "payment", // Must be inlined first
+						},
+					},
+				},
+			},
+			wantViolations: 1,
+			wantCode:       codes.FailedPrecondition,
+		},
 		{
 			name: "Ignored rule",
 			chart: &pb.Statechart{
@@ -164,6 +191,107 @@ func TestValidateChart(t *testing.T) {
 			wantViolations: 0,
 			wantCode:       codes.OK,
 		},
+		{
+			name: "Unreachable state",
+			chart: &pb.Statechart{
+				RootState: &pb.State{
+					Label: "__root__",
+					Type:  pb.StateType_STATE_TYPE_NORMAL,
+					Children: []*pb.State{
+						{
+							Label:     "A",
+							Type:      pb.StateType_STATE_TYPE_BASIC,
+							IsInitial: true,
+							IsFinal:   true,
+						},
+						{
+							Label:   "B", // No transition enters B
+							Type:    pb.StateType_STATE_TYPE_BASIC,
+							IsFinal: true,
+						},
+					},
+				},
+			},
+			wantViolations: 1,
+			wantCode:       codes.OK,
+		},
+		{
+			name: "Shadowed transition and deadlock",
+			chart: &pb.Statechart{
+				RootState: &pb.State{
+					Label: "__root__",
+					Type:  pb.StateType_STATE_TYPE_NORMAL,
+					Children: []*pb.State{
+						{
+							Label:     "A",
+							Type:      pb.StateType_STATE_TYPE_BASIC,
+							IsInitial: true,
+						},
+						{
+							Label: "B", // Not final and without outgoing transitions
+							Type:  pb.StateType_STATE_TYPE_BASIC,
+						},
+					},
+				},
+				Transitions: []*pb.Transition{
+					{
+						Label: "t1",
+						From:  []string{"A"},
+						To:    []string{"B"},
+						Event: "e1",
+					},
+					{
+						Label: "t2", // Always preempted by t1
+						From:  []string{"A"},
+						To:    []string{"B"},
+						Event: "e1",
+					},
+				},
+			},
+			wantViolations: 2,
+			wantCode:       codes.OK,
+		},
+		{
+			name: "Ignored reachability rules",
+			chart: &pb.Statechart{
+				RootState: &pb.State{
+					Label: "__root__",
+					Type:  pb.StateType_STATE_TYPE_NORMAL,
+					Children: []*pb.State{
+						{
+							Label:     "A",
+							Type:      pb.StateType_STATE_TYPE_BASIC,
+							IsInitial: true,
+						},
+						{
+							Label: "B",
+							Type:  pb.StateType_STATE_TYPE_BASIC,
+						},
+					},
+				},
+			},
+			ignoreRules:    []validationv1.RuleId{validationv1.RuleId_REACHABLE_STATES, validationv1.RuleId_NO_DEADLOCKS},
+			wantViolations: 0,
+			wantCode:       codes.OK,
+		},
+		{
+			name: "Unknown transition target",
+			chart: &pb.Statechart{
+				RootState: &pb.State{
+					Label: "__root__",
+					Children: []*pb.State{
+						{Label: "A", IsInitial: true},
+						{Label: "B", IsFinal: true},
+					},
+				},
+				Transitions: []*pb.Transition{
+					{Label: "t1", From: []string{"A"}, To: []string{"B"}, Event: "e1"},
+					{Label: "t2", From: []string{"B"}, To: []string{"Nope"}, Event: "e1"},
+				},
+			},
+			wantViolations: 1,
+			wantCode:       codes.FailedPrecondition,
+		},
 	}
 
 	for _, tt := range tests {
@@ -190,6 +318,15 @@ func TestValidateChart(t *testing.T) {
 	}
 }
 
+func TestValidateChartCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := &validationv1.ValidateChartRequest{Chart: &pb.Statechart{RootState: &pb.State{Label: "__root__"}}}
+	if _, err := NewSemanticValidator().ValidateChart(ctx, req); status.Code(err) != codes.Canceled {
+		t.Errorf("ValidateChart() with a canceled context error = %v, want %v", err, codes.Canceled)
+	}
+}
+
 func TestValidateTrace(t *testing.T) {
 	validator := NewSemanticValidator()
 
@@ -205,8 +342,9 @@ func TestValidateTrace(t *testing.T) {
 					IsInitial: true,
 				},
 				{
-					Label: "B",
-					Type:  pb.StateType_STATE_TYPE_BASIC,
+					Label:   "B",
+					Type:    pb.StateType_STATE_TYPE_BASIC,
+					IsFinal: true,
 				},
 			},
 		},
@@ -228,6 +366,10 @@ func TestValidateTrace(t *testing.T) {
 		{
 			Id:    "m1",
 			State: pb.MachineState_MACHINE_STATE_RUNNING,
+			Configuration: &pb.Configuration{States: []*pb.StateRef{
+				{Label: "__root__"},
+				{Label: "A"},
+			}},
 		},
 	}
 
@@ -241,8 +383,7 @@ func TestValidateTrace(t *testing.T) {
 		t.Fatalf("ValidateTrace() error = %v", err)
 	}
 
-	// For now, we're just validating the chart, so we expect the same 
-	// results as a chart validation
+	// A trace of a single initial machine has no steps to replay.
 	if len(resp.Violations) != 0 {
 		t.Errorf("ValidateTrace() got %d violations, want 0", len(resp.Violations))
 	}
@@ -251,4 +392,122 @@ func TestValidateTrace(t *testing.T) {
 	if s.Code() != codes.OK {
 		t.Errorf("ValidateTrace() got status code %v, want %v", s.Code(), codes.OK)
 	}
-}
\ No newline at end of file
+}
+
+func TestValidateTraceReplay(t *testing.T) {
+	chart := &pb.Statechart{
+		RootState: &pb.State{Label: "__root__", Children: []*pb.State{
+			{Label: "A", IsInitial: true},
+			{Label: "B"},
+			{Label: "C", IsFinal: true},
+		}},
+		Transitions: []*pb.Transition{
+			{Label: "t1", From: []string{"A"}, To: []string{"B"}, Event: "next"},
+			{Label: "t2", From: []string{"B"}, To: []string{"C"}, Event: "next"},
+			{Label: "t3", From: []string{"B"}, To: []string{"A"}, Event: "back"},
+		},
+	}
+	engine := semantics.NewEngine()
+	machine, err := engine.NewMachine("m", semantics.NewStatechart(chart), nil)
+	if err != nil {
+		t.Fatalf("NewMachine() error = %v", err)
+	}
+	trace := []*pb.Machine{proto.Clone(machine).(*pb.Machine)}
+	for _, event := range []string{"next", "back", "next", "next"} {
+		if _, err := engine.Step(machine, event); err != nil {
+			t.Fatalf("Step(%q) error = %v", event, err)
+		}
+		trace = append(trace, proto.Clone(machine).(*pb.Machine))
+	}
+	// The last machine stutters.
+	trace = append(trace, proto.Clone(machine).(*pb.Machine))
+
+	validator := NewSemanticValidator()
+	validate := func(trace []*pb.Machine, ignore ...validationv1.RuleId) *validationv1.ValidateTraceResponse {
+		t.Helper()
+		resp, err := validator.ValidateTrace(context.Background(), &validationv1.ValidateTraceRequest{Chart: chart, Trace: trace, IgnoreRules: ignore})
+		if err != nil {
+			t.Fatalf("ValidateTrace() error = %v", err)
+		}
+		return resp
+	}
+	if resp := validate(trace); len(resp.Violations) != 0 {
+		t.Errorf("ValidateTrace() of a replayable trace violations = %v", resp.Violations)
+	}
+
+	tampered := append([]*pb.Machine(nil), trace...)
+	tampered[2] = proto.Clone(trace[2]).(*pb.Machine)
+	tampered[2].Configuration = &pb.Configuration{States: []*pb.StateRef{{Label: "__root__"}, {Label: "C"}}}
+	tampered[5] = proto.Clone(trace[4]).(*pb.Machine)
+	tampered[5].Configuration = trace[3].Configuration
+	resp := validate(tampered)
+	var xpaths []string
+	for _, v := range resp.Violations {
+		if v.RuleId != "TRACE_STEPS" || v.Severity != validationv1.Severity_ERROR {
+			t.Errorf("violation %v is not an error of TRACE_STEPS", v)
+		}
+		xpaths = append(xpaths, v.Xpath...)
+	}
+	if diff := cmp.Diff([]string{"/trace[2]", "/trace[3]", "/trace[5]"}, xpaths); diff != "" {
+		t.Errorf("ValidateTrace() violations mismatch (-want +got):\n%s", diff)
+	}
+	if codes.Code(resp.Status.Code) != codes.FailedPrecondition {
+		t.Errorf("ValidateTrace() status = %v, want %v", resp.Status, codes.FailedPrecondition)
+	}
+	if resp := validate(tampered, validationv1.RuleId_TRACE_STEPS); len(resp.Violations) != 0 {
+		t.Errorf("ValidateTrace() ignoring TRACE_STEPS violations = %v", resp.Violations)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(trace []*pb.Machine)
+		want   []string
+	}{
+		{
+			name: "first machine not initial",
+			tamper: func(trace []*pb.Machine) {
+				trace[0].Configuration = trace[1].Configuration
+			},
+			want: []string{"/trace[0]", "/trace[1]"},
+		},
+		{
+			name: "first machine in illegal configuration",
+			tamper: func(trace []*pb.Machine) {
+				trace[0].StepHistory = trace[1].StepHistory
+				trace[0].Configuration = &pb.Configuration{States: []*pb.StateRef{{Label: "__root__"}, {Label: "A"}, {Label: "B"}}}
+			},
+			want: []string{"/trace[0]", "/trace[1]"},
+		},
+		{
+			name: "first machine with empty configuration",
+			tamper: func(trace []*pb.Machine) {
+				trace[0].Configuration = nil
+			},
+			want: []string{"/trace[0]", "/trace[1]"},
+		},
+		{
+			name: "step with unprocessed event",
+			tamper: func(trace []*pb.Machine) {
+				step := trace[1].StepHistory[0]
+				step.Events = append(step.Events, &pb.Event{Label: "back"})
+			},
+			want: []string{"/trace[1]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := make([]*pb.Machine, len(trace))
+			for i, m := range trace {
+				tampered[i] = proto.Clone(m).(*pb.Machine)
+			}
+			tt.tamper(tampered)
+			var xpaths []string
+			for _, v := range validate(tampered).Violations {
+				xpaths = append(xpaths, v.Xpath...)
+			}
+			if diff := cmp.Diff(tt.want, xpaths); diff != "" {
+				t.Errorf("ValidateTrace() violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}